@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/sevir/mesnada/internal/agent"
+	"github.com/sevir/mesnada/internal/config"
+)
+
+const configUsage = `Usage: mesnada config <command> [flags]
+
+Commands:
+  validate   Check the config for errors without starting the server
+`
+
+// runConfigCommand runs "mesnada config ..." and returns the exit code.
+func runConfigCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, configUsage)
+		return 2
+	}
+	switch args[0] {
+	case "validate":
+		return runConfigValidate(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, configUsage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown config command %q\n\n%s", args[0], configUsage)
+		return 2
+	}
+}
+
+// runConfigValidate loads the config and prints every problem found in it.
+// It exits non-zero when the config fails to load or has errors; warnings
+// alone pass.
+func runConfigValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "Path to config file")
+	profile := fs.String("profile", "", "Config profile to apply over the base config")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadProfile(*configPath, *profile)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	problems := append(cfg.Validate(), agent.CheckConfig(cfg)...)
+	var errs, warnings int
+	for _, p := range problems {
+		fmt.Fprintln(stdout, p)
+		if p.Warning {
+			warnings++
+		} else {
+			errs++
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Fprintln(stdout, "config OK")
+	} else {
+		fmt.Fprintf(stdout, "%d error(s), %d warning(s)\n", errs, warnings)
+	}
+	if errs > 0 {
+		return 1
+	}
+	return 0
+}
@@ -6,12 +6,14 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/internal/logging"
 	"github.com/sevir/mesnada/internal/orchestrator"
 	"github.com/sevir/mesnada/internal/server"
 )
@@ -22,9 +24,14 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Parse flags
 	var (
 		configPath  = flag.String("config", "", "Path to config file")
+		profile     = flag.String("profile", "", "Config profile to apply over the base config")
 		host        = flag.String("host", "", "Server host (default: 127.0.0.1)")
 		port        = flag.Int("port", 0, "Server port (default: 8765)")
 		storePath   = flag.String("store", "", "Path to task store file")
@@ -45,7 +52,7 @@ func main() {
 	// Handle init config flag
 	if *initConfig {
 		if err := config.InitConfig(*initPath); err != nil {
-			log.Fatalf("Failed to initialize config: %v", err)
+			fatalf("Failed to initialize config: %v", err)
 		}
 
 		outputPath := *initPath
@@ -58,9 +65,9 @@ func main() {
 	}
 
 	// Load configuration
-	cfg, err := config.Load(*configPath)
+	cfg, err := config.LoadProfile(*configPath, *profile)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatalf("Failed to load config: %v", err)
 	}
 
 	// Override with flags
@@ -80,6 +87,21 @@ func main() {
 		cfg.Orchestrator.MaxParallel = *maxParallel
 	}
 
+	// Route the log through the configured level, format and file.
+	closeLog, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+	defer closeLog()
+
+	maxLogSize, err := cfg.Orchestrator.MaxLogSizeBytes()
+	if err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+	if _, err := cfg.Server.SessionTTLDuration(); err != nil {
+		fatalf("Invalid configuration: %v", err)
+	}
+
 	// Create orchestrator
 	orch, err := orchestrator.New(orchestrator.Config{
 		StorePath:        cfg.Orchestrator.StorePath,
@@ -88,9 +110,20 @@ func main() {
 		DefaultMCPConfig: cfg.Orchestrator.DefaultMCPConfig,
 		DefaultEngine:    cfg.Orchestrator.DefaultEngine,
 		PersonaPath:      cfg.Orchestrator.PersonaPath,
+		Sandbox:          cfg.Orchestrator.Sandbox,
+		Engines:          cfg.Engines,
+		Env:              cfg.Orchestrator.Env,
+		Secrets:          cfg.Secrets,
+		MaxLogSize:       maxLogSize,
+		CompressLogs:     cfg.Orchestrator.CompressLogs,
+		CreateWorkDir:    cfg.Orchestrator.CreateWorkDir,
+		AllowedWorkDirs:  cfg.Orchestrator.AllowedWorkDirs,
+		AutoCommit:       cfg.Orchestrator.AutoCommit,
+		Defaults:         cfg.Defaults,
+		ModelAliases:     cfg.Aliases,
 	})
 	if err != nil {
-		log.Fatalf("Failed to create orchestrator: %v", err)
+		fatalf("Failed to create orchestrator: %v", err)
 	}
 
 	// Create server
@@ -119,23 +152,47 @@ func main() {
 		defer shutdownCancel()
 
 		if err := srv.Shutdown(shutdownCtx); err != nil {
-			log.Printf("Server shutdown error: %v", err)
+			slog.Error("server shutdown failed", "error", err)
 		}
 
 		if err := orch.Shutdown(); err != nil {
-			log.Printf("Orchestrator shutdown error: %v", err)
+			slog.Error("orchestrator shutdown failed", "error", err)
+		}
+	}()
+
+	// Reload the config on SIGHUP so model lists can change without a restart.
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			reloaded, err := config.LoadProfile(*configPath, *profile)
+			if err != nil {
+				slog.Error("config reload failed", "error", err)
+				continue
+			}
+			log.Println("Config reloaded")
+			srv.ReloadConfig(reloaded)
+			orch.SetModelAliases(reloaded.Aliases)
 		}
 	}()
 
 	// Print startup info
+	if *profile != "" {
+		log.Printf("Config profile: %s", *profile)
+	}
 	if *useStdio {
 		log.Printf("mesnada %s starting in stdio mode", version)
 	} else {
 		log.Printf("mesnada %s starting", version)
-		log.Printf("UI endpoint:  http://%s/ui", cfg.Address())
-		log.Printf("MCP endpoint: http://%s/mcp", cfg.Address())
-		log.Printf("SSE endpoint: http://%s/mcp/sse", cfg.Address())
-		log.Printf("Health check: http://%s/health", cfg.Address())
+		scheme := "http"
+		if cfg.Server.TLS.Enabled() {
+			scheme = "https"
+		}
+		root := scheme + "://" + cfg.Address() + cfg.Server.Prefix()
+		log.Printf("UI endpoint:  %s/ui", root)
+		log.Printf("MCP endpoint: %s/mcp", root)
+		log.Printf("SSE endpoint: %s/mcp/sse", root)
+		log.Printf("Health check: %s/health", root)
 	}
 
 	// Start server
@@ -144,7 +201,14 @@ func main() {
 		case <-ctx.Done():
 			// Expected shutdown
 		default:
-			log.Fatalf("Server error: %v", err)
+			fatalf("Server error: %v", err)
 		}
 	}
 }
+
+// fatalf logs an error and exits. It logs at error level so the message
+// is kept whatever logging.level is.
+func fatalf(format string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
@@ -0,0 +1,504 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	defaultLogDir    = ".mesnada/logs"
+	outputTailLines  = 50
+	maxOutputCapture = 1024 * 1024 // 1MB max output capture
+	// defaultMaxLine is the longest output line accepted from an engine.
+	defaultMaxLine = 1024 * 1024
+	// stopGracePeriod is how long Cancel and Pause wait after SIGTERM before killing.
+	stopGracePeriod = 5 * time.Second
+	// shutdownGracePeriod is how long Shutdown waits for processes to exit.
+	shutdownGracePeriod = 10 * time.Second
+)
+
+// engineSpec holds the engine-specific hooks used by BaseSpawner.
+type engineSpec struct {
+	// engine identifies the engine; its default binary comes from defaultEngineBinaries.
+	engine models.Engine
+	// prepare builds the arguments and environment for a task.
+	prepare func(task *models.Task, logDir string) (engineRun, error)
+	// newOutput returns the handler for the task's stdout. Nil logs raw text.
+	newOutput func(task *models.Task, logPath string) outputHandler
+	// stdinPrompt writes task.Prompt to stdin instead of passing it as an argument.
+	stdinPrompt bool
+	// maxLine overrides defaultMaxLine for stdout.
+	maxLine int
+}
+
+// engineRun is the engine-specific part of one task run.
+type engineRun struct {
+	args []string
+	env  []string
+	// secretEnv holds variables such as endpoint keys that, like configured
+	// secrets, must not appear on a command line.
+	secretEnv []string
+	// cleanup, if set, removes temporary files once the process exits.
+	cleanup func()
+}
+
+// outputHandler turns stdout lines into log lines and task results.
+type outputHandler interface {
+	// handleLine consumes one stdout line and returns the lines to log.
+	handleLine(line string) []string
+	// finish records the collected results on the task.
+	finish(task *models.Task)
+}
+
+// rawOutput logs stdout unchanged.
+type rawOutput struct{}
+
+func (rawOutput) handleLine(line string) []string { return []string{line} }
+func (rawOutput) finish(*models.Task)             {}
+
+// BaseSpawner implements the process lifecycle shared by all engines: log
+// files, output capture, completion, cancel, pause and shutdown.
+type BaseSpawner struct {
+	logDir     string
+	config     Config
+	spec       engineSpec
+	processes  map[string]*Process
+	mu         sync.RWMutex
+	onComplete func(task *models.Task)
+}
+
+// Process represents a running agent process.
+type Process struct {
+	cmd      *exec.Cmd
+	task     *models.Task
+	output   *strings.Builder
+	outputMu sync.Mutex
+	logFile  *logWriter
+	cancel   context.CancelFunc
+	done     chan struct{}
+	captured chan struct{}
+	stream   *outputStream
+	handler  outputHandler
+	cleanup  func()
+}
+
+func newBaseSpawner(cfg Config, spec engineSpec, onComplete func(task *models.Task)) *BaseSpawner {
+	logDir := cfg.LogDir
+	if logDir == "" {
+		home, _ := os.UserHomeDir()
+		logDir = filepath.Join(home, defaultLogDir)
+	}
+	// Ensure logDir is absolute so task.LogFile is a full path.
+	if abs, err := filepath.Abs(logDir); err == nil {
+		logDir = abs
+	}
+	os.MkdirAll(logDir, 0755)
+	cfg.LogDir = logDir
+
+	if spec.maxLine == 0 {
+		spec.maxLine = defaultMaxLine
+	}
+
+	return &BaseSpawner{
+		logDir:     logDir,
+		config:     cfg,
+		spec:       spec,
+		processes:  make(map[string]*Process),
+		onComplete: onComplete,
+	}
+}
+
+// promptWithTaskID prefixes the prompt with the task ID so agents can report progress.
+func promptWithTaskID(task *models.Task) string {
+	return fmt.Sprintf("You are the task_id: %s\n\n%s", task.ID, task.Prompt)
+}
+
+// prepare runs the engine hook with the engine's configured extra_args placed
+// ahead of the task's own (so both land before a positional prompt) and adds
+// the engine's configured env.
+func (s *BaseSpawner) prepare(task *models.Task) (engineRun, error) {
+	engineCfg := s.config.engineConfig(s.spec.engine)
+
+	if len(engineCfg.ExtraArgs) > 0 {
+		taskArgs := task.ExtraArgs
+		task.ExtraArgs = append(append([]string{}, engineCfg.ExtraArgs...), taskArgs...)
+		defer func() { task.ExtraArgs = taskArgs }()
+	}
+
+	run, err := s.spec.prepare(task, s.logDir)
+	if err != nil {
+		return run, err
+	}
+
+	keys := make([]string, 0, len(engineCfg.Env))
+	for k := range engineCfg.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		run.env = append(run.env, k+"="+engineCfg.Env[k])
+	}
+	return run, nil
+}
+
+// Spawn starts a new agent process for the task.
+func (s *BaseSpawner) Spawn(ctx context.Context, task *models.Task) error {
+	name := s.spec.engine
+
+	run, err := s.prepare(task)
+	if err != nil {
+		return err
+	}
+	if run.cleanup == nil {
+		run.cleanup = func() {}
+	}
+
+	// Create cancellable context
+	procCtx, cancel := context.WithCancel(ctx)
+	if task.Timeout > 0 {
+		procCtx, cancel = context.WithTimeout(ctx, time.Duration(task.Timeout))
+	}
+
+	cmd, err := s.config.newAgentCommand(procCtx, task, defaultEngineBinaries[name], run.args, run.env, run.secretEnv)
+	if err != nil {
+		cancel()
+		run.cleanup()
+		return err
+	}
+
+	// Create log file
+	logPath := filepath.Join(s.logDir, fmt.Sprintf("%s.log", task.ID))
+	logFile, err := s.config.createLog(task.ID, logPath)
+	if err != nil {
+		cancel()
+		run.cleanup()
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	task.LogFile = logPath
+
+	fail := func(err error) error {
+		cancel()
+		logFile.Close()
+		run.cleanup()
+		return err
+	}
+
+	var stdin io.WriteCloser
+	if s.spec.stdinPrompt {
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return fail(fmt.Errorf("failed to create stdin pipe: %w", err))
+		}
+	}
+
+	stdout, err := s.config.stdoutPipe(task, cmd)
+	if err != nil {
+		return fail(fmt.Errorf("failed to create stdout pipe: %w", err))
+	}
+
+	stderr, err := stderrPipe(cmd)
+	if err != nil {
+		return fail(fmt.Errorf("failed to create stderr pipe: %w", err))
+	}
+
+	var handler outputHandler = rawOutput{}
+	if s.spec.newOutput != nil {
+		handler = s.spec.newOutput(task, logPath)
+	}
+
+	// Start process
+	if err := cmd.Start(); err != nil {
+		return fail(fmt.Errorf("failed to start %s: %w", name, err))
+	}
+
+	if stdin != nil {
+		// Send the prompt to stdin and close it
+		go func() {
+			defer stdin.Close()
+			if _, err := stdin.Write([]byte(task.Prompt)); err != nil {
+				slog.Error("failed to write prompt to stdin", "task_id", task.ID, "error", err)
+			}
+		}()
+	}
+
+	task.PID = cmd.Process.Pid
+	now := time.Now()
+	task.StartedAt = &now
+	task.Status = models.TaskStatusRunning
+
+	log.Printf(
+		"task_event=started task_id=%s status=%s pid=%d log_file=%q work_dir=%q model=%q engine=%s",
+		task.ID,
+		task.Status,
+		task.PID,
+		task.LogFile,
+		task.WorkDir,
+		task.Model,
+		name,
+	)
+
+	proc := &Process{
+		cmd:      cmd,
+		task:     task,
+		output:   &strings.Builder{},
+		logFile:  logFile,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		captured: make(chan struct{}),
+		stream:   newOutputStream(),
+		handler:  handler,
+		cleanup:  run.cleanup,
+	}
+
+	s.mu.Lock()
+	s.processes[task.ID] = proc
+	s.mu.Unlock()
+
+	// Start output capture goroutines
+	go func() {
+		defer close(proc.captured)
+		s.captureOutput(proc, stdout, stderr)
+	}()
+
+	// Wait for completion in background
+	go s.waitForCompletion(proc)
+
+	return nil
+}
+
+func (s *BaseSpawner) captureOutput(proc *Process, stdout, stderr io.Reader) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Capture stdout through the engine output handler
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), s.spec.maxLine)
+
+		for scanner.Scan() {
+			for _, line := range proc.handler.handleLine(scanner.Text()) {
+				proc.writeLine(line)
+			}
+		}
+	}()
+
+	// Capture stderr with a prefix, or drain it when capture_stderr is off
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 0, 64*1024), defaultMaxLine)
+
+		capture := s.config.captureStderr(proc.task.Engine)
+		for scanner.Scan() {
+			if capture {
+				proc.writeLine("[stderr] " + scanner.Text())
+			}
+		}
+	}()
+
+	wg.Wait()
+}
+
+// writeLine appends one line to the log file, live subscribers and in-memory output.
+func (p *Process) writeLine(line string) {
+	fmt.Fprintf(p.logFile, "%s\n", line)
+	p.stream.publish(line)
+
+	// Capture to memory (with limit)
+	p.outputMu.Lock()
+	if p.output.Len() < maxOutputCapture {
+		p.output.WriteString(line)
+		p.output.WriteString("\n")
+	}
+	p.outputMu.Unlock()
+}
+
+func (s *BaseSpawner) waitForCompletion(proc *Process) {
+	defer close(proc.done)
+	defer proc.stream.close()
+	defer s.config.finishLog(proc.logFile, proc.task)
+	defer proc.cancel()
+
+	err := proc.cmd.Wait()
+	awaitOutput(proc.cmd, proc.task.ID, proc.captured)
+	err = s.config.finishSandbox(proc.task, err)
+	proc.handler.finish(proc.task)
+	proc.cleanup()
+
+	now := time.Now()
+	proc.task.CompletedAt = &now
+	proc.task.Output = proc.output.String()
+	proc.task.OutputTail = getTail(proc.task.Output, outputTailLines)
+
+	// Preserve explicit stop statuses (cancelled/paused) as the final status.
+	explicitStop := proc.task.Status == models.TaskStatusCancelled || proc.task.Status == models.TaskStatusPaused
+
+	if err != nil {
+		if !explicitStop {
+			proc.task.Status = models.TaskStatusFailed
+			proc.task.Error = err.Error()
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code := exitErr.ExitCode()
+			proc.task.ExitCode = &code
+		}
+	} else {
+		if !explicitStop {
+			proc.task.Status = models.TaskStatusCompleted
+		}
+		code := 0
+		proc.task.ExitCode = &code
+	}
+
+	s.mu.Lock()
+	delete(s.processes, proc.task.ID)
+	s.mu.Unlock()
+
+	if s.onComplete != nil {
+		s.onComplete(proc.task)
+	}
+}
+
+func getTail(output string, lines int) string {
+	allLines := strings.Split(output, "\n")
+	if len(allLines) <= lines {
+		return output
+	}
+	return strings.Join(allLines[len(allLines)-lines:], "\n")
+}
+
+// stop terminates a running process and records the given status.
+func (s *BaseSpawner) stop(taskID string, status models.TaskStatus) error {
+	s.mu.RLock()
+	proc, exists := s.processes[taskID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("process not found: %s", taskID)
+	}
+
+	proc.task.Status = status
+	proc.cancel()
+
+	// Send SIGTERM first
+	if proc.cmd.Process != nil {
+		terminateProcess(proc.cmd)
+
+		// Wait briefly, then force kill
+		select {
+		case <-proc.done:
+			// Process exited gracefully
+		case <-time.After(stopGracePeriod):
+			killProcess(proc.cmd)
+		}
+	}
+
+	return nil
+}
+
+// Cancel stops a running agent.
+func (s *BaseSpawner) Cancel(taskID string) error {
+	return s.stop(taskID, models.TaskStatusCancelled)
+}
+
+// Pause stops a running agent without marking it as cancelled.
+func (s *BaseSpawner) Pause(taskID string) error {
+	return s.stop(taskID, models.TaskStatusPaused)
+}
+
+// GetProcess returns information about a running process.
+func (s *BaseSpawner) GetProcess(taskID string) (*Process, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	proc, exists := s.processes[taskID]
+	return proc, exists
+}
+
+// IsRunning checks if a task is currently running.
+func (s *BaseSpawner) IsRunning(taskID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists := s.processes[taskID]
+	return exists
+}
+
+// StreamOutput subscribes to the live output of a running task, line by line.
+// Call cancel to stop receiving; the channel is also closed when the process exits.
+func (s *BaseSpawner) StreamOutput(taskID string) (<-chan string, func(), error) {
+	s.mu.RLock()
+	proc, exists := s.processes[taskID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, nil, fmt.Errorf("process not found: %s", taskID)
+	}
+
+	ch, cancel := proc.stream.subscribe()
+	return ch, cancel, nil
+}
+
+// Wait blocks until a task completes or context is cancelled.
+func (s *BaseSpawner) Wait(ctx context.Context, taskID string) error {
+	s.mu.RLock()
+	proc, exists := s.processes[taskID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil // Already completed
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-proc.done:
+		return nil
+	}
+}
+
+// RunningCount returns the number of currently running processes.
+func (s *BaseSpawner) RunningCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.processes)
+}
+
+// Shutdown cancels all running processes.
+func (s *BaseSpawner) Shutdown() {
+	s.mu.RLock()
+	procs := make([]*Process, 0, len(s.processes))
+	for _, p := range s.processes {
+		procs = append(procs, p)
+	}
+	s.mu.RUnlock()
+
+	for _, proc := range procs {
+		proc.cancel()
+		if proc.cmd.Process != nil {
+			terminateProcess(proc.cmd)
+		}
+	}
+
+	// Wait for all to finish
+	for _, proc := range procs {
+		select {
+		case <-proc.done:
+		case <-time.After(shutdownGracePeriod):
+			if proc.cmd.Process != nil {
+				killProcess(proc.cmd)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+//go:build !windows
+
+package agent
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// shellSpawner returns a Copilot spawner whose binary is replaced by a shell script.
+func shellSpawner(t *testing.T, script string) (*CopilotSpawner, chan *models.Task) {
+	t.Helper()
+	done := make(chan *models.Task, 1)
+	cfg := Config{
+		LogDir: t.TempDir(),
+		Engines: map[string]config.EngineConfig{
+			"copilot": {Binary: "sh", DefaultArgs: []string{"-c", script}},
+		},
+	}
+	return NewCopilotSpawner(cfg, func(task *models.Task) { done <- task }), done
+}
+
+func waitTask(t *testing.T, done chan *models.Task) *models.Task {
+	t.Helper()
+	select {
+	case task := <-done:
+		return task
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for task completion")
+		return nil
+	}
+}
+
+func TestBaseSpawner_Completes(t *testing.T) {
+	s, done := shellSpawner(t, "cat; echo oops >&2")
+	task := &models.Task{ID: "task-ok", Prompt: "hello", Engine: models.EngineCopilot, WorkDir: t.TempDir()}
+
+	if err := s.Spawn(context.Background(), task); err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	task = waitTask(t, done)
+
+	if task.Status != models.TaskStatusCompleted || task.ExitCode == nil || *task.ExitCode != 0 {
+		t.Fatalf("expected completed task, got status=%s exit=%v error=%q", task.Status, task.ExitCode, task.Error)
+	}
+	if !strings.Contains(task.Output, "You are the task_id: task-ok") || !strings.Contains(task.Output, "[stderr] oops") {
+		t.Fatalf("unexpected output %q", task.Output)
+	}
+	data, err := os.ReadFile(task.LogFile)
+	if err != nil || !strings.Contains(string(data), "hello") {
+		t.Fatalf("expected prompt in log file, got %q (%v)", data, err)
+	}
+	if s.IsRunning(task.ID) || s.RunningCount() != 0 {
+		t.Fatal("expected process to be removed after completion")
+	}
+}
+
+func TestBaseSpawner_Cancel(t *testing.T) {
+	s, done := shellSpawner(t, "sleep 30")
+	task := &models.Task{ID: "task-cancel", Prompt: "hello", Engine: models.EngineCopilot, WorkDir: t.TempDir()}
+
+	if err := s.Spawn(context.Background(), task); err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	if err := s.Cancel(task.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	task = waitTask(t, done)
+
+	if task.Status != models.TaskStatusCancelled {
+		t.Fatalf("expected cancelled task, got %s", task.Status)
+	}
+	if err := s.Cancel(task.ID); err == nil {
+		t.Fatal("expected error cancelling a finished task")
+	}
+}
+
+func TestBaseSpawner_EngineExtraArgsAndEnv(t *testing.T) {
+	done := make(chan *models.Task, 1)
+	cfg := Config{
+		LogDir: t.TempDir(),
+		Engines: map[string]config.EngineConfig{
+			"copilot": {
+				Binary:      "sh",
+				DefaultArgs: []string{"-c", `echo "$MESNADA_ENGINE_VAR $*"`},
+				ExtraArgs:   []string{"--engine-flag"},
+				Env:         map[string]string{"MESNADA_ENGINE_VAR": "from-config"},
+			},
+		},
+	}
+	s := NewCopilotSpawner(cfg, func(task *models.Task) { done <- task })
+	task := &models.Task{ID: "task-extra", Prompt: "hi", Engine: models.EngineCopilot, WorkDir: t.TempDir(), ExtraArgs: []string{"--task-flag"}}
+
+	if err := s.Spawn(context.Background(), task); err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	task = waitTask(t, done)
+
+	if !strings.Contains(task.Output, "from-config") || !strings.Contains(task.Output, "--engine-flag --task-flag") {
+		t.Fatalf("expected engine env and args ahead of task args, got %q", task.Output)
+	}
+	if len(task.ExtraArgs) != 1 || task.ExtraArgs[0] != "--task-flag" {
+		t.Fatalf("expected task extra_args to be left unchanged, got %q", task.ExtraArgs)
+	}
+}
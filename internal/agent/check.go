@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// CheckConfig checks what config.Validate cannot: that engine binaries
+// resolve and that default_mcp_config parses. A missing binary is an error
+// for the default engine and for engines with a configured binary, and a
+// warning for the others. Engines that run in a container are not checked.
+func CheckConfig(cfg *config.Config) []config.Problem {
+	c := Config{Sandbox: cfg.Orchestrator.Sandbox, Engines: cfg.Engines}
+	defaultEngine := models.Engine(cfg.Orchestrator.DefaultEngine)
+	if defaultEngine == "" {
+		defaultEngine = models.DefaultEngine()
+	}
+
+	var problems []config.Problem
+	for _, engine := range models.AllEngines() {
+		if c.configuredSandbox(engine) != models.SandboxNone {
+			continue
+		}
+		binary, _ := c.engineCommand(engine, defaultEngineBinaries[engine], nil)
+		if _, err := exec.LookPath(binary); err != nil {
+			problems = append(problems, config.Problem{
+				Field:   "engines." + string(engine) + ".binary",
+				Message: fmt.Sprintf("%q not found, so %s tasks will fail: %v", binary, engine, unwrapExecError(err)),
+				Warning: engine != defaultEngine && c.engineConfig(engine).Binary == "",
+			})
+		}
+	}
+
+	if mcp := cfg.Orchestrator.DefaultMCPConfig; mcp != "" {
+		// Relative paths are resolved from each task's work dir.
+		if IsInlineMCPConfig(mcp) || filepath.IsAbs(strings.TrimPrefix(mcp, "@")) {
+			for _, msg := range checkMCPConfig(mcp) {
+				problems = append(problems, config.Problem{Field: "orchestrator.default_mcp_config", Message: msg})
+			}
+		}
+	}
+	return problems
+}
+
+// checkMCPConfig parses an MCP config and lists what is wrong with it.
+func checkMCPConfig(mcpConfig string) []string {
+	parsed, _, err := loadMCPConfig(mcpConfig, "")
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if len(parsed.MCPServers) == 0 {
+		return []string{"no servers under mcpServers"}
+	}
+	names := make([]string, 0, len(parsed.MCPServers))
+	for name := range parsed.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		server := parsed.MCPServers[name]
+		switch server.Type {
+		case "local":
+			if server.Command == "" {
+				problems = append(problems, fmt.Sprintf("server %q has no command", name))
+			}
+		case "http":
+			if server.URL == "" {
+				problems = append(problems, fmt.Sprintf("server %q has no url", name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("server %q has unknown type %q (use local or http)", name, server.Type))
+		}
+	}
+	return problems
+}
+
+// unwrapExecError drops the binary name exec.LookPath repeats in its error.
+func unwrapExecError(err error) error {
+	if e, ok := err.(*exec.Error); ok {
+		return e.Err
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	mcpPath := filepath.Join(dir, "mcp.json")
+	if err := os.WriteFile(mcpPath, []byte(`{"mcpServers":{"docs":{"type":"http"}}}`), 0644); err != nil {
+		t.Fatalf("write mcp config: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Orchestrator.DefaultEngine = string(models.EngineClaude)
+	cfg.Orchestrator.DefaultMCPConfig = "@" + mcpPath
+	cfg.Engines = map[string]config.EngineConfig{
+		"claude": {Binary: filepath.Join(dir, "no-such-claude")},
+		"gemini": {Sandbox: models.SandboxDocker, Binary: filepath.Join(dir, "no-such-gemini")},
+	}
+
+	problems := CheckConfig(cfg)
+	byField := make(map[string]config.Problem)
+	for _, p := range problems {
+		byField[p.Field] = p
+	}
+
+	if p, ok := byField["engines.claude.binary"]; !ok || p.Warning {
+		t.Fatalf("expected an error for the missing default engine binary, got %v", problems)
+	}
+	if _, ok := byField["engines.gemini.binary"]; ok {
+		t.Fatalf("expected the sandboxed engine to be skipped, got %v", problems)
+	}
+	if p, ok := byField["orchestrator.default_mcp_config"]; !ok || !strings.Contains(p.Message, `"docs" has no url`) {
+		t.Fatalf("expected the MCP server without url to be reported, got %v", problems)
+	}
+
+	cfg.Orchestrator.DefaultMCPConfig = "mcp.json"
+	for _, p := range CheckConfig(cfg) {
+		if p.Field == "orchestrator.default_mcp_config" {
+			t.Fatalf("expected relative MCP config paths to be skipped, got %v", p)
+		}
+	}
+}
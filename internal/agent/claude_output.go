@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	// maxToolUses bounds the tool-use history kept on a task.
+	maxToolUses = 500
+	// maxStreamJSONLine is the longest stream-json line accepted; tool results can be large.
+	maxStreamJSONLine = 16 * 1024 * 1024
+)
+
+// claudeStreamEvent is a single line of `claude --output-format stream-json`.
+type claudeStreamEvent struct {
+	Type       string  `json:"type"`
+	Subtype    string  `json:"subtype"`
+	SessionID  string  `json:"session_id"`
+	IsError    bool    `json:"is_error"`
+	DurationMS int64   `json:"duration_ms"`
+	NumTurns   int     `json:"num_turns"`
+	Result     string  `json:"result"`
+	CostUSD    float64 `json:"total_cost_usd"`
+	Usage      *struct {
+		InputTokens              int64 `json:"input_tokens"`
+		OutputTokens             int64 `json:"output_tokens"`
+		CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+	Message struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// ClaudeOutputParser turns Claude CLI stream-json events into readable log
+// lines and collects structured results for the task.
+type ClaudeOutputParser struct {
+	sessionID    string
+	toolUses     []models.ToolUse
+	durationMS   int64
+	numTurns     int
+	resultStatus string
+	result       string
+	lastText     string
+	isError      bool
+	tokensIn     int64
+	tokensOut    int64
+	costUSD      float64
+}
+
+// NewClaudeOutputParser creates a parser for one Claude CLI run.
+func NewClaudeOutputParser() *ClaudeOutputParser {
+	return &ClaudeOutputParser{}
+}
+
+// ParseLine consumes one stdout line and returns the text to log for it.
+// Lines that are not stream-json events are returned unchanged.
+func (p *ClaudeOutputParser) ParseLine(line string) []string {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return []string{line}
+	}
+
+	var event claudeStreamEvent
+	if err := json.Unmarshal([]byte(trimmed), &event); err != nil || event.Type == "" {
+		return []string{line}
+	}
+
+	if event.SessionID != "" {
+		p.sessionID = event.SessionID
+	}
+
+	switch event.Type {
+	case "assistant":
+		var out []string
+		for _, block := range event.Message.Content {
+			switch block.Type {
+			case "text":
+				if block.Text != "" {
+					out = append(out, block.Text)
+					p.lastText = block.Text
+				}
+			case "tool_use":
+				if len(p.toolUses) < maxToolUses {
+					p.toolUses = append(p.toolUses, models.ToolUse{ID: block.ID, Name: block.Name})
+				}
+				out = append(out, "[tool_use] "+block.Name)
+			}
+		}
+		return out
+	case "result":
+		p.resultStatus = event.Subtype
+		p.isError = event.IsError
+		p.durationMS = event.DurationMS
+		p.numTurns = event.NumTurns
+		p.result = event.Result
+		p.costUSD = event.CostUSD
+		if u := event.Usage; u != nil {
+			p.tokensIn = u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+			p.tokensOut = u.OutputTokens
+		}
+		if event.IsError && event.Result != "" {
+			return []string{"[error] " + event.Result}
+		}
+		return nil
+	default:
+		// system, user (tool results) and other events are not echoed.
+		return nil
+	}
+}
+
+func (p *ClaudeOutputParser) handleLine(line string) []string { return p.ParseLine(line) }
+func (p *ClaudeOutputParser) finish(task *models.Task)        { p.Apply(task) }
+
+// SessionID returns the Claude session ID reported by the CLI.
+func (p *ClaudeOutputParser) SessionID() string {
+	return p.sessionID
+}
+
+// Summary returns the last assistant text block, falling back to the result
+// text of a successful run.
+func (p *ClaudeOutputParser) Summary() string {
+	summary := strings.TrimSpace(p.lastText)
+	if summary == "" && !p.isError {
+		summary = strings.TrimSpace(p.result)
+	}
+	if len(summary) > maxTaskSummary {
+		summary = summary[:maxTaskSummary]
+	}
+	return summary
+}
+
+// Apply copies the collected results onto the task.
+func (p *ClaudeOutputParser) Apply(task *models.Task) {
+	if p.sessionID != "" {
+		task.SessionID = p.sessionID
+	}
+	task.ToolUses = p.toolUses
+	task.DurationMS = p.durationMS
+	task.NumTurns = p.numTurns
+	task.ResultStatus = p.resultStatus
+	task.TokensIn = p.tokensIn
+	task.TokensOut = p.tokensOut
+	task.CostUSD = p.costUSD
+	task.Summary = p.Summary()
+	if p.isError && task.Error == "" {
+		task.Error = p.result
+	}
+}
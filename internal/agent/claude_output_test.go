@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestClaudeOutputParser(t *testing.T) {
+	p := NewClaudeOutputParser()
+
+	lines := []string{
+		`{"type":"system","subtype":"init","session_id":"sess-1","tools":["Bash"]}`,
+		`{"type":"assistant","session_id":"sess-1","message":{"content":[{"type":"text","text":"Looking at the repo"},{"type":"tool_use","id":"tu_1","name":"Bash","input":{"command":"ls"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"tu_1","content":"main.go"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Done."}]}}`,
+		`{"type":"result","subtype":"success","is_error":false,"duration_ms":4200,"num_turns":2,"result":"Done.","session_id":"sess-1","total_cost_usd":0.0125,"usage":{"input_tokens":100,"cache_read_input_tokens":900,"output_tokens":50}}`,
+		`not json`,
+	}
+
+	var rendered []string
+	for _, line := range lines {
+		rendered = append(rendered, p.ParseLine(line)...)
+	}
+
+	want := []string{"Looking at the repo", "[tool_use] Bash", "Done.", "not json"}
+	if len(rendered) != len(want) {
+		t.Fatalf("expected %v, got %v", want, rendered)
+	}
+	for i := range want {
+		if rendered[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, rendered)
+		}
+	}
+
+	task := &models.Task{}
+	p.Apply(task)
+	if task.SessionID != "sess-1" || task.DurationMS != 4200 || task.NumTurns != 2 || task.ResultStatus != "success" {
+		t.Fatalf("unexpected structured fields: %+v", task)
+	}
+	if task.TokensIn != 1000 || task.TokensOut != 50 {
+		t.Fatalf("unexpected token usage: in=%d out=%d", task.TokensIn, task.TokensOut)
+	}
+	if task.CostUSD != 0.0125 {
+		t.Fatalf("unexpected cost: %v", task.CostUSD)
+	}
+	if len(task.ToolUses) != 1 || task.ToolUses[0].Name != "Bash" || task.ToolUses[0].ID != "tu_1" {
+		t.Fatalf("unexpected tool uses: %+v", task.ToolUses)
+	}
+	if task.Summary != "Done." {
+		t.Fatalf("expected last assistant text as summary, got %q", task.Summary)
+	}
+}
+
+func TestClaudeOutputParser_ErrorResult(t *testing.T) {
+	p := NewClaudeOutputParser()
+	out := p.ParseLine(`{"type":"result","subtype":"error_max_turns","is_error":true,"result":"Reached max turns"}`)
+	if len(out) != 1 || out[0] != "[error] Reached max turns" {
+		t.Fatalf("unexpected output: %v", out)
+	}
+
+	task := &models.Task{}
+	p.Apply(task)
+	if task.ResultStatus != "error_max_turns" || task.Error != "Reached max turns" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+	if task.Summary != "" {
+		t.Fatalf("expected no summary for an error result, got %q", task.Summary)
+	}
+}
+
+func TestPrepareClaude_ResumeSession(t *testing.T) {
+	run, err := prepareClaude(&models.Task{ID: "task-1", Prompt: "continue", ResumeSessionID: "sess-1"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	args := strings.Join(run.args, " ")
+	if !strings.Contains(args, "--resume sess-1") {
+		t.Fatalf("expected --resume sess-1 in args, got %q", args)
+	}
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// newCopilotOutputParser parses Copilot CLI text output.
+// Copilot prints tool calls as "● Tool args", "✓ Tool args" or "✗ Tool args" (failed),
+// and ends with a "Total ..." / "Usage by model:" summary holding token counts.
+func newCopilotOutputParser() outputParser {
+	p := newTextOutputParser(classifyCopilotLine)
+	p.skip = isCopilotSummaryLine
+	return p
+}
+
+func isCopilotSummaryLine(line string) bool {
+	return strings.HasPrefix(line, "Total ") || strings.HasPrefix(line, "Usage by model")
+}
+
+func classifyCopilotLine(line string) (models.TaskEventType, string) {
+	for _, marker := range []string{"● ", "✓ ", "✔ "} {
+		if rest, ok := strings.CutPrefix(line, marker); ok {
+			return models.TaskEventToolUse, firstWord(rest)
+		}
+	}
+	for _, marker := range []string{"✗ ", "✘ "} {
+		if rest, ok := strings.CutPrefix(line, marker); ok {
+			return models.TaskEventError, firstWord(rest)
+		}
+	}
+	if strings.HasPrefix(line, "Error:") || strings.HasPrefix(line, "error:") {
+		return models.TaskEventError, ""
+	}
+	return "", ""
+}
+
+func firstWord(s string) string {
+	if fields := strings.Fields(s); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
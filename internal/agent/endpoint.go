@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"os"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// ollamaEndpoint is the local Ollama server the Ollama engines use by default.
+var ollamaEndpoint = endpoint{baseURL: "http://localhost:11434", apiKey: "ollama"}
+
+// endpoint is an OpenAI/Anthropic-compatible API (Ollama, LiteLLM, vLLM, ...)
+// that an engine CLI is routed to instead of its vendor's API.
+type endpoint struct {
+	baseURL   string
+	apiKey    string
+	apiKeyEnv string
+}
+
+// engineEndpoint returns the endpoint configured for an engine via
+// engines.<name>.base_url, or def when none is configured.
+func (c Config) engineEndpoint(engine models.Engine, def endpoint) endpoint {
+	engineCfg := c.engineConfig(engine)
+	if engineCfg.BaseURL == "" {
+		return def
+	}
+	return endpoint{
+		baseURL:   engineCfg.BaseURL,
+		apiKey:    engineCfg.APIKey,
+		apiKeyEnv: engineCfg.APIKeyEnv,
+	}
+}
+
+// configured reports whether the endpoint has a base URL.
+func (ep endpoint) configured() bool {
+	return ep.baseURL != ""
+}
+
+// key returns the API key, reading api_key_env from the server environment when set.
+func (ep endpoint) key() string {
+	if ep.apiKeyEnv != "" {
+		if v := os.Getenv(ep.apiKeyEnv); v != "" {
+			return v
+		}
+	}
+	return ep.apiKey
+}
+
+// anthropicEnv points the Claude CLI at the endpoint. When model is set, every
+// model tier (and subagents) is pinned to it since gateways rarely serve the
+// Claude tier names. The token is set apart by withAnthropicEndpoint.
+func (ep endpoint) anthropicEnv(model string) []string {
+	env := []string{
+		"ANTHROPIC_BASE_URL=" + ep.baseURL,
+		"ANTHROPIC_API_KEY=", // Keep a host key from taking precedence over the token
+	}
+	if model != "" {
+		env = append(env,
+			"ANTHROPIC_DEFAULT_OPUS_MODEL="+model,
+			"ANTHROPIC_DEFAULT_SONNET_MODEL="+model,
+			"ANTHROPIC_DEFAULT_HAIKU_MODEL="+model,
+			"CLAUDE_CODE_SUBAGENT_MODEL="+model,
+		)
+	}
+	return env
+}
+
+// withAnthropicEndpoint wraps a Claude prepare func so the CLI runs against ep.
+func withAnthropicEndpoint(prepare func(*models.Task, string) (engineRun, error), ep endpoint) func(*models.Task, string) (engineRun, error) {
+	return func(task *models.Task, logDir string) (engineRun, error) {
+		run, err := prepare(task, logDir)
+		if err != nil {
+			return run, err
+		}
+		run.env = append(run.env, ep.anthropicEnv(task.Model)...)
+		run.secretEnv = append(run.secretEnv, "ANTHROPIC_AUTH_TOKEN="+ep.key())
+		return run, nil
+	}
+}
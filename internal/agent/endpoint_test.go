@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestEngineEndpoint(t *testing.T) {
+	t.Setenv("MESNADA_TEST_GATEWAY_KEY", "from-env")
+	cfg := Config{
+		Engines: map[string]config.EngineConfig{
+			"claude":        {BaseURL: "https://gateway.example/anthropic", APIKey: "literal", APIKeyEnv: "MESNADA_TEST_GATEWAY_KEY"},
+			"ollama-claude": {BaseURL: "http://gpu-box:11434"},
+		},
+	}
+
+	if ep := cfg.engineEndpoint(models.EngineOpenCode, endpoint{}); ep.configured() {
+		t.Fatalf("expected no endpoint for opencode, got %+v", ep)
+	}
+	ep := cfg.engineEndpoint(models.EngineClaude, endpoint{})
+	if ep.baseURL != "https://gateway.example/anthropic" || ep.key() != "from-env" {
+		t.Fatalf("unexpected claude endpoint %+v (key %q)", ep, ep.key())
+	}
+	if ep := cfg.engineEndpoint(models.EngineOllamaClaude, ollamaEndpoint); ep.baseURL != "http://gpu-box:11434" || ep.key() != "" {
+		t.Fatalf("expected configured base_url to replace the Ollama default, got %+v", ep)
+	}
+	if ep := (Config{}).engineEndpoint(models.EngineOllamaClaude, ollamaEndpoint); ep != ollamaEndpoint {
+		t.Fatalf("expected Ollama default, got %+v", ep)
+	}
+}
+
+func TestWithAnthropicEndpoint(t *testing.T) {
+	ep := endpoint{baseURL: "http://litellm:4000", apiKey: "sk-test"}
+	prepare := withAnthropicEndpoint(prepareClaude, ep)
+
+	run, err := prepare(&models.Task{ID: "task-1", Prompt: "hi", Model: "qwen3-coder"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+
+	env := strings.Join(run.env, "\n")
+	for _, want := range []string{
+		"ANTHROPIC_BASE_URL=http://litellm:4000",
+		"ANTHROPIC_DEFAULT_SONNET_MODEL=qwen3-coder",
+	} {
+		if !strings.Contains(env, want) {
+			t.Fatalf("expected %q in env, got:\n%s", want, env)
+		}
+	}
+	if strings.Contains(env, "sk-test") || len(run.secretEnv) != 1 || run.secretEnv[0] != "ANTHROPIC_AUTH_TOKEN=sk-test" {
+		t.Fatalf("expected the token in secretEnv only, got env %q and secretEnv %q", run.env, run.secretEnv)
+	}
+
+	// In a Docker sandbox the token is forwarded by name, as secrets are.
+	cfg := Config{Sandbox: config.SandboxConfig{Mode: "docker", Image: "agents:1"}}
+	task := &models.Task{ID: "task-1", WorkDir: t.TempDir()}
+	cmd, err := cfg.newAgentCommand(context.Background(), task, "claude", run.args, run.env, run.secretEnv)
+	if err != nil {
+		t.Fatalf("newAgentCommand: %v", err)
+	}
+	if args := strings.Join(cmd.Args, " "); strings.Contains(args, "sk-test") || !strings.Contains(args, "-e ANTHROPIC_AUTH_TOKEN ") {
+		t.Fatalf("expected the token forwarded by name only, got %v", cmd.Args)
+	}
+	if !strings.Contains(strings.Join(cmd.Env, "\n"), "ANTHROPIC_AUTH_TOKEN=sk-test") {
+		t.Fatal("expected the token in the docker client environment")
+	}
+	if run.args[len(run.args)-1] != "You are the task_id: task-1\n\nhi" {
+		t.Fatalf("expected prompt as last argument, got %q", run.args)
+	}
+}
+
+func TestPrepareLocalOpenCode_UsesEndpoint(t *testing.T) {
+	logDir := t.TempDir()
+	ep := endpoint{baseURL: "http://vllm:8000/v1", apiKey: "sk-vllm"}
+
+	run, err := prepareLocalOpenCode(&models.Task{ID: "task-2", Model: "local.qwen"}, logDir, ep)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	defer run.cleanup()
+
+	if !strings.Contains(strings.Join(run.env, "\n"), "LOCAL_ENDPOINT=http://vllm:8000/v1") {
+		t.Fatalf("expected LOCAL_ENDPOINT in env, got %q", run.env)
+	}
+
+	data, err := os.ReadFile(filepath.Join(logDir, "ollama-opencode-config", "task-2", "opencode", "opencode.json"))
+	if err != nil {
+		t.Fatalf("read generated config: %v", err)
+	}
+	var generated struct {
+		Providers map[string]struct {
+			APIKey string `json:"apiKey"`
+		} `json:"providers"`
+	}
+	if err := json.Unmarshal(data, &generated); err != nil {
+		t.Fatalf("parse generated config: %v", err)
+	}
+	if got := generated.Providers["local"].APIKey; got != "sk-vllm" {
+		t.Fatalf("expected endpoint key in local provider, got %q", got)
+	}
+}
+
+func TestOllamaSpawnerArgs(t *testing.T) {
+	// The persona is already in the prompt (see ApplyPersona); neither CLI
+	// has a --persona flag.
+	task := &models.Task{ID: "task-3", Prompt: "hi", Model: "qwen3-coder", Persona: "reviewer", ExtraArgs: []string{"--x"}}
+
+	run, err := prepareOllamaClaude(task, t.TempDir())
+	if err != nil {
+		t.Fatalf("prepare ollama-claude: %v", err)
+	}
+	want := []string{"--print", "--output-format", "text", "--verbose", "--dangerously-skip-permissions",
+		"--model", "qwen3-coder", "--x", "You are the task_id: task-3\n\nhi"}
+	if strings.Join(run.args, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected ollama-claude args %q", run.args)
+	}
+
+	run, err = prepareLocalOpenCode(task, t.TempDir(), ollamaEndpoint)
+	if err != nil {
+		t.Fatalf("prepare ollama-opencode: %v", err)
+	}
+	defer run.cleanup()
+	if got := strings.Join(run.args, " "); got != "run -m qwen3-coder --x" {
+		t.Fatalf("unexpected ollama-opencode args %q", run.args)
+	}
+}
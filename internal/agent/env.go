@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// baseEnvVars are always passed through when an allowlist is configured,
+// since most CLIs cannot start without them. They can still be denied.
+var baseEnvVars = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TMPDIR", "TERM", "LANG", "LC_*", "TZ"}
+
+// environ returns the server environment filtered by the env allow/deny lists.
+func (c Config) environ() []string {
+	return filterEnv(os.Environ(), c.Env.Allow, c.Env.Deny)
+}
+
+// filterEnv keeps KEY=VALUE entries allowed by allow (all when empty) and not matched by deny.
+func filterEnv(environ, allow, deny []string) []string {
+	if len(allow) == 0 && len(deny) == 0 {
+		return environ
+	}
+
+	filtered := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if len(allow) > 0 && !matchEnvName(name, allow) && !matchEnvName(name, baseEnvVars) {
+			continue
+		}
+		if matchEnvName(name, deny) {
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	return filtered
+}
+
+func matchEnvName(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
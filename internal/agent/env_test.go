@@ -0,0 +1,33 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/me",
+		"AWS_ACCESS_KEY_ID=x",
+		"AWS_REGION=eu-west-1",
+		"GITHUB_TOKEN=secret",
+		"MESNADA_API_TOKEN=secret",
+	}
+
+	if got := filterEnv(environ, nil, nil); len(got) != len(environ) {
+		t.Fatalf("expected passthrough without lists, got %v", got)
+	}
+
+	got := strings.Join(filterEnv(environ, []string{"AWS_*", "GITHUB_TOKEN"}, []string{"AWS_ACCESS_KEY_ID"}), " ")
+	want := "PATH=/usr/bin HOME=/home/me AWS_REGION=eu-west-1 GITHUB_TOKEN=secret"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	got = strings.Join(filterEnv(environ, nil, []string{"MESNADA_*", "*_TOKEN"}), " ")
+	want = "PATH=/usr/bin HOME=/home/me AWS_ACCESS_KEY_ID=x AWS_REGION=eu-west-1"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
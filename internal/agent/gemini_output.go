@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// newGeminiOutputParser parses Gemini CLI text output.
+// In non-interactive mode Gemini only prints the answer, errors and tool notices.
+func newGeminiOutputParser() outputParser {
+	return newTextOutputParser(classifyGeminiLine)
+}
+
+func classifyGeminiLine(line string) (models.TaskEventType, string) {
+	if rest, ok := strings.CutPrefix(line, "Tool call:"); ok {
+		return models.TaskEventToolUse, firstWord(rest)
+	}
+	if strings.HasPrefix(line, "Error") || strings.HasPrefix(line, "[ERROR]") {
+		return models.TaskEventError, ""
+	}
+	return "", ""
+}
@@ -0,0 +1,268 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	kubectlBinary               = "kubectl"
+	defaultKubernetesNamespace  = "default"
+	defaultPodRunningTimeout    = "5m"
+	kubernetesContainerName     = "agent"
+	kubernetesJobTTLSeconds     = 600
+	kubernetesPhaseTimeout      = 30 * time.Second
+	kubernetesPhasePollInterval = 2 * time.Second
+)
+
+// kubectlArgs prefixes args with the configured context and namespace.
+func (c Config) kubectlArgs(args ...string) []string {
+	k := c.Sandbox.Kubernetes
+	namespace := k.Namespace
+	if namespace == "" {
+		namespace = defaultKubernetesNamespace
+	}
+
+	out := []string{}
+	if k.Context != "" {
+		out = append(out, "--context", k.Context)
+	}
+	out = append(out, "--namespace", namespace)
+	return append(out, args...)
+}
+
+// kubernetesImage returns the image for a Job: engine container_image, then
+// kubernetes.image, then sandbox.image.
+func (c Config) kubernetesImage(engine models.Engine) string {
+	if image := c.engineConfig(engine).ContainerImage; image != "" {
+		return image
+	}
+	if c.Sandbox.Kubernetes.Image != "" {
+		return c.Sandbox.Kubernetes.Image
+	}
+	return c.Sandbox.Image
+}
+
+// newKubernetesCommand submits the task as a Kubernetes Job and returns a
+// `kubectl attach` command that streams the pod output and forwards stdin.
+func (c Config) newKubernetesCommand(ctx context.Context, task *models.Task, binary string, args, env []string) (*exec.Cmd, error) {
+	manifest, err := c.kubernetesManifest(task, binary, args, env)
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	create := exec.CommandContext(ctx, kubectlBinary, c.kubectlArgs("create", "-f", "-")...)
+	create.Stdin = bytes.NewReader(manifest)
+	create.Stderr = &stderr
+	if err := create.Run(); err != nil {
+		// The Secret may have been created without the Job.
+		c.deleteKubernetesJob(task.ID)
+		return nil, fmt.Errorf("failed to create kubernetes job: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	log.Printf("task_event=sandboxed task_id=%s sandbox=kubernetes job=%s image=%q", task.ID, sandboxContainerName(task.ID), c.kubernetesImage(task.Engine))
+
+	timeout := c.Sandbox.Kubernetes.PodRunningTimeout
+	if timeout == "" {
+		timeout = defaultPodRunningTimeout
+	}
+
+	cmd := exec.CommandContext(ctx, kubectlBinary, c.kubectlArgs(
+		"attach", "-i",
+		"job/"+sandboxContainerName(task.ID),
+		"-c", kubernetesContainerName,
+		"--pod-running-timeout", timeout,
+	)...)
+	cmd.Dir = task.WorkDir
+	cmd.Env = os.Environ()
+	setProcessGroup(cmd)
+	return cmd, nil
+}
+
+// kubernetesManifest builds the objects created for a task as a JSON List:
+// a Secret holding the task's environment, which includes secrets and
+// endpoint keys, and the batch/v1 Job that reads it. Both are named after
+// the task and deleted together by deleteKubernetesJob.
+func (c Config) kubernetesManifest(task *models.Task, binary string, args, env []string) ([]byte, error) {
+	job, err := c.kubernetesJob(task, binary, args, len(env) > 0)
+	if err != nil {
+		return nil, err
+	}
+	items := []interface{}{job}
+	if len(env) > 0 {
+		items = []interface{}{kubernetesSecret(task, env), job}
+	}
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+}
+
+// kubernetesLabels are the labels of the objects created for a task.
+func kubernetesLabels(task *models.Task) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by": "mesnada",
+		"mesnada/task-id":              task.ID,
+	}
+}
+
+// kubernetesSecret builds the Secret holding the environment of a task, so
+// no value appears in the Job spec.
+func kubernetesSecret(task *models.Task, env []string) map[string]interface{} {
+	data := make(map[string]string, len(env))
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		data[name] = value
+	}
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":   sandboxContainerName(task.ID),
+			"labels": kubernetesLabels(task),
+		},
+		"type":       "Opaque",
+		"stringData": data,
+	}
+}
+
+// kubernetesJob builds the batch/v1 Job for a task. With withEnv, the
+// container takes its environment from the task's Secret.
+func (c Config) kubernetesJob(task *models.Task, binary string, args []string, withEnv bool) (map[string]interface{}, error) {
+	image := c.kubernetesImage(task.Engine)
+	if image == "" {
+		return nil, fmt.Errorf("kubernetes sandbox requires orchestrator.sandbox.kubernetes.image, orchestrator.sandbox.image or engines.%s.container_image to be set", task.Engine)
+	}
+
+	k := c.Sandbox.Kubernetes
+
+	container := map[string]interface{}{
+		"name":      kubernetesContainerName,
+		"image":     image,
+		"command":   []string{binary},
+		"args":      args,
+		"stdin":     true,
+		"stdinOnce": true,
+	}
+	if withEnv {
+		container["envFrom"] = []map[string]interface{}{{
+			"secretRef": map[string]string{"name": sandboxContainerName(task.ID)},
+		}}
+	}
+	if len(k.Resources.Requests) > 0 || len(k.Resources.Limits) > 0 {
+		resources := map[string]interface{}{}
+		if len(k.Resources.Requests) > 0 {
+			resources["requests"] = k.Resources.Requests
+		}
+		if len(k.Resources.Limits) > 0 {
+			resources["limits"] = k.Resources.Limits
+		}
+		container["resources"] = resources
+	}
+
+	podSpec := map[string]interface{}{
+		"restartPolicy": "Never",
+		"containers":    []interface{}{container},
+	}
+	if k.ServiceAccount != "" {
+		podSpec["serviceAccountName"] = k.ServiceAccount
+	}
+	if k.VolumeClaim != "" {
+		container["workingDir"] = task.WorkDir
+		container["volumeMounts"] = []map[string]string{{"name": "workdir", "mountPath": task.WorkDir}}
+		podSpec["volumes"] = []map[string]interface{}{{
+			"name":                  "workdir",
+			"persistentVolumeClaim": map[string]string{"claimName": k.VolumeClaim},
+		}}
+	}
+
+	labels := kubernetesLabels(task)
+	return map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":   sandboxContainerName(task.ID),
+			"labels": labels,
+		},
+		"spec": map[string]interface{}{
+			"backoffLimit":            0,
+			"ttlSecondsAfterFinished": kubernetesJobTTLSeconds,
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels},
+				"spec":     podSpec,
+			},
+		},
+	}, nil
+}
+
+// deleteKubernetesJob deletes the Job of a task and the Secret holding its
+// environment.
+func (c Config) deleteKubernetesJob(taskID string) {
+	name := sandboxContainerName(taskID)
+	del := exec.Command(kubectlBinary, c.kubectlArgs("delete", "job/"+name, "secret/"+name,
+		"--ignore-not-found", "--wait=false", "--cascade=background")...)
+	_ = del.Run()
+}
+
+// finishKubernetesJob maps the pod phase to the task result and deletes the
+// Job and its Secret.
+func (c Config) finishKubernetesJob(task *models.Task, err error) error {
+	jobName := sandboxContainerName(task.ID)
+	defer c.deleteKubernetesJob(task.ID)
+
+	if task.Status == models.TaskStatusCancelled || task.Status == models.TaskStatusPaused {
+		return err
+	}
+
+	deadline := time.Now().Add(kubernetesPhaseTimeout)
+	for {
+		phase, exitCode := c.kubernetesPodPhase(jobName)
+		switch phase {
+		case "Succeeded":
+			return nil
+		case "Failed":
+			if exitCode != "" {
+				return fmt.Errorf("kubernetes job %s failed with exit code %s", jobName, exitCode)
+			}
+			return fmt.Errorf("kubernetes job %s failed", jobName)
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("kubernetes job %s did not finish (pod phase %q)", jobName, phase)
+		}
+		time.Sleep(kubernetesPhasePollInterval)
+	}
+}
+
+// kubernetesPodPhase returns the phase and container exit code of the Job pod.
+func (c Config) kubernetesPodPhase(jobName string) (phase, exitCode string) {
+	cmd := exec.Command(kubectlBinary, c.kubectlArgs("get", "pods",
+		"-l", "job-name="+jobName,
+		"-o", "jsonpath={.items[0].status.phase} {.items[0].status.containerStatuses[0].state.terminated.exitCode}",
+	)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", ""
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) > 0 {
+		phase = fields[0]
+	}
+	if len(fields) > 1 {
+		exitCode = fields[1]
+	}
+	return phase, exitCode
+}
@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestKubernetesJobManifest(t *testing.T) {
+	cfg := Config{
+		Sandbox: config.SandboxConfig{
+			Image: "global:1",
+			Kubernetes: config.KubernetesConfig{
+				Namespace:   "agents",
+				VolumeClaim: "workspace",
+				Resources: config.KubernetesResources{
+					Limits: map[string]string{"memory": "4Gi"},
+				},
+			},
+		},
+	}
+	task := &models.Task{ID: "task-abcd1234", Engine: models.EngineClaude, WorkDir: "/work/repo"}
+
+	env := []string{"NO_COLOR=1", "ANTHROPIC_AUTH_TOKEN=sk-secret"}
+	data, err := cfg.kubernetesManifest(task, "claude", []string{"-p", "hi"}, env)
+	if err != nil {
+		t.Fatalf("kubernetesManifest: %v", err)
+	}
+	if strings.Contains(string(data), `"value"`) {
+		t.Fatalf("expected no literal env values in the manifest: %s", data)
+	}
+
+	type container struct {
+		Image      string   `json:"image"`
+		Command    []string `json:"command"`
+		Args       []string `json:"args"`
+		WorkingDir string   `json:"workingDir"`
+		Env        []struct {
+			Name string `json:"name"`
+		} `json:"env"`
+		EnvFrom []struct {
+			SecretRef struct {
+				Name string `json:"name"`
+			} `json:"secretRef"`
+		} `json:"envFrom"`
+		Resources struct {
+			Limits map[string]string `json:"limits"`
+		} `json:"resources"`
+	}
+	var list struct {
+		Kind  string            `json:"kind"`
+		Items []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil || list.Kind != "List" || len(list.Items) != 2 {
+		t.Fatalf("expected a List of a Secret and a Job, got %s (%v)", data, err)
+	}
+	var secret struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		StringData map[string]string `json:"stringData"`
+	}
+	var job struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			BackoffLimit int `json:"backoffLimit"`
+			Template     struct {
+				Spec struct {
+					RestartPolicy string      `json:"restartPolicy"`
+					Containers    []container `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(list.Items[0], &secret); err != nil {
+		t.Fatalf("unmarshal secret: %v", err)
+	}
+	if err := json.Unmarshal(list.Items[1], &job); err != nil {
+		t.Fatalf("unmarshal job: %v", err)
+	}
+
+	if secret.Kind != "Secret" || secret.Metadata.Name != "mesnada-task-abcd1234" ||
+		secret.StringData["NO_COLOR"] != "1" || secret.StringData["ANTHROPIC_AUTH_TOKEN"] != "sk-secret" {
+		t.Fatalf("unexpected secret: %+v", secret)
+	}
+	if job.Kind != "Job" || job.Metadata.Name != "mesnada-task-abcd1234" {
+		t.Fatalf("unexpected job identity: kind=%q name=%q", job.Kind, job.Metadata.Name)
+	}
+	if job.Spec.Template.Spec.RestartPolicy != "Never" {
+		t.Fatalf("expected restartPolicy Never, got %q", job.Spec.Template.Spec.RestartPolicy)
+	}
+	c := job.Spec.Template.Spec.Containers[0]
+	if c.Image != "global:1" || c.Command[0] != "claude" || len(c.Args) != 2 {
+		t.Fatalf("unexpected container: %+v", c)
+	}
+	if c.WorkingDir != "/work/repo" {
+		t.Fatalf("expected workingDir from volume claim, got %q", c.WorkingDir)
+	}
+	if len(c.Env) != 0 || len(c.EnvFrom) != 1 || c.EnvFrom[0].SecretRef.Name != secret.Metadata.Name {
+		t.Fatalf("expected the env to come from the task secret, got env=%+v envFrom=%+v", c.Env, c.EnvFrom)
+	}
+	if c.Resources.Limits["memory"] != "4Gi" {
+		t.Fatalf("unexpected resources: %+v", c.Resources)
+	}
+}
+
+func TestKubectlArgs(t *testing.T) {
+	cfg := Config{Sandbox: config.SandboxConfig{Kubernetes: config.KubernetesConfig{Context: "prod"}}}
+	got := cfg.kubectlArgs("get", "pods")
+	want := []string{"--context", "prod", "--namespace", "default", "get", "pods"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// compressedLogSuffix is appended to a log path once the log is gzipped.
+const compressedLogSuffix = ".gz"
+
+// rotatedLogPath returns the path holding the previous segment of a rotated log.
+func rotatedLogPath(logPath string) string {
+	return logPath + ".1"
+}
+
+// logBytesWritten counts the task output written to log files since start.
+var logBytesWritten atomic.Int64
+
+// LogBytesWritten returns the bytes of task output written to log files.
+func LogBytesWritten() int64 {
+	return logBytesWritten.Load()
+}
+
+// logWriter writes task output to a log file. When maxSize is set, the file
+// is rotated once it would grow past maxSize: the current file moves to
+// <log>.1 (replacing an older one) and a fresh file is started, so a task
+// never uses more than about twice maxSize on disk.
+type logWriter struct {
+	mu      sync.Mutex
+	file    *os.File
+	path    string
+	taskID  string
+	maxSize int64
+	size    int64
+}
+
+// createLog creates the log file for a task.
+func (c Config) createLog(taskID, path string) (*logWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &logWriter{file: f, path: path, taskID: taskID, maxSize: c.MaxLogSize}, nil
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			slog.Warn("task_event=log_rotate_failed", "task_id", w.taskID, "error", err)
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	logBytesWritten.Add(int64(n))
+	return n, err
+}
+
+func (w *logWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// rotate moves the current file aside and starts a new one. Callers hold w.mu.
+func (w *logWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(w.path, rotatedLogPath(w.path)); err != nil {
+		return err
+	}
+	f, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	w.file = f
+
+	marker := fmt.Sprintf("[mesnada] log rotated at %s after reaching %d bytes; earlier output is in %s\n",
+		time.Now().Format(time.RFC3339), w.maxSize, filepath.Base(rotatedLogPath(w.path)))
+	// The marker is not counted so that small limits still make progress.
+	_, err = w.file.WriteString(marker)
+	w.size = 0
+	log.Printf("task_event=log_rotated task_id=%s max_size=%d", w.taskID, w.maxSize)
+	return err
+}
+
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// finishLog closes a task log. With compress_logs enabled, logs of completed,
+// failed and cancelled tasks are then replaced by gzipped copies. Paused logs
+// are left as-is because resume prompts point the agent at the raw file.
+func (c Config) finishLog(w *logWriter, task *models.Task) {
+	w.Close()
+	if !c.CompressLogs || !task.IsTerminal() || task.Status == models.TaskStatusPaused {
+		return
+	}
+	for _, path := range []string{w.path, rotatedLogPath(w.path)} {
+		if err := compressFile(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("task_event=log_compress_failed", "task_id", task.ID, "path", path, "error", err)
+		}
+	}
+}
+
+// compressFile gzips path to path.gz and removes the original. The gzip file
+// is written under a temporary name first so readers never see a partial copy.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + compressedLogSuffix + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+compressedLogSuffix); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+// LogReader reads a task log, whether it is still plain text or gzipped.
+type LogReader struct {
+	io.ReadSeeker
+	closer io.Closer
+	size   int64
+}
+
+// Size returns the uncompressed size of the log.
+func (r *LogReader) Size() int64 {
+	return r.size
+}
+
+// Close releases the underlying file, if any.
+func (r *LogReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// OpenLog opens a task log by its original path. When only the compressed
+// copy (<path>.gz) exists it is decompressed into memory transparently.
+func OpenLog(path string) (*LogReader, error) {
+	f, err := os.Open(path)
+	if err == nil {
+		st, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &LogReader{ReadSeeker: f, closer: f, size: st.Size()}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	gz, gzErr := os.Open(path + compressedLogSuffix)
+	if gzErr != nil {
+		// Report the original path so callers can keep using os.IsNotExist.
+		return nil, err
+	}
+	defer gz.Close()
+
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compressed log: %w", err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compressed log: %w", err)
+	}
+	return &LogReader{ReadSeeker: bytes.NewReader(data), size: int64(len(data))}, nil
+}
+
+// ReadLog returns the full content of a task log, decompressing it if needed.
+func ReadLog(path string) ([]byte, error) {
+	r, err := OpenLog(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// RawLog is a task log as stored on disk: the rotated segment, if any,
+// followed by the current one. Gzipped segments form a single gzip stream
+// when concatenated, so a compressed log reads as one .gz file.
+type RawLog struct {
+	io.Reader
+	files []*os.File
+	// Size is the number of bytes Reader yields. Logs that are still being
+	// written are read up to their size when opened.
+	Size int64
+	// Gzipped reports that the log was compressed after the task finished.
+	Gzipped bool
+}
+
+// Close releases the log files.
+func (r *RawLog) Close() error {
+	var err error
+	for _, f := range r.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// OpenRawLog opens a task log by its original path for download, without
+// decompressing it. It falls back to the gzipped copies when the plain log
+// is gone.
+func OpenRawLog(path string) (*RawLog, error) {
+	r, err := openRawLog(path, false)
+	if os.IsNotExist(err) {
+		if gz, gzErr := openRawLog(path, true); gzErr == nil {
+			return gz, nil
+		}
+	}
+	return r, err
+}
+
+func openRawLog(path string, gzipped bool) (*RawLog, error) {
+	suffix := ""
+	if gzipped {
+		suffix = compressedLogSuffix
+	}
+	current, err := os.Open(path + suffix)
+	if err != nil {
+		return nil, err
+	}
+	r := &RawLog{Gzipped: gzipped}
+	var readers []io.Reader
+	if prev, err := os.Open(rotatedLogPath(path) + suffix); err == nil {
+		if st, err := prev.Stat(); err == nil {
+			r.files = append(r.files, prev)
+			readers = append(readers, io.LimitReader(prev, st.Size()))
+			r.Size += st.Size()
+		} else {
+			prev.Close()
+		}
+	}
+	st, err := current.Stat()
+	if err != nil {
+		current.Close()
+		r.Close()
+		return nil, err
+	}
+	r.files = append(r.files, current)
+	readers = append(readers, io.LimitReader(current, st.Size()))
+	r.Size += st.Size()
+	r.Reader = io.MultiReader(readers...)
+	return r, nil
+}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestLogWriter_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task-1.log")
+	w, err := (Config{MaxLogSize: 32}).createLog("task-1", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"0123456789\n", "abcdefghij\n", "ABCDEFGHIJ\n", "last\n"} {
+		if _, err := w.WriteString(line); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := os.ReadFile(rotatedLogPath(path))
+	if err != nil {
+		t.Fatalf("expected rotated log: %v", err)
+	}
+	if string(rotated) != "0123456789\nabcdefghij\n" {
+		t.Fatalf("unexpected rotated content %q", rotated)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(current), "[mesnada] log rotated") || !strings.HasSuffix(string(current), "last\n") {
+		t.Fatalf("unexpected current content %q", current)
+	}
+}
+
+func TestLogWriter_Unlimited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task-1.log")
+	w, err := (Config{}).createLog("task-1", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		w.WriteString("0123456789\n")
+	}
+	w.Close()
+
+	if _, err := os.Stat(rotatedLogPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("expected no rotation without max size, got %v", err)
+	}
+}
+
+func TestFinishLog_CompressesTerminalTasks(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{CompressLogs: true}
+
+	path := filepath.Join(dir, "task-1.log")
+	w, err := cfg.createLog("task-1", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("hello\nworld\n")
+	cfg.finishLog(w, &models.Task{ID: "task-1", Status: models.TaskStatusCompleted})
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected plain log to be removed, got %v", err)
+	}
+	if _, err := os.Stat(path + compressedLogSuffix); err != nil {
+		t.Fatalf("expected compressed log: %v", err)
+	}
+
+	data, err := ReadLog(path)
+	if err != nil || string(data) != "hello\nworld\n" {
+		t.Fatalf("expected transparent decompression, got %q (%v)", data, err)
+	}
+
+	r, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Size() != int64(len("hello\nworld\n")) {
+		t.Fatalf("expected uncompressed size, got %d", r.Size())
+	}
+}
+
+func TestFinishLog_KeepsPausedLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task-1.log")
+	cfg := Config{CompressLogs: true}
+	w, err := cfg.createLog("task-1", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.finishLog(w, &models.Task{ID: "task-1", Status: models.TaskStatusPaused})
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected paused log to stay uncompressed: %v", err)
+	}
+}
+
+func TestOpenLog_Missing(t *testing.T) {
+	if _, err := OpenLog(filepath.Join(t.TempDir(), "missing.log")); !os.IsNotExist(err) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestOpenRawLog(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{MaxLogSize: 16, CompressLogs: true}
+	path := filepath.Join(dir, "task-1.log")
+	w, err := cfg.createLog("task-1", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("first segment\n")
+	w.WriteString("second\n")
+
+	r, err := OpenRawLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if r.Gzipped || int64(len(data)) != r.Size || !strings.HasPrefix(string(data), "first segment\n") || !strings.HasSuffix(string(data), "second\n") {
+		t.Fatalf("expected both plain segments, got %q (size %d)", data, r.Size)
+	}
+
+	cfg.finishLog(w, &models.Task{ID: "task-1", Status: models.TaskStatusCompleted})
+	r, err = OpenRawLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.Gzipped {
+		t.Fatal("expected the compressed log")
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unzipped, _ := io.ReadAll(zr)
+	if string(unzipped) != string(data) {
+		t.Fatalf("expected the segments as one gzip stream, got %q", unzipped)
+	}
+
+	if _, err := OpenRawLog(filepath.Join(dir, "missing.log")); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"log"
 	"sync"
 
 	"github.com/sevir/mesnada/pkg/models"
@@ -11,27 +12,58 @@ import (
 
 // Manager coordinates multiple engine spawners.
 type Manager struct {
-	copilotSpawner         *CopilotSpawner
-	claudeSpawner          *ClaudeSpawner
-	geminiSpawner          *GeminiSpawner
-	opencodeSpawner        *OpenCodeSpawner
-	ollamaClaudeSpawner    *OllamaClaudeSpawner
-	ollamaOpenCodeSpawner  *OllamaOpenCodeSpawner
-	taskEngines            map[string]models.Engine // Maps task ID to engine
-	mu                     sync.RWMutex
+	spawners    map[models.Engine]Spawner // One spawner per engine
+	taskEngines map[string]models.Engine  // Maps task ID to engine
+	engineInfo  []models.EngineInfo       // Last engine probe results
+	config      Config
+	mu          sync.RWMutex
+
+	// slots limits concurrent processes for engines with max_parallel set.
+	slots map[models.Engine]chan struct{}
+	// held maps task ID to the slot its process occupies.
+	held   map[string]chan struct{}
+	heldMu sync.Mutex
 }
 
 // NewManager creates a new agent manager.
-func NewManager(logDir string, onComplete func(task *models.Task)) *Manager {
-	return &Manager{
-		copilotSpawner:        NewCopilotSpawner(logDir, onComplete),
-		claudeSpawner:         NewClaudeSpawner(logDir, onComplete),
-		geminiSpawner:         NewGeminiSpawner(logDir, onComplete),
-		opencodeSpawner:       NewOpenCodeSpawner(logDir, onComplete),
-		ollamaClaudeSpawner:   NewOllamaClaudeSpawner(logDir, onComplete),
-		ollamaOpenCodeSpawner: NewOllamaOpenCodeSpawner(logDir, onComplete),
-		taskEngines:           make(map[string]models.Engine),
+func NewManager(cfg Config, onComplete func(task *models.Task)) *Manager {
+	m := &Manager{
+		taskEngines: make(map[string]models.Engine),
+		config:      cfg,
+		slots:       make(map[models.Engine]chan struct{}),
+		held:        make(map[string]chan struct{}),
 	}
+
+	// Free the engine slot before the orchestrator reacts to the completion.
+	complete := func(task *models.Task) {
+		m.releaseSlot(task.ID)
+		if onComplete != nil {
+			onComplete(task)
+		}
+	}
+
+	m.spawners = map[models.Engine]Spawner{
+		models.EngineCopilot:        NewCopilotSpawner(cfg, complete),
+		models.EngineClaude:         NewClaudeSpawner(cfg, complete),
+		models.EngineGemini:         NewGeminiSpawner(cfg, complete),
+		models.EngineOpenCode:       NewOpenCodeSpawner(cfg, complete),
+		models.EngineOllamaClaude:   NewOllamaClaudeSpawner(cfg, complete),
+		models.EngineOllamaOpenCode: NewOllamaOpenCodeSpawner(cfg, complete),
+	}
+	for engine := range m.spawners {
+		if n := cfg.engineConfig(engine).MaxParallel; n > 0 {
+			m.slots[engine] = make(chan struct{}, n)
+		}
+	}
+	return m
+}
+
+// spawner returns the spawner for an engine, falling back to the default engine.
+func (m *Manager) spawner(engine models.Engine) Spawner {
+	if s, ok := m.spawners[engine]; ok {
+		return s
+	}
+	return m.spawners[models.DefaultEngine()]
 }
 
 // Spawn starts a new agent using the appropriate engine.
@@ -41,138 +73,143 @@ func (m *Manager) Spawn(ctx context.Context, task *models.Task) error {
 		engine = models.DefaultEngine()
 	}
 
+	if err := m.acquireSlot(ctx, engine, task); err != nil {
+		return err
+	}
+	// The task may have been cancelled or paused while it waited for a slot.
+	if task.IsTerminal() {
+		m.releaseSlot(task.ID)
+		return nil
+	}
+
 	// Track which engine is handling this task
 	m.mu.Lock()
 	m.taskEngines[task.ID] = engine
 	m.mu.Unlock()
 
-	switch engine {
-	case models.EngineClaude:
-		return m.claudeSpawner.Spawn(ctx, task)
-	case models.EngineGemini:
-		return m.geminiSpawner.Spawn(ctx, task)
-	case models.EngineOpenCode:
-		return m.opencodeSpawner.Spawn(ctx, task)
-	case models.EngineOllamaClaude:
-		return m.ollamaClaudeSpawner.Spawn(ctx, task)
-	case models.EngineOllamaOpenCode:
-		return m.ollamaOpenCodeSpawner.Spawn(ctx, task)
-	case models.EngineCopilot:
-		return m.copilotSpawner.Spawn(ctx, task)
+	if err := m.spawner(engine).Spawn(ctx, task); err != nil {
+		m.releaseSlot(task.ID)
+		return err
+	}
+	return nil
+}
+
+// TryReserveSlot takes an engine slot for a task without waiting and reports
+// whether the task's engine has room; Spawn then uses the reserved slot.
+// Engines without a max_parallel limit always have room.
+func (m *Manager) TryReserveSlot(task *models.Task) bool {
+	engine := task.Engine
+	if engine == "" {
+		engine = models.DefaultEngine()
+	}
+	slot, ok := m.slots[engine]
+	if !ok {
+		return true
+	}
+
+	m.heldMu.Lock()
+	defer m.heldMu.Unlock()
+	if _, held := m.held[task.ID]; held {
+		return true
+	}
+	select {
+	case slot <- struct{}{}:
+		m.held[task.ID] = slot
+		return true
 	default:
-		return m.copilotSpawner.Spawn(ctx, task)
+		return false
 	}
 }
 
-// Cancel stops a running agent.
-func (m *Manager) Cancel(taskID string) error {
-	engine := m.getTaskEngine(taskID)
-
-	switch engine {
-	case models.EngineClaude:
-		return m.claudeSpawner.Cancel(taskID)
-	case models.EngineGemini:
-		return m.geminiSpawner.Cancel(taskID)
-	case models.EngineOpenCode:
-		return m.opencodeSpawner.Cancel(taskID)
-	case models.EngineOllamaClaude:
-		return m.ollamaClaudeSpawner.Cancel(taskID)
-	case models.EngineOllamaOpenCode:
-		return m.ollamaOpenCodeSpawner.Cancel(taskID)
+// acquireSlot blocks until the engine is below its max_parallel limit.
+// Engines without a limit and tasks holding a reserved slot return
+// immediately.
+func (m *Manager) acquireSlot(ctx context.Context, engine models.Engine, task *models.Task) error {
+	slot, ok := m.slots[engine]
+	if !ok {
+		return nil
+	}
+	m.heldMu.Lock()
+	_, held := m.held[task.ID]
+	m.heldMu.Unlock()
+	if held {
+		return nil
+	}
+
+	select {
+	case slot <- struct{}{}:
 	default:
-		return m.copilotSpawner.Cancel(taskID)
+		log.Printf("task_event=queued task_id=%s engine=%s max_parallel=%d", task.ID, engine, cap(slot))
+		select {
+		case slot <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
+
+	m.heldMu.Lock()
+	m.held[task.ID] = slot
+	m.heldMu.Unlock()
+	return nil
+}
+
+// releaseSlot frees the engine slot held by a task, if any.
+func (m *Manager) releaseSlot(taskID string) {
+	m.heldMu.Lock()
+	slot, ok := m.held[taskID]
+	delete(m.held, taskID)
+	m.heldMu.Unlock()
+
+	if ok {
+		<-slot
+	}
+}
+
+// Cancel stops a running agent.
+func (m *Manager) Cancel(taskID string) error {
+	return m.spawner(m.getTaskEngine(taskID)).Cancel(taskID)
 }
 
 // Pause stops a running agent without marking it as cancelled.
 func (m *Manager) Pause(taskID string) error {
-	engine := m.getTaskEngine(taskID)
-
-	switch engine {
-	case models.EngineClaude:
-		return m.claudeSpawner.Pause(taskID)
-	case models.EngineGemini:
-		return m.geminiSpawner.Pause(taskID)
-	case models.EngineOpenCode:
-		return m.opencodeSpawner.Pause(taskID)
-	case models.EngineOllamaClaude:
-		return m.ollamaClaudeSpawner.Cancel(taskID)
-	case models.EngineOllamaOpenCode:
-		return m.ollamaOpenCodeSpawner.Cancel(taskID)
-	default:
-		return m.copilotSpawner.Pause(taskID)
-	}
+	return m.spawner(m.getTaskEngine(taskID)).Pause(taskID)
 }
 
 // Wait blocks until a task completes or context is cancelled.
 func (m *Manager) Wait(ctx context.Context, taskID string) error {
-	engine := m.getTaskEngine(taskID)
-
-	switch engine {
-	case models.EngineClaude:
-		return m.claudeSpawner.Wait(ctx, taskID)
-	case models.EngineGemini:
-		return m.geminiSpawner.Wait(ctx, taskID)
-	case models.EngineOpenCode:
-		return m.opencodeSpawner.Wait(ctx, taskID)
-	case models.EngineOllamaClaude:
-		// Wait not implemented for ollama spawners, return nil
-		return nil
-	case models.EngineOllamaOpenCode:
-		// Wait not implemented for ollama spawners, return nil
-		return nil
-	default:
-		return m.copilotSpawner.Wait(ctx, taskID)
-	}
+	return m.spawner(m.getTaskEngine(taskID)).Wait(ctx, taskID)
+}
+
+// StreamOutput subscribes to the live output of a running task.
+func (m *Manager) StreamOutput(taskID string) (<-chan string, func(), error) {
+	return m.spawner(m.getTaskEngine(taskID)).StreamOutput(taskID)
 }
 
 // IsRunning checks if a task is currently running.
 func (m *Manager) IsRunning(taskID string) bool {
-	engine := m.getTaskEngine(taskID)
-
-	switch engine {
-	case models.EngineClaude:
-		return m.claudeSpawner.IsRunning(taskID)
-	case models.EngineGemini:
-		return m.geminiSpawner.IsRunning(taskID)
-	case models.EngineOpenCode:
-		return m.opencodeSpawner.IsRunning(taskID)
-	case models.EngineOllamaClaude:
-		return m.ollamaClaudeSpawner.IsRunning(taskID)
-	case models.EngineOllamaOpenCode:
-		return m.ollamaOpenCodeSpawner.IsRunning(taskID)
-	default:
-		return m.copilotSpawner.IsRunning(taskID)
-	}
+	return m.spawner(m.getTaskEngine(taskID)).IsRunning(taskID)
 }
 
 // RunningCount returns the total number of currently running processes.
 func (m *Manager) RunningCount() int {
-	count := m.copilotSpawner.RunningCount() +
-		m.claudeSpawner.RunningCount() +
-		m.geminiSpawner.RunningCount() +
-		m.opencodeSpawner.RunningCount()
-	
-	// Count ollama spawners processes
-	m.ollamaClaudeSpawner.mu.RLock()
-	count += len(m.ollamaClaudeSpawner.processes)
-	m.ollamaClaudeSpawner.mu.RUnlock()
-	
-	m.ollamaOpenCodeSpawner.mu.RLock()
-	count += len(m.ollamaOpenCodeSpawner.processes)
-	m.ollamaOpenCodeSpawner.mu.RUnlock()
-	
+	count := 0
+	for _, s := range m.spawners {
+		count += s.RunningCount()
+	}
 	return count
 }
 
 // Shutdown cancels all running processes.
 func (m *Manager) Shutdown() {
-	m.copilotSpawner.Shutdown()
-	m.claudeSpawner.Shutdown()
-	m.geminiSpawner.Shutdown()
-	m.opencodeSpawner.Shutdown()
-	m.ollamaClaudeSpawner.Cleanup()
-	m.ollamaOpenCodeSpawner.Cleanup()
+	var wg sync.WaitGroup
+	for _, s := range m.spawners {
+		wg.Add(1)
+		go func(s Spawner) {
+			defer wg.Done()
+			s.Shutdown()
+		}(s)
+	}
+	wg.Wait()
 }
 
 // getTaskEngine returns the engine used for a task.
@@ -194,9 +231,14 @@ func (m *Manager) CleanupTask(taskID string) {
 	delete(m.taskEngines, taskID)
 }
 
-// GetProcess returns information about a running process (legacy support).
+// GetProcess returns information about a running process.
 func (m *Manager) GetProcess(taskID string) (*Process, bool) {
-	return m.copilotSpawner.GetProcess(taskID)
+	if s, ok := m.spawner(m.getTaskEngine(taskID)).(interface {
+		GetProcess(string) (*Process, bool)
+	}); ok {
+		return s.GetProcess(taskID)
+	}
+	return nil, false
 }
 
 // ValidateEngine checks if an engine string is valid.
@@ -0,0 +1,96 @@
+//go:build !windows
+
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestManager_RoutesOllamaEngines(t *testing.T) {
+	for _, engine := range []models.Engine{models.EngineOllamaClaude, models.EngineOllamaOpenCode} {
+		t.Run(string(engine), func(t *testing.T) {
+			done := make(chan *models.Task, 1)
+			m := NewManager(Config{
+				LogDir: t.TempDir(),
+				Engines: map[string]config.EngineConfig{
+					string(engine): {Binary: "sh", DefaultArgs: []string{"-c", "sleep 30"}},
+				},
+			}, func(task *models.Task) { done <- task })
+
+			task := &models.Task{ID: "task-" + string(engine), Prompt: "p", Engine: engine, WorkDir: t.TempDir()}
+			if err := m.Spawn(context.Background(), task); err != nil {
+				t.Fatalf("Spawn: %v", err)
+			}
+			if !m.IsRunning(task.ID) || m.RunningCount() != 1 {
+				t.Fatal("expected task to run on its engine spawner")
+			}
+
+			if err := m.Pause(task.ID); err != nil {
+				t.Fatalf("Pause: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := m.Wait(ctx, task.ID); err != nil {
+				t.Fatalf("Wait: %v", err)
+			}
+
+			if got := <-done; got.Status != models.TaskStatusPaused {
+				t.Fatalf("expected paused task, got %s", got.Status)
+			}
+			if m.IsRunning(task.ID) {
+				t.Fatal("expected task to stop running")
+			}
+		})
+	}
+}
+
+func TestManager_MaxParallelQueuesTasks(t *testing.T) {
+	done := make(chan *models.Task, 2)
+	m := NewManager(Config{
+		LogDir: t.TempDir(),
+		Engines: map[string]config.EngineConfig{
+			"copilot": {Binary: "sh", DefaultArgs: []string{"-c", "sleep 30"}, MaxParallel: 1},
+		},
+	}, func(task *models.Task) { done <- task })
+
+	first := &models.Task{ID: "task-first", Prompt: "p", Engine: models.EngineCopilot, WorkDir: t.TempDir()}
+	if err := m.Spawn(context.Background(), first); err != nil {
+		t.Fatalf("Spawn first: %v", err)
+	}
+
+	second := &models.Task{ID: "task-second", Prompt: "p", Engine: models.EngineCopilot, WorkDir: t.TempDir()}
+	spawned := make(chan error, 1)
+	go func() { spawned <- m.Spawn(context.Background(), second) }()
+
+	select {
+	case err := <-spawned:
+		t.Fatalf("expected second task to wait for a slot, Spawn returned %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if m.RunningCount() != 1 {
+		t.Fatalf("expected 1 running task, got %d", m.RunningCount())
+	}
+
+	if err := m.Cancel(first.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	<-done
+
+	select {
+	case err := <-spawned:
+		if err != nil {
+			t.Fatalf("Spawn second: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the queued task to start")
+	}
+	if !m.IsRunning(second.ID) {
+		t.Fatal("expected queued task to run once the slot freed")
+	}
+	m.Shutdown()
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/sevir/mesnada/internal/config"
 )
 
 // MesnadaMCPConfig represents the Mesnada MCP configuration format.
@@ -44,15 +46,42 @@ type ClaudeMCPServer struct {
 	URL  string `json:"url,omitempty"`
 }
 
-// ConvertMCPConfig converts a Mesnada MCP config file to Claude CLI format.
-// It reads the source file and returns the path to a temporary file with the converted config.
-// workDir is the working directory to resolve relative paths (should be task.WorkDir).
-func ConvertMCPConfig(mcpConfigPath, tempDir, workDir string) (string, error) {
-	// Handle @ prefix (file reference)
-	sourcePath := mcpConfigPath
-	if strings.HasPrefix(sourcePath, "@") {
-		sourcePath = sourcePath[1:]
+// IsInlineMCPConfig reports whether an mcp_config value is a raw JSON document
+// rather than a file path.
+func IsInlineMCPConfig(mcpConfig string) bool {
+	return config.IsInlineMCPConfig(mcpConfig)
+}
+
+// MCPConfigSource describes an mcp_config value for logs: the file path, or
+// "inline" for a JSON document, which may hold tokens and is never logged.
+func MCPConfigSource(mcpConfig string) string {
+	if IsInlineMCPConfig(mcpConfig) {
+		return "inline"
+	}
+	return mcpConfig
+}
+
+// writeInlineMCPConfig writes an inline mcp_config to
+// baseDir/copilot-mcp/<taskID>/mcp-config.json, readable by the owner only,
+// so the JSON is passed to the CLI as a file instead of on its command line.
+// It returns the file path.
+func writeInlineMCPConfig(mcpConfig, taskID, baseDir string) (string, error) {
+	tempDir := filepath.Join(baseDir, "copilot-mcp", taskID)
+	if err := os.MkdirAll(tempDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	outputPath := filepath.Join(tempDir, "mcp-config.json")
+	if err := os.WriteFile(outputPath, []byte(mcpConfig), 0600); err != nil {
+		return "", fmt.Errorf("failed to write MCP config: %w", err)
 	}
+	return outputPath, nil
+}
+
+// loadMCPConfig parses a Mesnada MCP config given as inline JSON or as a file
+// path (optionally prefixed with @, relative paths resolved from workDir).
+// It also returns workDir made absolute, for resolving server cwd entries.
+func loadMCPConfig(mcpConfig, workDir string) (MesnadaMCPConfig, string, error) {
+	var mesnadaConfig MesnadaMCPConfig
 
 	// Convert workDir to absolute path if it's relative
 	absWorkDir := workDir
@@ -60,25 +89,43 @@ func ConvertMCPConfig(mcpConfigPath, tempDir, workDir string) (string, error) {
 		var err error
 		absWorkDir, err = filepath.Abs(workDir)
 		if err != nil {
-			return "", fmt.Errorf("failed to resolve workDir to absolute path: %w", err)
+			return mesnadaConfig, "", fmt.Errorf("failed to resolve workDir to absolute path: %w", err)
 		}
 	}
 
-	// Resolve relative paths from workDir
-	if !filepath.IsAbs(sourcePath) && absWorkDir != "" {
-		sourcePath = filepath.Join(absWorkDir, sourcePath)
-	}
+	var data []byte
+	if IsInlineMCPConfig(mcpConfig) {
+		data = []byte(mcpConfig)
+	} else {
+		// Handle @ prefix (file reference)
+		sourcePath := strings.TrimPrefix(mcpConfig, "@")
 
-	// Read source file
-	data, err := os.ReadFile(sourcePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read MCP config: %w", err)
+		// Resolve relative paths from workDir
+		if !filepath.IsAbs(sourcePath) && absWorkDir != "" {
+			sourcePath = filepath.Join(absWorkDir, sourcePath)
+		}
+
+		var err error
+		data, err = os.ReadFile(sourcePath)
+		if err != nil {
+			return mesnadaConfig, "", fmt.Errorf("failed to read MCP config: %w", err)
+		}
 	}
 
 	// Parse Mesnada format
-	var mesnadaConfig MesnadaMCPConfig
 	if err := json.Unmarshal(data, &mesnadaConfig); err != nil {
-		return "", fmt.Errorf("failed to parse MCP config: %w", err)
+		return mesnadaConfig, "", fmt.Errorf("failed to parse MCP config: %w", err)
+	}
+	return mesnadaConfig, absWorkDir, nil
+}
+
+// ConvertMCPConfig converts a Mesnada MCP config (file or inline JSON) to Claude CLI format.
+// It reads the source and returns the path to a temporary file with the converted config.
+// workDir is the working directory to resolve relative paths (should be task.WorkDir).
+func ConvertMCPConfig(mcpConfigPath, tempDir, workDir string) (string, error) {
+	mesnadaConfig, absWorkDir, err := loadMCPConfig(mcpConfigPath, workDir)
+	if err != nil {
+		return "", err
 	}
 
 	// Convert to Claude format
@@ -195,37 +242,9 @@ func CreateGeminiSettingsFile(mcpConfigPath, taskID, baseDir, workDir string) (s
 		return "", nil
 	}
 
-	// Handle @ prefix (file reference)
-	sourcePath := mcpConfigPath
-	if strings.HasPrefix(sourcePath, "@") {
-		sourcePath = sourcePath[1:]
-	}
-
-	// Convert workDir to absolute path if it's relative
-	absWorkDir := workDir
-	if workDir != "" && !filepath.IsAbs(workDir) {
-		var err error
-		absWorkDir, err = filepath.Abs(workDir)
-		if err != nil {
-			return "", fmt.Errorf("failed to resolve workDir to absolute path: %w", err)
-		}
-	}
-
-	// Resolve relative paths from workDir
-	if !filepath.IsAbs(sourcePath) && absWorkDir != "" {
-		sourcePath = filepath.Join(absWorkDir, sourcePath)
-	}
-
-	// Read source file
-	data, err := os.ReadFile(sourcePath)
+	mesnadaConfig, absWorkDir, err := loadMCPConfig(mcpConfigPath, workDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to read MCP config: %w", err)
-	}
-
-	// Parse Mesnada format
-	var mesnadaConfig MesnadaMCPConfig
-	if err := json.Unmarshal(data, &mesnadaConfig); err != nil {
-		return "", fmt.Errorf("failed to parse MCP config: %w", err)
+		return "", err
 	}
 
 	// Convert to Gemini format
@@ -331,37 +350,9 @@ func ConvertMCPConfigForOpenCode(mcpConfigPath, taskID, baseDir, workDir string)
 		return "", nil
 	}
 
-	// Handle @ prefix (file reference)
-	sourcePath := mcpConfigPath
-	if strings.HasPrefix(sourcePath, "@") {
-		sourcePath = sourcePath[1:]
-	}
-
-	// Convert workDir to absolute path if it's relative
-	absWorkDir := workDir
-	if workDir != "" && !filepath.IsAbs(workDir) {
-		var err error
-		absWorkDir, err = filepath.Abs(workDir)
-		if err != nil {
-			return "", fmt.Errorf("failed to resolve workDir to absolute path: %w", err)
-		}
-	}
-
-	// Resolve relative paths from workDir
-	if !filepath.IsAbs(sourcePath) && absWorkDir != "" {
-		sourcePath = filepath.Join(absWorkDir, sourcePath)
-	}
-
-	// Read source file
-	data, err := os.ReadFile(sourcePath)
+	mesnadaConfig, _, err := loadMCPConfig(mcpConfigPath, workDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to read MCP config: %w", err)
-	}
-
-	// Parse Mesnada format
-	var mesnadaConfig MesnadaMCPConfig
-	if err := json.Unmarshal(data, &mesnadaConfig); err != nil {
-		return "", fmt.Errorf("failed to parse MCP config: %w", err)
+		return "", err
 	}
 
 	// Convert to OpenCode format
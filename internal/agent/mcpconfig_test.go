@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const inlineMCPConfig = `{"mcpServers":{"docs":{"type":"local","command":"docs-mcp","args":["--stdio"],"cwd":"tools"},"remote":{"type":"http","url":"https://mcp.example/mcp"}}}`
+
+func TestConvertMCPConfig_InlineJSON(t *testing.T) {
+	baseDir := t.TempDir()
+
+	path, err := ConvertMCPConfigForTask("  "+inlineMCPConfig, "task-1", baseDir, "/repo")
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if want := filepath.Join(baseDir, "claude-mcp", "task-1"); !strings.HasPrefix(path, want) {
+		t.Fatalf("expected converted config under %s, got %s", want, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read converted config: %v", err)
+	}
+	var converted ClaudeMCPConfig
+	if err := json.Unmarshal(data, &converted); err != nil {
+		t.Fatalf("parse converted config: %v", err)
+	}
+	if got := converted.MCPServers["docs"]; got.Command != "docs-mcp" || got.Cwd != "/repo/tools" {
+		t.Fatalf("unexpected local server %+v", got)
+	}
+	if got := converted.MCPServers["remote"]; got.Command != "npx" {
+		t.Fatalf("expected http server bridged through mcp-remote, got %+v", got)
+	}
+}
+
+func TestConvertMCPConfigForOpenCode_FileAndInline(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "mcp.json"), []byte(inlineMCPConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range []string{"@mcp.json", inlineMCPConfig} {
+		path, err := ConvertMCPConfigForOpenCode(source, "task-2", t.TempDir(), workDir)
+		if err != nil {
+			t.Fatalf("convert %q: %v", source, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read converted config: %v", err)
+		}
+		if !strings.Contains(string(data), "docs-mcp") {
+			t.Fatalf("expected docs server in converted config, got %s", data)
+		}
+	}
+}
+
+func TestConvertMCPConfig_InvalidInlineJSON(t *testing.T) {
+	if _, err := ConvertMCPConfigForTask(`{"mcpServers":`, "task-3", t.TempDir(), ""); err == nil ||
+		!strings.Contains(err.Error(), "failed to parse MCP config") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func TestConvertClaudeMCPConfig_DoesNotLogInlineConfig(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	// Invalid, so the conversion fails and is logged.
+	task := &models.Task{ID: "task-4", MCPConfig: `{"mcpServers":{"api":{"headers":{"Authorization":"Bearer sk-secret"}}`}
+	if path, _ := convertClaudeMCPConfig(task, t.TempDir()); path != "" {
+		t.Fatalf("expected no config path, got %q", path)
+	}
+	if out := buf.String(); strings.Contains(out, "sk-secret") || !strings.Contains(out, "mcp_config=inline") {
+		t.Fatalf("expected the inline config not to be logged, got %q", out)
+	}
+}
+
+func TestPrepareCopilot_WritesInlineMCPConfigToFile(t *testing.T) {
+	logDir := t.TempDir()
+	inline := `{"mcpServers":{"api":{"type":"http","url":"https://mcp.example","headers":{"Authorization":"Bearer sk-secret"}}}}`
+	run, err := prepareCopilot(&models.Task{ID: "task-5", Prompt: "p", MCPConfig: inline}, logDir)
+	if err != nil {
+		t.Fatalf("prepareCopilot: %v", err)
+	}
+	args := strings.Join(run.args, " ")
+	if strings.Contains(args, "sk-secret") {
+		t.Fatalf("expected the inline config off the command line, got %v", run.args)
+	}
+	path := filepath.Join(logDir, "copilot-mcp", "task-5", "mcp-config.json")
+	if !strings.Contains(args, "--additional-mcp-config @"+path) {
+		t.Fatalf("expected the config passed as a file, got %v", run.args)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != inline {
+		t.Fatalf("expected the config written to %s, got %q (%v)", path, data, err)
+	}
+
+	run.cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected cleanup to remove the config file, got %v", err)
+	}
+
+	run, _ = prepareCopilot(&models.Task{ID: "task-6", Prompt: "p", MCPConfig: ".github/mcp-config.json"}, logDir)
+	if !strings.Contains(strings.Join(run.args, " "), "--additional-mcp-config @.github/mcp-config.json") {
+		t.Fatalf("expected a file reference to be passed as is, got %v", run.args)
+	}
+}
@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// newOpenCodeOutputParser parses `opencode run` text output.
+// OpenCode prints tool calls as "|  Tool   args" lines.
+func newOpenCodeOutputParser() outputParser {
+	return newTextOutputParser(classifyOpenCodeLine)
+}
+
+func classifyOpenCodeLine(line string) (models.TaskEventType, string) {
+	if rest, ok := strings.CutPrefix(line, "|"); ok {
+		if tool := firstWord(rest); tool != "" {
+			return models.TaskEventToolUse, tool
+		}
+	}
+	if strings.HasPrefix(line, "Error:") || strings.HasPrefix(line, "error:") {
+		return models.TaskEventError, ""
+	}
+	return "", ""
+}
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	// maxSummaryLines bounds the final summary kept from text output.
+	maxSummaryLines = 40
+	// maxEventMessage bounds the message stored in a single event.
+	maxEventMessage = 4000
+	// maxTaskSummary bounds Task.Summary.
+	maxTaskSummary = 4000
+)
+
+// outputParser normalizes engine text output into task events.
+type outputParser interface {
+	// ParseLine consumes one stdout line and returns the events it produced.
+	ParseLine(line string) []models.TaskEvent
+	// Finish returns the events that can only be known once output ends.
+	Finish() []models.TaskEvent
+	// Usage returns the input and output tokens reported so far.
+	Usage() (tokensIn, tokensOut int64)
+}
+
+// lineClassifier recognizes tool invocations and errors in one output line.
+// It returns the event type (empty for plain text) and the tool name.
+type lineClassifier func(line string) (models.TaskEventType, string)
+
+// textOutputParser is the shared parser for engines that print plain text.
+// The last paragraph of plain text is reported as the summary; token usage
+// lines are accumulated and kept out of it.
+type textOutputParser struct {
+	classify  lineClassifier
+	skip      func(line string) bool
+	usage     tokenUsage
+	paragraph []string
+	last      []string
+}
+
+func newTextOutputParser(classify lineClassifier) *textOutputParser {
+	return &textOutputParser{classify: classify}
+}
+
+func (p *textOutputParser) ParseLine(line string) []models.TaskEvent {
+	trimmed := strings.TrimSpace(line)
+	if p.usage.parseLine(trimmed) || (p.skip != nil && p.skip(trimmed)) {
+		return nil
+	}
+	eventType, tool := p.classify(trimmed)
+
+	switch eventType {
+	case models.TaskEventToolUse, models.TaskEventError:
+		return []models.TaskEvent{newTaskEvent(eventType, tool, trimmed)}
+	}
+
+	if trimmed == "" {
+		if len(p.paragraph) > 0 {
+			p.last = p.paragraph
+			p.paragraph = nil
+		}
+		return nil
+	}
+	if len(p.paragraph) < maxSummaryLines {
+		p.paragraph = append(p.paragraph, line)
+	}
+	return nil
+}
+
+func (p *textOutputParser) Finish() []models.TaskEvent {
+	summary := p.paragraph
+	if len(summary) == 0 {
+		summary = p.last
+	}
+	if len(summary) == 0 {
+		return nil
+	}
+	return []models.TaskEvent{newTaskEvent(models.TaskEventSummary, "", strings.Join(summary, "\n"))}
+}
+
+func (p *textOutputParser) Usage() (int64, int64) {
+	return p.usage.in, p.usage.out
+}
+
+// summaryFromEvents returns the message of the last summary event.
+func summaryFromEvents(events []models.TaskEvent) string {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == models.TaskEventSummary {
+			return strings.TrimSpace(events[i].Message)
+		}
+	}
+	return ""
+}
+
+func newTaskEvent(eventType models.TaskEventType, tool, message string) models.TaskEvent {
+	if len(message) > maxEventMessage {
+		message = message[:maxEventMessage]
+	}
+	return models.TaskEvent{Time: time.Now(), Type: eventType, Tool: tool, Message: message}
+}
+
+// eventOutput logs stdout unchanged and feeds it to an outputParser whose
+// events are written to the task event log.
+type eventOutput struct {
+	parser outputParser
+	events *eventLog
+}
+
+// newEventOutput opens the event log next to logPath and records it on the task.
+func newEventOutput(task *models.Task, logPath string, parser outputParser) *eventOutput {
+	events, err := openEventLog(eventsPath(logPath))
+	if err != nil {
+		slog.Error("failed to create event log", "task_id", task.ID, "error", err)
+	} else {
+		task.EventsFile = eventsPath(logPath)
+	}
+	return &eventOutput{parser: parser, events: events}
+}
+
+func (o *eventOutput) handleLine(line string) []string {
+	o.events.record(o.parser.ParseLine(line)...)
+	return []string{line}
+}
+
+func (o *eventOutput) finish(task *models.Task) {
+	final := o.parser.Finish()
+	o.events.record(final...)
+	task.Summary = summaryFromEvents(final)
+	task.TokensIn, task.TokensOut = o.parser.Usage()
+	o.events.close()
+}
+
+// eventLog appends task events as JSON lines next to the raw task log.
+// A nil eventLog discards events.
+type eventLog struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func openEventLog(path string) (*eventLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLog{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (l *eventLog) record(events ...models.TaskEvent) {
+	if l == nil || len(events) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, event := range events {
+		if err := l.enc.Encode(event); err != nil {
+			slog.Error("failed to write task event", "error", err)
+			return
+		}
+	}
+}
+
+func (l *eventLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.file.Close()
+}
+
+// eventsPath returns the event log path for a task log file.
+func eventsPath(logPath string) string {
+	return strings.TrimSuffix(logPath, ".log") + ".events.jsonl"
+}
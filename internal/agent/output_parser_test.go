@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func parseAll(p outputParser, lines ...string) []models.TaskEvent {
+	var events []models.TaskEvent
+	for _, line := range lines {
+		events = append(events, p.ParseLine(line)...)
+	}
+	return append(events, p.Finish()...)
+}
+
+func TestCopilotOutputParser(t *testing.T) {
+	events := parseAll(newCopilotOutputParser(),
+		"● Read main.go",
+		"✗ Run go test ./...",
+		"",
+		"All tests pass now.",
+		"The bug was in the parser.",
+		"",
+	)
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", events)
+	}
+	if events[0].Type != models.TaskEventToolUse || events[0].Tool != "Read" {
+		t.Fatalf("unexpected tool event: %+v", events[0])
+	}
+	if events[1].Type != models.TaskEventError || events[1].Tool != "Run" {
+		t.Fatalf("unexpected error event: %+v", events[1])
+	}
+	if events[2].Type != models.TaskEventSummary || events[2].Message != "All tests pass now.\nThe bug was in the parser." {
+		t.Fatalf("unexpected summary event: %+v", events[2])
+	}
+	if got := summaryFromEvents(events); got != events[2].Message {
+		t.Fatalf("expected summary from events, got %q", got)
+	}
+}
+
+func TestOpenCodeOutputParser(t *testing.T) {
+	events := parseAll(newOpenCodeOutputParser(),
+		"|  Bash     ls -la",
+		"Error: model not found",
+		"Final answer",
+	)
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", events)
+	}
+	if events[0].Tool != "Bash" || events[1].Type != models.TaskEventError || events[2].Message != "Final answer" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestGeminiOutputParser_NoOutput(t *testing.T) {
+	if events := parseAll(newGeminiOutputParser()); len(events) != 0 {
+		t.Fatalf("expected no events, got %+v", events)
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	path := eventsPath(filepath.Join(t.TempDir(), "task-1.log"))
+	if filepath.Base(path) != "task-1.events.jsonl" {
+		t.Fatalf("unexpected events path: %s", path)
+	}
+
+	l, err := openEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.record(newTaskEvent(models.TaskEventToolUse, "Read", "● Read main.go"))
+	l.close()
+
+	var nilLog *eventLog
+	nilLog.record(newTaskEvent(models.TaskEventError, "", "ignored"))
+	nilLog.close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var got []models.TaskEvent
+	for scanner.Scan() {
+		var ev models.TaskEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ev)
+	}
+	if len(got) != 1 || got[0].Tool != "Read" {
+		t.Fatalf("unexpected events in file: %+v", got)
+	}
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// outputDrainTimeout is how long to wait for output after the agent exits
+// before killing its process group to release inherited pipes.
+var outputDrainTimeout = 5 * time.Second
+
+// outputPipe is the read end of an os.Pipe attached to a command. Unlike
+// cmd.StdoutPipe, it is not closed by cmd.Wait, so output written before the
+// process exits is never lost. The parent's write end is closed on the first
+// read, which happens after Start.
+type outputPipe struct {
+	r         *os.File
+	w         *os.File
+	closeOnce sync.Once
+}
+
+func newOutputPipe() (*outputPipe, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &outputPipe{r: r, w: w}, nil
+}
+
+func (p *outputPipe) Read(b []byte) (int, error) {
+	p.closeOnce.Do(func() { p.w.Close() })
+	n, err := p.r.Read(b)
+	if err == io.EOF {
+		p.r.Close()
+	}
+	return n, err
+}
+
+func (p *outputPipe) Close() error {
+	p.closeOnce.Do(func() { p.w.Close() })
+	return p.r.Close()
+}
+
+// stderrPipe returns a reader for the command's stderr that survives cmd.Wait.
+func stderrPipe(cmd *exec.Cmd) (io.ReadCloser, error) {
+	p, err := newOutputPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = p.w
+	return p, nil
+}
+
+// captureStderr reports whether stderr of an engine is written to the task log.
+// Gemini prints startup noise on stderr, so it keeps it discarded unless asked.
+func (c Config) captureStderr(engine models.Engine) bool {
+	if capture := c.engineConfig(engine).CaptureStderr; capture != nil {
+		return *capture
+	}
+	return engine != models.EngineGemini
+}
+
+// awaitOutput waits for output capture to finish after the process exited.
+// Children that outlive the agent keep the pipes open; the process group is
+// killed so capture can finish.
+func awaitOutput(cmd *exec.Cmd, taskID string, captured <-chan struct{}) {
+	select {
+	case <-captured:
+		return
+	case <-time.After(outputDrainTimeout):
+	}
+
+	log.Printf("task_event=orphans_killed task_id=%s reason=%q", taskID, "output still open after exit")
+	killProcess(cmd)
+
+	select {
+	case <-captured:
+	case <-time.After(outputDrainTimeout):
+		log.Printf("task_event=output_abandoned task_id=%s", taskID)
+	}
+}
@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	engineProbeTimeout = 10 * time.Second
+	maxVersionLength   = 200
+)
+
+// defaultEngineBinaries maps each engine to the executable its spawner runs.
+var defaultEngineBinaries = map[models.Engine]string{
+	models.EngineCopilot:        "copilot",
+	models.EngineClaude:         "claude",
+	models.EngineGemini:         "gemini",
+	models.EngineOpenCode:       "opencode",
+	models.EngineOllamaClaude:   "claude",
+	models.EngineOllamaOpenCode: "opencode",
+}
+
+// probeEngine runs `<binary> --version` and reports whether the engine is usable.
+func (c Config) probeEngine(ctx context.Context, engine models.Engine) models.EngineInfo {
+	binary, args := c.engineCommand(engine, defaultEngineBinaries[engine], nil)
+	info := models.EngineInfo{
+		Engine:    engine,
+		Binary:    binary,
+		CheckedAt: time.Now(),
+	}
+
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, engineProbeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, append(args, "--version")...).CombinedOutput()
+	if err != nil {
+		info.Error = strings.TrimSpace(err.Error() + ": " + firstLine(string(out)))
+		return info
+	}
+
+	info.Available = true
+	info.Version = firstLine(string(out))
+	info.SupportedModels = c.probeModels(ctx, engine, path, args)
+	return info
+}
+
+// modelListArgs are the subcommands that make an engine CLI print the
+// models it can run, one per line.
+var modelListArgs = map[models.Engine][]string{
+	models.EngineCopilot:  {"models", "list"},
+	models.EngineOpenCode: {"models"},
+}
+
+// probeModels asks an installed engine which models it can run. The Ollama
+// engines ask their server; engines that cannot list models, or fail to,
+// report none and spawn_agent falls back to the configured models.
+func (c Config) probeModels(ctx context.Context, engine models.Engine, path string, args []string) []string {
+	switch engine {
+	case models.EngineOllamaClaude, models.EngineOllamaOpenCode:
+		ids, err := ollamaModels(ctx, c.engineEndpoint(engine, ollamaEndpoint).baseURL)
+		if err != nil {
+			slog.Warn("engine_event=models_failed", "engine", engine, "error", err.Error())
+		}
+		return ids
+	}
+	listArgs, ok := modelListArgs[engine]
+	if !ok {
+		return nil
+	}
+	out, err := exec.CommandContext(ctx, path, append(args, listArgs...)...).Output()
+	if err != nil {
+		slog.Warn("engine_event=models_failed", "engine", engine, "error", err.Error())
+		return nil
+	}
+	return parseModelList(string(out))
+}
+
+// parseModelList reads the first word of each line of a model listing,
+// skipping blank lines, comments, headings ("Available models:") and table
+// headers ("NAME", "MODEL", "ID").
+func parseModelList(out string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(strings.TrimLeft(line, " \t-*•"))
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		id := fields[0]
+		if strings.HasSuffix(strings.TrimSpace(line), ":") {
+			continue
+		}
+		switch strings.ToUpper(id) {
+		case "NAME", "MODEL", "MODELS", "ID":
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ollamaModels lists the models pulled on an Ollama server via /api/tags.
+func ollamaModels(ctx context.Context, baseURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", req.URL, resp.Status)
+	}
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("invalid response from %s: %w", req.URL, err)
+	}
+	ids := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		if m.Name != "" {
+			ids = append(ids, m.Name)
+		}
+	}
+	return ids, nil
+}
+
+// ProbeEngines checks every engine concurrently and caches the results.
+func (m *Manager) ProbeEngines(ctx context.Context) []models.EngineInfo {
+	engines := models.AllEngines()
+	infos := make([]models.EngineInfo, len(engines))
+
+	var wg sync.WaitGroup
+	for i, engine := range engines {
+		wg.Add(1)
+		go func(i int, engine models.Engine) {
+			defer wg.Done()
+			infos[i] = m.config.probeEngine(ctx, engine)
+		}(i, engine)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	m.engineInfo = infos
+	m.mu.Unlock()
+
+	return infos
+}
+
+// EnginesProbed reports whether the engines have been probed at least once.
+func (m *Manager) EnginesProbed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.engineInfo != nil
+}
+
+// Engines returns the last probe results, probing first if none exist yet.
+func (m *Manager) Engines(ctx context.Context) []models.EngineInfo {
+	m.mu.RLock()
+	infos := m.engineInfo
+	m.mu.RUnlock()
+
+	if infos == nil {
+		return m.ProbeEngines(ctx)
+	}
+	return append([]models.EngineInfo(nil), infos...)
+}
+
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if len(line) > maxVersionLength {
+				line = line[:maxVersionLength]
+			}
+			return line
+		}
+	}
+	return ""
+}
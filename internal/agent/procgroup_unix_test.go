@@ -0,0 +1,86 @@
+//go:build !windows
+
+package agent
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestKillProcess_KillsChildren(t *testing.T) {
+	cmd := exec.CommandContext(context.Background(), "sh", "-c", "sleep 30 & echo $!; wait")
+	setProcessGroup(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read child pid: %v", err)
+	}
+	childPID, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		t.Fatalf("parse child pid %q: %v", line, err)
+	}
+
+	if err := killProcess(cmd); err != nil {
+		t.Fatalf("killProcess: %v", err)
+	}
+	cmd.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for syscall.Kill(childPID, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("child process %d survived group kill", childPID)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestAwaitOutput_KillsOrphansHoldingPipes(t *testing.T) {
+	prev := outputDrainTimeout
+	outputDrainTimeout = 200 * time.Millisecond
+	defer func() { outputDrainTimeout = prev }()
+
+	cmd := exec.CommandContext(context.Background(), "sh", "-c", "sleep 30 & echo started")
+	setProcessGroup(cmd)
+	stdout, err := (Config{}).stdoutPipe(testTask(), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	captured := make(chan struct{})
+	var out []byte
+	go func() {
+		defer close(captured)
+		out, _ = io.ReadAll(stdout)
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	awaitOutput(cmd, "task-orphan", captured)
+
+	select {
+	case <-captured:
+	default:
+		t.Fatal("expected output capture to finish after orphans were killed")
+	}
+	if strings.TrimSpace(string(out)) != "started" {
+		t.Fatalf("expected output to be preserved, got %q", out)
+	}
+}
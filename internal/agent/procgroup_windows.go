@@ -0,0 +1,23 @@
+//go:build windows
+
+package agent
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows; only the agent process is managed.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess stops the command. Windows has no SIGTERM, so it is killed.
+func terminateProcess(cmd *exec.Cmd) error {
+	return killProcess(cmd)
+}
+
+// killProcess kills the command.
+func killProcess(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// stdoutPipe returns the reader for the command's stdout; like stderrPipe it
+// is not closed by cmd.Wait. When the engine
+// sets use_pty, stdout is a pseudo-terminal so CLIs that buffer or change
+// behavior when piped stream output in real time. It must be called before Start.
+func (c Config) stdoutPipe(task *models.Task, cmd *exec.Cmd) (io.ReadCloser, error) {
+	if !c.engineConfig(task.Engine).UsePTY {
+		p, err := newOutputPipe()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdout = p.w
+		return p, nil
+	}
+	return ptyStdoutPipe(cmd)
+}
+
+// ptyReader reads a pseudo-terminal master as a plain stream. The parent's
+// copy of the terminal is closed on the first read (after Start), so the
+// master reports EOF once the process and its children exit.
+type ptyReader struct {
+	ptmx      *os.File
+	tty       *os.File
+	closeOnce sync.Once
+}
+
+func (r *ptyReader) Read(p []byte) (int, error) {
+	r.closeOnce.Do(func() { r.tty.Close() })
+
+	n, err := r.ptmx.Read(p)
+	if err != nil {
+		// Linux returns EIO instead of EOF once the terminal has no writers.
+		if errors.Is(err, syscall.EIO) || errors.Is(err, os.ErrClosed) {
+			err = io.EOF
+		}
+		if err == io.EOF {
+			r.ptmx.Close()
+		}
+	}
+	// Terminals translate "\n" to "\r\n"; drop the carriage returns.
+	if n > 0 {
+		n = copy(p, bytes.ReplaceAll(p[:n], []byte("\r"), nil))
+	}
+	return n, err
+}
+
+func (r *ptyReader) Close() error {
+	r.closeOnce.Do(func() { r.tty.Close() })
+	return r.ptmx.Close()
+}
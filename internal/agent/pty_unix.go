@@ -0,0 +1,37 @@
+//go:build !windows
+
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// ptyStdoutPipe attaches a new pseudo-terminal to the command's stdout and
+// makes it the controlling terminal. Stdin and stderr are left untouched.
+func ptyStdoutPipe(cmd *exec.Cmd) (io.ReadCloser, error) {
+	if cmd.Stdout != nil {
+		return nil, fmt.Errorf("stdout already set")
+	}
+
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+
+	cmd.Stdout = tty
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// A new session is also a new process group; Setpgid would fail with EPERM.
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 1 // child's stdout
+
+	return &ptyReader{ptmx: ptmx, tty: tty}, nil
+}
@@ -0,0 +1,63 @@
+//go:build !windows
+
+package agent
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestStdoutPipe_UsePTY(t *testing.T) {
+	cfg := Config{Engines: map[string]config.EngineConfig{"gemini": {UsePTY: true}}}
+	task := &models.Task{ID: "task-pty", Engine: models.EngineGemini}
+
+	cmd := exec.Command("sh", "-c", `if [ -t 1 ]; then echo tty; else echo pipe; fi; echo done`)
+	stdout, err := cfg.stdoutPipe(task, cmd)
+	if err != nil {
+		t.Fatalf("stdoutPipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	out, err := io.ReadAll(stdout)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	if got := string(out); got != "tty\ndone\n" {
+		t.Fatalf("expected tty output without carriage returns, got %q", got)
+	}
+}
+
+func TestStdoutPipe_DefaultPipe(t *testing.T) {
+	cfg := Config{}
+	task := &models.Task{ID: "task-pipe", Engine: models.EngineGemini}
+
+	cmd := exec.Command("sh", "-c", `if [ -t 1 ]; then echo tty; else echo pipe; fi`)
+	stdout, err := cfg.stdoutPipe(task, cmd)
+	if err != nil {
+		t.Fatalf("stdoutPipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	out, _ := io.ReadAll(stdout)
+	cmd.Wait()
+
+	if strings.TrimSpace(string(out)) != "pipe" {
+		t.Fatalf("expected pipe output, got %q", out)
+	}
+}
+
+func testTask() *models.Task {
+	return &models.Task{ID: "task-test", Engine: models.EngineCopilot}
+}
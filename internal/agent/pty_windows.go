@@ -0,0 +1,14 @@
+//go:build windows
+
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// ptyStdoutPipe is not supported on Windows.
+func ptyStdoutPipe(cmd *exec.Cmd) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("use_pty is not supported on windows")
+}
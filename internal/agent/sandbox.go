@@ -0,0 +1,203 @@
+// Package agent handles spawning and managing CLI agent processes.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	dockerBinary           = "docker"
+	defaultSandboxNetwork  = "bridge"
+	sandboxContainerPrefix = "mesnada-"
+)
+
+// Config holds settings shared by all engine spawners.
+type Config struct {
+	LogDir  string
+	Sandbox config.SandboxConfig
+	Engines map[string]config.EngineConfig
+	Env     config.EnvConfig
+	Secrets map[string]config.SecretConfig
+	// MaxLogSize rotates a task log once it reaches this many bytes (0 disables).
+	MaxLogSize int64
+	// CompressLogs gzips logs of tasks that reached a terminal state.
+	CompressLogs bool
+}
+
+// engineConfig returns the configuration for an engine (zero value if not configured).
+func (c Config) engineConfig(engine models.Engine) config.EngineConfig {
+	if engine == "" {
+		engine = models.DefaultEngine()
+	}
+	return c.Engines[string(engine)]
+}
+
+// configuredSandbox returns the sandbox mode the config sets for an engine:
+// the engine setting, then the orchestrator default. An engine with a pinned
+// container image runs in docker unless told otherwise.
+func (c Config) configuredSandbox(engine models.Engine) string {
+	engineCfg := c.engineConfig(engine)
+	if engineCfg.Sandbox != "" {
+		return engineCfg.Sandbox
+	}
+	if engineCfg.ContainerImage != "" {
+		return models.SandboxDocker
+	}
+	if c.Sandbox.Mode != "" {
+		return c.Sandbox.Mode
+	}
+	return models.SandboxNone
+}
+
+// resolveSandbox returns the sandbox mode for a task. A task may sandbox an
+// engine the config runs on the host, but not change the sandbox the config
+// sets: an agent inside it could otherwise spawn itself out of it.
+func (c Config) resolveSandbox(task *models.Task) (string, error) {
+	configured := c.configuredSandbox(task.Engine)
+	if task.Sandbox == "" || task.Sandbox == configured {
+		return configured, nil
+	}
+	if configured != models.SandboxNone {
+		return "", fmt.Errorf("sandbox %q not allowed: the configured %q sandbox cannot be overridden", task.Sandbox, configured)
+	}
+	return task.Sandbox, nil
+}
+
+// CheckSandbox reports whether a task may run with the sandbox it asks for.
+func (m *Manager) CheckSandbox(task *models.Task) error {
+	_, err := m.config.resolveSandbox(task)
+	return err
+}
+
+// engineCommand applies the engine's configured binary and default args.
+func (c Config) engineCommand(engine models.Engine, binary string, args []string) (string, []string) {
+	engineCfg := c.engineConfig(engine)
+	if engineCfg.Binary != "" {
+		binary = engineCfg.Binary
+	}
+	if len(engineCfg.DefaultArgs) > 0 {
+		args = append(append([]string{}, engineCfg.DefaultArgs...), args...)
+	}
+	return binary, args
+}
+
+// newAgentCommand creates the command that runs an engine binary for a task.
+// env holds the variables the engine needs on top of the host environment,
+// and engineSecrets those whose values must stay off command lines. When the
+// task is sandboxed, the binary runs inside a Docker container and only env
+// and secrets are forwarded into it.
+func (c Config) newAgentCommand(ctx context.Context, task *models.Task, binary string, args, env, engineSecrets []string) (*exec.Cmd, error) {
+	sandbox, err := c.resolveSandbox(task)
+	if err != nil {
+		return nil, err
+	}
+	task.Sandbox = sandbox
+	binary, args = c.engineCommand(task.Engine, binary, args)
+
+	secretEnv, err := c.secretEnv(task)
+	if err != nil {
+		return nil, err
+	}
+	secretEnv = append(append([]string{}, engineSecrets...), secretEnv...)
+
+	switch task.Sandbox {
+	case models.SandboxNone:
+		cmd := exec.CommandContext(ctx, binary, args...)
+		cmd.Dir = task.WorkDir
+		cmd.Env = append(append(c.environ(), env...), secretEnv...)
+		setProcessGroup(cmd)
+		return cmd, nil
+	case models.SandboxDocker:
+		// Secrets are forwarded by name (`-e NAME`) so values never appear in the docker command line.
+		dockerArgs, err := c.dockerArgs(task, binary, args, append(env, envNames(secretEnv)...))
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("task_event=sandboxed task_id=%s sandbox=docker image=%q", task.ID, c.containerImage(task.Engine))
+		cmd := exec.CommandContext(ctx, dockerBinary, dockerArgs...)
+		cmd.Dir = task.WorkDir
+		cmd.Env = append(os.Environ(), secretEnv...)
+		setProcessGroup(cmd)
+		return cmd, nil
+	case models.SandboxKubernetes:
+		return c.newKubernetesCommand(ctx, task, binary, args, append(env, secretEnv...))
+	default:
+		return nil, fmt.Errorf("invalid sandbox mode: %s (valid: none, docker, kubernetes)", task.Sandbox)
+	}
+}
+
+// dockerArgs builds the `docker run` arguments for a sandboxed task.
+// The work directory and log directory are bind-mounted at their host paths
+// so converted MCP configs and relative paths keep working inside the container.
+func (c Config) dockerArgs(task *models.Task, binary string, args, env []string) ([]string, error) {
+	image := c.containerImage(task.Engine)
+	if image == "" {
+		return nil, fmt.Errorf("docker sandbox requires orchestrator.sandbox.image or engines.%s.container_image to be set", task.Engine)
+	}
+
+	workDir, err := filepath.Abs(task.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve work dir: %w", err)
+	}
+
+	network := c.Sandbox.Network
+	if network == "" {
+		network = defaultSandboxNetwork
+	}
+
+	dockerArgs := []string{
+		"run", "--rm", "-i",
+		"--name", sandboxContainerName(task.ID),
+		"--network", network,
+		"-v", fmt.Sprintf("%s:%s", workDir, workDir),
+		"-w", workDir,
+	}
+	if c.LogDir != "" {
+		dockerArgs = append(dockerArgs, "-v", fmt.Sprintf("%s:%s", c.LogDir, c.LogDir))
+	}
+	for _, kv := range env {
+		dockerArgs = append(dockerArgs, "-e", kv)
+	}
+	dockerArgs = append(dockerArgs, c.Sandbox.ExtraArgs...)
+	dockerArgs = append(dockerArgs, c.engineConfig(task.Engine).ContainerArgs...)
+	dockerArgs = append(dockerArgs, image, binary)
+	dockerArgs = append(dockerArgs, args...)
+
+	return dockerArgs, nil
+}
+
+// containerImage returns the image used to sandbox an engine.
+// The engine's container_image takes precedence over the orchestrator image.
+func (c Config) containerImage(engine models.Engine) string {
+	if image := c.engineConfig(engine).ContainerImage; image != "" {
+		return image
+	}
+	return c.Sandbox.Image
+}
+
+// finishSandbox releases the sandbox of a task once its command has exited.
+// It returns the error that decides the task status: for Kubernetes Jobs the
+// pod phase is authoritative, since kubectl attach does not report exit codes.
+func (c Config) finishSandbox(task *models.Task, err error) error {
+	switch task.Sandbox {
+	case models.SandboxDocker:
+		// `docker run --rm` removes the container on normal exit; this covers killed clients.
+		cmd := exec.Command(dockerBinary, "rm", "-f", sandboxContainerName(task.ID))
+		_ = cmd.Run()
+	case models.SandboxKubernetes:
+		return c.finishKubernetesJob(task, err)
+	}
+	return err
+}
+
+func sandboxContainerName(taskID string) string {
+	return sandboxContainerPrefix + taskID
+}
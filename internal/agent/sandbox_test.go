@@ -0,0 +1,232 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestResolveSandbox_Precedence(t *testing.T) {
+	cfg := Config{
+		Sandbox: config.SandboxConfig{Mode: models.SandboxNone},
+		Engines: map[string]config.EngineConfig{
+			"claude": {Sandbox: models.SandboxDocker},
+		},
+	}
+
+	if got, _ := cfg.resolveSandbox(&models.Task{Engine: models.EngineCopilot}); got != models.SandboxNone {
+		t.Fatalf("expected orchestrator default %q, got %q", models.SandboxNone, got)
+	}
+	if got, _ := cfg.resolveSandbox(&models.Task{Engine: models.EngineClaude}); got != models.SandboxDocker {
+		t.Fatalf("expected engine sandbox %q, got %q", models.SandboxDocker, got)
+	}
+	task := &models.Task{Engine: models.EngineCopilot, Sandbox: models.SandboxDocker}
+	if got, err := cfg.resolveSandbox(task); err != nil || got != models.SandboxDocker {
+		t.Fatalf("expected a task to sandbox a host engine, got %q (%v)", got, err)
+	}
+	task = &models.Task{Engine: models.EngineClaude, Sandbox: models.SandboxDocker}
+	if got, err := cfg.resolveSandbox(task); err != nil || got != models.SandboxDocker {
+		t.Fatalf("expected the configured sandbox to be accepted, got %q (%v)", got, err)
+	}
+}
+
+func TestResolveSandbox_RejectsOverride(t *testing.T) {
+	cfg := Config{
+		Sandbox: config.SandboxConfig{Mode: models.SandboxKubernetes},
+		Engines: map[string]config.EngineConfig{
+			"claude": {Sandbox: models.SandboxDocker},
+		},
+	}
+
+	for _, task := range []*models.Task{
+		{Engine: models.EngineClaude, Sandbox: models.SandboxNone},
+		{Engine: models.EngineCopilot, Sandbox: models.SandboxNone},
+		{Engine: models.EngineClaude, Sandbox: models.SandboxKubernetes},
+	} {
+		if _, err := cfg.resolveSandbox(task); err == nil {
+			t.Fatalf("expected %s to refuse sandbox %q", task.Engine, task.Sandbox)
+		}
+	}
+	task := &models.Task{ID: "task-1", Engine: models.EngineClaude, Sandbox: models.SandboxNone, WorkDir: t.TempDir()}
+	if _, err := cfg.newAgentCommand(context.Background(), task, "claude", nil, nil, nil); err == nil || !strings.Contains(err.Error(), "cannot be overridden") {
+		t.Fatalf("expected newAgentCommand to refuse the downgrade, got %v", err)
+	}
+}
+
+func TestDockerArgs(t *testing.T) {
+	cfg := Config{
+		LogDir: "/var/log/mesnada",
+		Sandbox: config.SandboxConfig{
+			Image:     "ghcr.io/example/agents:1",
+			Network:   "none",
+			ExtraArgs: []string{"--memory", "2g"},
+		},
+	}
+	task := &models.Task{ID: "task-1234", WorkDir: "/repo"}
+
+	args, err := cfg.dockerArgs(task, "claude", []string{"--print", "hi"}, []string{"NO_COLOR=1"})
+	if err != nil {
+		t.Fatalf("dockerArgs: %v", err)
+	}
+
+	joined := strings.Join(args, " ")
+	for _, want := range []string{
+		"run --rm -i --name mesnada-task-1234",
+		"--network none",
+		"-v /repo:/repo -w /repo",
+		"-v /var/log/mesnada:/var/log/mesnada",
+		"-e NO_COLOR=1",
+		"--memory 2g ghcr.io/example/agents:1 claude --print hi",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in docker args, got %q", want, joined)
+		}
+	}
+}
+
+func TestDockerArgs_RequiresImage(t *testing.T) {
+	cfg := Config{}
+	if _, err := cfg.dockerArgs(&models.Task{ID: "task-1", WorkDir: "/repo"}, "claude", nil, nil); err == nil {
+		t.Fatal("expected error when sandbox image is not configured")
+	}
+}
+
+func TestEngineContainerImage(t *testing.T) {
+	cfg := Config{
+		Sandbox: config.SandboxConfig{Image: "global:1", ExtraArgs: []string{"--cpus", "2"}},
+		Engines: map[string]config.EngineConfig{
+			"claude": {ContainerImage: "claude:pinned", ContainerArgs: []string{"--memory", "4g"}},
+		},
+	}
+
+	claudeTask := &models.Task{ID: "task-1", Engine: models.EngineClaude, WorkDir: "/repo"}
+	if got, _ := cfg.resolveSandbox(claudeTask); got != models.SandboxDocker {
+		t.Fatalf("expected container_image to imply %q, got %q", models.SandboxDocker, got)
+	}
+	if got, _ := cfg.resolveSandbox(&models.Task{Engine: models.EngineCopilot}); got != models.SandboxNone {
+		t.Fatalf("expected copilot to run on host, got %q", got)
+	}
+
+	args, err := cfg.dockerArgs(claudeTask, "claude", nil, nil)
+	if err != nil {
+		t.Fatalf("dockerArgs: %v", err)
+	}
+	joined := strings.Join(args, " ")
+	if !strings.HasSuffix(joined, "--cpus 2 --memory 4g claude:pinned claude") {
+		t.Fatalf("expected engine image and args, got %q", joined)
+	}
+}
+
+func TestEngineCommand(t *testing.T) {
+	cfg := Config{
+		Engines: map[string]config.EngineConfig{
+			"gemini": {Binary: "/opt/gemini/bin/gemini", DefaultArgs: []string{"--sandbox=false"}},
+		},
+	}
+
+	binary, args := cfg.engineCommand(models.EngineGemini, "gemini", []string{"-p", "hi"})
+	if binary != "/opt/gemini/bin/gemini" {
+		t.Fatalf("expected configured binary, got %q", binary)
+	}
+	if strings.Join(args, " ") != "--sandbox=false -p hi" {
+		t.Fatalf("expected default args first, got %v", args)
+	}
+
+	binary, args = cfg.engineCommand(models.EngineClaude, "claude", []string{"-p"})
+	if binary != "claude" || len(args) != 1 {
+		t.Fatalf("expected unchanged command, got %q %v", binary, args)
+	}
+}
+
+func TestProbeEngine_MissingBinary(t *testing.T) {
+	cfg := Config{
+		Engines: map[string]config.EngineConfig{
+			"gemini": {Binary: "mesnada-test-missing-binary"},
+		},
+	}
+
+	info := cfg.probeEngine(context.Background(), models.EngineGemini)
+	if info.Available || info.Error == "" {
+		t.Fatalf("expected unavailable engine with error, got %+v", info)
+	}
+	if info.Binary != "mesnada-test-missing-binary" {
+		t.Fatalf("expected configured binary, got %q", info.Binary)
+	}
+}
+
+func TestProbeEngine_Version(t *testing.T) {
+	cfg := Config{
+		Engines: map[string]config.EngineConfig{
+			"claude": {Binary: "echo", DefaultArgs: []string{"claude", "1.2.3"}},
+		},
+	}
+
+	info := cfg.probeEngine(context.Background(), models.EngineClaude)
+	if !info.Available || info.Version != "claude 1.2.3 --version" {
+		t.Fatalf("expected available engine with version, got %+v", info)
+	}
+}
+
+func TestProbeEngine_Models(t *testing.T) {
+	script := `case "$1" in --version) echo opencode 1.0 ;; models) printf 'Available models:\nanthropic/claude-sonnet-4\n\nopenai/gpt-5\n' ;; esac`
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"models":[{"name":"llama3:latest"},{"name":"qwen3:8b"}]}`))
+	}))
+	defer ollama.Close()
+
+	cfg := Config{
+		Engines: map[string]config.EngineConfig{
+			"opencode":      {Binary: "sh", DefaultArgs: []string{"-c", script, "sh"}},
+			"ollama-claude": {Binary: "echo", BaseURL: ollama.URL},
+			"claude":        {Binary: "echo"},
+		},
+	}
+
+	info := cfg.probeEngine(context.Background(), models.EngineOpenCode)
+	if !info.Available || !slices.Equal(info.SupportedModels, []string{"anthropic/claude-sonnet-4", "openai/gpt-5"}) {
+		t.Fatalf("expected the listed models, got %+v", info)
+	}
+	info = cfg.probeEngine(context.Background(), models.EngineOllamaClaude)
+	if !slices.Equal(info.SupportedModels, []string{"llama3:latest", "qwen3:8b"}) {
+		t.Fatalf("expected the pulled Ollama models, got %+v", info)
+	}
+	// Engines that cannot list models report none.
+	info = cfg.probeEngine(context.Background(), models.EngineClaude)
+	if !info.Available || info.SupportedModels != nil {
+		t.Fatalf("expected no models, got %+v", info)
+	}
+}
+
+func TestCaptureStderr(t *testing.T) {
+	off := false
+	on := true
+	cfg := Config{
+		Engines: map[string]config.EngineConfig{
+			"opencode": {CaptureStderr: &off},
+			"gemini":   {CaptureStderr: &on},
+		},
+	}
+
+	if !cfg.captureStderr(models.EngineClaude) {
+		t.Fatal("expected stderr capture by default")
+	}
+	if cfg.captureStderr(models.EngineOpenCode) {
+		t.Fatal("expected capture_stderr: false to disable capture")
+	}
+	if !cfg.captureStderr(models.EngineGemini) {
+		t.Fatal("expected capture_stderr: true to enable gemini capture")
+	}
+	if (Config{}).captureStderr(models.EngineGemini) {
+		t.Fatal("expected gemini stderr to stay discarded by default")
+	}
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// secretEnv resolves the secrets referenced by a task into KEY=VALUE entries.
+// Values are read at spawn time and never written to the task.
+func (c Config) secretEnv(task *models.Task) ([]string, error) {
+	env := make([]string, 0, len(task.Secrets))
+	for _, name := range task.Secrets {
+		secret, ok := c.Secrets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown secret: %s", name)
+		}
+		value, err := secret.Value()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		env = append(env, secret.EnvName(name)+"="+value)
+	}
+	return env, nil
+}
+
+// envNames returns the variable names of KEY=VALUE entries.
+func envNames(env []string) []string {
+	names := make([]string, len(env))
+	for i, kv := range env {
+		names[i], _, _ = strings.Cut(kv, "=")
+	}
+	return names
+}
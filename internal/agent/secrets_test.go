@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestSecretEnv(t *testing.T) {
+	t.Setenv("MESNADA_TEST_GH", "ghp_secret")
+	cfg := Config{
+		Sandbox: config.SandboxConfig{Image: "agents:1"},
+		Secrets: map[string]config.SecretConfig{
+			"github": {Env: "MESNADA_TEST_GH", As: "GITHUB_TOKEN"},
+		},
+	}
+
+	task := &models.Task{ID: "task-s", WorkDir: "/tmp", Secrets: []string{"github"}}
+	env, err := cfg.secretEnv(task)
+	if err != nil {
+		t.Fatalf("secretEnv: %v", err)
+	}
+	if len(env) != 1 || env[0] != "GITHUB_TOKEN=ghp_secret" {
+		t.Fatalf("unexpected secret env: %v", env)
+	}
+
+	task.Sandbox = models.SandboxDocker
+	cmd, err := cfg.newAgentCommand(context.Background(), task, "claude", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("newAgentCommand: %v", err)
+	}
+	if strings.Contains(strings.Join(cmd.Args, " "), "ghp_secret") {
+		t.Fatalf("secret value leaked into docker args: %v", cmd.Args)
+	}
+	if !strings.Contains(strings.Join(cmd.Args, " "), "-e GITHUB_TOKEN") {
+		t.Fatalf("expected secret forwarded by name, got %v", cmd.Args)
+	}
+
+	if _, err := cfg.secretEnv(&models.Task{Secrets: []string{"missing"}}); err == nil {
+		t.Fatal("expected error for unknown secret")
+	}
+}
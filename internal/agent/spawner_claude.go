@@ -2,174 +2,48 @@
 package agent
 
 import (
-	"bufio"
-	"context"
-	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
-	"sync"
-	"syscall"
-	"time"
 
 	"github.com/sevir/mesnada/pkg/models"
 )
 
 // ClaudeSpawner manages Claude CLI process spawning.
 type ClaudeSpawner struct {
-	logDir     string
-	processes  map[string]*ClaudeProcess
-	mu         sync.RWMutex
-	onComplete func(task *models.Task)
-}
-
-// ClaudeProcess represents a running Claude CLI process.
-type ClaudeProcess struct {
-	cmd        *exec.Cmd
-	task       *models.Task
-	output     *strings.Builder
-	logFile    *os.File
-	cancel     context.CancelFunc
-	done       chan struct{}
-	mcpTempDir string // Temp dir for converted MCP config
+	*BaseSpawner
 }
 
 // NewClaudeSpawner creates a new Claude CLI agent spawner.
-func NewClaudeSpawner(logDir string, onComplete func(task *models.Task)) *ClaudeSpawner {
-	if logDir == "" {
-		home, _ := os.UserHomeDir()
-		logDir = filepath.Join(home, defaultLogDir)
-	}
-	if abs, err := filepath.Abs(logDir); err == nil {
-		logDir = abs
-	}
-	os.MkdirAll(logDir, 0755)
-
-	return &ClaudeSpawner{
-		logDir:     logDir,
-		processes:  make(map[string]*ClaudeProcess),
-		onComplete: onComplete,
-	}
+// With engines.claude.base_url set, the CLI is routed to that Anthropic-compatible gateway.
+func NewClaudeSpawner(cfg Config, onComplete func(task *models.Task)) *ClaudeSpawner {
+	prepare := prepareClaude
+	if ep := cfg.engineEndpoint(models.EngineClaude, endpoint{}); ep.configured() {
+		prepare = withAnthropicEndpoint(prepareClaude, ep)
+	}
+	return &ClaudeSpawner{newBaseSpawner(cfg, engineSpec{
+		engine:  models.EngineClaude,
+		prepare: prepare,
+		maxLine: maxStreamJSONLine,
+		newOutput: func(*models.Task, string) outputHandler {
+			return NewClaudeOutputParser()
+		},
+	}, onComplete)}
 }
 
-// Spawn starts a new Claude CLI agent.
-func (s *ClaudeSpawner) Spawn(ctx context.Context, task *models.Task) error {
-	// Convert MCP config if provided
-	var mcpConfigPath string
-	var mcpTempDir string
-	if task.MCPConfig != "" {
-		var err error
-		mcpTempDir = filepath.Join(s.logDir, "claude-mcp", task.ID)
-		mcpConfigPath, err = ConvertMCPConfigForTask(task.MCPConfig, task.ID, s.logDir, task.WorkDir)
-		if err != nil {
-			log.Printf("ERROR: failed to convert MCP config for task %s: %v (MCPConfig=%q, WorkDir=%q, LogDir=%q)",
-				task.ID, err, task.MCPConfig, task.WorkDir, s.logDir)
-			// Continue without MCP config
-		} else {
-			log.Printf("INFO: MCP config converted successfully for task %s: %s", task.ID, mcpConfigPath)
-		}
-	}
-
-	// Build command arguments
-	args := s.buildArgs(task, mcpConfigPath)
-
-	// Log the command being executed for debugging
-	log.Printf("Executing: claude %v", args)
-
-	// Create cancellable context
-	procCtx, cancel := context.WithCancel(ctx)
-	if task.Timeout > 0 {
-		procCtx, cancel = context.WithTimeout(ctx, time.Duration(task.Timeout))
-	}
-
-	// Create command - use 'claude' CLI
-	cmd := exec.CommandContext(procCtx, "claude", args...)
-	cmd.Dir = task.WorkDir
-
-	// Set up environment with Claude Code configuration
-	cmd.Env = append(os.Environ(), "NO_COLOR=1")
-
-	// Create log file
-	logPath := filepath.Join(s.logDir, fmt.Sprintf("%s.log", task.ID))
-	logFile, err := os.Create(logPath)
-	if err != nil {
-		cancel()
-		return fmt.Errorf("failed to create log file: %w", err)
-	}
-	task.LogFile = logPath
-
-	// Set up output capture
-	output := &strings.Builder{}
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		cancel()
-		logFile.Close()
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		cancel()
-		logFile.Close()
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
-
-	// Start process
-	if err := cmd.Start(); err != nil {
-		cancel()
-		logFile.Close()
-		return fmt.Errorf("failed to start claude: %w", err)
-	}
-
-	task.PID = cmd.Process.Pid
-	now := time.Now()
-	task.StartedAt = &now
-	task.Status = models.TaskStatusRunning
-
-	log.Printf(
-		"task_event=started task_id=%s status=%s pid=%d log_file=%q work_dir=%q model=%q engine=claude",
-		task.ID,
-		task.Status,
-		task.PID,
-		task.LogFile,
-		task.WorkDir,
-		task.Model,
-	)
-
-	proc := &ClaudeProcess{
-		cmd:        cmd,
-		task:       task,
-		output:     output,
-		logFile:    logFile,
-		cancel:     cancel,
-		done:       make(chan struct{}),
-		mcpTempDir: mcpTempDir,
-	}
-
-	s.mu.Lock()
-	s.processes[task.ID] = proc
-	s.mu.Unlock()
-
-	// Start output capture goroutines
-	go s.captureOutput(proc, stdout, stderr)
-
-	// Wait for completion in background
-	go s.waitForCompletion(proc)
-
-	return nil
+// SupportsSessionResume reports whether an engine can continue a previous
+// task's session natively (via Task.SessionID).
+func SupportsSessionResume(engine models.Engine) bool {
+	return engine == models.EngineClaude
 }
 
-func (s *ClaudeSpawner) buildArgs(task *models.Task, mcpConfigPath string) []string {
-	// Prepend task_id to the prompt
-	promptWithTaskID := fmt.Sprintf("You are the task_id: %s\n\n%s", task.ID, task.Prompt)
+func prepareClaude(task *models.Task, logDir string) (engineRun, error) {
+	// Convert MCP config if provided
+	mcpConfigPath, mcpTempDir := convertClaudeMCPConfig(task, logDir)
 
 	// Only pass model and prompt as arguments
 	// Other configuration is passed via environment variables
-	args := []string{"--print", "--output-format", "text", "--verbose", "--dangerously-skip-permissions"}
+	args := []string{"--print", "--output-format", "stream-json", "--verbose", "--dangerously-skip-permissions"}
 
 	if task.Model != "" {
 		args = append(args, "--model", task.Model)
@@ -179,233 +53,50 @@ func (s *ClaudeSpawner) buildArgs(task *models.Task, mcpConfigPath string) []str
 		args = append(args, "--mcp-config", mcpConfigPath)
 	}
 
-	// Add extra args if needed (but most should be env vars now)
-	args = append(args, task.ExtraArgs...)
-
-	// Add ttermin prompt as the final argument
-	args = append(args, promptWithTaskID)
-
-	// Store the modified prompt
-	task.Prompt = promptWithTaskID
-
-	return args
-}
-
-func (s *ClaudeSpawner) captureOutput(proc *ClaudeProcess, stdout, stderr io.ReadCloser) {
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	// Capture stdout as plain text
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stdout)
-		buf := make([]byte, 0, 64*1024)
-		scanner.Buffer(buf, 1024*1024)
-
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			// Write to log file
-			fmt.Fprintf(proc.logFile, "%s\n", line)
-
-			// Capture to memory (with limit)
-			if proc.output.Len() < maxOutputCapture {
-				proc.output.WriteString(line)
-				proc.output.WriteString("\n")
-			}
-		}
-	}()
-
-	// Capture stderr as-is
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stderr)
-		buf := make([]byte, 0, 64*1024)
-		scanner.Buffer(buf, 1024*1024)
-
-		for scanner.Scan() {
-			line := scanner.Text()
-			fmt.Fprintf(proc.logFile, "[stderr] %s\n", line)
-
-			if proc.output.Len() < maxOutputCapture {
-				proc.output.WriteString("[stderr] ")
-				proc.output.WriteString(line)
-				proc.output.WriteString("\n")
-			}
-		}
-	}()
-
-	wg.Wait()
-}
-
-func (s *ClaudeSpawner) waitForCompletion(proc *ClaudeProcess) {
-	defer close(proc.done)
-	defer proc.logFile.Close()
-
-	err := proc.cmd.Wait()
-
-	// Clean up temp MCP config
-	if proc.mcpTempDir != "" {
-		os.RemoveAll(proc.mcpTempDir)
-	}
-
-	now := time.Now()
-	proc.task.CompletedAt = &now
-	proc.task.Output = proc.output.String()
-	proc.task.OutputTail = s.getTail(proc.output.String(), outputTailLines)
-
-	explicitStop := proc.task.Status == models.TaskStatusCancelled || proc.task.Status == models.TaskStatusPaused
-
-	if err != nil {
-		if explicitStop {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				code := exitErr.ExitCode()
-				proc.task.ExitCode = &code
-			}
-		} else {
-			proc.task.Status = models.TaskStatusFailed
-			proc.task.Error = err.Error()
-
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				code := exitErr.ExitCode()
-				proc.task.ExitCode = &code
-			}
-		}
-	} else {
-		if !explicitStop {
-			proc.task.Status = models.TaskStatusCompleted
-		}
-		code := 0
-		proc.task.ExitCode = &code
-	}
-
-	s.mu.Lock()
-	delete(s.processes, proc.task.ID)
-	s.mu.Unlock()
-
-	if s.onComplete != nil {
-		s.onComplete(proc.task)
+	if task.ResumeSessionID != "" {
+		args = append(args, "--resume", task.ResumeSessionID)
 	}
-}
-
-func (s *ClaudeSpawner) getTail(output string, lines int) string {
-	allLines := strings.Split(output, "\n")
-	if len(allLines) <= lines {
-		return output
-	}
-	return strings.Join(allLines[len(allLines)-lines:], "\n")
-}
 
-// Cancel stops a running agent.
-func (s *ClaudeSpawner) Cancel(taskID string) error {
-	s.mu.RLock()
-	proc, exists := s.processes[taskID]
-	s.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("process not found: %s", taskID)
-	}
-
-	proc.cancel()
-
-	if proc.cmd.Process != nil {
-		proc.cmd.Process.Signal(syscall.SIGTERM)
-
-		select {
-		case <-proc.done:
-		case <-time.After(5 * time.Second):
-			proc.cmd.Process.Kill()
-		}
-	}
+	args = append(args, task.ExtraArgs...)
 
-	proc.task.Status = models.TaskStatusCancelled
+	// Store the modified prompt and pass it as the final argument
+	task.Prompt = promptWithTaskID(task)
+	args = append(args, task.Prompt)
 
-	return nil
+	return engineRun{
+		args:    args,
+		env:     []string{"NO_COLOR=1"},
+		cleanup: removeDirFunc(mcpTempDir),
+	}, nil
 }
 
-// Pause stops a running agent without marking it as cancelled.
-func (s *ClaudeSpawner) Pause(taskID string) error {
-	s.mu.RLock()
-	proc, exists := s.processes[taskID]
-	s.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("process not found: %s", taskID)
+// convertClaudeMCPConfig converts the task MCP config to Claude's format under
+// logDir/claude-mcp/<task>. It returns the config path (empty when there is no
+// usable MCP config) and the directory to remove afterwards.
+func convertClaudeMCPConfig(task *models.Task, logDir string) (string, string) {
+	if task.MCPConfig == "" {
+		return "", ""
 	}
-
-	proc.cancel()
-
-	if proc.cmd.Process != nil {
-		proc.cmd.Process.Signal(syscall.SIGTERM)
-
-		select {
-		case <-proc.done:
-		case <-time.After(5 * time.Second):
-			proc.cmd.Process.Kill()
-		}
+	mcpTempDir := filepath.Join(logDir, "claude-mcp", task.ID)
+	mcpConfigPath, err := ConvertMCPConfigForTask(task.MCPConfig, task.ID, logDir, task.WorkDir)
+	if err != nil {
+		slog.Error("failed to convert MCP config", "task_id", task.ID, "error", err,
+			"mcp_config", MCPConfigSource(task.MCPConfig), "work_dir", task.WorkDir, "log_dir", logDir)
+		// Continue without MCP config
+		return "", mcpTempDir
 	}
-
-	proc.task.Status = models.TaskStatusPaused
-
-	return nil
+	slog.Debug("MCP config converted", "task_id", task.ID, "path", mcpConfigPath)
+	return mcpConfigPath, mcpTempDir
 }
 
-// IsRunning checks if a task is currently running.
-func (s *ClaudeSpawner) IsRunning(taskID string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	_, exists := s.processes[taskID]
-	return exists
-}
-
-// Wait blocks until a task completes or context is cancelled.
-func (s *ClaudeSpawner) Wait(ctx context.Context, taskID string) error {
-	s.mu.RLock()
-	proc, exists := s.processes[taskID]
-	s.mu.RUnlock()
-
-	if !exists {
+// removeDirFunc returns a cleanup func that removes dir (nil when dir is empty).
+func removeDirFunc(dir string) func() {
+	if dir == "" {
 		return nil
 	}
-
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-proc.done:
-		return nil
-	}
-}
-
-// RunningCount returns the number of currently running processes.
-func (s *ClaudeSpawner) RunningCount() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.processes)
-}
-
-// Shutdown cancels all running processes.
-func (s *ClaudeSpawner) Shutdown() {
-	s.mu.Lock()
-	procs := make([]*ClaudeProcess, 0, len(s.processes))
-	for _, p := range s.processes {
-		procs = append(procs, p)
-	}
-	s.mu.Unlock()
-
-	for _, proc := range procs {
-		proc.cancel()
-		if proc.cmd.Process != nil {
-			proc.cmd.Process.Signal(syscall.SIGTERM)
-		}
-	}
-
-	for _, proc := range procs {
-		select {
-		case <-proc.done:
-		case <-time.After(10 * time.Second):
-			if proc.cmd.Process != nil {
-				proc.cmd.Process.Kill()
-			}
+	return func() {
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("failed to clean up temp dir", "path", dir, "error", err)
 		}
 	}
 }
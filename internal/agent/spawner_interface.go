@@ -21,6 +21,10 @@ type Spawner interface {
 	// Wait blocks until a task completes or context is cancelled.
 	Wait(ctx context.Context, taskID string) error
 
+	// StreamOutput subscribes to the live output of a running task, line by line.
+	// The channel is closed when the process exits or cancel is called.
+	StreamOutput(taskID string) (<-chan string, func(), error)
+
 	// IsRunning checks if a task is currently running.
 	IsRunning(taskID string) bool
 
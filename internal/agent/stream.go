@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"sync"
+)
+
+// streamBufferLines is the per-subscriber buffer. Lines are dropped for
+// subscribers that fall this far behind so a slow reader never blocks the agent.
+const streamBufferLines = 256
+
+// outputStream fans out live process output lines to subscribers.
+type outputStream struct {
+	mu     sync.Mutex
+	subs   map[int]chan string
+	nextID int
+	closed bool
+}
+
+func newOutputStream() *outputStream {
+	return &outputStream{subs: make(map[int]chan string)}
+}
+
+// publish sends a line to every subscriber without blocking.
+func (o *outputStream) publish(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, ch := range o.subs {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel of output lines and a function to stop receiving.
+// The channel is closed when the process exits or cancel is called.
+func (o *outputStream) subscribe() (<-chan string, func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ch := make(chan string, streamBufferLines)
+	if o.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	id := o.nextID
+	o.nextID++
+	o.subs[id] = ch
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			if sub, ok := o.subs[id]; ok {
+				delete(o.subs, id)
+				close(sub)
+			}
+		})
+	}
+	return ch, cancel
+}
+
+// close ends the stream and closes every subscriber channel.
+func (o *outputStream) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return
+	}
+	o.closed = true
+	for id, ch := range o.subs {
+		delete(o.subs, id)
+		close(ch)
+	}
+}
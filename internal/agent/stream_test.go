@@ -0,0 +1,48 @@
+package agent
+
+import "testing"
+
+func TestOutputStream_PublishAndClose(t *testing.T) {
+	stream := newOutputStream()
+	ch, cancel := stream.subscribe()
+	defer cancel()
+
+	stream.publish("line 1")
+	stream.publish("line 2")
+	stream.close()
+
+	var got []string
+	for line := range ch {
+		got = append(got, line)
+	}
+	if len(got) != 2 || got[0] != "line 1" || got[1] != "line 2" {
+		t.Fatalf("unexpected lines: %v", got)
+	}
+
+	late, lateCancel := stream.subscribe()
+	defer lateCancel()
+	if _, ok := <-late; ok {
+		t.Fatal("expected closed channel after stream close")
+	}
+}
+
+func TestOutputStream_CancelAndSlowSubscriber(t *testing.T) {
+	stream := newOutputStream()
+	slow, slowCancel := stream.subscribe()
+	gone, goneCancel := stream.subscribe()
+	goneCancel()
+	goneCancel()
+
+	if _, ok := <-gone; ok {
+		t.Fatal("expected cancelled subscriber channel to be closed")
+	}
+
+	for i := 0; i < streamBufferLines+10; i++ {
+		stream.publish("x")
+	}
+	if len(slow) != streamBufferLines {
+		t.Fatalf("expected buffer to cap at %d lines, got %d", streamBufferLines, len(slow))
+	}
+	slowCancel()
+	stream.close()
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// "45.2k input, 1.2k output" (Copilot "Usage by model" summary).
+	compactUsageRe = regexp.MustCompile(`(?i)([\d.,]+[km]?)\s+input,\s*([\d.,]+[km]?)\s+output`)
+	// "Input tokens: 1,234" / "prompt tokens = 1234" (Gemini and generic usage lines).
+	inputTokensRe  = regexp.MustCompile(`(?i)\b(?:input|prompt)[ _-]?tokens?\s*[:=]?\s*([\d.,]+[km]?)`)
+	outputTokensRe = regexp.MustCompile(`(?i)\b(?:output|completion|candidates)[ _-]?tokens?\s*[:=]?\s*([\d.,]+[km]?)`)
+)
+
+// tokenUsage accumulates token counts reported in agent output.
+type tokenUsage struct {
+	in  int64
+	out int64
+}
+
+// parseLine adds the tokens reported on a usage line and reports whether it was one.
+func (u *tokenUsage) parseLine(line string) bool {
+	if m := compactUsageRe.FindStringSubmatch(line); m != nil {
+		u.in += parseTokenCount(m[1])
+		u.out += parseTokenCount(m[2])
+		return true
+	}
+
+	matched := false
+	if m := inputTokensRe.FindStringSubmatch(line); m != nil {
+		u.in += parseTokenCount(m[1])
+		matched = true
+	}
+	if m := outputTokensRe.FindStringSubmatch(line); m != nil {
+		u.out += parseTokenCount(m[1])
+		matched = true
+	}
+	return matched
+}
+
+// parseTokenCount parses counts such as "1234", "1,234", "45.2k" or "1.1m".
+func parseTokenCount(s string) int64 {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), ",", ""))
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1e3
+		s = strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1e6
+		s = strings.TrimSuffix(s, "m")
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(n*multiplier + 0.5)
+}
@@ -0,0 +1,47 @@
+package agent
+
+import "testing"
+
+func TestParseTokenCount(t *testing.T) {
+	cases := map[string]int64{
+		"1234":  1234,
+		"1,234": 1234,
+		"45.2k": 45200,
+		"1.1M":  1100000,
+		"bad":   0,
+	}
+	for in, want := range cases {
+		if got := parseTokenCount(in); got != want {
+			t.Errorf("parseTokenCount(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestCopilotOutputParser_Usage(t *testing.T) {
+	p := newCopilotOutputParser()
+	events := parseAll(p,
+		"Fixed the bug.",
+		"",
+		"Total usage est:       1 Premium request",
+		"Usage by model:",
+		"    claude-sonnet-4.5    45.2k input, 1.2k output, 30.1k cache read (Est. 1 Premium request)",
+	)
+
+	in, out := p.Usage()
+	if in != 45200 || out != 1200 {
+		t.Fatalf("unexpected usage: in=%d out=%d", in, out)
+	}
+	if len(events) != 1 || events[0].Message != "Fixed the bug." {
+		t.Fatalf("expected usage summary to stay out of the answer, got %+v", events)
+	}
+}
+
+func TestGeminiOutputParser_Usage(t *testing.T) {
+	p := newGeminiOutputParser()
+	parseAll(p, "Answer", "Input tokens: 1,500", "Output tokens: 300")
+
+	in, out := p.Usage()
+	if in != 1500 || out != 300 {
+		t.Fatalf("unexpected usage: in=%d out=%d", in, out)
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/pelletier/go-toml/v2"
 	"gopkg.in/yaml.v2"
 )
 
@@ -21,10 +24,63 @@ type ModelConfig struct {
 	Description string `json:"description" yaml:"description"`
 }
 
-// EngineConfig holds engine-specific configuration.
+// modelConfigFields has the fields of ModelConfig without its unmarshalers.
+type modelConfigFields ModelConfig
+
+// UnmarshalYAML accepts a model as a mapping or as its bare id.
+func (m *ModelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var id string
+	if err := unmarshal(&id); err == nil {
+		*m = ModelConfig{ID: id}
+		return nil
+	}
+	return unmarshal((*modelConfigFields)(m))
+}
+
+// UnmarshalJSON accepts a model as an object or as its bare id.
+func (m *ModelConfig) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*m = ModelConfig{ID: id}
+		return nil
+	}
+	return json.Unmarshal(data, (*modelConfigFields)(m))
+}
+
+// EngineConfig holds engine-specific configuration, set under `engines:`
+// keyed by engine name. Without Models or DefaultModel the engine uses the
+// global ones.
 type EngineConfig struct {
 	DefaultModel string        `json:"default_model" yaml:"default_model"`
 	Models       []ModelConfig `json:"models" yaml:"models"`
+	// Sandbox overrides the orchestrator sandbox mode for this engine ("none", "docker" or "kubernetes").
+	Sandbox string `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+	// ContainerImage pins the Docker image for this engine and implies the docker sandbox.
+	ContainerImage string `json:"container_image,omitempty" yaml:"container_image,omitempty"`
+	// ContainerArgs are extra `docker run` arguments appended after the orchestrator ones.
+	ContainerArgs []string `json:"container_args,omitempty" yaml:"container_args,omitempty"`
+	// Binary overrides the engine executable (a name on PATH or a path to a binary or wrapper).
+	Binary string `json:"binary,omitempty" yaml:"binary,omitempty"`
+	// DefaultArgs are prepended to the arguments mesnada passes to the engine binary.
+	DefaultArgs []string `json:"default_args,omitempty" yaml:"default_args,omitempty"`
+	// ExtraArgs are added to every task of this engine, ahead of the task's own extra_args.
+	ExtraArgs []string `json:"extra_args,omitempty" yaml:"extra_args,omitempty"`
+	// Env sets variables for every task of this engine, overriding the engine defaults.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	// MaxParallel caps how many tasks of this engine run at once; further tasks wait (0 = no cap).
+	MaxParallel int `json:"max_parallel,omitempty" yaml:"max_parallel,omitempty"`
+	// UsePTY runs the engine with a pseudo-terminal as stdout, for CLIs that buffer when piped.
+	UsePTY bool `json:"use_pty,omitempty" yaml:"use_pty,omitempty"`
+	// CaptureStderr writes engine stderr to the task log with a "[stderr] " prefix.
+	// Unset means the engine default (on, except gemini).
+	CaptureStderr *bool `json:"capture_stderr,omitempty" yaml:"capture_stderr,omitempty"`
+	// BaseURL routes the engine CLI to an OpenAI/Anthropic-compatible endpoint
+	// (LiteLLM, vLLM, a remote Ollama, ...). Supported by claude, opencode and the Ollama engines.
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	// APIKey is the key sent to BaseURL.
+	APIKey string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	// APIKeyEnv reads the key for BaseURL from this server environment variable instead.
+	APIKeyEnv string `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`
 }
 
 // Config holds the application configuration.
@@ -34,22 +90,569 @@ type Config struct {
 	Engines      map[string]EngineConfig `json:"engines,omitempty" yaml:"engines,omitempty"`
 	Server       ServerConfig            `json:"server" yaml:"server"`
 	Orchestrator OrchestratorConfig      `json:"orchestrator" yaml:"orchestrator"`
+	Secrets      map[string]SecretConfig `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	Defaults     []DefaultRule           `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	// Aliases map short model names (e.g. "fast") to model ids, resolved
+	// when a task is spawned.
+	Aliases map[string]string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Logging LoggingConfig     `json:"logging,omitempty" yaml:"logging,omitempty"`
+}
+
+// LoggingConfig configures the server log, which the orchestrator, the
+// engine spawners and the server all write to.
+type LoggingConfig struct {
+	// Level is the minimum level written: "debug", "info" (default), "warn" or "error".
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// Format is "text" (default) or "json", one object per line.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// File writes the log to this file instead of stderr.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// MaxSize rotates File once it reaches this size (e.g. "50MB"). Empty means never.
+	MaxSize string `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	// MaxBackups is how many rotated files are kept as <file>.1, <file>.2, ... (default 3).
+	MaxBackups int `json:"max_backups,omitempty" yaml:"max_backups,omitempty"`
+}
+
+// MaxSizeBytes returns the parsed max_size (0 when unset).
+func (l LoggingConfig) MaxSizeBytes() (int64, error) {
+	if l.MaxSize == "" {
+		return 0, nil
+	}
+	size, err := ParseSize(l.MaxSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid logging max_size: %w", err)
+	}
+	return size, nil
+}
+
+// DefaultRule fills in values that spawn requests omit, for the tasks it
+// matches. A rule without work_dir or match_tags matches every task. Rules
+// apply in order, so for each value the first matching rule that sets it wins.
+type DefaultRule struct {
+	// WorkDir matches tasks in this directory or below it.
+	WorkDir string `json:"work_dir,omitempty" yaml:"work_dir,omitempty"`
+	// MatchTags matches tasks spawned with all of these tags.
+	MatchTags []string `json:"match_tags,omitempty" yaml:"match_tags,omitempty"`
+	Engine    string   `json:"engine,omitempty" yaml:"engine,omitempty"`
+	// Model only applies when the task runs on the rule's engine, if it sets one.
+	Model   string `json:"model,omitempty" yaml:"model,omitempty"`
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Tags are added to the task's tags.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// SecretConfig defines a secret that spawn requests can reference by name.
+// The value is read when a task starts and is only passed in the agent environment.
+type SecretConfig struct {
+	// Env reads the value from this server environment variable.
+	Env string `json:"env,omitempty" yaml:"env,omitempty"`
+	// File reads the value from this file (trailing newline trimmed).
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// As is the variable name in the agent environment (defaults to the upper-cased secret name).
+	As string `json:"as,omitempty" yaml:"as,omitempty"`
+}
+
+// EnvName returns the environment variable name used for the secret.
+func (s SecretConfig) EnvName(name string) string {
+	if s.As != "" {
+		return s.As
+	}
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// Value reads the current secret value.
+func (s SecretConfig) Value() (string, error) {
+	switch {
+	case s.Env != "":
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return value, nil
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return "", fmt.Errorf("secret has no env or file source")
+	}
 }
 
 // ServerConfig holds HTTP server configuration.
 type ServerConfig struct {
 	Host string `json:"host" yaml:"host"`
 	Port int    `json:"port" yaml:"port"`
+	// BasePath serves every route under a prefix such as "/mesnada", for
+	// reverse proxies that forward a sub-path without stripping it.
+	BasePath string `json:"base_path,omitempty" yaml:"base_path,omitempty"`
+	// AuthTokens are the bearer tokens accepted on /mcp, /api and /ui. Empty disables auth.
+	AuthTokens []string `json:"auth_tokens,omitempty" yaml:"auth_tokens,omitempty"`
+	// AuthTokenEnv names an environment variable holding one more accepted token.
+	AuthTokenEnv string `json:"auth_token_env,omitempty" yaml:"auth_token_env,omitempty"`
+	// ToolAccess restricts the MCP tools individual bearer tokens may call.
+	ToolAccess []ToolAccessRule `json:"tool_access,omitempty" yaml:"tool_access,omitempty"`
+	// APITokens are bearer tokens limited to scopes (read, spawn, admin).
+	APITokens []APIToken `json:"api_tokens,omitempty" yaml:"api_tokens,omitempty"`
+	// APITokensFile is a YAML or JSON list of more api_tokens, read on load and reload.
+	APITokensFile string `json:"api_tokens_file,omitempty" yaml:"api_tokens_file,omitempty"`
+	// OAuth accepts JWT access tokens from an OAuth 2.1 authorization server.
+	OAuth OAuthConfig `json:"oauth,omitempty" yaml:"oauth,omitempty"`
+	// TLS serves HTTPS directly instead of plain HTTP.
+	TLS TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+	// CORS controls which browser origins may call the server.
+	CORS CORSConfig `json:"cors,omitempty" yaml:"cors,omitempty"`
+	// RateLimit caps request rates on the MCP and REST endpoints.
+	RateLimit RateLimitConfig `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`
+	// SessionTTL expires MCP sessions idle for this long, e.g. "30m" (default "1h").
+	SessionTTL string `json:"session_ttl,omitempty" yaml:"session_ttl,omitempty"`
+	// MaxSessions caps live MCP sessions; the least recently used is evicted (default 1000).
+	MaxSessions int `json:"max_sessions,omitempty" yaml:"max_sessions,omitempty"`
+	// UI configures the web UI.
+	UI UIConfig `json:"ui,omitempty" yaml:"ui,omitempty"`
+}
+
+const (
+	defaultSessionTTL  = time.Hour
+	defaultMaxSessions = 1000
+)
+
+// SessionTTLDuration returns the parsed session_ttl.
+func (c ServerConfig) SessionTTLDuration() (time.Duration, error) {
+	if c.SessionTTL == "" {
+		return defaultSessionTTL, nil
+	}
+	ttl, err := time.ParseDuration(c.SessionTTL)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid session_ttl: %q", c.SessionTTL)
+	}
+	return ttl, nil
+}
+
+// Prefix returns base_path with a leading slash and no trailing one, or ""
+// when the server is served at the root.
+func (c ServerConfig) Prefix() string {
+	p := strings.Trim(c.BasePath, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// SessionLimit returns max_sessions, or the default when unset.
+func (c ServerConfig) SessionLimit() int {
+	if c.MaxSessions <= 0 {
+		return defaultMaxSessions
+	}
+	return c.MaxSessions
+}
+
+// CORSConfig configures cross-origin access for browser clients.
+type CORSConfig struct {
+	// AllowedOrigins are the origins (e.g. "https://app.example.com") allowed
+	// to make cross-origin requests; "*" allows any. When empty, any origin is
+	// allowed only while auth is disabled.
+	AllowedOrigins []string `json:"allowed_origins,omitempty" yaml:"allowed_origins,omitempty"`
+}
+
+// RateLimitConfig configures per-client request rate limits.
+type RateLimitConfig struct {
+	// RequestsPerMinute is the sustained rate per client; 0 disables limiting.
+	RequestsPerMinute int `json:"requests_per_minute,omitempty" yaml:"requests_per_minute,omitempty"`
+	// Burst is how many requests a client may make at once (defaults to RequestsPerMinute).
+	Burst int `json:"burst,omitempty" yaml:"burst,omitempty"`
+	// By keys clients by "token" (a configured token, falling back to the IP
+	// for other requests) or "ip" (default).
+	By string `json:"by,omitempty" yaml:"by,omitempty"`
+}
+
+// UIConfig configures the web UI.
+type UIConfig struct {
+	// Theme is the color theme users get until they pick one: "dark"
+	// (default), "light" or "system" to follow the browser.
+	Theme string `json:"theme,omitempty" yaml:"theme,omitempty"`
+}
+
+// DefaultTheme returns the configured theme, falling back to "dark".
+func (c UIConfig) DefaultTheme() string {
+	switch theme := strings.ToLower(strings.TrimSpace(c.Theme)); theme {
+	case "light", "system":
+		return theme
+	}
+	return "dark"
+}
+
+// TLSConfig configures HTTPS, from certificate files or automatic ACME certificates.
+type TLSConfig struct {
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	// AutocertHosts obtains certificates from Let's Encrypt for these host
+	// names (TLS-ALPN-01, so the server must be reachable on port 443).
+	AutocertHosts []string `json:"autocert_hosts,omitempty" yaml:"autocert_hosts,omitempty"`
+	// AutocertCacheDir stores the issued certificates (default ~/.mesnada/autocert).
+	AutocertCacheDir string `json:"autocert_cache_dir,omitempty" yaml:"autocert_cache_dir,omitempty"`
+	// AutocertEmail is the optional ACME account contact.
+	AutocertEmail string `json:"autocert_email,omitempty" yaml:"autocert_email,omitempty"`
+}
+
+// Enabled reports whether the server serves HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || len(c.AutocertHosts) > 0
+}
+
+// Validate checks that exactly one certificate source is configured.
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("server.tls needs both cert_file and key_file")
+	}
+	if c.CertFile != "" && len(c.AutocertHosts) > 0 {
+		return fmt.Errorf("server.tls cert_file and autocert_hosts are mutually exclusive")
+	}
+	return nil
+}
+
+// ToolAccessRule limits a bearer token to a set of MCP tools, e.g. a
+// read-only observer key. The token is accepted like auth_tokens.
+type ToolAccessRule struct {
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// TokenEnv reads the token from this environment variable instead.
+	TokenEnv string `json:"token_env,omitempty" yaml:"token_env,omitempty"`
+	// Tools are the tools the token may call, as names or patterns like "get_*".
+	Tools []string `json:"tools" yaml:"tools"`
+	// Scope is what the token may do on the REST API and UI: read (the
+	// default), spawn or admin, as for api_tokens.
+	Scope string `json:"scope,omitempty" yaml:"scope,omitempty"`
+}
+
+// token returns the rule's token, or "" when it has none.
+func (r ToolAccessRule) token() string {
+	if r.TokenEnv != "" {
+		return os.Getenv(r.TokenEnv)
+	}
+	return r.Token
+}
+
+// ToolsForToken returns the tools a token is limited to, and false when no
+// tool_access rule applies to it.
+func (c ServerConfig) ToolsForToken(token string) ([]string, bool) {
+	rule, ok := c.ToolAccessFor(token)
+	return rule.Tools, ok
+}
+
+// ToolAccessFor returns the tool_access rule of a token, and false when no
+// rule applies to it.
+func (c ServerConfig) ToolAccessFor(token string) (ToolAccessRule, bool) {
+	if token == "" {
+		return ToolAccessRule{}, false
+	}
+	for _, rule := range c.ToolAccess {
+		if rule.token() == token {
+			return rule, true
+		}
+	}
+	return ToolAccessRule{}, false
+}
+
+// APIToken returns the scoped API token a tool_access token acts as on the
+// REST API and UI.
+func (r ToolAccessRule) APIToken() APIToken {
+	scope := r.Scope
+	if scope == "" {
+		scope = ScopeRead
+	}
+	return APIToken{Name: "tool_access", Scopes: []string{scope}}
+}
+
+// API token scopes. Each scope includes the ones before it.
+const (
+	// ScopeRead allows reading tasks, logs and stats.
+	ScopeRead = "read"
+	// ScopeSpawn also allows creating and controlling tasks.
+	ScopeSpawn = "spawn"
+	// ScopeAdmin also allows deleting tasks.
+	ScopeAdmin = "admin"
+)
+
+var scopeRank = map[string]int{ScopeRead: 1, ScopeSpawn: 2, ScopeAdmin: 3}
+
+// APIToken is a bearer token limited to a set of scopes, e.g. a read-only
+// key for a dashboard. The token is accepted like auth_tokens.
+type APIToken struct {
+	// Name identifies the token in the audit log, which never logs the token itself.
+	Name  string `json:"name" yaml:"name"`
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// TokenEnv reads the token from this environment variable instead.
+	TokenEnv string   `json:"token_env,omitempty" yaml:"token_env,omitempty"`
+	Scopes   []string `json:"scopes" yaml:"scopes"`
+}
+
+// token returns the API token's value, or "" when it has none.
+func (t APIToken) token() string {
+	if t.TokenEnv != "" {
+		return os.Getenv(t.TokenEnv)
+	}
+	return t.Token
+}
+
+// HasScope reports whether the token grants scope, directly or through a
+// broader scope.
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if scopeRank[scope] > 0 && scopeRank[s] >= scopeRank[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenFor returns the api_tokens entry for a token, and false when the
+// token is not a scoped API token.
+func (c ServerConfig) APITokenFor(token string) (APIToken, bool) {
+	if token == "" {
+		return APIToken{}, false
+	}
+	for _, t := range c.APITokens {
+		if t.token() == token {
+			return t, true
+		}
+	}
+	return APIToken{}, false
+}
+
+// loadAPITokens appends the api_tokens_file entries to api_tokens and checks
+// the scopes of every entry and of the tool_access rules.
+func (c *ServerConfig) loadAPITokens() error {
+	if c.APITokensFile != "" {
+		data, err := os.ReadFile(c.APITokensFile)
+		if err != nil {
+			return fmt.Errorf("failed to read api_tokens_file: %w", err)
+		}
+		var tokens []APIToken
+		if err := yaml.Unmarshal(data, &tokens); err != nil {
+			return fmt.Errorf("failed to parse api_tokens_file: %w", err)
+		}
+		c.APITokens = append(c.APITokens, tokens...)
+	}
+	for _, t := range c.APITokens {
+		if t.Name == "" {
+			return fmt.Errorf("api_tokens entries need a name")
+		}
+		if len(t.Scopes) == 0 {
+			return fmt.Errorf("api token %q has no scopes", t.Name)
+		}
+		for _, scope := range t.Scopes {
+			if scopeRank[scope] == 0 {
+				return fmt.Errorf("api token %q has unknown scope %q (use read, spawn or admin)", t.Name, scope)
+			}
+		}
+	}
+	for i, rule := range c.ToolAccess {
+		if rule.Scope != "" && scopeRank[rule.Scope] == 0 {
+			return fmt.Errorf("tool_access[%d] has unknown scope %q (use read, spawn or admin)", i, rule.Scope)
+		}
+	}
+	return nil
+}
+
+// OAuthConfig configures mesnada as an OAuth 2.1 resource server (MCP authorization).
+type OAuthConfig struct {
+	// Issuer is the authorization server; tokens must carry it as "iss". Empty disables OAuth.
+	Issuer string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	// Resource is the canonical URL of this MCP server, advertised in the
+	// protected-resource metadata (defaults to the request's /mcp URL).
+	Resource string `json:"resource,omitempty" yaml:"resource,omitempty"`
+	// Audience is the required "aud" claim (defaults to Resource when set).
+	Audience string `json:"audience,omitempty" yaml:"audience,omitempty"`
+	// JWKSURL overrides the signing keys URL discovered from the issuer metadata.
+	JWKSURL string `json:"jwks_url,omitempty" yaml:"jwks_url,omitempty"`
+	// Scopes maps each scope to the MCP tools it allows ("*" for all). When
+	// empty, any valid token may call every tool.
+	Scopes map[string][]string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	// APIScopes maps token scopes to the REST API and UI scopes read, spawn
+	// or admin. Token scopes named read, spawn or admin map to themselves;
+	// tokens with none of these are denied the REST API and UI.
+	APIScopes map[string]string `json:"api_scopes,omitempty" yaml:"api_scopes,omitempty"`
+}
+
+// Enabled reports whether OAuth access tokens are accepted.
+func (c OAuthConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// RequiredAudience is the "aud" claim tokens must carry: the audience, or
+// the resource when no audience is set.
+func (c OAuthConfig) RequiredAudience() string {
+	if c.Audience != "" {
+		return c.Audience
+	}
+	return c.Resource
+}
+
+// APIScopesFor returns the REST API and UI scopes of a token's scopes.
+func (c OAuthConfig) APIScopesFor(tokenScopes []string) []string {
+	var scopes []string
+	for _, scope := range tokenScopes {
+		if mapped, ok := c.APIScopes[scope]; ok {
+			scopes = append(scopes, mapped)
+		} else if scopeRank[scope] > 0 {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// check reports OAuth settings that would weaken token validation.
+func (c OAuthConfig) check() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.RequiredAudience() == "" {
+		return fmt.Errorf("oauth needs audience or resource, or tokens issued for other services would be accepted")
+	}
+	for scope, apiScope := range c.APIScopes {
+		if scopeRank[apiScope] == 0 {
+			return fmt.Errorf("oauth.api_scopes %q has unknown scope %q (use read, spawn or admin)", scope, apiScope)
+		}
+	}
+	return nil
+}
+
+// Tokens returns the accepted bearer tokens; none means auth is disabled.
+func (c ServerConfig) Tokens() []string {
+	var tokens []string
+	for _, t := range c.AuthTokens {
+		if t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	if c.AuthTokenEnv != "" {
+		if t := os.Getenv(c.AuthTokenEnv); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	for _, rule := range c.ToolAccess {
+		if t := rule.token(); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	for _, apiToken := range c.APITokens {
+		if t := apiToken.token(); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
 }
 
 // OrchestratorConfig holds orchestrator configuration.
 type OrchestratorConfig struct {
-	StorePath        string `json:"store_path" yaml:"store_path"`
-	LogDir           string `json:"log_dir" yaml:"log_dir"`
-	MaxParallel      int    `json:"max_parallel" yaml:"max_parallel"`
-	DefaultMCPConfig string `json:"default_mcp_config" yaml:"default_mcp_config"`
-	DefaultEngine    string `json:"default_engine" yaml:"default_engine"`
-	PersonaPath      string `json:"persona_path,omitempty" yaml:"persona_path,omitempty"`
+	StorePath        string        `json:"store_path" yaml:"store_path"`
+	LogDir           string        `json:"log_dir" yaml:"log_dir"`
+	MaxParallel      int           `json:"max_parallel" yaml:"max_parallel"`
+	DefaultMCPConfig string        `json:"default_mcp_config" yaml:"default_mcp_config"`
+	DefaultEngine    string        `json:"default_engine" yaml:"default_engine"`
+	PersonaPath      string        `json:"persona_path,omitempty" yaml:"persona_path,omitempty"`
+	Sandbox          SandboxConfig `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+	Env              EnvConfig     `json:"env,omitempty" yaml:"env,omitempty"`
+	// MaxLogSize caps each task log (e.g. "100MB"); larger logs are rotated to <log>.1. Empty means unlimited.
+	MaxLogSize string `json:"max_log_size,omitempty" yaml:"max_log_size,omitempty"`
+	// CompressLogs gzips the logs of completed, failed and cancelled tasks.
+	CompressLogs bool `json:"compress_logs,omitempty" yaml:"compress_logs,omitempty"`
+	// CreateWorkDir creates a missing task work directory instead of rejecting the spawn.
+	CreateWorkDir bool `json:"create_workdir,omitempty" yaml:"create_workdir,omitempty"`
+	// AllowedWorkDirs restricts task work directories to these roots (empty allows any).
+	AllowedWorkDirs []string `json:"allowed_workdirs,omitempty" yaml:"allowed_workdirs,omitempty"`
+	// AutoCommit commits the changes of completed tasks in git work dirs to a
+	// mesnada/<task_id> branch. Spawn requests can override it.
+	AutoCommit bool `json:"auto_commit,omitempty" yaml:"auto_commit,omitempty"`
+}
+
+// MaxLogSizeBytes returns the parsed max_log_size (0 when unset).
+func (o OrchestratorConfig) MaxLogSizeBytes() (int64, error) {
+	if o.MaxLogSize == "" {
+		return 0, nil
+	}
+	size, err := ParseSize(o.MaxLogSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_log_size: %w", err)
+	}
+	return size, nil
+}
+
+// sizeUnits maps size suffixes to their multiplier, longest suffixes first.
+var sizeUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// ParseSize parses a byte size such as "512", "64KB", "100MB" or "1G".
+// Units are binary (1KB = 1024 bytes) and case-insensitive.
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	mult := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			mult = unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return n * mult, nil
+}
+
+// EnvConfig controls which server environment variables reach agent processes.
+// Entries are names or glob patterns (e.g. "AWS_*").
+type EnvConfig struct {
+	// Allow, when set, limits passthrough to matching variables plus a base set (PATH, HOME, ...).
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	// Deny removes matching variables, even if allowed.
+	Deny []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+// SandboxConfig holds container sandbox configuration for agent processes.
+type SandboxConfig struct {
+	// Mode is the default sandbox mode: "none" (run on the host), "docker" or "kubernetes".
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// Image is the container image used for sandboxed tasks. It must provide the engine CLIs.
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+	// Network is passed to `docker run --network` (e.g. "bridge", "none", "host").
+	Network string `json:"network,omitempty" yaml:"network,omitempty"`
+	// ExtraArgs are appended to `docker run` before the image name.
+	ExtraArgs []string `json:"extra_args,omitempty" yaml:"extra_args,omitempty"`
+	// Kubernetes configures the "kubernetes" mode, which runs each task as a Job.
+	Kubernetes KubernetesConfig `json:"kubernetes,omitempty" yaml:"kubernetes,omitempty"`
+}
+
+// KubernetesConfig holds settings for running agent tasks as Kubernetes Jobs.
+type KubernetesConfig struct {
+	// Namespace where Jobs are created (defaults to "default").
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Context selects a kubeconfig context (defaults to the current context).
+	Context string `json:"context,omitempty" yaml:"context,omitempty"`
+	// Image overrides sandbox.image for Jobs. Engine container_image still wins.
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+	// ServiceAccount is the service account used by the Job pods.
+	ServiceAccount string `json:"service_account,omitempty" yaml:"service_account,omitempty"`
+	// VolumeClaim is a PersistentVolumeClaim mounted at the task work_dir.
+	VolumeClaim string `json:"volume_claim,omitempty" yaml:"volume_claim,omitempty"`
+	// Resources are the container resource requests and limits.
+	Resources KubernetesResources `json:"resources,omitempty" yaml:"resources,omitempty"`
+	// PodRunningTimeout is how long to wait for the pod to start (e.g. "5m").
+	PodRunningTimeout string `json:"pod_running_timeout,omitempty" yaml:"pod_running_timeout,omitempty"`
+}
+
+// KubernetesResources mirrors the Kubernetes container resources block.
+type KubernetesResources struct {
+	Requests map[string]string `json:"requests,omitempty" yaml:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty" yaml:"limits,omitempty"`
 }
 
 // DefaultConfig returns the default configuration.
@@ -87,14 +690,22 @@ func DefaultConfig() *Config {
 
 // Load loads configuration from a file (supports JSON and YAML).
 func Load(path string) (*Config, error) {
+	return LoadProfile(path, "")
+}
+
+// LoadProfile loads configuration like Load, with the named entry of the
+// `profiles:` section merged over the top-level keys: mappings are merged
+// key by key, lists and scalars set in the profile replace the base ones.
+func LoadProfile(path, profile string) (*Config, error) {
 	cfg := DefaultConfig()
 	baseDir := ""
 
 	if path == "" {
 		home, _ := os.UserHomeDir()
-		// Try YAML first, then JSON
+		// Try YAML first, then JSON, then TOML
 		yamlPath := filepath.Join(home, ".mesnada", "config.yaml")
 		jsonPath := filepath.Join(home, ".mesnada", "config.json")
+		tomlPath := filepath.Join(home, ".mesnada", "config.toml")
 
 		if _, err := os.Stat(yamlPath); err == nil {
 			path = yamlPath
@@ -102,6 +713,11 @@ func Load(path string) (*Config, error) {
 		} else if _, err := os.Stat(jsonPath); err == nil {
 			path = jsonPath
 			baseDir = filepath.Dir(path)
+		} else if _, err := os.Stat(tomlPath); err == nil {
+			path = tomlPath
+			baseDir = filepath.Dir(path)
+		} else if profile != "" {
+			return nil, fmt.Errorf("profile %q not found: no config file", profile)
 		} else {
 			// No config file found, return defaults
 			return cfg, nil
@@ -112,16 +728,34 @@ func Load(path string) (*Config, error) {
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if os.IsNotExist(err) && profile == "" {
 			return cfg, nil
 		}
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
 	// Detect format by extension
-	isYAML := strings.HasSuffix(strings.ToLower(path), ".yaml") || strings.HasSuffix(strings.ToLower(path), ".yml")
+	format := formatOf(path)
 
-	if isYAML {
+	// Includes and profiles are merged as documents, then decoded as YAML.
+	// TOML is decoded the same way, so it follows the YAML keys.
+	if profile != "" || format == formatTOML || hasIncludes(data, format) {
+		doc, err := loadDocument(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if profile != "" {
+			if doc, err = applyProfile(doc, profile); err != nil {
+				return nil, err
+			}
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("failed to merge config: %w", err)
+		}
+		format = formatYAML
+	}
+
+	if format == formatYAML {
 		if err := yaml.Unmarshal(data, cfg); err != nil {
 			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
 		}
@@ -140,6 +774,39 @@ func Load(path string) (*Config, error) {
 	if cfg.Orchestrator.PersonaPath != "" {
 		cfg.Orchestrator.PersonaPath = resolvePath(cfg.Orchestrator.PersonaPath, baseDir)
 	}
+	cfg.Server.TLS.CertFile = resolvePath(cfg.Server.TLS.CertFile, baseDir)
+	cfg.Server.TLS.KeyFile = resolvePath(cfg.Server.TLS.KeyFile, baseDir)
+	cfg.Server.TLS.AutocertCacheDir = resolvePath(cfg.Server.TLS.AutocertCacheDir, baseDir)
+	cfg.Server.APITokensFile = resolvePath(cfg.Server.APITokensFile, baseDir)
+	if err := cfg.Server.loadAPITokens(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Server.OAuth.check(); err != nil {
+		return nil, err
+	}
+	for i, root := range cfg.Orchestrator.AllowedWorkDirs {
+		cfg.Orchestrator.AllowedWorkDirs[i] = resolvePath(root, baseDir)
+	}
+	for name, secret := range cfg.Secrets {
+		if secret.File != "" {
+			secret.File = resolvePath(secret.File, baseDir)
+			cfg.Secrets[name] = secret
+		}
+	}
+	if cfg.Logging.File != "" {
+		cfg.Logging.File = resolvePath(cfg.Logging.File, baseDir)
+	}
+	for i, rule := range cfg.Defaults {
+		if rule.WorkDir != "" {
+			cfg.Defaults[i].WorkDir = resolvePath(rule.WorkDir, baseDir)
+		}
+	}
+	for name, engineConfig := range cfg.Engines {
+		if engineConfig.Binary != "" {
+			engineConfig.Binary = expandHome(engineConfig.Binary)
+			cfg.Engines[name] = engineConfig
+		}
+	}
 
 	return cfg, nil
 }
@@ -157,17 +824,32 @@ func (c *Config) Save(path string) error {
 	}
 
 	// Detect format by extension
-	isYAML := strings.HasSuffix(strings.ToLower(path), ".yaml") || strings.HasSuffix(strings.ToLower(path), ".yml")
+	format := formatOf(path)
 
 	var data []byte
 	var err error
 
-	if isYAML {
+	switch format {
+	case formatYAML:
 		data, err = yaml.Marshal(c)
 		if err != nil {
 			return fmt.Errorf("failed to marshal YAML config: %w", err)
 		}
-	} else {
+	case formatTOML:
+		// Encoded from the JSON form, whose keys are the config keys.
+		data, err = json.Marshal(c)
+		if err != nil {
+			return fmt.Errorf("failed to marshal TOML config: %w", err)
+		}
+		doc, err := decodeDocument(data, formatJSON)
+		if err != nil {
+			return fmt.Errorf("failed to marshal TOML config: %w", err)
+		}
+		data, err = toml.Marshal(tomlValue(doc))
+		if err != nil {
+			return fmt.Errorf("failed to marshal TOML config: %w", err)
+		}
+	default:
 		data, err = json.MarshalIndent(c, "", "  ")
 		if err != nil {
 			return fmt.Errorf("failed to marshal JSON config: %w", err)
@@ -182,7 +864,8 @@ func (c *Config) Save(path string) error {
 }
 
 // InitConfig creates a new configuration file using the embedded template.
-// If path is empty, it uses the default path (~/.mesnada/config.yaml).
+// If path is empty, it uses the default path (~/.mesnada/config.yaml). A
+// .toml path gets the template's values in TOML.
 func InitConfig(path string) error {
 	if path == "" {
 		home, _ := os.UserHomeDir()
@@ -194,13 +877,74 @@ func InitConfig(path string) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	if err := os.WriteFile(path, []byte(defaultConfigTemplate), 0644); err != nil {
+	data := []byte(defaultConfigTemplate)
+	if formatOf(path) == formatTOML {
+		// The template's comments do not carry over, only its values.
+		doc, err := decodeDocument(data, formatYAML)
+		if err != nil {
+			return err
+		}
+		if data, err = toml.Marshal(tomlValue(doc)); err != nil {
+			return fmt.Errorf("failed to marshal TOML config: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
 	return nil
 }
 
+// RedactedValue replaces secret values in Redacted configs.
+const RedactedValue = "[redacted]"
+
+// Redacted returns a copy of the config that is safe to show: bearer tokens,
+// engine API keys and engine environment values are replaced by RedactedValue.
+// Secrets only name their sources and are kept as they are.
+func (c *Config) Redacted() *Config {
+	out := *c
+	mask := func(v string) string {
+		if v == "" {
+			return ""
+		}
+		return RedactedValue
+	}
+
+	out.Server.AuthTokens = make([]string, len(c.Server.AuthTokens))
+	for i, t := range c.Server.AuthTokens {
+		out.Server.AuthTokens[i] = mask(t)
+	}
+	out.Server.ToolAccess = append([]ToolAccessRule(nil), c.Server.ToolAccess...)
+	for i := range out.Server.ToolAccess {
+		out.Server.ToolAccess[i].Token = mask(out.Server.ToolAccess[i].Token)
+	}
+	out.Server.APITokens = append([]APIToken(nil), c.Server.APITokens...)
+	for i := range out.Server.APITokens {
+		out.Server.APITokens[i].Token = mask(out.Server.APITokens[i].Token)
+	}
+	// Inline MCP JSON often carries Authorization headers; paths are kept.
+	if IsInlineMCPConfig(c.Orchestrator.DefaultMCPConfig) {
+		out.Orchestrator.DefaultMCPConfig = RedactedValue
+	}
+
+	if c.Engines != nil {
+		out.Engines = make(map[string]EngineConfig, len(c.Engines))
+		for name, engine := range c.Engines {
+			engine.APIKey = mask(engine.APIKey)
+			if engine.Env != nil {
+				env := make(map[string]string, len(engine.Env))
+				for k, v := range engine.Env {
+					env[k] = mask(v)
+				}
+				engine.Env = env
+			}
+			out.Engines[name] = engine
+		}
+	}
+	return &out
+}
+
 // Address returns the server address.
 func (c *Config) Address() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
@@ -221,6 +965,15 @@ func (c *Config) ValidateModel(id string) bool {
 	return c.GetModelByID(id) != nil
 }
 
+// ResolveModel returns the model id an alias stands for, or id itself when
+// it is not an alias.
+func (c *Config) ResolveModel(id string) string {
+	if target, ok := c.Aliases[id]; ok && target != "" {
+		return target
+	}
+	return id
+}
+
 // GetModelsForEngine returns the list of available models for a specific engine.
 // If engine-specific models are configured, returns those; otherwise returns the global models list.
 func (c *Config) GetModelsForEngine(engine string) []ModelConfig {
@@ -260,6 +1013,16 @@ func (c *Config) GetDefaultModelForEngine(engine string) string {
 	return c.DefaultModel
 }
 
+// GetBinaryForEngine returns the executable for an engine, or defaultBinary if not overridden.
+func (c *Config) GetBinaryForEngine(engine, defaultBinary string) string {
+	if c.Engines != nil {
+		if engineConfig, ok := c.Engines[engine]; ok && engineConfig.Binary != "" {
+			return engineConfig.Binary
+		}
+	}
+	return defaultBinary
+}
+
 // GetModelIDsForEngine returns a list of model IDs for an engine.
 func (c *Config) GetModelIDsForEngine(engine string) []string {
 	models := c.GetModelsForEngine(engine)
@@ -309,6 +1072,12 @@ func resolvePath(value, baseDir string) string {
 
 // expandMCPConfig expands ~ in MCP config values.
 // It supports both "~/..." and "@~/..." forms.
+// IsInlineMCPConfig reports whether an mcp_config value is a raw JSON document
+// rather than a file path.
+func IsInlineMCPConfig(mcpConfig string) bool {
+	return strings.HasPrefix(strings.TrimSpace(mcpConfig), "{")
+}
+
 func expandMCPConfig(value string) string {
 	value = strings.TrimSpace(value)
 	if value == "" {
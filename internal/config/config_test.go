@@ -1,9 +1,13 @@
 package config
 
 import (
+	"encoding/json"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v2"
 )
 
 func TestExpandHome_TildeOnly(t *testing.T) {
@@ -56,3 +60,642 @@ func TestResolvePath_AbsoluteUnchanged(t *testing.T) {
 		t.Fatalf("expected %q, got %q", abs, got)
 	}
 }
+
+func TestLoad_EngineBinaryExpanded(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "engines:\n  claude:\n    binary: \"~/bin/claude-wrapper\"\n    default_args: [\"--verbose\"]\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got := cfg.GetBinaryForEngine("claude", "claude")
+	if strings.Contains(got, "~") || !filepath.IsAbs(got) {
+		t.Fatalf("expected expanded binary path, got %q", got)
+	}
+	if cfg.GetBinaryForEngine("gemini", "gemini") != "gemini" {
+		t.Fatalf("expected default binary for unconfigured engine")
+	}
+	if args := cfg.Engines["claude"].DefaultArgs; len(args) != 1 || args[0] != "--verbose" {
+		t.Fatalf("expected default_args to load, got %v", args)
+	}
+}
+
+func TestSecretConfig_Value(t *testing.T) {
+	t.Setenv("MESNADA_TEST_SECRET", "from-env")
+	secretFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := (SecretConfig{Env: "MESNADA_TEST_SECRET"}).Value(); err != nil || v != "from-env" {
+		t.Fatalf("expected env value, got %q (%v)", v, err)
+	}
+	if v, err := (SecretConfig{File: secretFile}).Value(); err != nil || v != "from-file" {
+		t.Fatalf("expected trimmed file value, got %q (%v)", v, err)
+	}
+	if _, err := (SecretConfig{Env: "MESNADA_TEST_SECRET_UNSET"}).Value(); err == nil {
+		t.Fatal("expected error for unset env variable")
+	}
+	if _, err := (SecretConfig{}).Value(); err == nil {
+		t.Fatal("expected error for secret without source")
+	}
+
+	if got := (SecretConfig{}).EnvName("github-token"); got != "GITHUB_TOKEN" {
+		t.Fatalf("expected GITHUB_TOKEN, got %q", got)
+	}
+	if got := (SecretConfig{As: "NPM_AUTH"}).EnvName("npm"); got != "NPM_AUTH" {
+		t.Fatalf("expected NPM_AUTH, got %q", got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":    512,
+		"64KB":   64 << 10,
+		"100mb":  100 << 20,
+		"1G":     1 << 30,
+		" 2 MB ": 2 << 20,
+	}
+	for in, want := range cases {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "MB", "-1", "10TB"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+}
+
+func TestLoad_TLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "server:\n  tls:\n    cert_file: \"certs/server.crt\"\n    key_file: \"certs/server.key\"\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tls := cfg.Server.TLS
+	if !tls.Enabled() || tls.CertFile != filepath.Join(dir, "certs", "server.crt") {
+		t.Fatalf("expected cert_file resolved against the config dir, got %+v", tls)
+	}
+	if err := tls.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if err := (TLSConfig{CertFile: "a.crt"}).Validate(); err == nil {
+		t.Fatal("expected error for cert_file without key_file")
+	}
+	if err := (TLSConfig{CertFile: "a.crt", KeyFile: "a.key", AutocertHosts: []string{"example.com"}}).Validate(); err == nil {
+		t.Fatal("expected error for cert files combined with autocert")
+	}
+}
+
+func TestLoad_APITokensFile(t *testing.T) {
+	dir := t.TempDir()
+	tokens := "- name: dashboard\n  token: \"dash\"\n  scopes: [read]\n"
+	if err := os.WriteFile(filepath.Join(dir, "tokens.yaml"), []byte(tokens), 0600); err != nil {
+		t.Fatalf("write tokens: %v", err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	data := "server:\n  api_tokens:\n    - name: ci\n      token: \"ci\"\n      scopes: [spawn]\n  api_tokens_file: \"tokens.yaml\"\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	dash, ok := cfg.Server.APITokenFor("dash")
+	if !ok || dash.Name != "dashboard" || !dash.HasScope(ScopeRead) || dash.HasScope(ScopeSpawn) {
+		t.Fatalf("expected read-only dashboard token from the file, got %+v", dash)
+	}
+	ci, _ := cfg.Server.APITokenFor("ci")
+	if !ci.HasScope(ScopeRead) || !ci.HasScope(ScopeSpawn) || ci.HasScope(ScopeAdmin) {
+		t.Fatalf("expected spawn to include read only, got %+v", ci)
+	}
+	if len(cfg.Server.Tokens()) != 2 {
+		t.Fatalf("expected both API tokens to be accepted, got %d", len(cfg.Server.Tokens()))
+	}
+
+	data = "server:\n  api_tokens:\n    - name: bad\n      token: \"x\"\n      scopes: [write]\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected an unknown scope to be rejected")
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.AuthTokens = []string{"secret", ""}
+	cfg.Server.APITokens = []APIToken{{Name: "ci", Token: "ci-token", Scopes: []string{ScopeRead}}, {Name: "env", TokenEnv: "CI_TOKEN"}}
+	cfg.Engines = map[string]EngineConfig{"claude": {APIKey: "sk-1", APIKeyEnv: "KEY", Env: map[string]string{"TOKEN": "t"}}}
+	cfg.Orchestrator.DefaultMCPConfig = `{"mcpServers":{"api":{"type":"http","headers":{"Authorization":"Bearer sk-2"}}}}`
+
+	out := cfg.Redacted()
+	if out.Server.AuthTokens[0] != RedactedValue || out.Server.AuthTokens[1] != "" {
+		t.Fatalf("unexpected auth tokens %v", out.Server.AuthTokens)
+	}
+	if out.Server.APITokens[0].Token != RedactedValue || out.Server.APITokens[1].TokenEnv != "CI_TOKEN" {
+		t.Fatalf("unexpected api tokens %+v", out.Server.APITokens)
+	}
+	claude := out.Engines["claude"]
+	if claude.APIKey != RedactedValue || claude.APIKeyEnv != "KEY" || claude.Env["TOKEN"] != RedactedValue {
+		t.Fatalf("unexpected engine %+v", claude)
+	}
+	if out.Orchestrator.DefaultMCPConfig != RedactedValue {
+		t.Fatalf("expected the inline default_mcp_config to be masked, got %q", out.Orchestrator.DefaultMCPConfig)
+	}
+	cfg.Orchestrator.DefaultMCPConfig = "@.github/mcp-config.json"
+	if got := cfg.Redacted().Orchestrator.DefaultMCPConfig; got != "@.github/mcp-config.json" {
+		t.Fatalf("expected a default_mcp_config path to be kept, got %q", got)
+	}
+	if cfg.Server.AuthTokens[0] != "secret" || cfg.Engines["claude"].APIKey != "sk-1" || cfg.Engines["claude"].Env["TOKEN"] != "t" {
+		t.Fatal("expected the original config to be left untouched")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	dir := t.TempDir()
+	if problems := DefaultConfig().Validate(); HasErrors(problems) {
+		t.Fatalf("expected default config to validate, got %v", problems)
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	data := "orchestrator:\n" +
+		"  default_engine: cursor\n" +
+		"  store_path: " + dir + "\n" +
+		"  allowed_workdirs: [\"" + filepath.Join(dir, "missing") + "\"]\n" +
+		"models:\n  - id: a\n  - id: a\n" +
+		"default_model: b\n" +
+		"engines:\n  claude:\n    models: [{id: x}]\n    default_model: y\n    max_parallel: -1\n" +
+		"server:\n  rate_limit:\n    by: user\n" +
+		"secrets:\n  token:\n    env: MESNADA_TEST_UNSET_SECRET\n" +
+		"defaults:\n  - work_dir: repos\n    engine: claud\n    timeout: soon\n  - match_tags: [x]\n" +
+		"aliases:\n  fast: a\n  quick: fast\n  a: x\n  best: gone\n" +
+		"logging:\n  level: loud\n  format: xml\n  max_size: big\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if want := filepath.Join(dir, "repos"); cfg.Defaults[0].WorkDir != want {
+		t.Fatalf("expected defaults work_dir resolved to %s, got %s", want, cfg.Defaults[0].WorkDir)
+	}
+
+	got := make(map[string]bool)
+	for _, p := range cfg.Validate() {
+		got[p.String()] = true
+	}
+	for _, want := range []string{
+		`error: orchestrator.default_engine: unknown engine "cursor"`,
+		"error: orchestrator.store_path: ",
+		"error: orchestrator.allowed_workdirs[0]: ",
+		`warning: models[1]: duplicate model "a"`,
+		`error: default_model: "b" is not in models`,
+		`error: engines.claude.default_model: "y" is not in engines.claude.models`,
+		"error: engines.claude.max_parallel: must not be negative",
+		`error: server.rate_limit.by: unknown value "user"`,
+		"warning: secrets.token.env: environment variable MESNADA_TEST_UNSET_SECRET is not set",
+		`error: defaults[0].engine: unknown engine "claud"`,
+		`error: defaults[0].timeout: "soon" is not a positive duration`,
+		"warning: defaults[1]: rule sets no engine, model, timeout or tags",
+		`error: aliases.a: alias shadows the model "a"`,
+		`error: aliases.quick: alias points to the alias "fast"`,
+		`warning: aliases.best: "gone" is not in models or any engine's models`,
+		`error: logging.level: unknown level "loud"`,
+		`error: logging.format: unknown format "xml"`,
+		"error: logging.max_size: ",
+	} {
+		found := false
+		for s := range got {
+			if strings.HasPrefix(s, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected a problem starting with %q, got %v", want, got)
+		}
+	}
+}
+
+func TestLoad_EnginesSection(t *testing.T) {
+	yamlData := `default_model: global-default
+models:
+  - id: global-default
+  - global-other
+engines:
+  claude:
+    default_model: sonnet
+    models:
+      - id: sonnet
+        description: Balanced
+      - opus
+    binary: /opt/claude
+    default_args: ["--verbose"]
+    extra_args: ["--max-turns", "5"]
+    env:
+      CLAUDE_DEBUG: "1"
+  gemini:
+    binary: gemini-wrapper
+`
+	jsonData := `{
+  "default_model": "global-default",
+  "models": [{"id": "global-default"}, "global-other"],
+  "engines": {
+    "claude": {
+      "default_model": "sonnet",
+      "models": [{"id": "sonnet", "description": "Balanced"}, "opus"],
+      "binary": "/opt/claude",
+      "default_args": ["--verbose"],
+      "extra_args": ["--max-turns", "5"],
+      "env": {"CLAUDE_DEBUG": "1"}
+    },
+    "gemini": {"binary": "gemini-wrapper"}
+  }
+}`
+	tomlData := `default_model = "global-default"
+models = [{ id = "global-default" }, "global-other"]
+
+[engines.claude]
+default_model = "sonnet"
+models = [{ id = "sonnet", description = "Balanced" }, "opus"]
+binary = "/opt/claude"
+default_args = ["--verbose"]
+extra_args = ["--max-turns", "5"]
+env = { CLAUDE_DEBUG = "1" }
+
+[engines.gemini]
+binary = "gemini-wrapper"
+`
+
+	for name, data := range map[string]string{"config.yaml": yamlData, "config.json": jsonData, "config.toml": tomlData} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			claude := cfg.Engines["claude"]
+			if want := []ModelConfig{{ID: "sonnet", Description: "Balanced"}, {ID: "opus"}}; len(claude.Models) != 2 || claude.Models[0] != want[0] || claude.Models[1] != want[1] {
+				t.Fatalf("unexpected claude models %+v", claude.Models)
+			}
+			if got := strings.Join(claude.DefaultArgs, " ") + "|" + strings.Join(claude.ExtraArgs, " "); got != "--verbose|--max-turns 5" {
+				t.Fatalf("unexpected claude args %q", got)
+			}
+			if claude.Env["CLAUDE_DEBUG"] != "1" {
+				t.Fatalf("unexpected claude env %v", claude.Env)
+			}
+
+			if got := cfg.GetDefaultModelForEngine("claude"); got != "sonnet" {
+				t.Fatalf("expected engine default model, got %q", got)
+			}
+			if got := strings.Join(cfg.GetModelIDsForEngine("claude"), ","); got != "sonnet,opus" {
+				t.Fatalf("expected engine models, got %q", got)
+			}
+			if !cfg.ValidateModelForEngine("claude", "opus") || cfg.ValidateModelForEngine("claude", "global-other") {
+				t.Fatalf("expected claude models to replace the global ones")
+			}
+
+			// Engines without models fall back to the global list and default.
+			if got := cfg.GetDefaultModelForEngine("gemini"); got != "global-default" {
+				t.Fatalf("expected global default model, got %q", got)
+			}
+			if got := strings.Join(cfg.GetModelIDsForEngine("gemini"), ","); got != "global-default,global-other" {
+				t.Fatalf("expected global models, got %q", got)
+			}
+			if got := cfg.GetBinaryForEngine("gemini", "gemini"); got != "gemini-wrapper" {
+				t.Fatalf("expected configured binary, got %q", got)
+			}
+			if got := cfg.GetBinaryForEngine("copilot", "copilot"); got != "copilot" {
+				t.Fatalf("expected default binary for an unconfigured engine, got %q", got)
+			}
+
+			// The section survives a save and reload in the same format.
+			saved := filepath.Join(t.TempDir(), name)
+			if err := cfg.Save(saved); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			reloaded, err := Load(saved)
+			if err != nil {
+				t.Fatalf("Load saved: %v", err)
+			}
+			if got := strings.Join(reloaded.GetModelIDsForEngine("claude"), ","); got != "sonnet,opus" || reloaded.Engines["claude"].Binary != "/opt/claude" {
+				t.Fatalf("engines section lost on save: %+v", reloaded.Engines["claude"])
+			}
+		})
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	yamlData := `default_model: base
+server:
+  port: 8765
+  host: 127.0.0.1
+engines:
+  claude:
+    binary: /usr/bin/claude
+    models: [base, other]
+orchestrator:
+  max_parallel: 2
+  allowed_workdirs: ["/a", "/b"]
+profiles:
+  prod:
+    server:
+      host: 0.0.0.0
+    engines:
+      claude:
+        default_model: other
+    orchestrator:
+      allowed_workdirs: ["/srv"]
+  empty:
+`
+	jsonData := `{
+  "default_model": "base",
+  "server": {"port": 8765, "host": "127.0.0.1"},
+  "engines": {"claude": {"binary": "/usr/bin/claude", "models": ["base", "other"]}},
+  "orchestrator": {"max_parallel": 2, "allowed_workdirs": ["/a", "/b"]},
+  "profiles": {
+    "prod": {
+      "server": {"host": "0.0.0.0"},
+      "engines": {"claude": {"default_model": "other"}},
+      "orchestrator": {"allowed_workdirs": ["/srv"]}
+    },
+    "empty": null
+  }
+}`
+
+	for name, data := range map[string]string{"config.yaml": yamlData, "config.json": jsonData} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+
+			base, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if base.Server.Host != "127.0.0.1" || base.Engines["claude"].DefaultModel != "" {
+				t.Fatalf("expected profiles to be ignored without --profile, got %+v", base.Server)
+			}
+
+			cfg, err := LoadProfile(path, "prod")
+			if err != nil {
+				t.Fatalf("LoadProfile: %v", err)
+			}
+			if cfg.Server.Host != "0.0.0.0" || cfg.Server.Port != 8765 {
+				t.Fatalf("expected host overridden and port kept, got %s:%d", cfg.Server.Host, cfg.Server.Port)
+			}
+			claude := cfg.Engines["claude"]
+			if claude.DefaultModel != "other" || claude.Binary != "/usr/bin/claude" || len(claude.Models) != 2 {
+				t.Fatalf("expected engine section merged, got %+v", claude)
+			}
+			if cfg.Orchestrator.MaxParallel != 2 || len(cfg.Orchestrator.AllowedWorkDirs) != 1 || cfg.Orchestrator.AllowedWorkDirs[0] != "/srv" {
+				t.Fatalf("expected lists replaced and other values kept, got %+v", cfg.Orchestrator)
+			}
+
+			if cfg, err := LoadProfile(path, "empty"); err != nil || cfg.Server.Host != "127.0.0.1" {
+				t.Fatalf("expected an empty profile to keep the base config, got %v", err)
+			}
+			if _, err := LoadProfile(path, "staging"); err == nil || !strings.Contains(err.Error(), "available: empty, prod") {
+				t.Fatalf("expected unknown profile error listing profiles, got %v", err)
+			}
+		})
+	}
+
+	if _, err := LoadProfile(filepath.Join(t.TempDir(), "missing.yaml"), "prod"); err == nil {
+		t.Fatalf("expected an error for a profile without a config file")
+	}
+}
+
+func TestLoad_Include(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	write("shared/org.yaml", `models: [org-a, org-b]
+engines:
+  claude:
+    models: [sonnet, opus]
+    default_model: sonnet
+orchestrator:
+  max_parallel: 3
+profiles:
+  prod:
+    orchestrator:
+      max_parallel: 10
+`)
+	write("conf.d/10-server.json", `{"server": {"port": 9000, "host": "0.0.0.0"}}`)
+	write("conf.d/20-server.yaml", "server:\n  host: 10.0.0.1\n")
+	write("conf.d/notes.txt", "not config")
+	path := write("config.yaml", `include:
+  - shared/org.yaml
+  - conf.d
+engines:
+  claude:
+    default_model: opus
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(cfg.GetModelIDsForEngine("claude"), ","); got != "sonnet,opus" || cfg.Engines["claude"].DefaultModel != "opus" {
+		t.Fatalf("expected included engine with local override, got %+v", cfg.Engines["claude"])
+	}
+	if cfg.Server.Port != 9000 || cfg.Server.Host != "10.0.0.1" || cfg.Orchestrator.MaxParallel != 3 {
+		t.Fatalf("expected conf.d files merged in name order, got %s:%d parallel=%d", cfg.Server.Host, cfg.Server.Port, cfg.Orchestrator.MaxParallel)
+	}
+	if cfg, err := LoadProfile(path, "prod"); err != nil || cfg.Orchestrator.MaxParallel != 10 {
+		t.Fatalf("expected a profile from an included file to apply, got %v", err)
+	}
+
+	write("a.yaml", "include: b.yaml\n")
+	write("b.yaml", "include: [a.yaml]\n")
+	if _, err := Load(filepath.Join(dir, "a.yaml")); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+	missing := write("missing.json", `{"include": ["nope.yaml"]}`)
+	if _, err := Load(missing); err == nil || !strings.Contains(err.Error(), "nope.yaml") {
+		t.Fatalf("expected missing include error, got %v", err)
+	}
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	if project, err := LoadProjectConfig(dir); err != nil || project != nil {
+		t.Fatalf("expected no project config, got %+v (%v)", project, err)
+	}
+
+	path := filepath.Join(dir, ProjectConfigFile)
+	data := "engine: claude\nmodel: sonnet\nmcp_config: .mcp.json\npersona: reviewer\ntimeout: 30m\ntags: [web]\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project, err := LoadProjectConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig: %v", err)
+	}
+	want := ProjectConfig{Engine: "claude", Model: "sonnet", MCPConfig: ".mcp.json", Persona: "reviewer", Timeout: "30m", Tags: []string{"web"}}
+	if project.Engine != want.Engine || project.Model != want.Model || project.MCPConfig != want.MCPConfig || project.Persona != want.Persona || project.Timeout != want.Timeout || strings.Join(project.Tags, ",") != "web" {
+		t.Fatalf("expected %+v, got %+v", want, *project)
+	}
+
+	if err := os.WriteFile(path, []byte("engin: claude\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProjectConfig(dir); err == nil || !strings.Contains(err.Error(), "engin") {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	want, err := GenerateSchema(".")
+	if err != nil {
+		t.Fatalf("GenerateSchema: %v", err)
+	}
+	root, err := os.ReadFile(filepath.Join("..", "..", SchemaFile))
+	if err != nil {
+		t.Fatalf("read root schema: %v", err)
+	}
+	if string(JSONSchema()) != string(want) || string(root) != string(want) {
+		t.Fatalf("%s is out of date, run go generate ./internal/config", SchemaFile)
+	}
+
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(JSONSchema(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	for _, def := range []string{"ModelConfig", "EngineConfig", "ServerConfig", "OrchestratorConfig", "LoggingConfig", "DefaultRule"} {
+		if _, ok := schema.Defs[def]; !ok {
+			t.Errorf("missing $defs/%s", def)
+		}
+	}
+
+	// Every top-level key of the example config is described.
+	var example map[string]interface{}
+	if err := yaml.Unmarshal([]byte(defaultConfigTemplate), &example); err != nil {
+		t.Fatalf("parse example config: %v", err)
+	}
+	for key := range example {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("example key %q missing from schema properties", key)
+		}
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+	write("engines.yaml", "engines:\n  claude:\n    binary: /opt/claude\n")
+	path := write("config.toml", `include = "engines.yaml"
+aliases = { fast = "claude-haiku-4.5" }
+
+[orchestrator]
+max_parallel = 3
+log_dir = "logs"
+
+[profiles.ci.orchestrator]
+max_parallel = 1
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Orchestrator.MaxParallel != 3 || cfg.Orchestrator.LogDir != filepath.Join(dir, "logs") {
+		t.Fatalf("unexpected orchestrator %+v", cfg.Orchestrator)
+	}
+	if cfg.Engines["claude"].Binary != "/opt/claude" || cfg.ResolveModel("fast") != "claude-haiku-4.5" {
+		t.Fatalf("expected the included engines and the aliases, got %+v %v", cfg.Engines, cfg.Aliases)
+	}
+	if cfg, err = LoadProfile(path, "ci"); err != nil || cfg.Orchestrator.MaxParallel != 1 {
+		t.Fatalf("expected the ci profile, got %v %+v", err, cfg)
+	}
+
+	if _, err := Load(write("broken.toml", "[orchestrator\n")); err == nil || !strings.Contains(err.Error(), "TOML") {
+		t.Fatalf("expected a TOML parse error, got %v", err)
+	}
+
+	// A .toml init path gets the template's values in TOML.
+	initPath := filepath.Join(dir, "init", "config.toml")
+	if err := InitConfig(initPath); err != nil {
+		t.Fatalf("InitConfig: %v", err)
+	}
+	initCfg, err := Load(initPath)
+	if err != nil {
+		t.Fatalf("Load init config: %v", err)
+	}
+	if len(initCfg.Engines) == 0 || initCfg.GetDefaultModelForEngine("claude") == "" {
+		t.Fatalf("expected the template engines, got %+v", initCfg.Engines)
+	}
+}
+
+func TestLoad_OAuthNeedsAudience(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+	}
+
+	write("server:\n  oauth:\n    issuer: https://auth.example\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "audience") {
+		t.Fatalf("expected OAuth without an audience to be rejected, got %v", err)
+	}
+	write("server:\n  oauth:\n    issuer: https://auth.example\n    resource: https://mesnada.example/mcp\n    api_scopes:\n      tasks:write: write\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "unknown scope") {
+		t.Fatalf("expected an unknown API scope to be rejected, got %v", err)
+	}
+	write("server:\n  oauth:\n    issuer: https://auth.example\n    resource: https://mesnada.example/mcp\n    api_scopes:\n      tasks:write: spawn\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(cfg.Server.OAuth.APIScopesFor([]string{"tasks:write", "read", "other"}), ","); got != "spawn,read" {
+		t.Fatalf("unexpected API scopes %q", got)
+	}
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// A config document is a decoded config file: a mapping whose values are
+// mappings, lists and scalars as decoded from YAML, JSON or TOML. Documents are
+// merged key by key before being decoded into a Config, which is how
+// `include:` files and profiles combine.
+
+// hasIncludes reports whether config file data has a top-level include key.
+func hasIncludes(data []byte, format configFormat) bool {
+	doc, err := decodeDocument(data, format)
+	if err != nil {
+		return false // reported when the data is decoded into the config
+	}
+	_, ok := doc["include"]
+	return ok
+}
+
+// loadDocument reads the config document at path with its `include:` files
+// merged under it: included files in order, then the file's own keys on
+// top. Include entries are files, directories (their .yaml, .yml, .json and
+// .toml files in name order) or glob patterns, relative to the including file.
+// stack holds the files being included, to report cycles.
+func loadDocument(path string, stack []string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config path %s: %w", path, err)
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("config include cycle: %s", strings.Join(append(stack, abs), " -> "))
+		}
+	}
+	stack = append(stack, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	doc, err := decodeDocument(data, formatOf(abs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+
+	entries, err := includeEntries(doc["include"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+	delete(doc, "include")
+
+	merged := map[string]interface{}{}
+	for _, entry := range entries {
+		files, err := includeFiles(filepath.Dir(abs), entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", abs, err)
+		}
+		for _, file := range files {
+			included, err := loadDocument(file, stack)
+			if err != nil {
+				return nil, err
+			}
+			merged = mergeValue(merged, included).(map[string]interface{})
+		}
+	}
+	return mergeValue(merged, doc).(map[string]interface{}), nil
+}
+
+// includeEntries reads an include value: one path or a list of paths.
+func includeEntries(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		entries := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("include entries must be paths, got %v", e)
+			}
+			entries = append(entries, s)
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("include must be a path or a list of paths")
+}
+
+// includeFiles lists the config files an include entry names.
+func includeFiles(baseDir, entry string) ([]string, error) {
+	path := resolvePath(entry, baseDir)
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", entry, err)
+		}
+		sort.Strings(matches)
+		return matches, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("include %q: %w", entry, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("include %q: %w", entry, err)
+	}
+	var files []string
+	for _, e := range dirEntries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if !e.IsDir() && (ext == ".yaml" || ext == ".yml" || ext == ".json" || ext == ".toml") {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	return files, nil // ReadDir sorts by name
+}
+
+// applyProfile merges the named entry of the document's `profiles:`
+// section over its top-level keys.
+func applyProfile(doc map[string]interface{}, profile string) (map[string]interface{}, error) {
+	profiles, _ := toStringMap(doc["profiles"])
+	overlay, ok := profiles[profile]
+	if !ok {
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("profile %q not found: the config has no profiles", profile)
+		}
+		return nil, fmt.Errorf("profile %q not found (available: %s)", profile, strings.Join(sortedKeys(profiles), ", "))
+	}
+	if overlay == nil {
+		return doc, nil
+	}
+	values, ok := toStringMap(overlay)
+	if !ok {
+		return nil, fmt.Errorf("profile %q must be a mapping", profile)
+	}
+	delete(values, "profiles")
+	delete(values, "include")
+	return mergeValue(doc, values).(map[string]interface{}), nil
+}
+
+// decodeDocument decodes config file data into a document. JSON numbers
+// are kept as integers where they are whole, so they re-encode as such.
+func decodeDocument(data []byte, format configFormat) (map[string]interface{}, error) {
+	switch format {
+	case formatYAML:
+		var decoded interface{}
+		if err := yaml.Unmarshal(data, &decoded); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+		}
+		if decoded == nil {
+			return map[string]interface{}{}, nil
+		}
+		doc, ok := toStringMap(decoded)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse YAML config: not a mapping")
+		}
+		return doc, nil
+	case formatTOML:
+		doc := map[string]interface{}{}
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML config: %w", err)
+		}
+		return doc, nil
+	}
+
+	var decoded interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON config: %w", err)
+	}
+	doc, ok := jsonNumbers(decoded).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to parse JSON config: not an object")
+	}
+	return doc, nil
+}
+
+// jsonNumbers replaces the json.Numbers in a decoded JSON value with int64
+// or float64 values.
+func jsonNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonNumbers(e)
+		}
+	}
+	return v
+}
+
+// mergeValue merges override into base: mappings key by key, recursively;
+// anything else is replaced.
+func mergeValue(base, override interface{}) interface{} {
+	b, ok := toStringMap(base)
+	o, ok2 := toStringMap(override)
+	if !ok || !ok2 {
+		return override
+	}
+	merged := make(map[string]interface{}, len(b)+len(o))
+	for k, v := range b {
+		merged[k] = v
+	}
+	for k, v := range o {
+		if prev, exists := merged[k]; exists {
+			v = mergeValue(prev, v)
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// toStringMap returns a decoded YAML, JSON or TOML mapping with string keys.
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			out[fmt.Sprint(k)] = val
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// configFormat is the syntax of a config file.
+type configFormat int
+
+const (
+	formatJSON configFormat = iota
+	formatYAML
+	formatTOML
+)
+
+// formatOf detects the format of a config file by extension: .yaml and
+// .yml are YAML, .toml is TOML and anything else is JSON.
+func formatOf(path string) configFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	}
+	return formatJSON
+}
+
+// tomlValue prepares a document value for encoding as TOML: mappings get
+// string keys and nulls, which TOML cannot represent, are dropped.
+func tomlValue(v interface{}) interface{} {
+	if m, ok := toStringMap(v); ok {
+		out := make(map[string]interface{}, len(m))
+		for k, e := range m {
+			if e != nil {
+				out[k] = tomlValue(e)
+			}
+		}
+		return out
+	}
+	if list, ok := v.([]interface{}); ok {
+		out := make([]interface{}, 0, len(list))
+		for _, e := range list {
+			if e != nil {
+				out = append(out, tomlValue(e))
+			}
+		}
+		return out
+	}
+	return v
+}
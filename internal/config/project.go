@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ProjectConfigFile is the name of the per-project settings file looked up
+// in a task's work dir.
+const ProjectConfigFile = ".mesnada.yaml"
+
+// ProjectConfig holds the settings a repository carries for the tasks run
+// in it. They fill in what spawn requests omit, ahead of the server's
+// defaults rules.
+type ProjectConfig struct {
+	Engine string `json:"engine,omitempty" yaml:"engine,omitempty"`
+	Model  string `json:"model,omitempty" yaml:"model,omitempty"`
+	// MCPConfig is resolved like the spawn mcp_config: relative paths are
+	// relative to the work dir.
+	MCPConfig string `json:"mcp_config,omitempty" yaml:"mcp_config,omitempty"`
+	Persona   string `json:"persona,omitempty" yaml:"persona,omitempty"`
+	Timeout   string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Tags are added to the task's tags.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// LoadProjectConfig reads the .mesnada.yaml file of dir. It returns nil
+// without error when there is none. Unknown keys are an error, so typos do
+// not go unnoticed.
+func LoadProjectConfig(dir string) (*ProjectConfig, error) {
+	path := filepath.Join(dir, ProjectConfigFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var project ProjectConfig
+	if err := yaml.UnmarshalStrict(data, &project); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &project, nil
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+//go:generate go run ./schemagen
+
+// SchemaFile is the name of the generated JSON Schema of the config file.
+const SchemaFile = "config.schema.json"
+
+//go:embed config.schema.json
+var schemaJSON []byte
+
+// JSONSchema returns the JSON Schema of the config file, for editors and
+// for GET /api/config/schema. It is generated from the Config types by
+// `go generate ./internal/config`.
+func JSONSchema() []byte {
+	return schemaJSON
+}
+
+// schemaEnums lists the accepted values of fields validated by name.
+var schemaEnums = map[string][]string{
+	"OrchestratorConfig.DefaultEngine": engineNameList(),
+	"DefaultRule.Engine":               engineNameList(),
+	"EngineConfig.Sandbox":             {models.SandboxNone, models.SandboxDocker, models.SandboxKubernetes},
+	"SandboxConfig.Mode":               {models.SandboxNone, models.SandboxDocker, models.SandboxKubernetes},
+	"RateLimitConfig.By":               {"ip", "token"},
+	"LoggingConfig.Level":              {"debug", "info", "warn", "error"},
+	"LoggingConfig.Format":             {"text", "json"},
+}
+
+func engineNameList() []string {
+	var names []string
+	for _, e := range models.AllEngines() {
+		names = append(names, string(e))
+	}
+	return names
+}
+
+// GenerateSchema builds the JSON Schema of Config, describing each field
+// with its doc comment from the Go sources in srcDir.
+func GenerateSchema(srcDir string) ([]byte, error) {
+	docs, err := fieldDocs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	g := schemaGenerator{docs: docs, defs: map[string]interface{}{}}
+	root := g.object(reflect.TypeOf(Config{}))
+	props := root["properties"].(map[string]interface{})
+	// Keys handled before decoding, see LoadProfile.
+	props["include"] = map[string]interface{}{
+		"description": "Config files, directories or glob patterns merged under this file, relative to it.",
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+	props["profiles"] = map[string]interface{}{
+		"description":          "Named sets of values merged over this config when selected with --profile.",
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"$ref": "#"},
+	}
+
+	schema := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "mesnada configuration",
+		"$defs":   g.defs,
+	}
+	for k, v := range root {
+		schema[k] = v
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+type schemaGenerator struct {
+	docs map[string]string // "Type.Field" or "Type" -> doc comment
+	defs map[string]interface{}
+}
+
+// object describes a struct type by its JSON fields.
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		key := t.Name() + "." + f.Name
+		prop := g.value(f.Type)
+		if doc := g.docs[key]; doc != "" {
+			prop = withDescription(prop, doc)
+		}
+		if enum := schemaEnums[key]; len(enum) > 0 {
+			prop["enum"] = enum
+		}
+		if key == "Config.Engines" {
+			prop["propertyNames"] = map[string]interface{}{"enum": engineNameList()}
+		}
+		props[name] = prop
+	}
+	obj := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if doc := g.docs[t.Name()]; doc != "" {
+		obj["description"] = doc
+	}
+	return obj
+}
+
+// value describes a field type, adding struct types to $defs.
+func (g *schemaGenerator) value(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.value(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": g.value(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.value(t.Elem())}
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // reserve, for recursive types
+			def := g.object(t)
+			if t == reflect.TypeOf(ModelConfig{}) {
+				// See ModelConfig.UnmarshalYAML.
+				def = map[string]interface{}{
+					"description": def["description"],
+					"anyOf":       []interface{}{map[string]interface{}{"type": "string", "description": "Model id"}, def},
+				}
+				delete(def["anyOf"].([]interface{})[1].(map[string]interface{}), "description")
+			}
+			g.defs[t.Name()] = def
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// withDescription adds a description to a property. A $ref is wrapped so
+// the description is not ignored next to it.
+func withDescription(prop map[string]interface{}, doc string) map[string]interface{} {
+	if ref, ok := prop["$ref"]; ok {
+		return map[string]interface{}{"description": doc, "allOf": []interface{}{map[string]interface{}{"$ref": ref}}}
+	}
+	prop["description"] = doc
+	return prop
+}
+
+// fieldDocs reads the doc comments of the struct types and fields in the
+// non-test Go files of dir.
+func fieldDocs(dir string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	docs := map[string]string{}
+	fset := token.NewFileSet()
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				if doc := commentText(gen.Doc); doc != "" {
+					docs[ts.Name.Name] = doc
+				}
+				for _, f := range st.Fields.List {
+					doc := commentText(f.Doc)
+					if doc == "" {
+						doc = commentText(f.Comment)
+					}
+					for _, name := range f.Names {
+						if doc != "" {
+							docs[ts.Name.Name+"."+name.Name] = doc
+						}
+					}
+				}
+			}
+		}
+	}
+	return docs, nil
+}
+
+// commentText returns a comment as one line.
+func commentText(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(cg.Text()), " ")
+}
@@ -0,0 +1,24 @@
+// Command schemagen writes the JSON Schema of the config file. It is run
+// by `go generate ./internal/config`, from that directory, and writes the
+// embedded copy next to the sources and the one at the repository root.
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+func main() {
+	data, err := config.GenerateSchema(".")
+	if err != nil {
+		log.Fatalf("Failed to generate schema: %v", err)
+	}
+	for _, path := range []string{config.SchemaFile, filepath.Join("..", "..", config.SchemaFile)} {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			log.Fatalf("Failed to write schema: %v", err)
+		}
+	}
+}
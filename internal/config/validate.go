@@ -0,0 +1,281 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// Problem is an issue found when validating a config.
+type Problem struct {
+	// Field is the config key concerned, e.g. "engines.claude.default_model".
+	Field   string
+	Message string
+	// Warning marks problems that only affect some tasks; the server starts
+	// and other tasks run.
+	Warning bool
+}
+
+func (p Problem) String() string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%s: %s: %s", level, p.Field, p.Message)
+}
+
+// HasErrors reports whether any of the problems is an error.
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if !p.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the values and paths of a loaded config that would
+// otherwise only fail at startup or once a task runs: engine and sandbox
+// names, model lists, sizes and durations, and the files and directories the
+// config points at. Engine binaries and MCP configs are checked by
+// agent.CheckConfig.
+func (c *Config) Validate() []Problem {
+	var problems []Problem
+	add := func(warning bool, field, format string, args ...interface{}) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, args...), Warning: warning})
+	}
+
+	// Engines and models.
+	if e := c.Orchestrator.DefaultEngine; !models.ValidEngine(models.Engine(e)) {
+		add(false, "orchestrator.default_engine", "unknown engine %q (use %s)", e, engineNames())
+	}
+	checkModels(add, "models", c.Models)
+	if c.DefaultModel != "" && len(c.Models) > 0 && !c.ValidateModel(c.DefaultModel) {
+		add(false, "default_model", "%q is not in models", c.DefaultModel)
+	}
+	for _, name := range sortedKeys(c.Engines) {
+		engine := c.Engines[name]
+		field := "engines." + name
+		if name == "" || !models.ValidEngine(models.Engine(name)) {
+			add(false, "engines", "unknown engine %q (use %s)", name, engineNames())
+			continue
+		}
+		checkModels(add, field+".models", engine.Models)
+		if engine.DefaultModel != "" && !c.ValidateModelForEngine(name, engine.DefaultModel) {
+			source := field + ".models"
+			if len(engine.Models) == 0 {
+				source = "models"
+			}
+			add(false, field+".default_model", "%q is not in %s", engine.DefaultModel, source)
+		}
+		if !models.ValidSandbox(engine.Sandbox) {
+			add(false, field+".sandbox", "unknown sandbox mode %q (use none, docker or kubernetes)", engine.Sandbox)
+		}
+		if engine.MaxParallel < 0 {
+			add(false, field+".max_parallel", "must not be negative")
+		}
+		if engine.APIKey != "" && engine.APIKeyEnv != "" {
+			add(false, field, "api_key and api_key_env are mutually exclusive")
+		}
+		if engine.APIKeyEnv != "" && os.Getenv(engine.APIKeyEnv) == "" {
+			add(true, field+".api_key_env", "environment variable %s is not set", engine.APIKeyEnv)
+		}
+	}
+
+	// Orchestrator values and paths.
+	o := c.Orchestrator
+	if o.MaxParallel < 0 {
+		add(false, "orchestrator.max_parallel", "must not be negative")
+	}
+	if _, err := o.MaxLogSizeBytes(); err != nil {
+		add(false, "orchestrator.max_log_size", "%v", err)
+	}
+	if !models.ValidSandbox(o.Sandbox.Mode) {
+		add(false, "orchestrator.sandbox.mode", "unknown sandbox mode %q (use none, docker or kubernetes)", o.Sandbox.Mode)
+	}
+	// The store and log directories are created when missing.
+	if info, err := os.Stat(o.StorePath); err == nil && info.IsDir() {
+		add(false, "orchestrator.store_path", "%s is a directory, not a file", o.StorePath)
+	}
+	if info, err := os.Stat(o.LogDir); err == nil && !info.IsDir() {
+		add(false, "orchestrator.log_dir", "%s is not a directory", o.LogDir)
+	}
+	if o.PersonaPath != "" {
+		checkDir(add, true, "orchestrator.persona_path", o.PersonaPath)
+	}
+	for i, root := range o.AllowedWorkDirs {
+		checkDir(add, false, fmt.Sprintf("orchestrator.allowed_workdirs[%d]", i), root)
+	}
+
+	// Server values and files.
+	s := c.Server
+	if s.Port < 0 || s.Port > 65535 {
+		add(false, "server.port", "%d is not a valid port", s.Port)
+	}
+	if _, err := s.SessionTTLDuration(); err != nil {
+		add(false, "server.session_ttl", "%v", err)
+	}
+	if err := s.TLS.Validate(); err != nil {
+		add(false, "server.tls", "%v", err)
+	}
+	for _, f := range []struct{ field, path string }{
+		{"server.tls.cert_file", s.TLS.CertFile},
+		{"server.tls.key_file", s.TLS.KeyFile},
+	} {
+		if f.path != "" {
+			checkFile(add, false, f.field, f.path)
+		}
+	}
+	if by := s.RateLimit.By; by != "" && by != "ip" && by != "token" {
+		add(false, "server.rate_limit.by", "unknown value %q (use ip or token)", by)
+	}
+	if s.AuthTokenEnv != "" && os.Getenv(s.AuthTokenEnv) == "" {
+		add(true, "server.auth_token_env", "environment variable %s is not set", s.AuthTokenEnv)
+	}
+
+	// Secrets are read when a task starts.
+	for _, name := range sortedKeys(c.Secrets) {
+		secret := c.Secrets[name]
+		field := "secrets." + name
+		switch {
+		case secret.Env != "" && secret.File != "":
+			add(false, field, "env and file are mutually exclusive")
+		case secret.Env == "" && secret.File == "":
+			add(false, field, "needs an env or file source")
+		case secret.Env != "":
+			if _, ok := os.LookupEnv(secret.Env); !ok {
+				add(true, field+".env", "environment variable %s is not set", secret.Env)
+			}
+		default:
+			checkFile(add, true, field+".file", secret.File)
+		}
+	}
+
+	// Logging.
+	l := c.Logging
+	switch l.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		add(false, "logging.level", "unknown level %q (use debug, info, warn or error)", l.Level)
+	}
+	if l.Format != "" && l.Format != "text" && l.Format != "json" {
+		add(false, "logging.format", "unknown format %q (use text or json)", l.Format)
+	}
+	if _, err := l.MaxSizeBytes(); err != nil {
+		add(false, "logging.max_size", "%v", err)
+	}
+	if l.MaxBackups < 0 {
+		add(false, "logging.max_backups", "must not be negative")
+	}
+	if l.File != "" {
+		if info, err := os.Stat(l.File); err == nil && info.IsDir() {
+			add(false, "logging.file", "%s is a directory, not a file", l.File)
+		}
+	}
+
+	// Model aliases resolve once, to a model of the config.
+	known := make(map[string]bool)
+	for _, m := range c.Models {
+		known[m.ID] = true
+	}
+	for _, engine := range c.Engines {
+		for _, m := range engine.Models {
+			known[m.ID] = true
+		}
+	}
+	for _, alias := range sortedKeys(c.Aliases) {
+		target := c.Aliases[alias]
+		field := "aliases." + alias
+		switch {
+		case target == "":
+			add(false, field, "alias has no model")
+		case known[alias]:
+			add(false, field, "alias shadows the model %q", alias)
+		case c.Aliases[target] != "":
+			add(false, field, "alias points to the alias %q; aliases are resolved once", target)
+		case len(known) > 0 && !known[target]:
+			add(true, field, "%q is not in models or any engine's models", target)
+		}
+	}
+
+	// Spawn defaults.
+	for i, rule := range c.Defaults {
+		field := fmt.Sprintf("defaults[%d]", i)
+		if rule.Engine != "" && !models.ValidEngine(models.Engine(rule.Engine)) {
+			add(false, field+".engine", "unknown engine %q (use %s)", rule.Engine, engineNames())
+		}
+		if rule.Model != "" && rule.Engine != "" && len(c.GetModelsForEngine(rule.Engine)) > 0 && !c.ValidateModelForEngine(rule.Engine, rule.Model) {
+			add(true, field+".model", "%q is not a configured model of %s", rule.Model, rule.Engine)
+		}
+		if rule.Timeout != "" {
+			if d, err := time.ParseDuration(rule.Timeout); err != nil || d <= 0 {
+				add(false, field+".timeout", "%q is not a positive duration", rule.Timeout)
+			}
+		}
+		if rule.Engine == "" && rule.Model == "" && rule.Timeout == "" && len(rule.Tags) == 0 {
+			add(true, field, "rule sets no engine, model, timeout or tags")
+		}
+	}
+
+	return problems
+}
+
+// checkModels reports model entries without an id and duplicated ids.
+func checkModels(add func(bool, string, string, ...interface{}), field string, list []ModelConfig) {
+	seen := make(map[string]bool)
+	for i, m := range list {
+		switch {
+		case strings.TrimSpace(m.ID) == "":
+			add(false, fmt.Sprintf("%s[%d]", field, i), "model has no id")
+		case seen[m.ID]:
+			add(true, fmt.Sprintf("%s[%d]", field, i), "duplicate model %q", m.ID)
+		}
+		seen[m.ID] = true
+	}
+}
+
+// checkDir reports a path that is missing or not a directory.
+func checkDir(add func(bool, string, string, ...interface{}), warning bool, field, path string) {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		add(warning, field, "%v", err)
+	case !info.IsDir():
+		add(false, field, "%s is not a directory", path)
+	}
+}
+
+// checkFile reports a path that cannot be read as a file.
+func checkFile(add func(bool, string, string, ...interface{}), warning bool, field, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		add(warning, field, "%v", err)
+		return
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		add(false, field, "%s is a directory, not a file", path)
+	}
+}
+
+// engineNames lists the valid engine names for error messages.
+func engineNames() string {
+	var names []string
+	for _, e := range models.AllEngines() {
+		names = append(names, string(e))
+	}
+	return strings.Join(names, ", ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
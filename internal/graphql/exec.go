@@ -0,0 +1,500 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Resolver resolves a root query field.
+type Resolver func(ctx context.Context, field *Field) (interface{}, error)
+
+// Schema describes the queries a server answers. Root fields resolve to Go
+// values, whose fields are selected by their JSON names; maps with string
+// keys select entries by key and are returned whole without a selection.
+type Schema struct {
+	// Query resolves the root fields.
+	Query map[string]Resolver
+	// Resolve, when set, resolves fields computed from other data, such as a
+	// task's dependencies. It reports whether it handled the field; fields it
+	// does not handle are looked up on parent.
+	Resolve func(ctx context.Context, parent interface{}, field *Field) (interface{}, bool, error)
+	// TypeName, when set, names a value's type for __typename. The Go type
+	// name is used when it returns "".
+	TypeName func(v interface{}) string
+}
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is a GraphQL response. Data is absent when the request failed
+// before execution; otherwise fields that failed are null and listed in
+// Errors.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error, with the path of the field it concerns.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute parses and runs a query.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	op, err := selectOperation(req)
+	if err == nil {
+		err = bindVariables(op, req.Variables)
+	}
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	e := &executor{schema: s, ctx: ctx}
+	data, err := e.root(op.selections)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// selectOperation parses the query and picks the operation to run.
+func selectOperation(req Request) (*operation, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	ops, err := parseDocument(req.Query)
+	if err != nil {
+		return nil, err
+	}
+	if req.OperationName == "" {
+		if len(ops) > 1 {
+			return nil, fmt.Errorf("operationName is required when the query has several operations")
+		}
+		return ops[0], nil
+	}
+	for _, op := range ops {
+		if op.name == req.OperationName {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("operation %q not found", req.OperationName)
+}
+
+// bindVariables resolves the arguments of every field in op against the
+// request variables and the operation's defaults.
+func bindVariables(op *operation, values map[string]interface{}) error {
+	vars := make(map[string]interface{}, len(op.variables))
+	for _, def := range op.variables {
+		v, ok := values[def.name]
+		switch {
+		case ok && v != nil:
+			vars[def.name] = v
+		case def.hasDef:
+			vars[def.name], _ = resolveValue(def.def, nil)
+		case def.required:
+			return fmt.Errorf("variable $%s is required", def.name)
+		default:
+			vars[def.name] = nil
+		}
+	}
+
+	var bind func(fields []*Field) error
+	bind = func(fields []*Field) error {
+		for _, f := range fields {
+			if len(f.args) > 0 {
+				f.Args = make(map[string]interface{}, len(f.args))
+				for name, raw := range f.args {
+					v, err := resolveValue(raw, vars)
+					if err != nil {
+						return err
+					}
+					if v != nil {
+						f.Args[name] = v
+					}
+				}
+			}
+			if err := bind(f.Selections); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return bind(op.selections)
+}
+
+// resolveValue substitutes variables and turns enum values into strings.
+func resolveValue(v interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case variableRef:
+		value, ok := vars[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", string(v))
+		}
+		return value, nil
+	case enumValue:
+		return string(v), nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], err = resolveValue(item, vars); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var err error
+			if obj[key], err = resolveValue(item, vars); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return v, nil
+}
+
+// CheckArgs returns an error for the first argument not in allowed.
+func (f *Field) CheckArgs(allowed ...string) error {
+	for name := range f.args {
+		found := false
+		for _, a := range allowed {
+			found = found || a == name
+		}
+		if !found {
+			return fmt.Errorf("unknown argument %q on field %q", name, f.Name)
+		}
+	}
+	return nil
+}
+
+// StringArg returns a string argument, or "" when it is absent.
+func (f *Field) StringArg(name string) (string, error) {
+	switch v := f.Args[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("argument %q must be a string", name)
+}
+
+// IntArg returns an integer argument, or 0 when it is absent.
+func (f *Field) IntArg(name string) (int, error) {
+	switch v := f.Args[name].(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	case float64:
+		// Variables decoded from JSON are floats.
+		if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+// BoolArg returns a boolean argument, or false when it is absent.
+func (f *Field) BoolArg(name string) (bool, error) {
+	switch v := f.Args[name].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+	return false, fmt.Errorf("argument %q must be a boolean", name)
+}
+
+// StringsArg returns a list of strings argument. A single string is read
+// as a list of one, as GraphQL coerces list inputs.
+func (f *Field) StringsArg(name string) ([]string, error) {
+	switch v := f.Args[name].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("argument %q must be a list of strings", name)
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("argument %q must be a list of strings", name)
+}
+
+// executor runs one operation, collecting field errors.
+type executor struct {
+	schema *Schema
+	ctx    context.Context
+	errors []Error
+}
+
+// queryError reports a query that does not fit the data, such as an unknown
+// field. Unlike resolver errors it fails the whole request.
+type queryError struct{ msg string }
+
+func (e *queryError) Error() string { return e.msg }
+
+func (e *executor) root(fields []*Field) (*object, error) {
+	out := &object{}
+	for _, f := range fields {
+		if f.Name == "__typename" {
+			out.set(f.Key(), "Query")
+			continue
+		}
+		resolve, ok := e.schema.Query[f.Name]
+		if !ok {
+			return nil, &queryError{fmt.Sprintf("cannot query field %q on type Query", f.Name)}
+		}
+		path := []interface{}{f.Key()}
+		v, err := resolve(e.ctx, f)
+		if err != nil {
+			e.errors = append(e.errors, Error{Message: err.Error(), Path: path})
+			out.set(f.Key(), nil)
+			continue
+		}
+		value, err := e.complete(reflect.ValueOf(v), f, path)
+		if err != nil {
+			return nil, err
+		}
+		out.set(f.Key(), value)
+	}
+	return out, nil
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isScalar reports whether values of t encode themselves, like time.Time.
+func isScalar(t reflect.Type) bool {
+	return t.Implements(jsonMarshaler) || t.Implements(textMarshaler)
+}
+
+// complete turns a resolved value into the result for f's selection.
+func (e *executor) complete(rv reflect.Value, f *Field, path []interface{}) (interface{}, error) {
+	for rv.IsValid() && !isScalar(rv.Type()) && (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, nil
+	}
+
+	t := rv.Type()
+	switch {
+	case isScalar(t) || (rv.CanAddr() && isScalar(reflect.PointerTo(t))):
+		if len(f.Selections) > 0 {
+			return nil, &queryError{fmt.Sprintf("field %q is a scalar and cannot have a selection", f.Name)}
+		}
+		if !isScalar(t) {
+			return rv.Addr().Interface(), nil
+		}
+		return rv.Interface(), nil
+
+	case t.Kind() == reflect.Struct:
+		if len(f.Selections) == 0 {
+			return nil, &queryError{fmt.Sprintf("field %q of type %s must have a selection of subfields", f.Name, e.typeName(rv))}
+		}
+		return e.object(rv, f.Selections, path)
+
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8:
+		if t.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			item, err := e.complete(rv.Index(i), f, append(path[:len(path):len(path)], i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = item
+		}
+		return list, nil
+
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && len(f.Selections) > 0:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		out := &object{}
+		for _, sel := range f.Selections {
+			entry := rv.MapIndex(reflect.ValueOf(sel.Name).Convert(t.Key()))
+			var item interface{}
+			if entry.IsValid() {
+				var err error
+				if item, err = e.complete(entry, sel, append(path[:len(path):len(path)], sel.Key())); err != nil {
+					return nil, err
+				}
+			}
+			out.set(sel.Key(), item)
+		}
+		return out, nil
+	}
+
+	if len(f.Selections) > 0 && t.Kind() != reflect.Map {
+		return nil, &queryError{fmt.Sprintf("field %q is a scalar and cannot have a selection", f.Name)}
+	}
+	return rv.Interface(), nil
+}
+
+// object selects fields of a struct.
+func (e *executor) object(rv reflect.Value, fields []*Field, path []interface{}) (*object, error) {
+	parent := rv.Interface()
+	if rv.CanAddr() {
+		parent = rv.Addr().Interface()
+	}
+	out := &object{}
+	for _, f := range fields {
+		fieldPath := append(path[:len(path):len(path)], f.Key())
+		if f.Name == "__typename" {
+			out.set(f.Key(), e.typeName(rv))
+			continue
+		}
+
+		if e.schema.Resolve != nil {
+			v, ok, err := e.schema.Resolve(e.ctx, parent, f)
+			if ok {
+				if err != nil {
+					e.errors = append(e.errors, Error{Message: err.Error(), Path: fieldPath})
+					out.set(f.Key(), nil)
+					continue
+				}
+				value, err := e.complete(reflect.ValueOf(v), f, fieldPath)
+				if err != nil {
+					return nil, err
+				}
+				out.set(f.Key(), value)
+				continue
+			}
+		}
+
+		index, ok := jsonFields(rv.Type())[f.Name]
+		if !ok {
+			return nil, &queryError{fmt.Sprintf("cannot query field %q on type %s", f.Name, e.typeName(rv))}
+		}
+		if len(f.args) > 0 {
+			return nil, &queryError{fmt.Sprintf("field %q takes no arguments", f.Name)}
+		}
+		value, err := e.complete(rv.FieldByIndex(index), f, fieldPath)
+		if err != nil {
+			return nil, err
+		}
+		out.set(f.Key(), value)
+	}
+	return out, nil
+}
+
+func (e *executor) typeName(rv reflect.Value) string {
+	if e.schema.TypeName != nil {
+		v := rv.Interface()
+		if rv.CanAddr() {
+			v = rv.Addr().Interface()
+		}
+		if name := e.schema.TypeName(v); name != "" {
+			return name
+		}
+	}
+	return rv.Type().Name()
+}
+
+var fieldCache sync.Map // reflect.Type -> map[string][]int
+
+// jsonFields maps the JSON names of a struct's fields to their indexes,
+// promoting the fields of embedded structs as encoding/json does.
+func jsonFields(t reflect.Type) map[string][]int {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+
+	fields := make(map[string][]int)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			fieldIndex := append(index[:len(index):len(index)], i)
+			if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+				walk(sf.Type, fieldIndex)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			// Shallower fields win over promoted ones.
+			if existing, ok := fields[name]; !ok || len(existing) > len(fieldIndex) {
+				fields[name] = fieldIndex
+			}
+		}
+	}
+	walk(t, nil)
+
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// object is a result object that keeps its fields in selection order.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *object) set(key string, value interface{}) {
+	if o.values == nil {
+		o.values = make(map[string]interface{})
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
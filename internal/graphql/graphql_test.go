@@ -0,0 +1,159 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	ID string `json:"id"`
+}
+
+type testItem struct {
+	testBase
+	Name     string            `json:"name"`
+	Created  time.Time         `json:"created_at"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Children []*testItem       `json:"children,omitempty"`
+	Secret   string            `json:"-"`
+}
+
+func testSchema() *Schema {
+	root := &testItem{
+		testBase: testBase{ID: "root"},
+		Name:     "Root",
+		Created:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Labels:   map[string]string{"team": "core"},
+		Children: []*testItem{{testBase: testBase{ID: "a"}}, {testBase: testBase{ID: "b"}}},
+	}
+	return &Schema{
+		Query: map[string]Resolver{
+			"item": func(ctx context.Context, f *Field) (interface{}, error) {
+				if err := f.CheckArgs("id"); err != nil {
+					return nil, err
+				}
+				id, err := f.StringArg("id")
+				if err != nil {
+					return nil, err
+				}
+				if id != root.ID {
+					return nil, nil
+				}
+				return root, nil
+			},
+			"echo": func(ctx context.Context, f *Field) (interface{}, error) {
+				return f.Args, nil
+			},
+			"fail": func(ctx context.Context, f *Field) (interface{}, error) {
+				return nil, errors.New("boom")
+			},
+		},
+		Resolve: func(ctx context.Context, parent interface{}, f *Field) (interface{}, bool, error) {
+			if item, ok := parent.(*testItem); ok && f.Name == "count" {
+				return len(item.Children), true, nil
+			}
+			return nil, false, nil
+		},
+		TypeName: func(v interface{}) string {
+			if _, ok := v.(*testItem); ok {
+				return "Item"
+			}
+			return ""
+		},
+	}
+}
+
+func execute(t *testing.T, req Request) string {
+	t.Helper()
+	b, err := json.Marshal(testSchema().Execute(context.Background(), req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExecute(t *testing.T) {
+	cases := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "selection order, aliases, embedded fields and computed fields",
+			req: Request{Query: `
+				# comments and commas are ignored
+				{ item(id: "root") { name, first: id, __typename, count, children { id } } }`},
+			want: `{"data":{"item":{"name":"Root","first":"root","__typename":"Item","count":2,"children":[{"id":"a"},{"id":"b"}]}}}`,
+		},
+		{
+			name: "scalars with marshalers and maps",
+			req:  Request{Query: `{ item(id: "root") { created_at labels byKey: labels { team other } } }`},
+			want: `{"data":{"item":{"created_at":"2026-01-02T03:04:05Z","labels":{"team":"core"},"byKey":{"team":"core","other":null}}}}`,
+		},
+		{
+			name: "variables, defaults and named operations",
+			req: Request{
+				Query:         `query A { __typename } query B($id: String = "root", $n: Int) { item(id: $id) { id } echo(n: $n, e: ENUM, l: [1, 2.5, """` + "\n    block\n      text\n  " + `"""], o: {k: null}) }`,
+				OperationName: "B",
+				Variables:     map[string]interface{}{"n": 3.0},
+			},
+			want: `{"data":{"item":{"id":"root"},"echo":{"e":"ENUM","l":[1,2.5,"block\n  text"],"n":3,"o":{"k":null}}}}`,
+		},
+		{
+			name: "null results",
+			req:  Request{Query: `{ item(id: "nope") { id } }`},
+			want: `{"data":{"item":null}}`,
+		},
+		{
+			name: "resolver errors null the field",
+			req:  Request{Query: `{ fail ok: item(id: "root") { id } }`},
+			want: `{"data":{"fail":null,"ok":{"id":"root"}},"errors":[{"message":"boom","path":["fail"]}]}`,
+		},
+		{
+			name: "argument errors",
+			req:  Request{Query: `{ item(id: 1) { id } other: item(x: "root") { id } }`},
+			want: `{"data":{"item":null,"other":null},"errors":[{"message":"argument \"id\" must be a string","path":["item"]},` +
+				`{"message":"unknown argument \"x\" on field \"item\"","path":["other"]}]}`,
+		},
+	}
+	for _, tc := range cases {
+		if got := execute(t, tc.req); got != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	deep := "{ item(id: \"root\") " + strings.Repeat("{ children ", maxDepth) + "{ id }" + strings.Repeat(" }", maxDepth) + " }"
+	cases := []struct {
+		req  Request
+		want string
+	}{
+		{Request{Query: ``}, "query is required"},
+		{Request{Query: `{ nope }`}, `cannot query field \"nope\" on type Query`},
+		{Request{Query: `{ item(id: "root") { secret } }`}, `cannot query field \"secret\" on type Item`},
+		{Request{Query: `{ item(id: "root") }`}, `field \"item\" of type Item must have a selection of subfields`},
+		{Request{Query: `{ item(id: "root") { name { x } } }`}, `field \"name\" is a scalar and cannot have a selection`},
+		{Request{Query: `{ item(id: "root") { name(x: 1) } }`}, `field \"name\" takes no arguments`},
+		{Request{Query: `query ($id: String!) { item(id: $id) { id } }`}, "variable $id is required"},
+		{Request{Query: `{ item(id: $id) { id } }`}, "variable $id is not defined"},
+		{Request{Query: `query A { __typename } query B { __typename }`}, "operationName is required"},
+		{Request{Query: `query A { __typename }`, OperationName: "C"}, `operation \"C\" not found`},
+		{Request{Query: `subscription { item }`}, "subscription operations are not supported"},
+		{Request{Query: `{ ...F }`}, "fragments are not supported"},
+		{Request{Query: `{ item @skip(if: true) }`}, "directives are not supported"},
+		{Request{Query: `{ item(id: "root) }`}, "unterminated string"},
+		{Request{Query: `{ item }}`}, `unexpected \"}\" at offset 8`},
+		{Request{Query: deep}, "nested more than 12 levels deep"},
+	}
+	for _, tc := range cases {
+		got := execute(t, tc.req)
+		if !strings.Contains(got, tc.want) || strings.Contains(got, `"data"`) {
+			t.Errorf("%q: expected an error containing %q, got %s", tc.req.Query, tc.want, got)
+		}
+	}
+}
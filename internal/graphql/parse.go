@@ -0,0 +1,565 @@
+// Package graphql executes read-only GraphQL queries against Go values.
+//
+// It implements the subset of GraphQL that dashboards need: query
+// operations with variables, aliases, arguments and nested selections, and
+// __typename. Mutations, subscriptions, fragments, directives and
+// introspection are not supported. Fields resolve to the JSON names of the
+// Go values returned by the schema's resolvers.
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDepth bounds how deeply selections may nest, so a query cannot walk
+// recursive fields such as task dependencies without limit.
+const maxDepth = 12
+
+// Field is a selected field with its arguments resolved.
+type Field struct {
+	Alias      string
+	Name       string
+	Args       map[string]interface{}
+	Selections []*Field
+
+	// args holds the arguments as parsed, before variables are substituted.
+	args map[string]interface{}
+}
+
+// Key returns the name the field's value is returned under.
+func (f *Field) Key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// operation is a parsed query operation.
+type operation struct {
+	name       string
+	variables  []variableDef
+	selections []*Field
+}
+
+// variableDef declares an operation variable.
+type variableDef struct {
+	name     string
+	required bool
+	def      interface{}
+	hasDef   bool
+}
+
+// variableRef is a $variable used as an argument value.
+type variableRef string
+
+// enumValue is a bare name used as a value; it resolves to its name.
+type enumValue string
+
+// parseDocument parses a GraphQL document into its operations.
+func parseDocument(src string) ([]*operation, error) {
+	p := &parser{lex: lexer{src: src}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	var ops []*operation
+	for p.tok.kind != tokEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("query has no operations")
+	}
+	return ops, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lexer splits a document into tokens, skipping whitespace, commas and
+// comments.
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"): // byte order mark
+			l.pos += len("\uFEFF")
+		default:
+			return l.token()
+		}
+	}
+	return token{kind: tokEOF, pos: l.pos}, nil
+}
+
+func (l *lexer) token() (token, error) {
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokPunct, value: "...", pos: start}, nil
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, value: string(c), pos: start}, nil
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokName, value: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		return l.string()
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, fmt.Errorf("unexpected character %q at offset %d", r, start)
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, fmt.Errorf("invalid number at offset %d", start)
+	}
+	kind := tokInt
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.pos++
+		kind = tokFloat
+		if digits() == 0 {
+			return token{}, fmt.Errorf("invalid number at offset %d", start)
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.pos++
+		kind = tokFloat
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return token{}, fmt.Errorf("invalid number at offset %d", start)
+		}
+	}
+	return token{kind: kind, value: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return token{}, fmt.Errorf("unterminated string at offset %d", start)
+		}
+		value := l.src[l.pos+3 : l.pos+3+end]
+		l.pos += end + 6
+		return token{kind: tokString, value: blockString(value), pos: start}, nil
+	}
+
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{kind: tokString, value: b.String(), pos: start}, nil
+		case '\n', '\r':
+			return token{}, fmt.Errorf("unterminated string at offset %d", start)
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			esc := l.src[l.pos+1]
+			l.pos += 2
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return token{}, fmt.Errorf("invalid escape at offset %d", l.pos-2)
+				}
+				n, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 16)
+				if err != nil {
+					return token{}, fmt.Errorf("invalid escape at offset %d", l.pos-2)
+				}
+				b.WriteRune(rune(n))
+				l.pos += 4
+			default:
+				return token{}, fmt.Errorf("invalid escape at offset %d", l.pos-2)
+			}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return token{}, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+// blockString strips the common indentation and the blank first and last
+// lines of a block string.
+func blockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(raw, "\r\n", "\n"), "\r", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+// parser is a recursive descent parser over the lexer's tokens.
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) next() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) is(value string) bool {
+	return p.tok.kind == tokPunct && p.tok.value == value
+}
+
+func (p *parser) expect(value string) error {
+	if !p.is(value) {
+		return p.unexpected("expected " + strconv.Quote(value))
+	}
+	return p.next()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected("expected a name")
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *parser) unexpected(want string) error {
+	if p.tok.kind == tokEOF {
+		return fmt.Errorf("unexpected end of query, %s", want)
+	}
+	return fmt.Errorf("unexpected %q at offset %d, %s", p.tok.value, p.tok.pos, want)
+}
+
+func (p *parser) parseOperation() (*operation, error) {
+	op := &operation{}
+	if p.tok.kind == tokName {
+		switch p.tok.value {
+		case "query":
+		case "mutation", "subscription":
+			return nil, fmt.Errorf("%s operations are not supported", p.tok.value)
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			return nil, p.unexpected("expected an operation")
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokName {
+			op.name = p.tok.value
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if p.is("(") {
+			vars, err := p.parseVariableDefs()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = vars
+		}
+	}
+	if p.is("@") {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+	sels, err := p.parseSelections(1)
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sels
+	return op, nil
+}
+
+func (p *parser) parseVariableDefs() ([]variableDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var defs []variableDef
+	for !p.is(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		required, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		def := variableDef{name: name, required: required}
+		if p.is("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if def.def, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+			def.hasDef = true
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.next()
+}
+
+// parseType skips a variable type and reports whether it is non-null.
+// Values are not checked against declared types.
+func (p *parser) parseType() (bool, error) {
+	if p.is("[") {
+		if err := p.next(); err != nil {
+			return false, err
+		}
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+	if p.is("!") {
+		return true, p.next()
+	}
+	return false, nil
+}
+
+func (p *parser) parseSelections(depth int) ([]*Field, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("query is nested more than %d levels deep", maxDepth)
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []*Field
+	for !p.is("}") {
+		if p.is("...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		f, err := p.parseField(depth)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, p.unexpected("expected a field")
+	}
+	return fields, p.next()
+}
+
+func (p *parser) parseField(depth int) (*Field, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f := &Field{Name: name}
+	if p.is(":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		f.Alias = name
+		if f.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.is("(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		f.args = make(map[string]interface{})
+		for !p.is(")") {
+			arg, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if f.args[arg], err = p.parseValue(false); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if p.is("@") {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+	if p.is("{") {
+		if f.Selections, err = p.parseSelections(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// parseValue parses an argument or default value. Constant values, such as
+// variable defaults, may not reference variables.
+func (p *parser) parseValue(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s at offset %d", tok.value, tok.pos)
+		}
+		return n, p.next()
+	case tokFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at offset %d", tok.value, tok.pos)
+		}
+		return f, p.next()
+	case tokString:
+		return tok.value, p.next()
+	case tokName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, p.next()
+	}
+
+	switch {
+	case p.is("$") && !constant:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return variableRef(name), err
+	case p.is("["):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.is("]") {
+			v, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.next()
+	case p.is("{"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !p.is("}") {
+			key, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[key], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.next()
+	}
+	return nil, p.unexpected("expected a value")
+}
@@ -0,0 +1,231 @@
+// Package logging configures the log the orchestrator, the engine spawners
+// and the server write to: a minimum level, text or JSON output, and a log
+// file with size-based rotation.
+//
+// Messages are logged with log/slog at an explicit level (slog.Debug,
+// slog.Warn, slog.Error); the standard logger's messages are info. Event
+// messages such as "task_event=queued task_id=t1" keep their key=value form
+// in text output and have their fields as keys in JSON.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+// ParseLevel parses a config level name; empty means info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", name)
+}
+
+// eventPattern matches key=value event messages such as task_event=queued.
+var eventPattern = regexp.MustCompile(`^[a-z]+_event=(\S+)`)
+
+// Handler is a slog.Handler that writes the records at or above its level
+// as text or JSON lines.
+type Handler struct {
+	mu    *sync.Mutex
+	out   io.Writer
+	min   slog.Level
+	json  bool
+	attrs []slog.Attr
+	group string // key prefix of attrs added after WithGroup
+	now   func() time.Time
+}
+
+// NewHandler creates a Handler for the level and format ("text" or "json")
+// names of the logging config.
+func NewHandler(out io.Writer, level, format string) (*Handler, error) {
+	min, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{mu: &sync.Mutex{}, out: out, min: min, now: time.Now}
+	switch format {
+	case "", "text":
+	case "json":
+		h.json = true
+	default:
+		return nil, fmt.Errorf("unknown log format %q (use text or json)", format)
+	}
+	return h, nil
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.min
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		a.Key = h.group + a.Key
+		h2.attrs = append(h2.attrs, a)
+	}
+	return &h2
+}
+
+// WithGroup implements slog.Handler; attrs added later are prefixed with
+// the group name.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+// Handle implements slog.Handler: text lines are the time, the level
+// unless it is info, the message and the attrs as key=value; JSON lines
+// are objects with time, level, msg, the event fields and the attrs.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	attrs := append([]slog.Attr(nil), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		a.Key = h.group + a.Key
+		attrs = append(attrs, a)
+		return true
+	})
+	now := h.now()
+
+	var line []byte
+	if !h.json {
+		var b strings.Builder
+		b.WriteString(now.Format("2006/01/02 15:04:05 "))
+		if r.Level != slog.LevelInfo {
+			b.WriteString(r.Level.String() + " ")
+		}
+		b.WriteString(r.Message)
+		for _, a := range attrs {
+			b.WriteString(" " + a.Key + "=" + textValue(a.Value))
+		}
+		line = []byte(b.String() + "\n")
+	} else {
+		entry := map[string]interface{}{}
+		if eventPattern.MatchString(r.Message) {
+			for k, v := range parseFields(r.Message) {
+				entry[k] = v
+			}
+		}
+		for _, a := range attrs {
+			entry[a.Key] = jsonValue(a.Value)
+		}
+		entry["time"] = now.UTC().Format(time.RFC3339Nano)
+		entry["level"] = strings.ToLower(r.Level.String())
+		entry["msg"] = r.Message
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		line = append(data, '\n')
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.out.Write(line)
+	return err
+}
+
+// textValue formats an attr value as in event messages: bare words, or
+// Go-quoted strings when they contain spaces, quotes or '='.
+func textValue(v slog.Value) string {
+	s := v.Resolve().String()
+	if s == "" || strings.ContainsAny(s, " \"=") || strconv.Quote(s) != `"`+s+`"` {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// jsonValue returns an attr value JSON encodes as it prints.
+func jsonValue(v slog.Value) interface{} {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindString, slog.KindInt64, slog.KindUint64, slog.KindFloat64, slog.KindBool:
+		return v.Any()
+	case slog.KindTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	}
+	return v.String()
+}
+
+// parseFields reads the key=value pairs of an event message. Values are
+// bare words or Go-quoted strings, as written with %q.
+func parseFields(msg string) map[string]string {
+	fields := make(map[string]string)
+	rest := msg
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 || strings.ContainsAny(rest[:eq], " \"") {
+			return fields
+		}
+		key, value := rest[:eq], rest[eq+1:]
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return fields
+			}
+			unquoted, _ := strconv.Unquote(quoted)
+			fields[key] = unquoted
+			rest = value[len(quoted):]
+			continue
+		}
+		end := strings.IndexByte(value, ' ')
+		if end < 0 {
+			end = len(value)
+		}
+		fields[key] = value[:end]
+		rest = value[end:]
+	}
+}
+
+// Setup applies the logging config to slog and the standard logger. It
+// returns a function that closes the log file, if any.
+func Setup(cfg config.LoggingConfig) (func() error, error) {
+	var out io.Writer = os.Stderr
+	closeFn := func() error { return nil }
+	if cfg.File != "" {
+		maxSize, err := cfg.MaxSizeBytes()
+		if err != nil {
+			return nil, err
+		}
+		file, err := openRotatingFile(cfg.File, maxSize, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		out, closeFn = file, file.Close
+	}
+	h, err := NewHandler(out, cfg.Level, cfg.Format)
+	if err != nil {
+		closeFn()
+		return nil, err
+	}
+	// The standard logger's messages go through the handler as info.
+	log.SetFlags(0)
+	slog.SetDefault(slog.New(h))
+	return closeFn, nil
+}
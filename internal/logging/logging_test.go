@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]slog.Level{"": slog.LevelInfo, "debug": slog.LevelDebug, "Warning": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(name); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %s, %v; want %s", name, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Fatal("expected unknown level error")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	var text bytes.Buffer
+	h, err := NewHandler(&text, "warn", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.now = func() time.Time { return now }
+	logger := slog.New(h)
+	logger.Info("mesnada starting")
+	logger.Debug("probing")
+	logger.Warn("disk almost full", "free", "1 GB")
+	logger.Error("task_event=commit_failed", "task_id", "t1", "error", errors.New("not a repo"))
+	want := "2025/03/01 12:30:00 WARN disk almost full free=\"1 GB\"\n" +
+		"2025/03/01 12:30:00 ERROR task_event=commit_failed task_id=t1 error=\"not a repo\"\n"
+	if text.String() != want {
+		t.Fatalf("expected %q, got %q", want, text.String())
+	}
+
+	// The level comes from the call, not the message.
+	text.Reset()
+	h, _ = NewHandler(&text, "debug", "text")
+	h.now = func() time.Time { return now }
+	slog.New(h).Debug("ERROR: looks like an error")
+	if want := "2025/03/01 12:30:00 DEBUG ERROR: looks like an error\n"; text.String() != want {
+		t.Fatalf("expected %q, got %q", want, text.String())
+	}
+
+	var out bytes.Buffer
+	h, err = NewHandler(&out, "", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.now = func() time.Time { return now }
+	slog.New(h).With("engine", "claude").Warn(`task_event=queued task_id=t1 prompt_preview="fix \"it\" now" level=x`, "max_parallel", 2)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON object, got %q: %v", out.String(), err)
+	}
+	if entry["level"] != "warn" || entry["time"] != "2025-03-01T12:30:00Z" || entry["task_event"] != "queued" ||
+		entry["task_id"] != "t1" || entry["prompt_preview"] != `fix "it" now` || entry["engine"] != "claude" ||
+		entry["max_parallel"] != float64(2) || !strings.HasPrefix(entry["msg"].(string), "task_event=queued") {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+
+	if _, err := NewHandler(&out, "loud", "text"); err == nil {
+		t.Fatal("expected unknown level error")
+	}
+	if _, err := NewHandler(&out, "info", "xml"); err == nil {
+		t.Fatal("expected unknown format error")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "mesnada.log")
+	r, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for suffix, want := range map[string]string{"": "fourth\n", ".1": "third\n", ".2": "second\n"} {
+		data, err := os.ReadFile(path + suffix)
+		if err != nil || string(data) != want {
+			t.Fatalf("expected %s%s to hold %q, got %q (%v)", path, suffix, want, data, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected only 2 backups, got %v", err)
+	}
+
+	// Reopening appends to the current file.
+	r.Close()
+	if r, err = openRotatingFile(path, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("fifth\n"))
+	if data, _ := os.ReadFile(path); string(data) != "fourth\nfifth\n" {
+		t.Fatalf("expected appended log, got %q", data)
+	}
+}
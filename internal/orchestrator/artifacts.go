@@ -0,0 +1,198 @@
+package orchestrator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// maxArtifacts caps how many artifacts are listed for one task.
+const maxArtifacts = 1000
+
+// Artifact is a file a task added or changed in its git work dir.
+type Artifact struct {
+	Path string `json:"path"`
+	// Status is added, modified or renamed.
+	Status string `json:"status"`
+	Size   int64  `json:"size"`
+}
+
+// TaskArtifacts lists the files a task added or changed, as TaskDiff finds
+// them: from the task's auto-commit when it has one, otherwise from the work
+// dir compared with the HEAD recorded when the task started. Deleted files
+// are left out. It reports whether the list was cut to maxArtifacts.
+func (o *Orchestrator) TaskArtifacts(taskID string) ([]Artifact, bool, error) {
+	g, trees, err := o.taskArtifactTrees(taskID)
+	if err != nil {
+		return nil, false, err
+	}
+	artifacts, truncated, _, err := listArtifacts(g, trees.base, trees.tree)
+	return artifacts, truncated, err
+}
+
+// OpenArtifact returns the content of one of the task's artifacts as the task
+// left it, with its listing entry. The caller must close the reader.
+func (o *Orchestrator) OpenArtifact(taskID, path string) (io.ReadCloser, *Artifact, error) {
+	g, trees, err := o.taskArtifactTrees(taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	artifacts, _, objects, err := listArtifacts(g, trees.base, trees.tree)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range artifacts {
+		if artifacts[i].Path != path {
+			continue
+		}
+		cmd := exec.Command("git", "cat-file", "blob", objects[i])
+		cmd.Dir = g.dir
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, err
+		}
+		return &cmdReader{ReadCloser: out, cmd: cmd}, &artifacts[i], nil
+	}
+	return nil, nil, fmt.Errorf("artifact %s not found", path)
+}
+
+// artifactTrees are the trees compared to find a task's artifacts.
+type artifactTrees struct {
+	base string
+	tree string
+}
+
+// taskArtifactTrees finds the trees to compare for a task: its auto-commit
+// and that commit's parent, or a snapshot of the work dir and the task's base
+// commit. A missing base is the empty tree.
+func (o *Orchestrator) taskArtifactTrees(taskID string) (gitRunner, artifactTrees, error) {
+	task, err := o.GetTask(taskID)
+	if err != nil {
+		return gitRunner{}, artifactTrees{}, err
+	}
+	g := gitRunner{dir: task.WorkDir}
+	if _, err := g.run(nil, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return g, artifactTrees{}, fmt.Errorf("work dir %s is not a git repository", task.WorkDir)
+	}
+
+	var trees artifactTrees
+	if task.CommitSHA != "" {
+		trees.tree = task.CommitSHA
+		trees.base, err = g.run(nil, "rev-parse", "--verify", "--quiet", task.CommitSHA+"^")
+		if err != nil {
+			trees.base = "" // a root commit
+		}
+	} else {
+		trees.base = task.BaseCommit
+		if trees.base == "" {
+			trees.base = gitHead(task.WorkDir)
+		}
+		if trees.tree, err = gitWorkDirTree(g); err != nil {
+			return g, trees, err
+		}
+	}
+	if trees.base == "" {
+		if trees.base, err = gitEmptyTree(g); err != nil {
+			return g, trees, err
+		}
+	}
+	return g, trees, nil
+}
+
+// listArtifacts lists the files added, changed or renamed between base and
+// tree with their sizes in tree, and the blob id of each.
+func listArtifacts(g gitRunner, base, tree string) ([]Artifact, bool, []string, error) {
+	out, err := g.run(nil, "diff", "-M", "--raw", "-z", "--no-abbrev", base, tree)
+	if err != nil {
+		return nil, false, nil, err
+	}
+
+	artifacts := []Artifact{}
+	var objects []string
+	truncated := false
+	// Each entry is ":<modes> <old> <new> <status>\0<path>\0", with a second
+	// path after renames and copies.
+	fields := strings.Split(out, "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		meta := strings.Fields(strings.TrimPrefix(fields[i], ":"))
+		if len(meta) < 5 {
+			break
+		}
+		status := "modified"
+		switch meta[4][0] {
+		case 'D':
+			continue
+		case 'A':
+			status = "added"
+		case 'R', 'C':
+			// Renames and copies list the old path, then the new one.
+			status = "renamed"
+			i++
+		}
+		if i+1 >= len(fields) {
+			break
+		}
+		path := fields[i+1]
+		if meta[1] == "160000" || meta[1] == "120000" {
+			continue // submodules and symlinks are not files to download
+		}
+		if len(artifacts) == maxArtifacts {
+			truncated = true
+			break
+		}
+		artifacts = append(artifacts, Artifact{Path: path, Status: status})
+		objects = append(objects, meta[3])
+	}
+
+	if len(objects) > 0 {
+		sizes, err := blobSizes(g, objects)
+		if err != nil {
+			return nil, false, nil, err
+		}
+		for i := range artifacts {
+			artifacts[i].Size = sizes[i]
+		}
+	}
+	return artifacts, truncated, objects, nil
+}
+
+// blobSizes returns the size of each blob in one git call.
+func blobSizes(g gitRunner, objects []string) ([]int64, error) {
+	cmd := exec.Command("git", "cat-file", "--batch-check=%(objectsize)")
+	cmd.Dir = g.dir
+	cmd.Stdin = strings.NewReader(strings.Join(objects, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != len(objects) {
+		return nil, fmt.Errorf("git cat-file: expected %d sizes, got %d", len(objects), len(lines))
+	}
+	sizes := make([]int64, len(lines))
+	for i, line := range lines {
+		sizes[i], _ = strconv.ParseInt(line, 10, 64)
+	}
+	return sizes, nil
+}
+
+// cmdReader reads a command's output and waits for it on Close.
+type cmdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+// Close stops reading early too; git then exits on the closed pipe.
+func (r *cmdReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.cmd.Wait()
+	return err
+}
@@ -0,0 +1,138 @@
+package orchestrator
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	// autoCommitBranchPrefix namespaces the branches created for task commits.
+	autoCommitBranchPrefix = "mesnada/"
+	// commitPromptExcerpt is how many characters of the prompt go into the
+	// commit message.
+	commitPromptExcerpt = 200
+)
+
+// commitTaskChanges commits the work dir changes of a completed task to the
+// mesnada/<task_id> branch and records the branch and SHA on the task.
+// The commit is built on a temporary index, so the working tree, the real
+// index and the checked-out branch are left untouched.
+func commitTaskChanges(task *models.Task) {
+	branch, sha, err := gitCommitWorkDir(task)
+	if err != nil {
+		slog.Warn("task_event=commit_failed", "task_id", task.ID, "work_dir", task.WorkDir, "error", err.Error())
+		return
+	}
+	if sha == "" {
+		return
+	}
+	task.CommitBranch = branch
+	task.CommitSHA = sha
+	log.Printf("task_event=committed task_id=%s branch=%s sha=%s", task.ID, branch, sha)
+}
+
+// gitCommitWorkDir returns an empty SHA when the work dir is not a git
+// repository or has no changes.
+func gitCommitWorkDir(task *models.Task) (string, string, error) {
+	g := gitRunner{dir: task.WorkDir}
+	if _, err := g.run(nil, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return "", "", nil
+	}
+
+	status, err := g.run(nil, "status", "--porcelain")
+	if err != nil {
+		return "", "", err
+	}
+	if status == "" {
+		return "", "", nil
+	}
+
+	gitDir, err := g.run(nil, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", "", err
+	}
+	index, err := os.CreateTemp(gitDir, "mesnada-index-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	// Start from HEAD unless the repository has no commits yet.
+	parent, err := g.run(nil, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil {
+		parent = ""
+		os.Remove(index.Name()) // git treats a missing index as empty
+	} else if _, err := g.run(env, "read-tree", parent); err != nil {
+		return "", "", err
+	}
+
+	if _, err := g.run(env, "add", "-A"); err != nil {
+		return "", "", err
+	}
+	tree, err := g.run(env, "write-tree")
+	if err != nil {
+		return "", "", err
+	}
+
+	args := []string{"commit-tree", tree, "-m", commitMessage(task)}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	sha, err := g.run(g.identityEnv(), args...)
+	if err != nil {
+		return "", "", err
+	}
+
+	branch := autoCommitBranchPrefix + task.ID
+	if _, err := g.run(nil, "update-ref", "refs/heads/"+branch, sha); err != nil {
+		return "", "", err
+	}
+	return branch, sha, nil
+}
+
+// commitMessage names the task and quotes the start of its original prompt.
+func commitMessage(task *models.Task) string {
+	prompt := strings.TrimSpace(taskPrompt(task))
+	if r := []rune(prompt); len(r) > commitPromptExcerpt {
+		prompt = string(r[:commitPromptExcerpt]) + "..."
+	}
+	return fmt.Sprintf("mesnada: task %s\n\n%s\n", task.ID, prompt)
+}
+
+// gitRunner runs git commands in a work dir.
+type gitRunner struct {
+	dir string
+}
+
+func (g gitRunner) run(env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// identityEnv supplies a fallback author when the repository has none configured.
+func (g gitRunner) identityEnv() []string {
+	if email, _ := g.run(nil, "config", "user.email"); email != "" {
+		return nil
+	}
+	return []string{
+		"GIT_AUTHOR_NAME=mesnada", "GIT_AUTHOR_EMAIL=mesnada@localhost",
+		"GIT_COMMITTER_NAME=mesnada", "GIT_COMMITTER_EMAIL=mesnada@localhost",
+	}
+}
@@ -0,0 +1,217 @@
+package orchestrator
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func gitInit(t *testing.T) (gitRunner, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	g := gitRunner{dir: dir}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "dev@example.com"},
+		{"config", "user.name", "Dev"},
+	} {
+		if _, err := g.run(nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g, dir
+}
+
+func TestCommitTaskChanges(t *testing.T) {
+	g, dir := gitInit(t)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0644)
+	g.run(nil, "add", "a.txt")
+	g.run(nil, "commit", "-q", "-m", "initial")
+	head, _ := g.run(nil, "rev-parse", "HEAD")
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("two\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("new\n"), 0644)
+
+	task := &models.Task{ID: "task-abc", WorkDir: dir, Prompt: "You are the task_id: task-abc\n\nUpdate a.txt"}
+	commitTaskChanges(task)
+
+	if task.CommitBranch != "mesnada/task-abc" || task.CommitSHA == "" {
+		t.Fatalf("expected commit on mesnada/task-abc, got branch=%q sha=%q", task.CommitBranch, task.CommitSHA)
+	}
+	if got, _ := g.run(nil, "rev-parse", "HEAD"); got != head {
+		t.Fatalf("expected HEAD to stay at %s, got %s", head, got)
+	}
+	if status, _ := g.run(nil, "status", "--porcelain"); !strings.Contains(status, "b.txt") {
+		t.Fatalf("expected working tree to keep its changes, got %q", status)
+	}
+	files, _ := g.run(nil, "show", "--name-only", "--format=%B", task.CommitSHA)
+	for _, want := range []string{"mesnada: task task-abc", "Update a.txt", "a.txt", "b.txt"} {
+		if !strings.Contains(files, want) {
+			t.Fatalf("expected %q in commit, got:\n%s", want, files)
+		}
+	}
+	if strings.Contains(files, "You are the task_id") {
+		t.Fatalf("expected task ID preamble to be stripped from message, got:\n%s", files)
+	}
+}
+
+func TestCommitMessage_TruncatesOnRuneBoundary(t *testing.T) {
+	prompt := strings.Repeat("é", commitPromptExcerpt+10)
+	msg := commitMessage(&models.Task{ID: "task-utf8", Prompt: prompt})
+	if !utf8.ValidString(msg) {
+		t.Fatalf("expected valid UTF-8 in the commit message, got %q", msg)
+	}
+	if want := strings.Repeat("é", commitPromptExcerpt) + "..."; !strings.Contains(msg, want) {
+		t.Fatalf("expected %d characters of the prompt, got:\n%s", commitPromptExcerpt, msg)
+	}
+}
+
+func TestCommitTaskChanges_NoRepoOrNoChanges(t *testing.T) {
+	task := &models.Task{ID: "task-plain", WorkDir: t.TempDir()}
+	commitTaskChanges(task)
+	if task.CommitSHA != "" {
+		t.Fatalf("expected no commit outside git, got %q", task.CommitSHA)
+	}
+
+	_, dir := gitInit(t)
+	task = &models.Task{ID: "task-clean", WorkDir: dir}
+	commitTaskChanges(task)
+	if task.CommitSHA != "" {
+		t.Fatalf("expected no commit for a clean tree, got %q", task.CommitSHA)
+	}
+}
+
+func TestTaskDiff(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	g, dir := gitInit(t)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "gone.txt"), []byte("bye\n"), 0644)
+	g.run(nil, "add", "-A")
+	g.run(nil, "commit", "-q", "-m", "initial")
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\nthree\nfour\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("new\n"), 0644)
+	os.Remove(filepath.Join(dir, "gone.txt"))
+
+	task := &models.Task{ID: "task-diff", WorkDir: dir, Status: models.TaskStatusCompleted, BaseCommit: gitHead(dir)}
+	orch.store.Save(task)
+
+	diff, err := orch.TaskDiff(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Source != "work_dir" || diff.Base != task.BaseCommit {
+		t.Fatalf("expected work dir diff against %s, got %s against %s", task.BaseCommit, diff.Source, diff.Base)
+	}
+	want := map[string]string{"a.txt": "modified", "b.txt": "added", "gone.txt": "deleted"}
+	if len(diff.Files) != len(want) {
+		t.Fatalf("expected %d files, got %+v", len(want), diff.Files)
+	}
+	for _, f := range diff.Files {
+		if want[f.Path] != f.Status {
+			t.Fatalf("expected %s to be %s, got %s", f.Path, want[f.Path], f.Status)
+		}
+		if f.Path == "a.txt" && (f.Additions != 2 || f.Deletions != 1 || !strings.Contains(f.Patch, "+three")) {
+			t.Fatalf("unexpected a.txt change: %+v", f)
+		}
+	}
+	if diff.Additions != 3 || diff.Deletions != 2 {
+		t.Fatalf("expected +3 -2, got +%d -%d", diff.Additions, diff.Deletions)
+	}
+	if status, _ := g.run(nil, "status", "--porcelain"); !strings.Contains(status, "?? b.txt") {
+		t.Fatalf("expected the real index to be untouched, got %q", status)
+	}
+
+	commitTaskChanges(task)
+	orch.store.Save(task)
+	os.WriteFile(filepath.Join(dir, "later.txt"), []byte("later\n"), 0644)
+	diff, err = orch.TaskDiff(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Source != "commit" || diff.Commit != task.CommitSHA || len(diff.Files) != 3 {
+		t.Fatalf("expected the auto-commit's three files, got %s %+v", diff.Source, diff.Files)
+	}
+
+	plain := &models.Task{ID: "task-plain", WorkDir: t.TempDir(), Status: models.TaskStatusCompleted}
+	orch.store.Save(plain)
+	if _, err := orch.TaskDiff(plain.ID); err == nil || !strings.Contains(err.Error(), "not a git repository") {
+		t.Fatalf("expected not a git repository error, got %v", err)
+	}
+}
+
+func TestTaskArtifacts(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	g, dir := gitInit(t)
+	os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("keep\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("old\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "gone.txt"), []byte("bye\n"), 0644)
+	g.run(nil, "add", "-A")
+	g.run(nil, "commit", "-q", "-m", "initial")
+
+	os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("new content\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "report.md"), []byte("# Report\n"), 0644)
+	os.Remove(filepath.Join(dir, "gone.txt"))
+
+	task := &models.Task{ID: "task-art", WorkDir: dir, Status: models.TaskStatusCompleted, BaseCommit: gitHead(dir)}
+	orch.store.Save(task)
+
+	artifacts, truncated, err := orch.TaskArtifacts(task.ID)
+	if err != nil || truncated {
+		t.Fatal(err, truncated)
+	}
+	want := map[string]Artifact{
+		"edit.txt":  {Path: "edit.txt", Status: "modified", Size: 12},
+		"report.md": {Path: "report.md", Status: "added", Size: 9},
+	}
+	if len(artifacts) != len(want) {
+		t.Fatalf("expected %d artifacts, got %+v", len(want), artifacts)
+	}
+	for _, a := range artifacts {
+		if want[a.Path] != a {
+			t.Fatalf("unexpected artifact %+v", a)
+		}
+	}
+
+	// The auto-commit keeps the content the task left, whatever happens later.
+	commitTaskChanges(task)
+	orch.store.Save(task)
+	os.WriteFile(filepath.Join(dir, "report.md"), []byte("edited later\n"), 0644)
+	r, a, err := orch.OpenArtifact(task.ID, "report.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(r)
+	r.Close()
+	if string(content) != "# Report\n" || a.Size != 9 {
+		t.Fatalf("unexpected artifact content %q (%+v)", content, a)
+	}
+	if _, _, err := orch.OpenArtifact(task.ID, "keep.txt"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected unchanged files not to be artifacts, got %v", err)
+	}
+
+	// Without commits everything in the work dir is new.
+	_, fresh := gitInit(t)
+	os.WriteFile(filepath.Join(fresh, "a.txt"), []byte("a\n"), 0644)
+	first := &models.Task{ID: "task-fresh", WorkDir: fresh, Status: models.TaskStatusCompleted}
+	orch.store.Save(first)
+	if artifacts, _, err := orch.TaskArtifacts(first.ID); err != nil || len(artifacts) != 1 || artifacts[0].Status != "added" {
+		t.Fatalf("expected a.txt as added, got %+v %v", artifacts, err)
+	}
+	if diff, err := orch.TaskDiff(first.ID); err != nil || len(diff.Files) != 1 || diff.Additions != 1 {
+		t.Fatalf("expected a.txt in the diff, got %+v %v", diff, err)
+	}
+}
@@ -0,0 +1,108 @@
+package orchestrator
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// applyDefaults fills in the engine, model and timeout a spawn request omits
+// from the configured defaults rules matching its work dir and tags, and
+// adds the rules' tags. Rules match on the tags of the request, not on tags
+// added by other rules.
+func (o *Orchestrator) applyDefaults(req models.SpawnRequest, workDir string) models.SpawnRequest {
+	requested := req.Tags
+	var tags []string
+	for _, rule := range o.defaults {
+		if !defaultRuleMatches(rule, workDir, requested) {
+			continue
+		}
+		if req.Engine == "" && rule.Engine != "" {
+			req.Engine = models.Engine(rule.Engine)
+		}
+		if req.Model == "" && rule.Model != "" && (rule.Engine == "" || req.Engine == models.Engine(rule.Engine)) {
+			req.Model = rule.Model
+		}
+		if req.Timeout == "" {
+			req.Timeout = rule.Timeout
+		}
+		for _, tag := range rule.Tags {
+			if !slices.Contains(requested, tag) && !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if len(tags) > 0 {
+		req.Tags = append(append([]string(nil), requested...), tags...)
+	}
+	return req
+}
+
+// defaultRuleMatches reports whether a defaults rule applies to a task in
+// workDir spawned with tags.
+func defaultRuleMatches(rule config.DefaultRule, workDir string, tags []string) bool {
+	if rule.WorkDir != "" {
+		root, err := filepath.Abs(rule.WorkDir)
+		if err != nil || !withinDir(root, workDir) {
+			return false
+		}
+	}
+	for _, tag := range rule.MatchTags {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyProjectConfig fills in what a spawn request omits from the work dir's
+// .mesnada.yaml, and adds its tags.
+func applyProjectConfig(req models.SpawnRequest, project *config.ProjectConfig) models.SpawnRequest {
+	if project == nil {
+		return req
+	}
+	if req.Engine == "" && project.Engine != "" {
+		req.Engine = models.Engine(project.Engine)
+	}
+	if req.Model == "" && project.Model != "" && (project.Engine == "" || req.Engine == models.Engine(project.Engine)) {
+		req.Model = project.Model
+	}
+	if req.MCPConfig == "" {
+		req.MCPConfig = project.MCPConfig
+	}
+	if req.Persona == "" {
+		req.Persona = project.Persona
+	}
+	if req.Timeout == "" {
+		req.Timeout = project.Timeout
+	}
+	var tags []string
+	for _, tag := range project.Tags {
+		if !slices.Contains(req.Tags, tag) && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > 0 {
+		req.Tags = append(append([]string(nil), req.Tags...), tags...)
+	}
+	return req
+}
+
+// SetModelAliases replaces the model aliases, e.g. on a config reload.
+func (o *Orchestrator) SetModelAliases(aliases map[string]string) {
+	o.aliasesMu.Lock()
+	defer o.aliasesMu.Unlock()
+	o.modelAliases = aliases
+}
+
+// resolveModel returns the model id an alias stands for, or model itself.
+func (o *Orchestrator) resolveModel(model string) string {
+	o.aliasesMu.RLock()
+	defer o.aliasesMu.RUnlock()
+	if target := o.modelAliases[model]; target != "" {
+		return target
+	}
+	return model
+}
@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	o := &Orchestrator{defaults: []config.DefaultRule{
+		{WorkDir: "/repos/frontend", Engine: "claude", Model: "sonnet", Timeout: "45m", Tags: []string{"frontend"}},
+		{MatchTags: []string{"docs"}, Model: "mini", Tags: []string{"low-priority"}},
+		{Timeout: "2h", Tags: []string{"frontend"}},
+	}}
+
+	tests := []struct {
+		name    string
+		req     models.SpawnRequest
+		workDir string
+		want    models.SpawnRequest
+	}{
+		{
+			name:    "work dir rule",
+			workDir: "/repos/frontend/app",
+			want:    models.SpawnRequest{Engine: models.EngineClaude, Model: "sonnet", Timeout: "45m", Tags: []string{"frontend"}},
+		},
+		{
+			name:    "request values win",
+			req:     models.SpawnRequest{Engine: models.EngineGemini, Timeout: "5m", Tags: []string{"urgent"}},
+			workDir: "/repos/frontend",
+			// The model of the claude rule does not follow the task to gemini.
+			want: models.SpawnRequest{Engine: models.EngineGemini, Timeout: "5m", Tags: []string{"urgent", "frontend"}},
+		},
+		{
+			name:    "tag rule",
+			req:     models.SpawnRequest{Tags: []string{"docs"}},
+			workDir: "/repos/backend",
+			want:    models.SpawnRequest{Model: "mini", Timeout: "2h", Tags: []string{"docs", "low-priority", "frontend"}},
+		},
+		{
+			name:    "sibling dir does not match",
+			workDir: "/repos/frontend-old",
+			want:    models.SpawnRequest{Timeout: "2h", Tags: []string{"frontend"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := o.applyDefaults(tt.req, tt.workDir)
+			if got.Engine != tt.want.Engine || got.Model != tt.want.Model || got.Timeout != tt.want.Timeout || strings.Join(got.Tags, ",") != strings.Join(tt.want.Tags, ",") {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSpawnAppliesDefaults(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mesnada-orch-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	orch, err := New(Config{
+		StorePath: filepath.Join(tmpDir, "tasks.json"),
+		LogDir:    filepath.Join(tmpDir, "logs"),
+		Defaults:  []config.DefaultRule{{WorkDir: tmpDir, Engine: "claude", Timeout: "45m", Tags: []string{"frontend"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+	defer orch.Shutdown()
+
+	task, err := orch.Spawn(context.Background(), models.SpawnRequest{Prompt: "x", WorkDir: tmpDir, Dependencies: []string{"missing"}})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	if task.Engine != models.EngineClaude || time.Duration(task.Timeout) != 45*time.Minute || strings.Join(task.Tags, ",") != "frontend" {
+		t.Fatalf("expected defaults applied, got engine=%s timeout=%v tags=%v", task.Engine, time.Duration(task.Timeout), task.Tags)
+	}
+}
+
+func TestSpawnAppliesProjectConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mesnada-orch-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	project := filepath.Join(tmpDir, "project")
+	if err := os.Mkdir(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := "engine: gemini\nmodel: flash\nmcp_config: .mcp.json\ntimeout: 30m\ntags: [web]\n"
+	if err := os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	orch, err := New(Config{
+		StorePath: filepath.Join(tmpDir, "tasks.json"),
+		LogDir:    filepath.Join(tmpDir, "logs"),
+		// The project file wins over the server rules; rules fill in the rest.
+		Defaults: []config.DefaultRule{{Engine: "claude", Timeout: "45m", Tags: []string{"rule"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+	defer orch.Shutdown()
+
+	task, err := orch.Spawn(context.Background(), models.SpawnRequest{Prompt: "x", WorkDir: project, Dependencies: []string{"missing"}})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	if task.Engine != models.EngineGemini || task.Model != "flash" || task.MCPConfig != ".mcp.json" || time.Duration(task.Timeout) != 30*time.Minute || strings.Join(task.Tags, ",") != "web,rule" {
+		t.Fatalf("expected project settings applied, got engine=%s model=%s mcp=%s timeout=%v tags=%v", task.Engine, task.Model, task.MCPConfig, time.Duration(task.Timeout), task.Tags)
+	}
+
+	// Request values win over the project file.
+	task, err = orch.Spawn(context.Background(), models.SpawnRequest{Prompt: "x", WorkDir: project, Engine: models.EngineClaude, Dependencies: []string{"missing"}})
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	if task.Engine != models.EngineClaude || task.Model != "" {
+		t.Fatalf("expected the requested engine without the project model, got engine=%s model=%s", task.Engine, task.Model)
+	}
+
+	if err := os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte("engine: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orch.Spawn(context.Background(), models.SpawnRequest{Prompt: "x", WorkDir: project}); err == nil || !strings.Contains(err.Error(), config.ProjectConfigFile) {
+		t.Fatalf("expected invalid project config error, got %v", err)
+	}
+}
+
+func TestResolveModelAliases(t *testing.T) {
+	o := &Orchestrator{modelAliases: map[string]string{"fast": "claude-haiku-4.5"}}
+	if got := o.resolveModel("fast"); got != "claude-haiku-4.5" {
+		t.Fatalf("expected alias resolved, got %q", got)
+	}
+	if got := o.resolveModel("gpt-5"); got != "gpt-5" {
+		t.Fatalf("expected plain model unchanged, got %q", got)
+	}
+
+	// Aliases set by defaults rules resolve too, and reloads replace them.
+	o.defaults = []config.DefaultRule{{Model: "best"}}
+	o.SetModelAliases(map[string]string{"best": "claude-opus-4.5"})
+	req := o.applyDefaults(models.SpawnRequest{}, "/tmp")
+	if got := o.resolveModel(req.Model); got != "claude-opus-4.5" {
+		t.Fatalf("expected default rule alias resolved, got %q", got)
+	}
+	if got := o.resolveModel("fast"); got != "fast" {
+		t.Fatalf("expected replaced aliases to drop fast, got %q", got)
+	}
+}
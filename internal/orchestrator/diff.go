@@ -0,0 +1,215 @@
+package orchestrator
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// maxDiffBytes caps the diff returned for one task.
+	maxDiffBytes = 2 << 20
+	// maxFilePatchBytes caps the patch of a single file within it.
+	maxFilePatchBytes = 256 << 10
+)
+
+// DiffFile is the change to one file.
+type DiffFile struct {
+	Path string `json:"path"`
+	// OldPath is set when the file was renamed.
+	OldPath string `json:"old_path,omitempty"`
+	// Status is added, deleted, renamed or modified.
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+	Patch     string `json:"patch"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// TaskDiff is the change a task made to its git work dir.
+type TaskDiff struct {
+	TaskID string `json:"task_id"`
+	// Source is "commit" for the task's auto-commit, or "work_dir" for the
+	// current work dir compared with Base.
+	Source string `json:"source"`
+	Base   string `json:"base,omitempty"`
+	// Commit is the auto-commit shown, for the commit source.
+	Commit    string     `json:"commit,omitempty"`
+	Files     []DiffFile `json:"files"`
+	Additions int        `json:"additions"`
+	Deletions int        `json:"deletions"`
+	// Truncated reports that files were left out to keep the diff small.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// TaskDiff returns the changes of a task. A task that was auto-committed shows
+// its commit. Otherwise the diff is computed now, between the HEAD recorded
+// when the task started (or the current HEAD for tasks without one) and the
+// work dir, untracked files included, so it also holds any later edits.
+func (o *Orchestrator) TaskDiff(taskID string) (*TaskDiff, error) {
+	task, err := o.GetTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	g := gitRunner{dir: task.WorkDir}
+	if _, err := g.run(nil, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, fmt.Errorf("work dir %s is not a git repository", task.WorkDir)
+	}
+
+	diff := &TaskDiff{TaskID: task.ID}
+	var patch string
+	if task.CommitSHA != "" {
+		diff.Source = "commit"
+		diff.Commit = task.CommitSHA
+		patch, err = g.run(nil, "diff-tree", "-p", "-M", "--root", "--no-commit-id", "--no-color", task.CommitSHA)
+	} else {
+		diff.Source = "work_dir"
+		diff.Base = task.BaseCommit
+		if diff.Base == "" {
+			diff.Base = gitHead(task.WorkDir)
+		}
+		patch, err = gitWorkDirDiff(g, diff.Base)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	diff.Files, diff.Truncated = parseDiff(patch)
+	for _, f := range diff.Files {
+		diff.Additions += f.Additions
+		diff.Deletions += f.Deletions
+	}
+	return diff, nil
+}
+
+// gitHead returns the HEAD commit of dir, or "" when dir is not a git
+// repository or has no commits yet.
+func gitHead(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	sha, err := gitRunner{dir: dir}.run(nil, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return sha
+}
+
+// gitWorkDirDiff diffs the work dir, untracked files included, against base,
+// or against an empty tree when base is "".
+func gitWorkDirDiff(g gitRunner, base string) (string, error) {
+	tree, err := gitWorkDirTree(g)
+	if err != nil {
+		return "", err
+	}
+	if base == "" {
+		if base, err = gitEmptyTree(g); err != nil {
+			return "", err
+		}
+	}
+	return g.run(nil, "diff", "-M", "--no-color", base, tree)
+}
+
+// gitWorkDirTree writes the work dir, untracked files included, as a tree
+// object and returns its id. Like gitCommitWorkDir it stages into a
+// temporary index so the real index is left untouched.
+func gitWorkDirTree(g gitRunner) (string, error) {
+	gitDir, err := g.run(nil, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	index, err := os.CreateTemp(gitDir, "mesnada-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if head := gitHead(g.dir); head != "" {
+		if _, err := g.run(env, "read-tree", head); err != nil {
+			return "", err
+		}
+	} else {
+		os.Remove(index.Name()) // git treats a missing index as empty
+	}
+	if _, err := g.run(env, "add", "-A"); err != nil {
+		return "", err
+	}
+	return g.run(env, "write-tree")
+}
+
+// gitEmptyTree returns the id of the empty tree in the repository's hash.
+func gitEmptyTree(g gitRunner) (string, error) {
+	return g.run(nil, "hash-object", "-t", "tree", os.DevNull)
+}
+
+// parseDiff splits a unified git diff into files and counts their changed
+// lines. It reports whether files or patches were cut to the size limits.
+func parseDiff(patch string) ([]DiffFile, bool) {
+	files := []DiffFile{}
+	if strings.TrimSpace(patch) == "" {
+		return files, false
+	}
+
+	truncated := false
+	size := 0
+	chunks := strings.Split("\n"+patch, "\ndiff --git ")
+	for _, chunk := range chunks[1:] {
+		chunk = "diff --git " + chunk
+		if size >= maxDiffBytes {
+			truncated = true
+			break
+		}
+
+		f := DiffFile{Status: "modified"}
+		inHunk := false
+		for _, line := range strings.Split(chunk, "\n") {
+			if inHunk {
+				switch {
+				case strings.HasPrefix(line, "+"):
+					f.Additions++
+				case strings.HasPrefix(line, "-"):
+					f.Deletions++
+				}
+				continue
+			}
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				inHunk = true
+			case strings.HasPrefix(line, "diff --git "):
+				// "diff --git a/x b/x"; used when no ---/+++ lines follow.
+				if i := strings.Index(line, " b/"); i >= 0 {
+					f.Path = line[i+3:]
+				}
+			case strings.HasPrefix(line, "new file mode"):
+				f.Status = "added"
+			case strings.HasPrefix(line, "deleted file mode"):
+				f.Status = "deleted"
+			case strings.HasPrefix(line, "rename from "):
+				f.Status = "renamed"
+				f.OldPath = strings.TrimPrefix(line, "rename from ")
+			case strings.HasPrefix(line, "rename to "):
+				f.Path = strings.TrimPrefix(line, "rename to ")
+			case strings.HasPrefix(line, "Binary files "):
+				f.Binary = true
+			case strings.HasPrefix(line, "--- a/"):
+				f.Path = strings.TrimPrefix(line, "--- a/")
+			case strings.HasPrefix(line, "+++ b/"):
+				f.Path = strings.TrimPrefix(line, "+++ b/")
+			}
+		}
+
+		f.Patch = strings.TrimRight(chunk, "\n")
+		if len(f.Patch) > maxFilePatchBytes {
+			f.Patch = f.Patch[:maxFilePatchBytes]
+			f.Truncated = true
+			truncated = true
+		}
+		size += len(f.Patch)
+		files = append(files, f)
+	}
+	return files, truncated
+}
@@ -0,0 +1,80 @@
+package orchestrator
+
+import (
+	"slices"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// TaskChange says what happened to a task when listeners are notified.
+type TaskChange string
+
+const (
+	// TaskCreated is sent when a task is spawned.
+	TaskCreated TaskChange = "created"
+	// TaskStarted is sent when a task's agent process starts running.
+	TaskStarted TaskChange = "started"
+	// TaskUpdated is sent when a running or pending task changes, e.g. reports progress.
+	TaskUpdated TaskChange = "updated"
+	// TaskFinished is sent when a task reaches a terminal state (including paused).
+	TaskFinished TaskChange = "finished"
+	// TaskDeleted is sent when a task is removed from the store.
+	TaskDeleted TaskChange = "deleted"
+)
+
+// TaskListener is called after a task changes. Listeners run synchronously on
+// the goroutine that changed the task, so they must not block.
+type TaskListener func(change TaskChange, task *models.Task)
+
+// OnTaskChange registers a listener for task changes.
+func (o *Orchestrator) OnTaskChange(fn TaskListener) {
+	o.listenersMu.Lock()
+	defer o.listenersMu.Unlock()
+	o.listeners = append(o.listeners, fn)
+}
+
+func (o *Orchestrator) notifyTaskChange(change TaskChange, task *models.Task) {
+	o.listenersMu.RLock()
+	listeners := o.listeners
+	o.listenersMu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(change, task)
+	}
+}
+
+// EngineListener is called when an engine probe finds engines whose
+// availability or version changed since the previous probe.
+type EngineListener func(infos []models.EngineInfo)
+
+// OnEnginesChange registers a listener for engine probe changes.
+func (o *Orchestrator) OnEnginesChange(fn EngineListener) {
+	o.listenersMu.Lock()
+	defer o.listenersMu.Unlock()
+	o.engineListeners = append(o.engineListeners, fn)
+}
+
+func (o *Orchestrator) notifyEnginesChange(infos []models.EngineInfo) {
+	o.listenersMu.RLock()
+	listeners := o.engineListeners
+	o.listenersMu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(infos)
+	}
+}
+
+// enginesChanged reports whether two probes differ in availability, version
+// or supported models.
+func enginesChanged(prev, next []models.EngineInfo) bool {
+	if len(prev) != len(next) {
+		return true
+	}
+	for i := range prev {
+		if prev[i].Engine != next[i].Engine || prev[i].Available != next[i].Available || prev[i].Version != next[i].Version ||
+			!slices.Equal(prev[i].SupportedModels, next[i].SupportedModels) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sevir/mesnada/internal/store"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// maxGraphLabelLen bounds the prompt excerpt used as a node label.
+const maxGraphLabelLen = 80
+
+// TaskGraph returns the dependency graph around a task or a tag. For a task,
+// it holds everything the task transitively depends on and everything that
+// depends on it. For a tag, it holds the tagged tasks and their transitive
+// dependencies. With neither, it holds every task.
+func (o *Orchestrator) TaskGraph(taskID, tag string) (*models.TaskGraph, error) {
+	all, err := o.store.List(store.ListFilter{})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Task, len(all))
+	dependents := make(map[string][]string)
+	for _, task := range all {
+		byID[task.ID] = task
+		for _, dep := range task.Dependencies {
+			dependents[dep] = append(dependents[dep], task.ID)
+		}
+	}
+
+	included := make(map[string]bool)
+	// walk adds id and everything reachable through next.
+	var walk func(id string, next func(string) []string)
+	walk = func(id string, next func(string) []string) {
+		included[id] = true
+		for _, n := range next(id) {
+			if !included[n] {
+				walk(n, next)
+			}
+		}
+	}
+	upstream := func(id string) []string {
+		if task, ok := byID[id]; ok {
+			return task.Dependencies
+		}
+		return nil
+	}
+	downstream := func(id string) []string { return dependents[id] }
+
+	switch {
+	case taskID != "":
+		if _, ok := byID[taskID]; !ok {
+			return nil, fmt.Errorf("task not found: %s", taskID)
+		}
+		walk(taskID, upstream)
+		walk(taskID, downstream)
+	case tag != "":
+		for _, task := range all {
+			for _, t := range task.Tags {
+				if t == tag {
+					walk(task.ID, upstream)
+					break
+				}
+			}
+		}
+	default:
+		for _, task := range all {
+			included[task.ID] = true
+		}
+	}
+
+	ids := make([]string, 0, len(included))
+	for id := range included {
+		ids = append(ids, id)
+	}
+	// Oldest first, so nodes come out roughly in execution order.
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := byID[ids[i]], byID[ids[j]]
+		switch {
+		case a == nil || b == nil:
+			if (a == nil) != (b == nil) {
+				return a == nil
+			}
+			return ids[i] < ids[j]
+		case !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return ids[i] < ids[j]
+	})
+
+	graph := &models.TaskGraph{
+		Nodes: make([]models.TaskGraphNode, 0, len(ids)),
+		Edges: []models.TaskGraphEdge{},
+	}
+	for _, id := range ids {
+		task, ok := byID[id]
+		if !ok {
+			graph.Nodes = append(graph.Nodes, models.TaskGraphNode{ID: id, Label: id, Missing: true})
+			continue
+		}
+		node := models.TaskGraphNode{
+			ID:     id,
+			Label:  graphLabel(task.Prompt),
+			Status: task.Status,
+			Engine: task.Engine,
+			Tags:   task.Tags,
+		}
+		if task.Progress != nil {
+			node.Progress = task.Progress.Percentage
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		for _, dep := range task.Dependencies {
+			if included[dep] {
+				graph.Edges = append(graph.Edges, models.TaskGraphEdge{From: dep, To: id})
+			}
+		}
+	}
+	return graph, nil
+}
+
+// graphLabel is the first line of a prompt, shortened for display.
+func graphLabel(prompt string) string {
+	line := strings.TrimSpace(prompt)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	if runes := []rune(line); len(runes) > maxGraphLabelLen {
+		line = strings.TrimSpace(string(runes[:maxGraphLabelLen])) + "..."
+	}
+	return line
+}
@@ -0,0 +1,66 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestTaskGraph(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	// a waits on a missing task, so nothing in the chain a <- b <- d runs.
+	tasks, err := orch.SpawnBatch(context.Background(), []models.SpawnRequest{
+		{Prompt: "a", WorkDir: "/tmp", Dependencies: []string{"missing"}},
+		{Prompt: "b", WorkDir: "/tmp"},
+		{Prompt: "c", WorkDir: "/tmp", Dependencies: []string{"missing"}},
+		{Prompt: "d\nmore detail", WorkDir: "/tmp", Tags: []string{"release"}},
+	}, [][]int{nil, {0}, nil, {1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, d := tasks[0].ID, tasks[1].ID, tasks[3].ID
+
+	nodes := func(g *models.TaskGraph) map[string]models.TaskGraphNode {
+		m := make(map[string]models.TaskGraphNode)
+		for _, n := range g.Nodes {
+			m[n.ID] = n
+		}
+		return m
+	}
+
+	g, err := orch.TaskGraph(b, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := nodes(g)
+	if len(got) != 4 || !got["missing"].Missing || got[d].Label != "d" || got[a].Status != models.TaskStatusPending {
+		t.Fatalf("unexpected nodes for %s: %+v", b, g.Nodes)
+	}
+	if _, ok := got[tasks[2].ID]; ok {
+		t.Fatalf("unrelated task included: %+v", g.Nodes)
+	}
+	want := map[models.TaskGraphEdge]bool{{From: "missing", To: a}: true, {From: a, To: b}: true, {From: b, To: d}: true}
+	if len(g.Edges) != len(want) {
+		t.Fatalf("unexpected edges %+v", g.Edges)
+	}
+	for _, e := range g.Edges {
+		if !want[e] {
+			t.Fatalf("unexpected edge %+v", e)
+		}
+	}
+
+	g, err = orch.TaskGraph("", "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 4 || len(g.Edges) != 3 {
+		t.Fatalf("expected the tagged task and its dependencies, got %+v", g)
+	}
+
+	if _, err := orch.TaskGraph("nope", ""); err == nil {
+		t.Fatal("expected an error for an unknown task")
+	}
+}
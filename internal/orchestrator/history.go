@@ -0,0 +1,196 @@
+package orchestrator
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sevir/mesnada/internal/store"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// DayStats counts the tasks created and finished on one day.
+type DayStats struct {
+	Date      string `json:"date"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+	Failed    int    `json:"failed"`
+	Cancelled int    `json:"cancelled"`
+}
+
+// ModelStats breaks the outcomes, durations and usage of finished tasks down
+// for one engine and model.
+type ModelStats struct {
+	Engine    string `json:"engine"`
+	Model     string `json:"model"`
+	Completed int    `json:"completed"`
+	Failed    int    `json:"failed"`
+	Cancelled int    `json:"cancelled"`
+	// AvgDurationSeconds averages the run time of the finished tasks that started.
+	AvgDurationSeconds float64 `json:"avg_duration_seconds"`
+	TokensIn           int64   `json:"tokens_in"`
+	TokensOut          int64   `json:"tokens_out"`
+	CostUSD            float64 `json:"cost_usd"`
+}
+
+// TaskHistory is the activity of the last days, for charts.
+type TaskHistory struct {
+	Days   []DayStats   `json:"days"`
+	Models []ModelStats `json:"models"`
+}
+
+// TaskHistory returns per-day task counts for the days days up to now, oldest
+// first, and per engine and model outcomes of the tasks that finished in that
+// time. Days are calendar days in now's location. Tasks count as created on
+// the day of CreatedAt and as finished on the day of CompletedAt.
+func (o *Orchestrator) TaskHistory(days int, now time.Time) (*TaskHistory, error) {
+	tasks, err := o.store.List(store.ListFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	loc := now.Location()
+	y, m, d := now.Date()
+	first := time.Date(y, m, d-days+1, 0, 0, 0, 0, loc)
+	history := &TaskHistory{
+		Days:   make([]DayStats, days),
+		Models: []ModelStats{},
+	}
+	for i := range history.Days {
+		history.Days[i].Date = first.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	// day returns the bucket of t, or nil when it is outside the range.
+	day := func(t time.Time) *DayStats {
+		t = t.In(loc)
+		i := int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).Sub(first).Hours()+12) / 24
+		if t.Before(first) || i < 0 || i >= days {
+			return nil
+		}
+		return &history.Days[i]
+	}
+
+	type modelKey struct{ engine, model string }
+	byModel := make(map[modelKey]*ModelStats)
+	durations := make(map[modelKey]time.Duration)
+	timed := make(map[modelKey]int)
+	for _, task := range tasks {
+		if ds := day(task.CreatedAt); ds != nil {
+			ds.Created++
+		}
+		// Paused tasks also have CompletedAt; they have not finished.
+		if task.CompletedAt == nil || task.Status == models.TaskStatusPaused || !task.IsTerminal() {
+			continue
+		}
+		ds := day(*task.CompletedAt)
+		if ds == nil {
+			continue
+		}
+
+		engine := task.Engine
+		if engine == "" {
+			engine = o.defaultEngine
+		}
+		key := modelKey{string(engine), task.Model}
+		ms := byModel[key]
+		if ms == nil {
+			ms = &ModelStats{Engine: key.engine, Model: key.model}
+			byModel[key] = ms
+		}
+		switch task.Status {
+		case models.TaskStatusCompleted:
+			ds.Completed++
+			ms.Completed++
+		case models.TaskStatusFailed:
+			ds.Failed++
+			ms.Failed++
+		case models.TaskStatusCancelled:
+			ds.Cancelled++
+			ms.Cancelled++
+		}
+		if task.StartedAt != nil {
+			durations[key] += task.CompletedAt.Sub(*task.StartedAt)
+			timed[key]++
+		}
+		ms.TokensIn += task.TokensIn
+		ms.TokensOut += task.TokensOut
+		ms.CostUSD += task.CostUSD
+	}
+
+	for key, ms := range byModel {
+		if n := timed[key]; n > 0 {
+			ms.AvgDurationSeconds = (durations[key] / time.Duration(n)).Seconds()
+		}
+		history.Models = append(history.Models, *ms)
+	}
+	sort.Slice(history.Models, func(i, j int) bool {
+		a, b := history.Models[i], history.Models[j]
+		if a.Engine != b.Engine {
+			return a.Engine < b.Engine
+		}
+		return a.Model < b.Model
+	})
+	return history, nil
+}
+
+// TimelineTask is one task's run, for timeline charts. CompletedAt is nil
+// while the task is still running.
+type TimelineTask struct {
+	ID            string            `json:"id"`
+	Status        models.TaskStatus `json:"status"`
+	Engine        string            `json:"engine"`
+	Model         string            `json:"model,omitempty"`
+	Tags          []string          `json:"tags"`
+	PromptExcerpt string            `json:"prompt_excerpt"`
+	StartedAt     time.Time         `json:"started_at"`
+	CompletedAt   *time.Time        `json:"completed_at,omitempty"`
+}
+
+// Timeline returns the tasks that ran at some point between from and to,
+// ordered by start time. Tasks that never started are left out.
+func (o *Orchestrator) Timeline(from, to time.Time) ([]TimelineTask, error) {
+	tasks, err := o.store.List(store.ListFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := []TimelineTask{}
+	for _, task := range tasks {
+		if task.StartedAt == nil || task.StartedAt.After(to) {
+			continue
+		}
+		var completed *time.Time
+		if task.CompletedAt != nil && task.Status != models.TaskStatusRunning {
+			if task.CompletedAt.Before(from) {
+				continue
+			}
+			completed = task.CompletedAt
+		} else if !task.IsTerminal() && task.Status != models.TaskStatusRunning {
+			// Paused without a pause time; nothing to draw.
+			continue
+		}
+
+		engine := task.Engine
+		if engine == "" {
+			engine = o.defaultEngine
+		}
+		tags := task.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		prompt := taskPrompt(task)
+		timeline = append(timeline, TimelineTask{
+			ID:            task.ID,
+			Status:        task.Status,
+			Engine:        string(engine),
+			Model:         task.Model,
+			Tags:          tags,
+			PromptExcerpt: truncateForLog(strings.TrimSpace(prompt), 80),
+			StartedAt:     *task.StartedAt,
+			CompletedAt:   completed,
+		})
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		return timeline[i].StartedAt.Before(timeline[j].StartedAt)
+	})
+	return timeline, nil
+}
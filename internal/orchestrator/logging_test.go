@@ -67,6 +67,30 @@ func TestTaskLifecycleLogging_ReceivedAndStartable(t *testing.T) {
 	}
 }
 
+func TestTaskLifecycleLogging_ReceivedHidesInlineMCPConfig(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	buf, restore := captureStdLogger(t)
+	defer restore()
+
+	_, err := orch.Spawn(context2.Background(), models.SpawnRequest{
+		Prompt:       "echo hello",
+		WorkDir:      "/tmp",
+		Background:   true,
+		Dependencies: []string{"missing"},
+		MCPConfig:    `{"mcpServers":{"api":{"type":"http","url":"https://mcp.example","headers":{"Authorization":"Bearer sk-secret"}}}}`,
+	})
+	if err != nil {
+		t.Fatalf("Failed to spawn task: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "sk-secret") || !strings.Contains(out, `mcp_config="inline"`) {
+		t.Fatalf("expected the inline MCP config not to be logged, got:\n%s", out)
+	}
+}
+
 func TestTaskLifecycleLogging_StartableWhenDependenciesSatisfied(t *testing.T) {
 	orch, cleanup := setupTestOrchestrator(t)
 	defer cleanup()
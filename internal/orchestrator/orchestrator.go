@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
 	"sync"
@@ -12,11 +13,19 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sevir/mesnada/internal/agent"
+	"github.com/sevir/mesnada/internal/config"
 	"github.com/sevir/mesnada/internal/persona"
 	"github.com/sevir/mesnada/internal/store"
 	"github.com/sevir/mesnada/pkg/models"
 )
 
+// engineProbeInterval is how often engine binaries are re-probed.
+const engineProbeInterval = 5 * time.Minute
+
+// maxResultDataBytes bounds the JSON data of a reported task result, which
+// is persisted with the task.
+const maxResultDataBytes = 256 * 1024
+
 // Orchestrator coordinates the execution of CLI agents.
 type Orchestrator struct {
 	store            store.Store
@@ -27,6 +36,16 @@ type Orchestrator struct {
 	maxParallel      int
 	defaultMCPConfig string
 	defaultEngine    models.Engine
+	secrets          map[string]config.SecretConfig
+	createWorkDir    bool
+	allowedWorkDirs  []string
+	autoCommit       bool
+	defaults         []config.DefaultRule
+	modelAliases     map[string]string
+	aliasesMu        sync.RWMutex
+	listeners        []TaskListener
+	engineListeners  []EngineListener
+	listenersMu      sync.RWMutex
 	wg               sync.WaitGroup
 	ctx              context.Context
 	cancel           context.CancelFunc
@@ -40,6 +59,17 @@ type Config struct {
 	DefaultMCPConfig string
 	DefaultEngine    string
 	PersonaPath      string
+	Sandbox          config.SandboxConfig
+	Engines          map[string]config.EngineConfig
+	Env              config.EnvConfig
+	Secrets          map[string]config.SecretConfig
+	MaxLogSize       int64
+	CompressLogs     bool
+	CreateWorkDir    bool
+	AllowedWorkDirs  []string
+	AutoCommit       bool
+	Defaults         []config.DefaultRule
+	ModelAliases     map[string]string
 }
 
 // New creates a new Orchestrator.
@@ -75,19 +105,91 @@ func New(cfg Config) (*Orchestrator, error) {
 		maxParallel:      cfg.MaxParallel,
 		defaultMCPConfig: cfg.DefaultMCPConfig,
 		defaultEngine:    defaultEngine,
+		secrets:          cfg.Secrets,
+		createWorkDir:    cfg.CreateWorkDir,
+		allowedWorkDirs:  cfg.AllowedWorkDirs,
+		autoCommit:       cfg.AutoCommit,
+		defaults:         cfg.Defaults,
+		modelAliases:     cfg.ModelAliases,
 		ctx:              ctx,
 		cancel:           cancel,
 	}
 
-	o.manager = agent.NewManager(cfg.LogDir, o.onTaskComplete)
+	o.manager = agent.NewManager(agent.Config{
+		LogDir:       cfg.LogDir,
+		Sandbox:      cfg.Sandbox,
+		Engines:      cfg.Engines,
+		Env:          cfg.Env,
+		Secrets:      cfg.Secrets,
+		MaxLogSize:   cfg.MaxLogSize,
+		CompressLogs: cfg.CompressLogs,
+	}, o.onTaskComplete)
+
+	go o.probeEnginesLoop()
 
 	return o, nil
 }
 
+// probeEnginesLoop probes engine binaries on startup and then periodically.
+func (o *Orchestrator) probeEnginesLoop() {
+	ticker := time.NewTicker(engineProbeInterval)
+	defer ticker.Stop()
+
+	var prev []models.EngineInfo
+	for {
+		infos := o.manager.ProbeEngines(o.ctx)
+		for _, info := range infos {
+			slog.Debug("engine_event=probed", "engine", info.Engine, "available", info.Available, "version", info.Version)
+		}
+		if prev != nil && enginesChanged(prev, infos) {
+			o.notifyEnginesChange(infos)
+		}
+		prev = infos
+
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// StreamOutput subscribes to the live output of a running task.
+// Lines already written before subscribing must be read from the task log.
+func (o *Orchestrator) StreamOutput(taskID string) (<-chan string, func(), error) {
+	if _, err := o.store.Get(taskID); err != nil {
+		return nil, nil, err
+	}
+	return o.manager.StreamOutput(taskID)
+}
+
+// ListEngines returns the availability of each engine. With refresh, engines are probed again.
+func (o *Orchestrator) ListEngines(ctx context.Context, refresh bool) []models.EngineInfo {
+	if refresh {
+		return o.manager.ProbeEngines(ctx)
+	}
+	return o.manager.Engines(ctx)
+}
+
+// EnginesProbed reports whether engine availability has been probed yet.
+func (o *Orchestrator) EnginesProbed() bool {
+	return o.manager.EnginesProbed()
+}
+
+// CheckStore reports why the task store cannot persist tasks, or nil.
+func (o *Orchestrator) CheckStore() error {
+	return o.store.Check()
+}
+
 func (o *Orchestrator) onTaskComplete(task *models.Task) {
+	if task.AutoCommit && task.Status == models.TaskStatusCompleted {
+		commitTaskChanges(task)
+	}
+
 	// Save final state
 	o.store.Save(task)
 	logTaskFinished(task)
+	o.notifyTaskChange(TaskFinished, task)
 
 	// Notify subscribers
 	o.subMu.RLock()
@@ -147,6 +249,7 @@ func (o *Orchestrator) canStart(task *models.Task) bool {
 }
 
 func (o *Orchestrator) startTask(task *models.Task) {
+	task.BaseCommit = gitHead(task.WorkDir)
 	if err := o.manager.Spawn(o.ctx, task); err != nil {
 		task.Status = models.TaskStatusFailed
 		task.Error = err.Error()
@@ -154,8 +257,12 @@ func (o *Orchestrator) startTask(task *models.Task) {
 		task.CompletedAt = &now
 		// When spawning fails, we still consider the task finished.
 		logTaskFinished(task)
+		o.store.Save(task)
+		o.notifyTaskChange(TaskFinished, task)
+		return
 	}
 	o.store.Save(task)
+	o.notifyTaskChange(TaskStarted, task)
 }
 
 // getDependencyLogs retrieves the last N lines from the log files of dependency tasks.
@@ -170,19 +277,19 @@ func (o *Orchestrator) getDependencyLogs(dependencies []string, numLines int) (s
 	for _, depID := range dependencies {
 		dep, err := o.store.Get(depID)
 		if err != nil {
-			log.Printf("Warning: failed to get dependency task %s: %v", depID, err)
+			slog.Warn("failed to get dependency task", "task_id", depID, "error", err)
 			continue
 		}
 
 		if dep.LogFile == "" {
-			log.Printf("Warning: dependency task %s has no log file", depID)
+			slog.Warn("dependency task has no log file", "task_id", depID)
 			continue
 		}
 
 		// Read the log file
-		content, err := os.ReadFile(dep.LogFile)
+		content, err := agent.ReadLog(dep.LogFile)
 		if err != nil {
-			log.Printf("Warning: failed to read log file %s: %v", dep.LogFile, err)
+			slog.Warn("failed to read dependency log file", "path", dep.LogFile, "error", err)
 			continue
 		}
 
@@ -203,11 +310,150 @@ func (o *Orchestrator) getDependencyLogs(dependencies []string, numLines int) (s
 
 // Spawn creates and optionally starts a new agent task.
 func (o *Orchestrator) Spawn(ctx context.Context, req models.SpawnRequest) (*models.Task, error) {
+	task, err := o.newTask(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.submit(task, req.Background); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// submit saves a new task and starts it if its dependencies allow.
+func (o *Orchestrator) submit(task *models.Task, background bool) error {
+	// Save task
+	if err := o.store.Save(task); err != nil {
+		return fmt.Errorf("failed to save task: %w", err)
+	}
+	o.notifyTaskChange(TaskCreated, task)
+	o.startIfReady(task, background)
+	return nil
+}
+
+// startIfReady starts a pending task whose dependencies are satisfied.
+func (o *Orchestrator) startIfReady(task *models.Task, background bool) {
+	if !o.canStart(task) {
+		return
+	}
+	reason := "dependencies_satisfied"
+	if len(task.Dependencies) == 0 {
+		reason = "no_dependencies"
+	}
+	logTaskStartable(task, reason)
+	// A foreground task whose engine is at max_parallel is queued like a
+	// background one, so the caller gets the pending task back at once.
+	if background || !o.manager.TryReserveSlot(task) {
+		go o.startTask(task)
+	} else {
+		o.startTask(task)
+	}
+}
+
+// SpawnBatch creates several tasks at once. dependsOn[i] lists the indexes of
+// the batch items that task i must wait for, on top of its own dependencies.
+// Either every task is created or none is, and no task starts before the
+// whole batch has been saved. Batch tasks always run in the background.
+func (o *Orchestrator) SpawnBatch(ctx context.Context, reqs []models.SpawnRequest, dependsOn [][]int) ([]*models.Task, error) {
+	if err := checkBatchDependencies(len(reqs), dependsOn); err != nil {
+		return nil, err
+	}
+
+	tasks := make([]*models.Task, len(reqs))
+	for i, req := range reqs {
+		task, err := o.newTask(req)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		tasks[i] = task
+	}
+	for i, deps := range dependsOn {
+		for _, j := range deps {
+			tasks[i].Dependencies = append(tasks[i].Dependencies, tasks[j].ID)
+		}
+	}
+
+	for i, task := range tasks {
+		if err := o.store.Save(task); err != nil {
+			for _, saved := range tasks[:i] {
+				o.store.Delete(saved.ID)
+			}
+			return nil, fmt.Errorf("failed to save task: %w", err)
+		}
+	}
+
+	for _, task := range tasks {
+		o.notifyTaskChange(TaskCreated, task)
+	}
+	for _, task := range tasks {
+		o.startIfReady(task, true)
+	}
+
+	return tasks, nil
+}
+
+// checkBatchDependencies rejects out-of-range, self and cyclic dependencies
+// between the items of a batch, which would leave tasks pending forever.
+func checkBatchDependencies(n int, dependsOn [][]int) error {
+	if len(dependsOn) > n {
+		return fmt.Errorf("dependencies given for %d items, but the batch has %d", len(dependsOn), n)
+	}
+	for i, deps := range dependsOn {
+		for _, j := range deps {
+			if j < 0 || j >= n || j == i {
+				return fmt.Errorf("item %d: invalid dependency index %d", i, j)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, n)
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("item %d: dependency cycle", i)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		if i < len(dependsOn) {
+			for _, j := range dependsOn[i] {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = done
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTask validates a spawn request and builds the pending task for it.
+func (o *Orchestrator) newTask(req models.SpawnRequest) (*models.Task, error) {
 	// Validate work directory
-	workDir := req.WorkDir
-	if workDir == "" {
-		workDir = "."
+	workDir, err := o.resolveWorkDir(req.WorkDir)
+	if err != nil {
+		return nil, err
 	}
+	project, err := config.LoadProjectConfig(workDir)
+	if err != nil {
+		return nil, err
+	}
+	req = applyProjectConfig(req, project)
+	req = o.applyDefaults(req, workDir)
+	req.Model = o.resolveModel(req.Model)
 
 	// Parse timeout
 	var timeout models.Duration
@@ -231,9 +477,29 @@ func (o *Orchestrator) Spawn(ctx context.Context, req models.SpawnRequest) (*mod
 		engine = o.defaultEngine
 	}
 
+	if err := agent.ValidateEngine(string(engine)); err != nil {
+		return nil, err
+	}
+
+	if !models.ValidSandbox(req.Sandbox) {
+		return nil, fmt.Errorf("invalid sandbox: %s (valid: none, docker, kubernetes)", req.Sandbox)
+	}
+	if err := o.manager.CheckSandbox(&models.Task{Engine: engine, Sandbox: req.Sandbox}); err != nil {
+		return nil, err
+	}
+
+	for _, name := range req.Secrets {
+		if _, ok := o.secrets[name]; !ok {
+			return nil, fmt.Errorf("unknown secret: %s", name)
+		}
+	}
+
 	// Apply persona to prompt if specified
 	prompt := req.Prompt
 	if req.Persona != "" {
+		if !o.personaManager.HasPersona(req.Persona) {
+			return nil, fmt.Errorf("unknown persona: %s", req.Persona)
+		}
 		prompt = o.personaManager.ApplyPersona(req.Persona, prompt)
 	}
 
@@ -246,7 +512,7 @@ func (o *Orchestrator) Spawn(ctx context.Context, req models.SpawnRequest) (*mod
 
 		dependencyLogs, err := o.getDependencyLogs(req.Dependencies, logLines)
 		if err != nil {
-			log.Printf("Warning: failed to get dependency logs: %v", err)
+			slog.Warn("failed to get dependency logs", "error", err)
 		} else if dependencyLogs != "" {
 			prompt = prompt + "\n\n" + dependencyLogs
 		}
@@ -259,37 +525,27 @@ func (o *Orchestrator) Spawn(ctx context.Context, req models.SpawnRequest) (*mod
 		Status:       models.TaskStatusPending,
 		Engine:       engine,
 		Model:        req.Model,
-		Dependencies: req.Dependencies,
+		Dependencies: append([]string(nil), req.Dependencies...),
 		Tags:         req.Tags,
 		Priority:     req.Priority,
 		Timeout:      timeout,
 		MCPConfig:    mcpConfig,
 		ExtraArgs:    req.ExtraArgs,
 		Persona:      req.Persona,
+		Sandbox:      req.Sandbox,
+		Secrets:      req.Secrets,
 		CreatedAt:    time.Now(),
-	}
 
-	logTaskReceived(task)
-
-	// Save task
-	if err := o.store.Save(task); err != nil {
-		return nil, fmt.Errorf("failed to save task: %w", err)
+		ResumeSessionID: req.ResumeSessionID,
+		Metadata:        req.Metadata,
 	}
 
-	// Check if can start immediately
-	if o.canStart(task) {
-		reason := "dependencies_satisfied"
-		if len(task.Dependencies) == 0 {
-			reason = "no_dependencies"
-		}
-		logTaskStartable(task, reason)
-		if req.Background {
-			go o.startTask(task)
-		} else {
-			o.startTask(task)
-		}
+	task.AutoCommit = o.autoCommit
+	if req.AutoCommit != nil {
+		task.AutoCommit = *req.AutoCommit
 	}
 
+	logTaskReceived(task)
 	return task, nil
 }
 
@@ -301,10 +557,16 @@ func (o *Orchestrator) GetTask(taskID string) (*models.Task, error) {
 // ListTasks lists tasks matching the filter.
 func (o *Orchestrator) ListTasks(req models.ListRequest) ([]*models.Task, error) {
 	return o.store.List(store.ListFilter{
-		Status: req.Status,
-		Tags:   req.Tags,
-		Limit:  req.Limit,
-		Offset: req.Offset,
+		Status:        req.Status,
+		Tags:          req.Tags,
+		Limit:         req.Limit,
+		Offset:        req.Offset,
+		Query:         req.Query,
+		Engine:        req.Engine,
+		Model:         req.Model,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		Sort:          req.Sort,
 	})
 }
 
@@ -441,6 +703,7 @@ func (o *Orchestrator) Cancel(taskID string) error {
 		return err
 	}
 	logTaskFinished(task)
+	o.notifyTaskChange(TaskFinished, task)
 	return nil
 }
 
@@ -474,6 +737,7 @@ func (o *Orchestrator) Pause(taskID string) (*models.Task, error) {
 		return nil, err
 	}
 	logTaskFinished(task)
+	o.notifyTaskChange(TaskFinished, task)
 	return task, nil
 }
 
@@ -484,6 +748,8 @@ type ResumeOptions struct {
 	Background bool
 	Timeout    string
 	Tags       *[]string
+	// Persona overrides the persona of the paused task.
+	Persona string
 }
 
 // Resume creates a new task to continue work from a previously paused task.
@@ -514,26 +780,110 @@ func (o *Orchestrator) Resume(ctx context.Context, taskID string, opts ResumeOpt
 		tags = *opts.Tags
 	}
 
-	resumePrompt := fmt.Sprintf(
-		"Resume work from previous task_id: %s\nPrevious task log file path: %s\n\nAdditional resume instructions:\n%s\n",
-		prev.ID,
-		prev.LogFile,
-		strings.TrimSpace(opts.Prompt),
-	)
+	// Engines that report a session ID continue the real conversation; the
+	// others start fresh with a pointer to the previous log.
+	resumePrompt := strings.TrimSpace(opts.Prompt)
+	persona := opts.Persona
+	var sessionID string
+	if prev.SessionID != "" && agent.SupportsSessionResume(prev.Engine) {
+		sessionID = prev.SessionID
+	} else {
+		// A fresh agent gets the persona again; a continued session already has it.
+		if persona == "" && o.personaManager.HasPersona(prev.Persona) {
+			persona = prev.Persona
+		}
+		resumePrompt = fmt.Sprintf(
+			"Resume work from previous task_id: %s\nPrevious task log file path: %s\n\nAdditional resume instructions:\n%s\n",
+			prev.ID,
+			prev.LogFile,
+			resumePrompt,
+		)
+	}
 
-	// Keep workdir/deps/config consistent with the paused task by default.
+	// Keep engine/workdir/deps/config consistent with the paused task by default.
 	return o.Spawn(ctx, models.SpawnRequest{
-		Prompt:       resumePrompt,
+		Prompt:          resumePrompt,
+		WorkDir:         prev.WorkDir,
+		Model:           model,
+		Engine:          prev.Engine,
+		Dependencies:    prev.Dependencies,
+		Tags:            tags,
+		Priority:        prev.Priority,
+		Timeout:         timeout,
+		MCPConfig:       prev.MCPConfig,
+		ExtraArgs:       prev.ExtraArgs,
+		Sandbox:         prev.Sandbox,
+		Secrets:         prev.Secrets,
+		Persona:         persona,
+		Background:      opts.Background,
+		ResumeSessionID: sessionID,
+		AutoCommit:      &prev.AutoCommit,
+		Metadata:        prev.Metadata,
+	})
+}
+
+// RetryOptions controls how a failed task is retried.
+type RetryOptions struct {
+	// PromptAddendum is appended to the original prompt.
+	PromptAddendum string
+	// IncludeError appends the previous attempt's error to the prompt.
+	IncludeError bool
+	Background   bool
+}
+
+// Retry creates a new task with the parameters of a failed or cancelled task.
+// The new task records the task it retries and its attempt number.
+func (o *Orchestrator) Retry(ctx context.Context, taskID string, opts RetryOptions) (*models.Task, error) {
+	prev, err := o.store.Get(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if prev.Status != models.TaskStatusFailed && prev.Status != models.TaskStatusCancelled {
+		return nil, fmt.Errorf("task %s cannot be retried (status=%s)", taskID, prev.Status)
+	}
+
+	// The stored prompt already has the persona and dependency logs applied;
+	// the spawner adds the new task's ID line again.
+	prompt := taskPrompt(prev)
+	if opts.IncludeError && prev.Error != "" {
+		prompt += "\n\nThe previous attempt failed with this error:\n" + prev.Error
+	}
+	if addendum := strings.TrimSpace(opts.PromptAddendum); addendum != "" {
+		prompt += "\n\n" + addendum
+	}
+
+	var timeout string
+	if prev.Timeout > 0 {
+		timeout = time.Duration(prev.Timeout).String()
+	}
+
+	task, err := o.newTask(models.SpawnRequest{
+		Prompt:       prompt,
 		WorkDir:      prev.WorkDir,
-		Model:        model,
+		Engine:       prev.Engine,
+		Model:        prev.Model,
 		Dependencies: prev.Dependencies,
-		Tags:         tags,
+		Tags:         prev.Tags,
 		Priority:     prev.Priority,
 		Timeout:      timeout,
 		MCPConfig:    prev.MCPConfig,
 		ExtraArgs:    prev.ExtraArgs,
-		Background:   opts.Background,
+		Sandbox:      prev.Sandbox,
+		Secrets:      prev.Secrets,
+		AutoCommit:   &prev.AutoCommit,
+		Metadata:     prev.Metadata,
 	})
+	if err != nil {
+		return nil, err
+	}
+	task.Persona = prev.Persona
+	task.RetryOf = prev.ID
+	task.Attempt = prev.AttemptNumber() + 1
+
+	if err := o.submit(task, opts.Background); err != nil {
+		return nil, err
+	}
+	return task, nil
 }
 
 // Delete removes a task from the store.
@@ -549,7 +899,7 @@ func (o *Orchestrator) Delete(taskID string) error {
 		// Try to cancel the task first through the manager
 		if err := o.manager.Cancel(taskID); err != nil {
 			// If cancel fails (e.g., process already dead), log it but continue
-			log.Printf("Warning: failed to cancel task %s before deletion (process may be dead): %v", taskID, err)
+			slog.Warn("failed to cancel task before deletion (process may be dead)", "task_id", taskID, "error", err)
 		}
 
 		// Mark task as cancelled and save state
@@ -557,14 +907,18 @@ func (o *Orchestrator) Delete(taskID string) error {
 		now := time.Now()
 		task.CompletedAt = &now
 		if err := o.store.Save(task); err != nil {
-			log.Printf("Warning: failed to save cancelled state for task %s: %v", taskID, err)
+			slog.Warn("failed to save cancelled state", "task_id", taskID, "error", err)
 		}
 
 		// Wait a bit for cleanup
 		time.Sleep(100 * time.Millisecond)
 	}
 
-	return o.store.Delete(taskID)
+	if err := o.store.Delete(taskID); err != nil {
+		return err
+	}
+	o.notifyTaskChange(TaskDeleted, task)
+	return nil
 }
 
 // Purge stops a running task (if needed), deletes its log file (if any), and removes it from the store.
@@ -582,7 +936,7 @@ func (o *Orchestrator) Purge(taskID string) error {
 	if task.Status == models.TaskStatusRunning {
 		if err := o.manager.Cancel(taskID); err != nil {
 			// If cancel fails (e.g., process already dead), log it but continue with purge
-			log.Printf("Warning: failed to cancel task %s during purge (process may be dead): %v", taskID, err)
+			slog.Warn("failed to cancel task during purge (process may be dead)", "task_id", taskID, "error", err)
 		}
 
 		// Mark task as cancelled and save state
@@ -590,16 +944,21 @@ func (o *Orchestrator) Purge(taskID string) error {
 		now := time.Now()
 		task.CompletedAt = &now
 		if err := o.store.Save(task); err != nil {
-			log.Printf("Warning: failed to save cancelled state for task %s during purge: %v", taskID, err)
+			slog.Warn("failed to save cancelled state during purge", "task_id", taskID, "error", err)
 		}
 
 		// Wait a bit for cleanup
 		time.Sleep(100 * time.Millisecond)
 	}
 
-	// Best-effort: remove log file.
+	// Best-effort: remove log and event files.
 	if task.LogFile != "" {
-		_ = os.Remove(task.LogFile)
+		for _, suffix := range []string{"", ".gz", ".1", ".1.gz"} {
+			_ = os.Remove(task.LogFile + suffix)
+		}
+	}
+	if task.EventsFile != "" {
+		_ = os.Remove(task.EventsFile)
 	}
 
 	if err := o.store.Delete(taskID); err != nil {
@@ -608,10 +967,42 @@ func (o *Orchestrator) Purge(taskID string) error {
 		}
 		return err
 	}
+	o.notifyTaskChange(TaskDeleted, task)
 
 	return nil
 }
 
+// DeleteTasks removes every terminal task matching the filter and returns how
+// many were deleted. Without a status filter it matches completed, failed and
+// cancelled tasks; pending and running tasks are never deleted. With purge set,
+// log files are removed as well.
+func (o *Orchestrator) DeleteTasks(req models.ListRequest, purge bool) (int, error) {
+	if len(req.Status) == 0 {
+		req.Status = []models.TaskStatus{models.TaskStatusCompleted, models.TaskStatusFailed, models.TaskStatusCancelled}
+	}
+	tasks, err := o.ListTasks(req)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, task := range tasks {
+		if !task.IsTerminal() {
+			continue
+		}
+		if purge {
+			err = o.Purge(task.ID)
+		} else {
+			err = o.Delete(task.ID)
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete task %s: %w", task.ID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
 // SetProgress updates the progress of a running task.
 func (o *Orchestrator) SetProgress(taskID string, percentage int, description string) error {
 	task, err := o.store.Get(taskID)
@@ -633,7 +1024,200 @@ func (o *Orchestrator) SetProgress(taskID string, percentage int, description st
 		UpdatedAt:   time.Now(),
 	}
 
-	return o.store.Save(task)
+	if err := o.store.Save(task); err != nil {
+		return err
+	}
+	o.notifyTaskChange(TaskUpdated, task)
+	return nil
+}
+
+// TaskUpdate lists the task fields to change; nil fields are left as they are.
+type TaskUpdate struct {
+	Tags     *[]string
+	Priority *int
+	// Timeout is a duration string; empty removes the timeout.
+	Timeout *string
+	// Metadata is merged into the task metadata; an empty value removes the key.
+	Metadata map[string]string
+}
+
+// Update changes the mutable fields of a task. Pending tasks accept every
+// field; running tasks only tags and metadata, since their priority and
+// timeout were already applied when they started. Finished tasks are frozen.
+func (o *Orchestrator) Update(taskID string, update TaskUpdate) (*models.Task, error) {
+	task, err := o.store.Get(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch task.Status {
+	case models.TaskStatusPending:
+	case models.TaskStatusRunning:
+		if update.Priority != nil {
+			return nil, fmt.Errorf("priority of task %s cannot change while it is running", taskID)
+		}
+		if update.Timeout != nil {
+			return nil, fmt.Errorf("timeout of task %s cannot change while it is running", taskID)
+		}
+	default:
+		return nil, fmt.Errorf("task %s cannot be updated (status=%s)", taskID, task.Status)
+	}
+
+	var timeout models.Duration
+	if update.Timeout != nil && *update.Timeout != "" {
+		dur, err := time.ParseDuration(*update.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		timeout = models.Duration(dur)
+	}
+
+	if update.Tags != nil {
+		task.Tags = *update.Tags
+	}
+	if update.Priority != nil {
+		task.Priority = *update.Priority
+	}
+	if update.Timeout != nil {
+		task.Timeout = timeout
+	}
+	if len(update.Metadata) > 0 {
+		metadata := make(map[string]string, len(task.Metadata)+len(update.Metadata))
+		for k, v := range task.Metadata {
+			metadata[k] = v
+		}
+		for k, v := range update.Metadata {
+			if v == "" {
+				delete(metadata, k)
+			} else {
+				metadata[k] = v
+			}
+		}
+		task.Metadata = metadata
+	}
+
+	if err := o.store.Save(task); err != nil {
+		return nil, err
+	}
+	o.notifyTaskChange(TaskUpdated, task)
+	return task, nil
+}
+
+// AddDependency makes a pending task wait for another task as well. The
+// dependency must exist and must not (transitively) depend on the task.
+func (o *Orchestrator) AddDependency(taskID, dependencyID string) (*models.Task, error) {
+	task, err := o.pendingTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if dependencyID == taskID {
+		return nil, fmt.Errorf("task %s cannot depend on itself", taskID)
+	}
+	if _, err := o.store.Get(dependencyID); err != nil {
+		return nil, err
+	}
+	for _, dep := range task.Dependencies {
+		if dep == dependencyID {
+			return task, nil
+		}
+	}
+	if o.dependsOn(dependencyID, taskID) {
+		return nil, fmt.Errorf("adding dependency %s to task %s would create a cycle", dependencyID, taskID)
+	}
+
+	task.Dependencies = append(append([]string(nil), task.Dependencies...), dependencyID)
+	if err := o.store.Save(task); err != nil {
+		return nil, err
+	}
+	o.notifyTaskChange(TaskUpdated, task)
+	return task, nil
+}
+
+// RemoveDependency drops a dependency of a pending task, starting the task
+// if its remaining dependencies are satisfied.
+func (o *Orchestrator) RemoveDependency(taskID, dependencyID string) (*models.Task, error) {
+	task, err := o.pendingTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make([]string, 0, len(task.Dependencies))
+	for _, dep := range task.Dependencies {
+		if dep != dependencyID {
+			deps = append(deps, dep)
+		}
+	}
+	if len(deps) == len(task.Dependencies) {
+		return nil, fmt.Errorf("task %s does not depend on %s", taskID, dependencyID)
+	}
+
+	task.Dependencies = deps
+	if err := o.store.Save(task); err != nil {
+		return nil, err
+	}
+	o.notifyTaskChange(TaskUpdated, task)
+	o.startIfReady(task, true)
+	return task, nil
+}
+
+// pendingTask returns a task whose dependencies may still be edited.
+func (o *Orchestrator) pendingTask(taskID string) (*models.Task, error) {
+	task, err := o.store.Get(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status != models.TaskStatusPending {
+		return nil, fmt.Errorf("dependencies of task %s cannot change (status=%s)", taskID, task.Status)
+	}
+	return task, nil
+}
+
+// dependsOn reports whether taskID transitively depends on target.
+func (o *Orchestrator) dependsOn(taskID, target string) bool {
+	seen := make(map[string]bool)
+	stack := []string{taskID}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == target {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if task, err := o.store.Get(id); err == nil {
+			stack = append(stack, task.Dependencies...)
+		}
+	}
+	return false
+}
+
+// SetResult records the structured result an agent reports for its task.
+// Only pending or running tasks accept a result.
+func (o *Orchestrator) SetResult(taskID string, result models.TaskResult) error {
+	task, err := o.store.Get(taskID)
+	if err != nil {
+		return err
+	}
+	if task.IsTerminal() {
+		return fmt.Errorf("task %s already finished (status=%s)", taskID, task.Status)
+	}
+	if !models.ValidResultStatus(result.Status) {
+		return fmt.Errorf("invalid result status: %s (valid: success, partial, failure)", result.Status)
+	}
+	if len(result.Data) > maxResultDataBytes {
+		return fmt.Errorf("result data too large: %d bytes (max %d)", len(result.Data), maxResultDataBytes)
+	}
+
+	result.ReportedAt = time.Now()
+	task.Result = &result
+
+	if err := o.store.Save(task); err != nil {
+		return err
+	}
+	o.notifyTaskChange(TaskUpdated, task)
+	return nil
 }
 
 // GetStats returns orchestrator statistics.
@@ -643,10 +1227,23 @@ func (o *Orchestrator) GetStats() Stats {
 	stats := Stats{
 		Running:         o.manager.RunningCount(),
 		RunningProgress: make(map[string]TaskProgressInfo),
+		TokensByModel:   make(map[string]TokenUsage),
 	}
 
 	for _, task := range tasks {
 		stats.Total++
+		if task.TokensIn > 0 || task.TokensOut > 0 || task.CostUSD > 0 {
+			model := task.Model
+			if model == "" {
+				model = "default"
+			}
+			usage := stats.TokensByModel[model]
+			usage.Tasks++
+			usage.TokensIn += task.TokensIn
+			usage.TokensOut += task.TokensOut
+			usage.CostUSD += task.CostUSD
+			stats.TokensByModel[model] = usage
+		}
 		switch task.Status {
 		case models.TaskStatusPending:
 			stats.Pending++
@@ -682,6 +1279,14 @@ type TaskProgressInfo struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// TokenUsage aggregates token counts for one model.
+type TokenUsage struct {
+	Tasks     int     `json:"tasks"`
+	TokensIn  int64   `json:"tokens_in"`
+	TokensOut int64   `json:"tokens_out"`
+	CostUSD   float64 `json:"cost_usd,omitempty"`
+}
+
 // Stats holds orchestrator statistics.
 type Stats struct {
 	Total           int                         `json:"total"`
@@ -692,6 +1297,75 @@ type Stats struct {
 	Failed          int                         `json:"failed"`
 	Cancelled       int                         `json:"cancelled"`
 	RunningProgress map[string]TaskProgressInfo `json:"running_progress,omitempty"`
+	TokensByModel   map[string]TokenUsage       `json:"tokens_by_model,omitempty"`
+}
+
+// EngineStats breaks task counts and usage down for one engine.
+type EngineStats struct {
+	Pending   int     `json:"pending"`
+	Running   int     `json:"running"`
+	Completed int     `json:"completed"`
+	Failed    int     `json:"failed"`
+	TokensIn  int64   `json:"tokens_in"`
+	TokensOut int64   `json:"tokens_out"`
+	CostUSD   float64 `json:"cost_usd"`
+}
+
+// DetailedStats extends Stats with the breakdowns dashboards need.
+type DetailedStats struct {
+	Stats
+	// QueueDepth counts pending tasks ready to start, waiting for an engine slot.
+	QueueDepth int `json:"queue_depth"`
+	// WaitingOnDependencies counts pending tasks whose dependencies have not completed.
+	WaitingOnDependencies int                    `json:"waiting_on_dependencies"`
+	MaxParallel           int                    `json:"max_parallel"`
+	Engines               map[string]EngineStats `json:"engines"`
+	TokensIn              int64                  `json:"tokens_in"`
+	TokensOut             int64                  `json:"tokens_out"`
+	CostUSD               float64                `json:"cost_usd"`
+}
+
+// GetDetailedStats returns Stats plus queue depth, per-engine counts and
+// token and cost totals.
+func (o *Orchestrator) GetDetailedStats() DetailedStats {
+	stats := DetailedStats{
+		Stats:       o.GetStats(),
+		MaxParallel: o.maxParallel,
+		Engines:     make(map[string]EngineStats),
+	}
+
+	tasks, _ := o.store.List(store.ListFilter{})
+	for _, task := range tasks {
+		engine := task.Engine
+		if engine == "" {
+			engine = o.defaultEngine
+		}
+		es := stats.Engines[string(engine)]
+		switch task.Status {
+		case models.TaskStatusPending:
+			es.Pending++
+			if o.canStart(task) {
+				stats.QueueDepth++
+			} else {
+				stats.WaitingOnDependencies++
+			}
+		case models.TaskStatusRunning:
+			es.Running++
+		case models.TaskStatusCompleted:
+			es.Completed++
+		case models.TaskStatusFailed:
+			es.Failed++
+		}
+		es.TokensIn += task.TokensIn
+		es.TokensOut += task.TokensOut
+		es.CostUSD += task.CostUSD
+		stats.Engines[string(engine)] = es
+
+		stats.TokensIn += task.TokensIn
+		stats.TokensOut += task.TokensOut
+		stats.CostUSD += task.CostUSD
+	}
+	return stats
 }
 
 // Shutdown gracefully shuts down the orchestrator.
@@ -710,6 +1384,26 @@ func (o *Orchestrator) ListPersonas() []string {
 	return o.personaManager.ListPersonas()
 }
 
+// GetPersona returns the content of a persona, or "" when it is not loaded.
+func (o *Orchestrator) GetPersona(name string) string {
+	return o.personaManager.GetPersona(name)
+}
+
+// HasPersona reports whether a persona is loaded.
+func (o *Orchestrator) HasPersona(name string) bool {
+	return o.personaManager.HasPersona(name)
+}
+
+// SavePersona creates or replaces a persona in the persona directory.
+func (o *Orchestrator) SavePersona(name, content string) error {
+	return o.personaManager.SavePersona(name, content)
+}
+
+// DeletePersona removes a persona from the persona directory.
+func (o *Orchestrator) DeletePersona(name string) error {
+	return o.personaManager.DeletePersona(name)
+}
+
 func logTaskReceived(task *models.Task) {
 	log.Printf(
 		"task_event=received task_id=%s status=%s work_dir=%q engine=%q model=%q dependencies=%v tags=%v priority=%d timeout=%q mcp_config=%q extra_args=%v prompt_len=%d prompt_preview=%q",
@@ -722,7 +1416,7 @@ func logTaskReceived(task *models.Task) {
 		task.Tags,
 		task.Priority,
 		time.Duration(task.Timeout).String(),
-		task.MCPConfig,
+		agent.MCPConfigSource(task.MCPConfig),
 		task.ExtraArgs,
 		len(task.Prompt),
 		truncateForLog(task.Prompt, 160),
@@ -761,6 +1455,12 @@ func logTaskFinished(task *models.Task) {
 	)
 }
 
+// taskPrompt returns the prompt of a task without the task ID line the
+// spawner puts in front of it.
+func taskPrompt(task *models.Task) string {
+	return strings.TrimPrefix(task.Prompt, fmt.Sprintf("You are the task_id: %s\n\n", task.ID))
+}
+
 func truncateForLog(s string, max int) string {
 	if max <= 0 {
 		return ""
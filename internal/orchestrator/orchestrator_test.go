@@ -4,10 +4,12 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/sevir/mesnada/internal/config"
 	"github.com/sevir/mesnada/pkg/models"
 )
 
@@ -69,6 +71,63 @@ func TestOrchestratorSpawn(t *testing.T) {
 	}
 }
 
+func TestOrchestratorSpawnQueuesWhenEngineIsFull(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir, err := os.MkdirTemp("", "mesnada-orch-test-queue-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	orch, err := New(Config{
+		StorePath:   filepath.Join(tmpDir, "tasks.json"),
+		LogDir:      filepath.Join(tmpDir, "logs"),
+		MaxParallel: 2,
+		Engines: map[string]config.EngineConfig{
+			"copilot": {Binary: "sh", DefaultArgs: []string{"-c", "sleep 30"}, MaxParallel: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+	defer orch.Shutdown()
+
+	req := models.SpawnRequest{Prompt: "p", WorkDir: tmpDir, Engine: models.EngineCopilot}
+	first, err := orch.Spawn(context.Background(), req)
+	if err != nil || first.Status != models.TaskStatusRunning {
+		t.Fatalf("expected the first task to run, got %v (err %v)", first, err)
+	}
+
+	// The engine is full: a foreground spawn must not wait for the slot.
+	spawned := make(chan *models.Task, 1)
+	go func() {
+		task, _ := orch.Spawn(context.Background(), req)
+		spawned <- task
+	}()
+	var second *models.Task
+	select {
+	case second = <-spawned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Spawn to return while the engine is at max_parallel")
+	}
+	if second == nil || second.Status != models.TaskStatusPending {
+		t.Fatalf("expected a pending task, got %v", second)
+	}
+
+	if err := orch.Cancel(first.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !orch.manager.IsRunning(second.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the queued task to start once the slot freed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
 func TestOrchestratorDefaultMCPConfigApplied(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "mesnada-orch-test-default-mcp-*")
 	if err != nil {
@@ -289,6 +348,44 @@ func TestOrchestratorPauseAndResume(t *testing.T) {
 	}
 }
 
+func TestOrchestratorResumeUsesEngineSession(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	task, err := orch.Spawn(ctx, models.SpawnRequest{
+		Prompt:       "p",
+		WorkDir:      "/tmp",
+		Engine:       models.EngineClaude,
+		Background:   true,
+		Dependencies: []string{"missing-dep"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to spawn task: %v", err)
+	}
+	task.SessionID = "sess-123"
+	task.LogFile = "/tmp/mesnada-prev.log"
+
+	if _, err := orch.Pause(task.ID); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+
+	resumed, err := orch.Resume(ctx, task.ID, ResumeOptions{Prompt: "continue", Background: true})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if resumed.ResumeSessionID != "sess-123" {
+		t.Fatalf("Expected resume of session sess-123, got %q", resumed.ResumeSessionID)
+	}
+	if resumed.Engine != models.EngineClaude {
+		t.Fatalf("Expected engine to carry over, got %q", resumed.Engine)
+	}
+	if strings.Contains(resumed.Prompt, task.LogFile) {
+		t.Fatalf("Expected native resume prompt without log reference, got %q", resumed.Prompt)
+	}
+}
+
 func TestGenerateID(t *testing.T) {
 	id1 := generateID()
 	id2 := generateID()
@@ -303,3 +400,313 @@ func TestGenerateID(t *testing.T) {
 		t.Errorf("Expected ID length >= 10, got %d", len(id1))
 	}
 }
+
+func TestOrchestratorStatsTokensByModel(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	for _, usage := range []struct {
+		model   string
+		in, out int64
+	}{
+		{"claude-sonnet-4.5", 1000, 100},
+		{"claude-sonnet-4.5", 500, 50},
+		{"gpt-5.2", 200, 20},
+	} {
+		task, err := orch.Spawn(ctx, models.SpawnRequest{
+			Prompt:       "p",
+			WorkDir:      "/tmp",
+			Background:   true,
+			Dependencies: []string{"missing-dep"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to spawn task: %v", err)
+		}
+		task.Model = usage.model
+		task.TokensIn = usage.in
+		task.TokensOut = usage.out
+	}
+
+	stats := orch.GetStats()
+	sonnet := stats.TokensByModel["claude-sonnet-4.5"]
+	if sonnet.Tasks != 2 || sonnet.TokensIn != 1500 || sonnet.TokensOut != 150 {
+		t.Fatalf("unexpected sonnet usage: %+v", sonnet)
+	}
+	if gpt := stats.TokensByModel["gpt-5.2"]; gpt.TokensIn != 200 || gpt.TokensOut != 20 {
+		t.Fatalf("unexpected gpt usage: %+v", gpt)
+	}
+}
+
+func TestOrchestratorSpawnRejectsUnknownSecret(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	_, err := orch.Spawn(context.Background(), models.SpawnRequest{
+		Prompt:     "p",
+		WorkDir:    "/tmp",
+		Background: true,
+		Secrets:    []string{"does-not-exist"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown secret") {
+		t.Fatalf("expected unknown secret error, got %v", err)
+	}
+}
+
+func TestOrchestratorSpawnRejectsSandboxDowngrade(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mesnada-orch-test-sandbox-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	orch, err := New(Config{
+		StorePath:   filepath.Join(tmpDir, "tasks.json"),
+		LogDir:      filepath.Join(tmpDir, "logs"),
+		MaxParallel: 1,
+		Sandbox:     config.SandboxConfig{Mode: models.SandboxDocker, Image: "agents:1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+	defer orch.Shutdown()
+
+	_, err = orch.Spawn(context.Background(), models.SpawnRequest{
+		Prompt:     "p",
+		WorkDir:    "/tmp",
+		Background: true,
+		Sandbox:    models.SandboxNone,
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot be overridden") {
+		t.Fatalf("expected the docker sandbox to be kept, got %v", err)
+	}
+}
+
+func TestOrchestratorSpawnRejectsUnknownEngine(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	_, err := orch.Spawn(context.Background(), models.SpawnRequest{
+		Prompt:     "p",
+		WorkDir:    "/tmp",
+		Background: true,
+		Engine:     "ollama-gpt",
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid engine") {
+		t.Fatalf("expected invalid engine error, got %v", err)
+	}
+}
+
+func TestOrchestratorRetry(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	// The missing dependency keeps the tasks pending instead of running an agent.
+	task, err := orch.Spawn(ctx, models.SpawnRequest{
+		Prompt:       "build it",
+		WorkDir:      "/tmp",
+		Dependencies: []string{"missing"},
+		Tags:         []string{"retry"},
+		Timeout:      "5m",
+		Background:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := orch.Retry(ctx, task.ID, RetryOptions{}); err == nil {
+		t.Fatal("expected a pending task not to be retryable")
+	}
+
+	task.Status = models.TaskStatusFailed
+	task.Error = "exit status 1"
+	// The spawner stores the prompt with the task ID line in front.
+	task.Prompt = "You are the task_id: " + task.ID + "\n\n" + task.Prompt
+	if err := orch.store.Save(task); err != nil {
+		t.Fatal(err)
+	}
+
+	retry, err := orch.Retry(ctx, task.ID, RetryOptions{IncludeError: true, PromptAddendum: "Run the tests first.", Background: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.ID == task.ID || retry.RetryOf != task.ID || retry.Attempt != 2 {
+		t.Fatalf("unexpected retry bookkeeping: id=%s retry_of=%s attempt=%d", retry.ID, retry.RetryOf, retry.Attempt)
+	}
+	if !strings.HasPrefix(retry.Prompt, "build it") || !strings.Contains(retry.Prompt, "exit status 1") || !strings.HasSuffix(retry.Prompt, "Run the tests first.") {
+		t.Fatalf("unexpected retry prompt %q", retry.Prompt)
+	}
+	// Spawning the retry adds its own ID line, so it ends up with exactly one.
+	if strings.Contains(retry.Prompt, "You are the task_id:") {
+		t.Fatalf("expected the previous task ID line to be dropped, got %q", retry.Prompt)
+	}
+	if retry.Timeout != task.Timeout || len(retry.Tags) != 1 || len(retry.Dependencies) != 1 {
+		t.Fatalf("expected the original parameters, got %+v", retry)
+	}
+
+	retry.Status = models.TaskStatusCancelled
+	retry.Prompt = "You are the task_id: " + retry.ID + "\n\n" + retry.Prompt
+	again, err := orch.Retry(ctx, retry.ID, RetryOptions{Background: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Attempt != 3 || again.Prompt != strings.TrimPrefix(retry.Prompt, "You are the task_id: "+retry.ID+"\n\n") {
+		t.Fatalf("expected attempt 3 with the same prompt, got %d %q", again.Attempt, again.Prompt)
+	}
+}
+
+func TestOrchestratorUpdate(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	task, err := orch.Spawn(context.Background(), models.SpawnRequest{
+		Prompt:       "queued",
+		WorkDir:      "/tmp",
+		Dependencies: []string{"missing"},
+		Metadata:     map[string]string{"ticket": "ABC-1", "owner": "ops"},
+		Background:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := []string{"urgent"}
+	priority := 10
+	timeout := "45m"
+	updated, err := orch.Update(task.ID, TaskUpdate{
+		Tags:     &tags,
+		Priority: &priority,
+		Timeout:  &timeout,
+		Metadata: map[string]string{"owner": "", "sprint": "42"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Priority != 10 || time.Duration(updated.Timeout) != 45*time.Minute || len(updated.Tags) != 1 {
+		t.Fatalf("unexpected update result %+v", updated)
+	}
+	if len(updated.Metadata) != 2 || updated.Metadata["ticket"] != "ABC-1" || updated.Metadata["sprint"] != "42" {
+		t.Fatalf("expected metadata to be merged, got %v", updated.Metadata)
+	}
+
+	bad := "soon"
+	if _, err := orch.Update(task.ID, TaskUpdate{Timeout: &bad}); err == nil {
+		t.Fatal("expected an invalid timeout to be rejected")
+	}
+
+	task.Status = models.TaskStatusRunning
+	if _, err := orch.Update(task.ID, TaskUpdate{Priority: &priority}); err == nil {
+		t.Fatal("expected priority to be immutable while running")
+	}
+	if _, err := orch.Update(task.ID, TaskUpdate{Tags: &tags}); err != nil {
+		t.Fatalf("expected tags to be mutable while running: %v", err)
+	}
+
+	task.Status = models.TaskStatusCompleted
+	if _, err := orch.Update(task.ID, TaskUpdate{Tags: &tags}); err == nil {
+		t.Fatal("expected a finished task to be frozen")
+	}
+}
+
+func TestOrchestratorEditDependencies(t *testing.T) {
+	orch, cleanup := setupTestOrchestrator(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	spawn := func(deps ...string) *models.Task {
+		task, err := orch.Spawn(ctx, models.SpawnRequest{Prompt: "p", WorkDir: "/tmp", Dependencies: deps, Background: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	a := spawn("missing")
+	b := spawn(a.ID)
+	c := spawn("missing")
+
+	if _, err := orch.AddDependency(a.ID, b.ID); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if _, err := orch.AddDependency(c.ID, c.ID); err == nil {
+		t.Fatal("expected a self dependency to be rejected")
+	}
+	if _, err := orch.AddDependency(c.ID, "nope"); err == nil {
+		t.Fatal("expected an unknown dependency to be rejected")
+	}
+
+	updated, err := orch.AddDependency(c.ID, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Dependencies) != 2 || updated.Dependencies[1] != b.ID {
+		t.Fatalf("unexpected dependencies %v", updated.Dependencies)
+	}
+	if _, err := orch.AddDependency(a.ID, c.ID); err == nil {
+		t.Fatal("expected a transitive cycle to be rejected")
+	}
+
+	updated, err = orch.RemoveDependency(c.ID, "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Dependencies) != 1 || updated.Status != models.TaskStatusPending {
+		t.Fatalf("expected c to keep waiting on b, got %+v", updated)
+	}
+	if _, err := orch.RemoveDependency(c.ID, "missing"); err == nil {
+		t.Fatal("expected removing an absent dependency to fail")
+	}
+
+	a.Status = models.TaskStatusRunning
+	if _, err := orch.AddDependency(a.ID, c.ID); err == nil {
+		t.Fatal("expected dependencies of a running task to be frozen")
+	}
+}
+
+func TestOrchestratorSpawnAppliesPersona(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mesnada-orch-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	personaDir := filepath.Join(tmpDir, "personas")
+	if err := os.MkdirAll(personaDir, 0755); err != nil {
+		t.Fatalf("mkdir personas: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(personaDir, "reviewer.md"), []byte("You review code."), 0644); err != nil {
+		t.Fatalf("write persona: %v", err)
+	}
+
+	orch, err := New(Config{
+		StorePath:   filepath.Join(tmpDir, "tasks.json"),
+		LogDir:      filepath.Join(tmpDir, "logs"),
+		MaxParallel: 2,
+		PersonaPath: personaDir,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+	defer orch.Shutdown()
+
+	// The persona is applied before the engine is chosen, so every engine gets it.
+	for _, engine := range models.AllEngines() {
+		task, err := orch.Spawn(context.Background(), models.SpawnRequest{
+			Prompt:       "Check main.go",
+			WorkDir:      tmpDir,
+			Engine:       engine,
+			Persona:      "reviewer",
+			Dependencies: []string{"missing"},
+		})
+		if err != nil {
+			t.Fatalf("Spawn with %s: %v", engine, err)
+		}
+		if task.Prompt != "You review code.\n\nCheck main.go" {
+			t.Fatalf("expected persona prepended for %s, got %q", engine, task.Prompt)
+		}
+	}
+
+	if _, err := orch.Spawn(context.Background(), models.SpawnRequest{Prompt: "x", WorkDir: tmpDir, Persona: "nobody"}); err == nil || !strings.Contains(err.Error(), "unknown persona") {
+		t.Fatalf("expected unknown persona error, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveWorkDir returns the absolute task work directory ("." when empty).
+// The directory must lie under one of the allowed roots (when configured) and
+// must exist, unless create_workdir is set, in which case it is created.
+func (o *Orchestrator) resolveWorkDir(workDir string) (string, error) {
+	if strings.TrimSpace(workDir) == "" {
+		workDir = "."
+	}
+	abs, err := filepath.Abs(workDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid work_dir %q: %w", workDir, err)
+	}
+
+	if !o.workDirAllowed(abs) {
+		return "", fmt.Errorf("work_dir %s is outside the allowed roots (%s)", abs, strings.Join(o.allowedWorkDirs, ", "))
+	}
+
+	info, err := os.Stat(abs)
+	switch {
+	case err == nil && !info.IsDir():
+		return "", fmt.Errorf("work_dir %s is not a directory", abs)
+	case err == nil:
+		return abs, nil
+	case !os.IsNotExist(err):
+		return "", fmt.Errorf("invalid work_dir %s: %w", abs, err)
+	case !o.createWorkDir:
+		return "", fmt.Errorf("work_dir %s does not exist (set orchestrator.create_workdir to create it)", abs)
+	}
+
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return "", fmt.Errorf("failed to create work_dir %s: %w", abs, err)
+	}
+	return abs, nil
+}
+
+// workDirAllowed reports whether dir is inside one of the allowed roots.
+// Symlinks are resolved on both sides, so a link inside a root cannot point
+// a task outside it; a dir whose links cannot be resolved is rejected.
+func (o *Orchestrator) workDirAllowed(dir string) bool {
+	if len(o.allowedWorkDirs) == 0 {
+		return true
+	}
+	resolved, err := evalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	for _, root := range o.allowedWorkDirs {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if resolvedRoot, err := evalSymlinks(root); err == nil && withinDir(resolvedRoot, resolved) {
+			return true
+		}
+	}
+	return false
+}
+
+// evalSymlinks resolves the symlinks in an absolute path. For a path that
+// does not exist yet, its longest existing ancestor is resolved and the
+// missing part appended. A dangling symlink is an error.
+func evalSymlinks(path string) (string, error) {
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if _, lerr := os.Lstat(path); lerr == nil || !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, missing), nil
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
+
+// withinDir reports whether the absolute path dir is root or below it.
+func withinDir(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
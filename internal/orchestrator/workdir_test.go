@@ -0,0 +1,87 @@
+package orchestrator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveWorkDir(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "repo")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(root, "file.txt")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &Orchestrator{allowedWorkDirs: []string{root}}
+
+	if got, err := o.resolveWorkDir(existing); err != nil || got != existing {
+		t.Fatalf("expected %s, got %q (%v)", existing, got, err)
+	}
+	if _, err := o.resolveWorkDir(filepath.Join(root, "missing")); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing dir error, got %v", err)
+	}
+	if _, err := o.resolveWorkDir(file); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("expected not a directory error, got %v", err)
+	}
+	for _, dir := range []string{"/", filepath.Join(root, "..", "elsewhere"), root + "-sibling"} {
+		if _, err := o.resolveWorkDir(dir); err == nil || !strings.Contains(err.Error(), "outside the allowed roots") {
+			t.Fatalf("expected %s to be rejected, got %v", dir, err)
+		}
+	}
+
+	o.createWorkDir = true
+	created := filepath.Join(root, "new", "nested")
+	if got, err := o.resolveWorkDir(created); err != nil || got != created {
+		t.Fatalf("expected %s to be created, got %q (%v)", created, got, err)
+	}
+	if info, err := os.Stat(created); err != nil || !info.IsDir() {
+		t.Fatalf("expected created directory, got %v", err)
+	}
+}
+
+func TestResolveWorkDirSymlinks(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "repos")
+	outside := filepath.Join(base, "secret")
+	for _, dir := range []string{root, outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A link inside the root to a directory outside it.
+	escape := filepath.Join(root, "escape")
+	if err := os.Symlink(outside, escape); err != nil {
+		t.Fatal(err)
+	}
+	dangling := filepath.Join(root, "dangling")
+	if err := os.Symlink(filepath.Join(base, "nowhere"), dangling); err != nil {
+		t.Fatal(err)
+	}
+	// A root given through a link still allows its directories.
+	rootLink := filepath.Join(base, "repos-link")
+	if err := os.Symlink(root, rootLink); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &Orchestrator{allowedWorkDirs: []string{rootLink}, createWorkDir: true}
+
+	for _, dir := range []string{escape, filepath.Join(escape, "sub"), dangling, filepath.Join(dangling, "sub")} {
+		if _, err := o.resolveWorkDir(dir); err == nil || !strings.Contains(err.Error(), "outside the allowed roots") {
+			t.Fatalf("expected %s to be rejected, got %v", dir, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "sub")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing created outside the root, got %v", err)
+	}
+	for _, dir := range []string{root, filepath.Join(rootLink, "new")} {
+		if _, err := o.resolveWorkDir(dir); err != nil {
+			t.Fatalf("expected %s to be allowed, got %v", dir, err)
+		}
+	}
+}
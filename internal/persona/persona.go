@@ -5,14 +5,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 )
 
+// MaxPersonaSize caps the content of a saved persona.
+const MaxPersonaSize = 256 << 10
+
+// namePattern is what SavePersona accepts as a persona name, so that it maps
+// to a file directly inside the persona directory.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
 // Manager handles persona loading and retrieval.
 type Manager struct {
 	personaPath string
 	personas    map[string]string // name -> content
+	files       map[string]string // name -> file name in personaPath
 	mu          sync.RWMutex
 }
 
@@ -22,6 +31,7 @@ func NewManager(personaPath string) (*Manager, error) {
 	m := &Manager{
 		personaPath: personaPath,
 		personas:    make(map[string]string),
+		files:       make(map[string]string),
 	}
 
 	if personaPath != "" {
@@ -79,6 +89,7 @@ func (m *Manager) loadPersonas() error {
 		}
 
 		m.personas[personaName] = string(content)
+		m.files[personaName] = name
 	}
 
 	return nil
@@ -139,3 +150,92 @@ func (m *Manager) ApplyPersona(personaName, prompt string) string {
 	// Prepend persona content + blank line + original prompt
 	return content + "\n\n" + prompt
 }
+
+// ValidatePersona checks a persona name and content before they are saved:
+// the name must be usable as a file name, and the content non-empty, at most
+// MaxPersonaSize bytes, and free of malformed {{placeholders}}.
+func ValidatePersona(name, content string) error {
+	if !namePattern.MatchString(name) || strings.HasSuffix(strings.ToLower(name), ".md") {
+		return fmt.Errorf("invalid persona name %q: use letters, digits, '.', '_' and '-', up to 64 characters", name)
+	}
+	return validateContent(content)
+}
+
+func validateContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("persona content is empty")
+	}
+	if len(content) > MaxPersonaSize {
+		return fmt.Errorf("persona content exceeds %d bytes", MaxPersonaSize)
+	}
+	for _, m := range braces.FindAllString(content, -1) {
+		if !placeholderPattern.MatchString(m) {
+			return fmt.Errorf("invalid placeholder %s: use {{name}} with letters, digits and '_'", m)
+		}
+	}
+	return nil
+}
+
+// SavePersona validates and writes a persona to the persona directory,
+// creating or replacing it, and makes it available right away. New personas
+// are stored as <name>.md.
+func (m *Manager) SavePersona(name, content string) error {
+	if m.personaPath == "" {
+		return fmt.Errorf("persona_path is not configured")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Personas loaded from disk keep their file, whatever its name.
+	file := m.files[name]
+	validate := validateContent(content)
+	if file == "" {
+		file = name + ".md"
+		validate = ValidatePersona(name, content)
+	}
+	if validate != nil {
+		return validate
+	}
+	if err := os.MkdirAll(m.personaPath, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial persona.
+	tmp, err := os.CreateTemp(m.personaPath, ".persona-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(m.personaPath, file)); err != nil {
+		return err
+	}
+
+	m.personas[name] = content
+	m.files[name] = file
+	return nil
+}
+
+// DeletePersona removes a persona and its file.
+func (m *Manager) DeletePersona(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, ok := m.files[name]
+	if !ok {
+		return fmt.Errorf("persona %q not found", name)
+	}
+	if err := os.Remove(filepath.Join(m.personaPath, file)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(m.personas, name)
+	delete(m.files, name)
+	return nil
+}
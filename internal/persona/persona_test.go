@@ -0,0 +1,70 @@
+package persona
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSavePersona(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "Old Style.md"), []byte("old"), 0644)
+	m, err := NewManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.SavePersona("reviewer", "Review {{ language }} code."); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "reviewer.md")); string(b) != "Review {{ language }} code." {
+		t.Fatalf("unexpected file content %q", b)
+	}
+	if m.GetPersona("reviewer") == "" {
+		t.Fatal("expected the saved persona to be loaded")
+	}
+
+	// Loaded personas keep their file even when the name is not a valid new one.
+	if err := m.SavePersona("Old Style", "new"); err != nil {
+		t.Fatalf("save existing: %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "Old Style.md")); string(b) != "new" {
+		t.Fatalf("unexpected file content %q", b)
+	}
+
+	for name, content := range map[string]string{
+		"../escape": "x",
+		"Bad Name":  "x",
+		"dup.md":    "x",
+		"empty":     "  \n",
+		"braces":    "Use {{ not valid }}.",
+		"too-large": strings.Repeat("x", MaxPersonaSize+1),
+		".hidden":   "x",
+		"blank":     "",
+	} {
+		if err := m.SavePersona(name, content); err == nil {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+
+	if err := m.DeletePersona("reviewer"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reviewer.md")); !os.IsNotExist(err) {
+		t.Fatalf("expected the file to be removed, got %v", err)
+	}
+	if m.HasPersona("reviewer") {
+		t.Fatal("expected the persona to be unloaded")
+	}
+	if err := m.DeletePersona("reviewer"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestSavePersonaWithoutPath(t *testing.T) {
+	m, _ := NewManager("")
+	if err := m.SavePersona("reviewer", "x"); err == nil {
+		t.Fatal("expected an error without persona_path")
+	}
+}
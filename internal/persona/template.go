@@ -0,0 +1,50 @@
+package persona
+
+import (
+	"regexp"
+	"strings"
+)
+
+// placeholderPattern matches {{name}} placeholders in persona content.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// braces matches anything written as a {{placeholder}}, valid or not.
+var braces = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// Placeholders returns the distinct {{name}} placeholders of a persona in
+// order of first appearance.
+func Placeholders(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range placeholderPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// Render substitutes {{name}} placeholders with the given arguments.
+// Placeholders without an argument are left as they are.
+func Render(content string, args map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := args[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// Summary returns the first non-empty line of a persona, without any
+// markdown heading marker.
+func Summary(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
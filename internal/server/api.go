@@ -10,10 +10,16 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sevir/mesnada/internal/agent"
 	"github.com/sevir/mesnada/pkg/models"
 )
 
-const defaultLogTailBytes = 64 * 1024
+const (
+	defaultLogTailBytes = 64 * 1024
+	// defaultOutputChunkBytes and maxOutputChunkBytes bound get_task_output_chunk reads.
+	defaultOutputChunkBytes = 64 * 1024
+	maxOutputChunkBytes     = 1024 * 1024
+)
 
 func (s *Server) registerAPI(mux *http.ServeMux) {
 	r := gin.New()
@@ -36,6 +42,7 @@ type apiTaskListItem struct {
 	StartedAt     *string              `json:"started_at,omitempty"`
 	PromptExcerpt string               `json:"prompt_excerpt"`
 	LogFile       string               `json:"log_file,omitempty"`
+	Summary       string               `json:"summary,omitempty"`
 }
 
 func (s *Server) apiListTasks(c *gin.Context) {
@@ -74,6 +81,7 @@ func (s *Server) apiListTasks(c *gin.Context) {
 			StartedAt:     started,
 			PromptExcerpt: sanitizeExcerpt(truncateString(t.Prompt, 100)),
 			LogFile:       t.LogFile,
+			Summary:       t.Summary,
 		})
 	}
 
@@ -133,17 +141,13 @@ func (s *Server) apiTaskLog(c *gin.Context) {
 }
 
 func readGrowingFile(path string, offset *int64, limit int64) (string, int64, bool, error) {
-	f, err := os.Open(path)
+	f, err := agent.OpenLog(path)
 	if err != nil {
 		return "", 0, false, err
 	}
 	defer f.Close()
 
-	st, err := f.Stat()
-	if err != nil {
-		return "", 0, false, err
-	}
-	size := st.Size()
+	size := f.Size()
 
 	var start int64
 	truncated := false
@@ -2,15 +2,21 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sevir/mesnada/internal/config"
 	"github.com/sevir/mesnada/pkg/models"
 )
 
@@ -78,6 +84,72 @@ func TestAPITasksList_FilterAndOrder(t *testing.T) {
 	}
 }
 
+func TestAPITasksList_Pagination(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	base := time.Now().Add(-time.Hour)
+	var want []string
+	for i := 0; i < 5; i++ {
+		task := spawnPending(t, srv, models.SpawnRequest{})
+		task.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		want = append([]string{task.ID}, want...)
+	}
+
+	type page struct {
+		Tasks []struct {
+			ID string `json:"id"`
+		} `json:"tasks"`
+		Total      int    `json:"total"`
+		NextCursor string `json:"next_cursor"`
+	}
+	get := func(query string) (int, page) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/tasks?"+query, nil)
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		var p page
+		_ = json.Unmarshal(w.Body.Bytes(), &p)
+		return w.Code, p
+	}
+
+	var got []string
+	cursor := ""
+	for i := 0; ; i++ {
+		code, p := get("limit=2&cursor=" + cursor)
+		if code != http.StatusOK || p.Total < 5 {
+			t.Fatalf("unexpected page %d: %d %+v", i, code, p)
+		}
+		for _, task := range p.Tasks {
+			got = append(got, task.ID)
+		}
+		if i == 0 {
+			// A task created while paging must not shift later pages.
+			spawnPending(t, srv, models.SpawnRequest{})
+		}
+		if p.NextCursor == "" {
+			break
+		}
+		cursor = p.NextCursor
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("pages returned %v, want %v", got, want)
+	}
+
+	// The newer task now comes first, so offset 4 is the last page.
+	if _, p := get("limit=2&offset=4"); len(p.Tasks) != 2 || p.Tasks[0].ID != want[3] || p.NextCursor != "" {
+		t.Fatalf("unexpected offset page %+v", p)
+	}
+	if _, p := get(""); len(p.Tasks) != 6 || p.NextCursor != "" {
+		t.Fatalf("expected every task without a limit, got %+v", p)
+	}
+	for _, query := range []string{"cursor=%21", "limit=x", "cursor=" + cursor + "&offset=1"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
 func TestAPITaskLog_TailAndOffset(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -140,6 +212,52 @@ func TestAPITaskLog_TailAndOffset(t *testing.T) {
 	}
 }
 
+func TestAPITaskLogDownload(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	if w := get("/api/tasks/" + task.ID + "/log/download"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a log, got %d", w.Code)
+	}
+
+	task.LogFile = filepath.Join(t.TempDir(), task.ID+".log")
+	os.WriteFile(task.LogFile+".1", []byte("older\n"), 0644)
+	os.WriteFile(task.LogFile, []byte("newer\n"), 0644)
+	w := get("/api/tasks/" + task.ID + "/log/download")
+	if w.Code != http.StatusOK || w.Body.String() != "older\nnewer\n" {
+		t.Fatalf("expected both segments, got %d %q", w.Code, w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != "attachment; filename="+task.ID+".log" {
+		t.Fatalf("unexpected Content-Disposition %q", cd)
+	}
+	if w.Header().Get("Content-Length") != "12" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+
+	// A compressed log is sent gzipped.
+	os.Remove(task.LogFile + ".1")
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("newer\n"))
+	zw.Close()
+	os.WriteFile(task.LogFile+".gz", gz.Bytes(), 0644)
+	os.Remove(task.LogFile)
+	w = get("/api/tasks/" + task.ID + "/log/download")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), gz.Bytes()) || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("expected the gzipped log, got %d %v", w.Code, w.Header())
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasSuffix(cd, ".log.gz") {
+		t.Fatalf("unexpected Content-Disposition %q", cd)
+	}
+}
+
 func TestAPIPauseAndResumeTask(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -233,3 +351,821 @@ func TestAPIPurgeTask_TerminalAndMissingLogIdempotent(t *testing.T) {
 func jsonNumber(n int64) string {
 	return strconv.FormatInt(n, 10)
 }
+
+func TestAPITaskSpawn(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	post := func(body string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest("POST", "/api/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		var resp map[string]json.RawMessage
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := post(`{"prompt":"Write docs","work_dir":"/tmp","tags":["ui"],"timeout":"10m","dependencies":["missing"],"background":false}`)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %v", code, resp)
+	}
+	var task models.Task
+	if err := json.Unmarshal(resp["task"], &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != models.TaskStatusPending || time.Duration(task.Timeout) != 10*time.Minute || len(task.Tags) != 1 || task.Tags[0] != "ui" {
+		t.Fatalf("unexpected task %+v", task)
+	}
+	if _, err := srv.orchestrator.GetTask(task.ID); err != nil {
+		t.Fatalf("expected the task to be stored: %v", err)
+	}
+
+	for _, body := range []string{`{"prompt":"  "}`, `{"prompt":"x","persona":"nobody"}`, `not json`} {
+		if code, _ := post(body); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/personas", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"personas"`) {
+		t.Fatalf("expected personas list, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIRejectsCrossOriginWrites(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	send := func(method, path, origin, contentType, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	spawn := `{"prompt":"x","work_dir":"/tmp","dependencies":["missing"]}`
+
+	// A form-like text/plain POST needs no preflight, so the Origin is all
+	// that tells it apart from the UI's own requests.
+	if code := send("POST", "/api/v1/tasks", "https://evil.example", "text/plain", spawn); code != http.StatusForbidden {
+		t.Fatalf("expected a cross-origin spawn to be refused, got %d", code)
+	}
+	if code := send("POST", "/api/tasks", "", "text/plain", spawn); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a text/plain body, got %d", code)
+	}
+	if code := send("POST", "/ui/purge", "https://evil.example", "application/x-www-form-urlencoded", "task_id=x"); code != http.StatusForbidden {
+		t.Fatalf("expected a cross-origin purge to be refused, got %d", code)
+	}
+	if tasks, _ := srv.orchestrator.ListTasks(models.ListRequest{}); len(tasks) != 0 {
+		t.Fatalf("expected no task to be created, got %d", len(tasks))
+	}
+
+	if code := send("POST", "/api/v1/tasks", "http://example.com", "application/json", spawn); code != http.StatusCreated {
+		t.Fatalf("expected a same-origin JSON spawn to succeed, got %d", code)
+	}
+	if code := send("GET", "/api/v1/tasks", "https://evil.example", "", ""); code != http.StatusOK {
+		t.Fatalf("expected reads to stay open to CORS origins, got %d", code)
+	}
+}
+
+func TestAPITasksBulkDelete(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	oldFailed := spawnPending(t, srv, models.SpawnRequest{})
+	oldFailed.Status = models.TaskStatusFailed
+	oldFailed.CreatedAt = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	newFailed := spawnPending(t, srv, models.SpawnRequest{})
+	newFailed.Status = models.TaskStatusFailed
+	oldCompleted := spawnPending(t, srv, models.SpawnRequest{})
+	oldCompleted.Status = models.TaskStatusCompleted
+	oldCompleted.CreatedAt = oldFailed.CreatedAt
+	oldPending := spawnPending(t, srv, models.SpawnRequest{})
+	oldPending.CreatedAt = oldFailed.CreatedAt
+
+	del := func(query string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("DELETE", "/api/tasks"+query, nil)
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	for _, query := range []string{"", "?status=running", "?before=yesterday"} {
+		if code, _ := del(query); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q, got %d", query, code)
+		}
+	}
+
+	code, resp := del("?status=failed&before=2024-01-01")
+	if code != http.StatusOK || resp["deleted"] != float64(1) {
+		t.Fatalf("expected 1 deleted, got %d %v", code, resp)
+	}
+	if _, err := srv.orchestrator.GetTask(oldFailed.ID); err == nil {
+		t.Fatal("expected old failed task to be deleted")
+	}
+
+	// Without a status, every old terminal task goes but pending ones stay.
+	code, resp = del("?before=2024-01-01")
+	if code != http.StatusOK || resp["deleted"] != float64(1) {
+		t.Fatalf("expected 1 deleted, got %d %v", code, resp)
+	}
+	for _, id := range []string{newFailed.ID, oldPending.ID} {
+		if _, err := srv.orchestrator.GetTask(id); err != nil {
+			t.Fatalf("expected task %s to remain: %v", id, err)
+		}
+	}
+}
+
+func TestAPITasksSearch(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	first := spawnPending(t, srv, models.SpawnRequest{Prompt: "Migrate billing", Engine: models.EngineClaude, Model: "claude-sonnet-4", Tags: []string{"db"}})
+	first.CreatedAt = first.CreatedAt.Add(-time.Hour)
+	second := spawnPending(t, srv, models.SpawnRequest{Prompt: "Review billing", Engine: models.EngineClaude, Tags: []string{"db"}})
+	spawnPending(t, srv, models.SpawnRequest{Prompt: "Unrelated"})
+
+	search := func(query string) (int, []string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/tasks/search?"+query, nil)
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		var resp struct {
+			Tasks []models.TaskSummary `json:"tasks"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		var ids []string
+		for _, task := range resp.Tasks {
+			ids = append(ids, task.ID)
+		}
+		return w.Code, ids
+	}
+
+	if code, ids := search("q=billing&tag=db&engine=claude-code"); code != http.StatusOK || len(ids) != 2 || ids[0] != second.ID {
+		t.Fatalf("expected both billing tasks, newest first; got %d %v", code, ids)
+	}
+	if _, ids := search("q=billing&sort=created_at"); len(ids) != 2 || ids[0] != first.ID {
+		t.Fatalf("expected oldest first, got %v", ids)
+	}
+	if _, ids := search("model=claude-sonnet-4"); len(ids) != 1 || ids[0] != first.ID {
+		t.Fatalf("expected the model match, got %v", ids)
+	}
+	if _, ids := search("q=billing&limit=1&offset=1"); len(ids) != 1 || ids[0] != first.ID {
+		t.Fatalf("expected the second page, got %v", ids)
+	}
+	for _, query := range []string{"sort=prompt", "limit=-1", "created_after=yesterday", "status=done"} {
+		if code, _ := search(query); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q, got %d", query, code)
+		}
+	}
+}
+
+func TestAPIEngines_ListsAllEngines(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineClaude})
+	running := spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineClaude})
+	running.Status = models.TaskStatusRunning
+
+	req := httptest.NewRequest("GET", "/api/engines?refresh=1", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+
+	var resp struct {
+		Engines []struct {
+			models.EngineInfo
+			Running int `json:"running"`
+			Pending int `json:"pending"`
+		} `json:"engines"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Engines) != len(models.AllEngines()) {
+		t.Fatalf("expected %d engines, got %d", len(models.AllEngines()), len(resp.Engines))
+	}
+	for _, info := range resp.Engines {
+		if info.Binary == "" || info.CheckedAt.IsZero() {
+			t.Fatalf("expected probed engine info, got %+v", info)
+		}
+		if !info.Available && info.Error == "" {
+			t.Fatalf("expected error for unavailable engine %s", info.Engine)
+		}
+		wantRunning, wantPending := 0, 0
+		if info.Engine == models.EngineClaude {
+			wantRunning, wantPending = 1, 1
+		}
+		if info.Running != wantRunning || info.Pending != wantPending {
+			t.Fatalf("unexpected counts for %s: running=%d pending=%d", info.Engine, info.Running, info.Pending)
+		}
+	}
+}
+
+func TestAPIStats_EngineAndCostBreakdown(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineClaude})
+	done := spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineClaude})
+	done.Status = models.TaskStatusCompleted
+	done.TokensIn = 100
+	done.TokensOut = 20
+	done.CostUSD = 0.5
+	failed := spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineGemini})
+	failed.Status = models.TaskStatusFailed
+	failed.CostUSD = 0.25
+
+	req := httptest.NewRequest("GET", "/api/stats", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Total                 int     `json:"total"`
+		Pending               int     `json:"pending"`
+		QueueDepth            int     `json:"queue_depth"`
+		WaitingOnDependencies int     `json:"waiting_on_dependencies"`
+		TokensIn              int64   `json:"tokens_in"`
+		CostUSD               float64 `json:"cost_usd"`
+		Engines               map[string]struct {
+			Pending   int     `json:"pending"`
+			Completed int     `json:"completed"`
+			Failed    int     `json:"failed"`
+			CostUSD   float64 `json:"cost_usd"`
+		} `json:"engines"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 3 || resp.Pending != 1 || resp.WaitingOnDependencies != 1 || resp.QueueDepth != 0 {
+		t.Fatalf("unexpected counts: %s", w.Body.String())
+	}
+	if resp.TokensIn != 100 || resp.CostUSD != 0.75 {
+		t.Fatalf("unexpected totals: %s", w.Body.String())
+	}
+	claude, gemini := resp.Engines["claude"], resp.Engines["gemini"]
+	if claude.Pending != 1 || claude.Completed != 1 || claude.CostUSD != 0.5 {
+		t.Fatalf("unexpected claude breakdown: %+v", claude)
+	}
+	if gemini.Failed != 1 || gemini.CostUSD != 0.25 {
+		t.Fatalf("unexpected gemini breakdown: %+v", gemini)
+	}
+}
+
+func TestAPIStatsHistory(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	finish := func(task *models.Task, status models.TaskStatus, at time.Time, run time.Duration) {
+		started := at.Add(-run)
+		task.Status = status
+		task.CreatedAt = started
+		task.StartedAt = &started
+		task.CompletedAt = &at
+	}
+	done := spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineClaude, Model: "opus"})
+	finish(done, models.TaskStatusCompleted, yesterday, time.Minute)
+	done.CostUSD = 0.5
+	failed := spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineClaude, Model: "opus"})
+	finish(failed, models.TaskStatusFailed, now, 3*time.Minute)
+	old := spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineGemini})
+	finish(old, models.TaskStatusCompleted, now.AddDate(0, 0, -30), time.Minute)
+	spawnPending(t, srv, models.SpawnRequest{})
+
+	req := httptest.NewRequest("GET", "/api/stats/history?days=7", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Days []struct {
+			Date      string `json:"date"`
+			Created   int    `json:"created"`
+			Completed int    `json:"completed"`
+			Failed    int    `json:"failed"`
+		} `json:"days"`
+		Models []struct {
+			Engine             string  `json:"engine"`
+			Model              string  `json:"model"`
+			Completed          int     `json:"completed"`
+			Failed             int     `json:"failed"`
+			AvgDurationSeconds float64 `json:"avg_duration_seconds"`
+			CostUSD            float64 `json:"cost_usd"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Days) != 7 {
+		t.Fatalf("expected 7 days, got %d", len(resp.Days))
+	}
+	today, prev := resp.Days[6], resp.Days[5]
+	if today.Date != now.Format("2006-01-02") || prev.Date != yesterday.Format("2006-01-02") {
+		t.Fatalf("unexpected dates: %s", w.Body.String())
+	}
+	if today.Failed != 1 || today.Completed != 0 || prev.Completed != 1 {
+		t.Fatalf("unexpected day counts: %s", w.Body.String())
+	}
+	// The 30-day-old task is outside the range.
+	if len(resp.Models) != 1 {
+		t.Fatalf("expected one model, got %s", w.Body.String())
+	}
+	m := resp.Models[0]
+	if m.Engine != "claude" || m.Model != "opus" || m.Completed != 1 || m.Failed != 1 || m.AvgDurationSeconds != 120 || m.CostUSD != 0.5 {
+		t.Fatalf("unexpected model breakdown: %+v", m)
+	}
+
+	req = httptest.NewRequest("GET", "/api/stats/history?days=1000", nil)
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for days=1000, got %d", w.Code)
+	}
+}
+
+func TestAPITaskFacets(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineGemini, Model: "pro", Tags: []string{"web", "auth"}})
+	spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineClaude, Tags: []string{"web"}})
+
+	req := httptest.NewRequest("GET", "/api/tasks/facets", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+	}
+
+	type facet struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	var resp struct {
+		Engines []facet `json:"engines"`
+		Models  []facet `json:"models"`
+		Tags    []facet `json:"tags"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Engines) != 2 || resp.Engines[0] != (facet{"claude", 1}) || resp.Engines[1] != (facet{"gemini", 1}) {
+		t.Fatalf("unexpected engines: %+v", resp.Engines)
+	}
+	if len(resp.Models) != 1 || resp.Models[0] != (facet{"pro", 1}) {
+		t.Fatalf("unexpected models: %+v", resp.Models)
+	}
+	if len(resp.Tags) != 2 || resp.Tags[0] != (facet{"auth", 1}) || resp.Tags[1] != (facet{"web", 2}) {
+		t.Fatalf("unexpected tags: %+v", resp.Tags)
+	}
+}
+
+func TestAPITaskCancelAndRetry(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{Tags: []string{"x"}})
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("/api/tasks/"+task.ID+"/retry", ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 retrying a pending task, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := post("/api/tasks/"+task.ID+"/cancel", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+	}
+	var cancelled struct {
+		Task models.Task `json:"task"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &cancelled); err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Task.Status != models.TaskStatusCancelled {
+		t.Fatalf("expected cancelled, got %s", cancelled.Task.Status)
+	}
+	if w := post("/api/tasks/"+task.ID+"/cancel", ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 cancelling twice, got %d", w.Code)
+	}
+
+	w = post("/api/tasks/"+task.ID+"/retry", `{"prompt_addendum": "try harder"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d: %s", w.Code, w.Body.String())
+	}
+	var retried struct {
+		Task models.Task `json:"task"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &retried); err != nil {
+		t.Fatal(err)
+	}
+	if retried.Task.RetryOf != task.ID || !strings.HasSuffix(retried.Task.Prompt, "try harder") || len(retried.Task.Tags) != 1 {
+		t.Fatalf("unexpected retry: %+v", retried.Task)
+	}
+
+	if w := post("/api/tasks/nope/cancel", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown task, got %d", w.Code)
+	}
+}
+
+func TestAPIGraph(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{Tags: []string{"graph"}})
+
+	req := httptest.NewRequest("GET", "/api/graph?tag=graph", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+	var graph models.TaskGraph
+	if err := json.Unmarshal(w.Body.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Nodes) != 2 || len(graph.Edges) != 1 || graph.Edges[0].To != task.ID {
+		t.Fatalf("unexpected graph %+v", graph)
+	}
+
+	req = httptest.NewRequest("GET", "/api/graph?task_id=nope", nil)
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown task, got %d", w.Code)
+	}
+}
+
+func TestAPIOpenAPICoversRoutes(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI == "" {
+		t.Fatal("expected an openapi version")
+	}
+
+	for _, route := range srv.newGinEngine().Routes() {
+		if !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		// The unversioned /api routes are aliases of /api/v1.
+		path := "/api/v1/" + strings.TrimPrefix(strings.TrimPrefix(route.Path, "/api/"), "v1/")
+		if path == "/api/v1/openapi.json" || path == "/api/v1/docs" {
+			continue
+		}
+		path = regexp.MustCompile(`[:*](\w+)`).ReplaceAllString(path, "{$1}")
+		if _, ok := doc.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("route %s %s is missing from the OpenAPI document", route.Method, route.Path)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/docs", nil)
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "SwaggerUIBundle") {
+		t.Fatalf("expected the Swagger UI page, got %d", w.Code)
+	}
+}
+
+func TestAPITaskDiff(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if w := get("/api/tasks/task-missing/diff"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown task, got %d: %s", w.Code, w.Body.String())
+	}
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	task.WorkDir = t.TempDir()
+	w := get("/api/tasks/" + task.ID + "/diff")
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "not a git repository") {
+		t.Fatalf("expected 409 outside git, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPITaskArtifacts(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	os.MkdirAll(filepath.Join(dir, "out"), 0755)
+	os.WriteFile(filepath.Join(dir, "out", "page.html"), []byte("<p>hi</p>"), 0644)
+	os.WriteFile(filepath.Join(dir, "data"), []byte("%PDF-1.4 data"), 0644)
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	task.WorkDir = dir
+
+	w := get("/api/tasks/" + task.ID + "/artifacts")
+	var list struct {
+		Artifacts []struct {
+			Path   string `json:"path"`
+			Status string `json:"status"`
+			Size   int64  `json:"size"`
+		} `json:"artifacts"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || len(list.Artifacts) != 2 || list.Artifacts[1].Path != "out/page.html" || list.Artifacts[1].Size != 9 {
+		t.Fatalf("unexpected artifacts %d %s", w.Code, w.Body.String())
+	}
+
+	w = get("/api/tasks/" + task.ID + "/artifacts/out/page.html")
+	if w.Code != http.StatusOK || w.Body.String() != "<p>hi</p>" {
+		t.Fatalf("unexpected download %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("expected an HTML content type, got %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename=page.html` || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("expected an attachment, got %q", cd)
+	}
+	if w = get("/api/tasks/" + task.ID + "/artifacts/data"); w.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("expected the content type to be detected, got %q", w.Header().Get("Content-Type"))
+	}
+	if w = get("/api/tasks/" + task.ID + "/artifacts/missing.txt"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown artifact, got %d", w.Code)
+	}
+
+	task.WorkDir = t.TempDir()
+	if w = get("/api/tasks/" + task.ID + "/artifacts"); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 outside git, got %d", w.Code)
+	}
+}
+
+func TestAPITasksBulk(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	post := func(path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		var out map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	a := spawnPending(t, srv, models.SpawnRequest{Tags: []string{"old", "keep"}})
+	b := spawnPending(t, srv, models.SpawnRequest{})
+	ids := fmt.Sprintf(`"task_ids": [%q, %q]`, a.ID, b.ID)
+
+	if code, _ := post("/api/tasks/bulk/cancel", `{"task_ids": []}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without task_ids, got %d", code)
+	}
+	if code, _ := post("/api/tasks/bulk/tag", `{`+ids+`}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 tagging without add or remove, got %d", code)
+	}
+
+	code, out := post("/api/tasks/bulk/tag", `{`+ids+`, "add": ["new", "keep"], "remove": ["old"]}`)
+	if code != http.StatusOK || out["succeeded"] != float64(2) {
+		t.Fatalf("expected both tasks tagged, got %d %v", code, out)
+	}
+	if strings.Join(a.Tags, ",") != "keep,new" || strings.Join(b.Tags, ",") != "new,keep" {
+		t.Fatalf("unexpected tags %v and %v", a.Tags, b.Tags)
+	}
+
+	b.Status = models.TaskStatusCompleted
+	code, out = post("/api/tasks/bulk/cancel", `{`+ids+`}`)
+	if code != http.StatusOK || out["succeeded"] != float64(1) || out["failed"] != float64(1) {
+		t.Fatalf("expected one cancel to fail, got %d %v", code, out)
+	}
+	results := out["results"].([]interface{})
+	if r := results[1].(map[string]interface{}); r["task_id"] != b.ID || r["error"] == nil {
+		t.Fatalf("expected an error for the completed task, got %v", r)
+	}
+
+	code, out = post("/api/tasks/bulk/delete", `{`+ids+`}`)
+	if code != http.StatusOK || out["succeeded"] != float64(2) {
+		t.Fatalf("expected both tasks deleted, got %d %v", code, out)
+	}
+	if _, err := srv.orchestrator.GetTask(a.ID); err == nil {
+		t.Fatal("expected the task to be deleted")
+	}
+}
+
+func TestAPIStatsTimeline(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	now := time.Now()
+	run := func(task *models.Task, status models.TaskStatus, started time.Time, completed *time.Time) {
+		task.Status = status
+		task.StartedAt = &started
+		task.CompletedAt = completed
+	}
+	finished := now.Add(-time.Hour)
+	done := spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineGemini, Tags: []string{"web"}})
+	run(done, models.TaskStatusCompleted, now.Add(-2*time.Hour), &finished)
+	running := spawnPending(t, srv, models.SpawnRequest{})
+	run(running, models.TaskStatusRunning, now.Add(-10*time.Minute), nil)
+	longAgo := now.Add(-29 * time.Hour)
+	old := spawnPending(t, srv, models.SpawnRequest{})
+	run(old, models.TaskStatusCompleted, now.Add(-30*time.Hour), &longAgo)
+	spawnPending(t, srv, models.SpawnRequest{})
+
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats/timeline?hours=24", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Tasks []struct {
+			ID          string     `json:"id"`
+			Engine      string     `json:"engine"`
+			Tags        []string   `json:"tags"`
+			CompletedAt *time.Time `json:"completed_at"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Tasks) != 2 || resp.Tasks[0].ID != done.ID || resp.Tasks[1].ID != running.ID {
+		t.Fatalf("expected the finished and the running task in start order, got %+v", resp.Tasks)
+	}
+	if resp.Tasks[0].Engine != "gemini" || len(resp.Tasks[0].Tags) != 1 || resp.Tasks[0].CompletedAt == nil || resp.Tasks[1].CompletedAt != nil {
+		t.Fatalf("unexpected timeline entries %+v", resp.Tasks)
+	}
+
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats/timeline?hours=1000", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for too many hours, got %d", w.Code)
+	}
+}
+
+func TestAPIPersonaEdit(t *testing.T) {
+	srv := setupPromptServer(t)
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		var out map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	code, out := do("GET", "/api/personas/reviewer", "")
+	if code != http.StatusOK || out["summary"] != "Code reviewer" || fmt.Sprint(out["placeholders"]) != "[language]" {
+		t.Fatalf("unexpected persona %d %v", code, out)
+	}
+	if code, _ := do("GET", "/api/personas/nobody", ""); code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", code)
+	}
+
+	code, out = do("PUT", "/api/personas/tester", `{"content": "# Tester\n\nTest {{ target }}."}`)
+	if code != http.StatusCreated || out["summary"] != "Tester" {
+		t.Fatalf("expected persona created, got %d %v", code, out)
+	}
+	if code, _ := do("PUT", "/api/personas/tester", `{"content": "Test it."}`); code != http.StatusOK {
+		t.Fatalf("expected persona updated, got %d", code)
+	}
+	if srv.orchestrator.GetPersona("tester") != "Test it." {
+		t.Fatalf("unexpected content %q", srv.orchestrator.GetPersona("tester"))
+	}
+	for _, body := range []string{`{"content": ""}`, `{"content": "{{ bad one }}"}`, `not json`} {
+		if code, _ := do("PUT", "/api/personas/tester", body); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, code)
+		}
+	}
+
+	if code, _ := do("DELETE", "/api/personas/tester", ""); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if code, _ := do("DELETE", "/api/personas/tester", ""); code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", code)
+	}
+}
+
+func TestAPIConfig(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.AuthTokens = []string{"secret-token"}
+	srv.ReloadConfig(cfg)
+
+	req := httptest.NewRequest("GET", "/api/config", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret-token") {
+		t.Fatalf("expected the token to be redacted, got %s", w.Body.String())
+	}
+	var resp struct {
+		Config config.Config `json:"config"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Config.Orchestrator.StorePath != cfg.Orchestrator.StorePath || resp.Config.Server.AuthTokens[0] != config.RedactedValue {
+		t.Fatalf("unexpected config %+v", resp.Config)
+	}
+}
+
+func TestAPIConfigSchema(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest("GET", "/api/v1/config/schema", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/schema+json" {
+		t.Fatalf("expected a schema, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := schema.Properties["orchestrator"]; !ok {
+		t.Fatalf("expected the orchestrator section, got %s", w.Body.String())
+	}
+}
+
+func TestAPIVersionedRoutes(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	var bodies []string
+	for _, path := range []string{"/api/v1/tasks/search?q=" + task.ID, "/api/tasks/search?q=" + task.ID} {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), task.ID) {
+			t.Fatalf("GET %s: got %d %s", path, w.Code, w.Body.String())
+		}
+		bodies = append(bodies, w.Body.String())
+	}
+	if bodies[0] != bodies[1] {
+		t.Fatalf("expected /api to alias /api/v1, got %s and %s", bodies[0], bodies[1])
+	}
+
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"/api/v1/tasks"`) {
+		t.Fatalf("expected the document to list /api/v1 paths, got %d", w.Code)
+	}
+}
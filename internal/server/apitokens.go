@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sevir/mesnada/internal/config"
+)
+
+type apiTokenKey struct{}
+
+// scopeTools are the MCP tools a scoped API token may call on /mcp; admin
+// tokens may call every tool.
+var scopeTools = map[string][]string{
+	config.ScopeRead: {"get_*", "list_*", "search_tasks", "wait_task", "wait_multiple"},
+	config.ScopeSpawn: {
+		"get_*", "list_*", "search_tasks", "wait_task", "wait_multiple",
+		"spawn_agent", "spawn_batch", "cancel_task", "pause_task", "resume_task", "retry_task",
+		"update_task", "add_dependency", "remove_dependency", "set_progress", "set_task_result",
+	},
+}
+
+// withAPIToken records a scoped API token in the request context and limits
+// its MCP tools to those of its broadest scope.
+func withAPIToken(ctx context.Context, token config.APIToken) context.Context {
+	ctx = context.WithValue(ctx, apiTokenKey{}, token)
+	if token.HasScope(config.ScopeAdmin) {
+		return ctx
+	}
+	scope := config.ScopeRead
+	if token.HasScope(config.ScopeSpawn) {
+		scope = config.ScopeSpawn
+	}
+	return context.WithValue(ctx, tokenToolsKey{}, scopeTools[scope])
+}
+
+// requiredScope returns the scope a REST or UI request needs: admin to
+// delete or purge, spawn for other changes and read for everything else,
+// GraphQL queries included.
+func requiredScope(method, path string) string {
+	if rest, ok := strings.CutPrefix(path, apiV1Prefix+"/"); ok {
+		path = "/api/" + rest
+	}
+	switch {
+	case method == http.MethodDelete || strings.HasSuffix(path, "/purge") || strings.HasSuffix(path, "/bulk/delete"):
+		return config.ScopeAdmin
+	case method == http.MethodGet || method == http.MethodHead || path == "/graphql":
+		return config.ScopeRead
+	case strings.HasPrefix(path, "/api/personas/"):
+		// Persona files are server configuration.
+		return config.ScopeAdmin
+	}
+	return config.ScopeSpawn
+}
+
+// apiScopeMiddleware rejects requests whose API token lacks the scope the
+// route needs, and writes an audit line for every /api request naming the
+// token that made it. Requests authenticated otherwise are not limited.
+func apiScopeMiddleware(c *gin.Context) {
+	start := time.Now()
+	scope := requiredScope(c.Request.Method, c.Request.URL.Path)
+	token, scoped := c.Request.Context().Value(apiTokenKey{}).(config.APIToken)
+	name := "-"
+	if scoped {
+		name = token.Name
+	}
+
+	if scoped && !token.HasScope(scope) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token lacks the " + scope + " scope"})
+	} else {
+		c.Next()
+	}
+
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		log.Printf("audit_event=api_request method=%s path=%s status=%d token=%q scope=%s remote=%s duration_ms=%d",
+			c.Request.Method, c.Request.URL.Path, c.Writer.Status(), name, scope, c.Request.RemoteAddr, time.Since(start).Milliseconds())
+	}
+}
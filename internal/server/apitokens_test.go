@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestAPITokenScopes(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.APITokens = []config.APIToken{
+		{Name: "dashboard", Token: "reader", Scopes: []string{config.ScopeRead}},
+		{Name: "ci", Token: "spawner", Scopes: []string{config.ScopeSpawn}},
+		{Name: "ops", Token: "admin", Scopes: []string{config.ScopeAdmin}},
+	}
+	srv.ReloadConfig(cfg)
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	task.Status = models.TaskStatusFailed
+
+	do := func(token, method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cases := []struct {
+		token, method, path string
+		want                int
+	}{
+		{"reader", "GET", "/api/tasks", http.StatusOK},
+		{"reader", "POST", "/api/tasks/" + task.ID + "/pause", http.StatusForbidden},
+		{"reader", "DELETE", "/api/tasks/" + task.ID, http.StatusForbidden},
+		{"spawner", "GET", "/api/stats", http.StatusOK},
+		{"spawner", "DELETE", "/api/tasks/" + task.ID, http.StatusForbidden},
+		{"spawner", "POST", "/ui/purge", http.StatusForbidden},
+		{"spawner", "POST", "/api/tasks/bulk/delete", http.StatusForbidden},
+		{"spawner", "POST", "/api/tasks/bulk/purge", http.StatusForbidden},
+		{"spawner", "PUT", "/api/personas/x", http.StatusForbidden},
+		{"spawner", "DELETE", "/api/v1/tasks/" + task.ID, http.StatusForbidden},
+		{"spawner", "PUT", "/api/v1/personas/x", http.StatusForbidden},
+		{"reader", "GET", "/api/v1/tasks", http.StatusOK},
+		{"reader", "POST", "/graphql", http.StatusBadRequest}, // allowed, but without a query
+		{"admin", "DELETE", "/api/tasks/" + task.ID, http.StatusNoContent},
+		{"unknown", "GET", "/api/tasks", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if got := do(tc.token, tc.method, tc.path); got != tc.want {
+			t.Errorf("%s %s with %s: got %d, want %d", tc.method, tc.path, tc.token, got, tc.want)
+		}
+	}
+
+	// On /mcp, scopes map to tools.
+	call := func(token, tool string) string {
+		w := postMCP(t, srv, "", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"`+tool+`","arguments":{}}}`,
+			map[string]string{"Authorization": "Bearer " + token})
+		return w.Body.String()
+	}
+	if body := call("reader", "get_stats"); strings.Contains(body, `"error"`) {
+		t.Fatalf("expected read token to call get_stats, got %s", body)
+	}
+	if body := call("reader", "spawn_agent"); !strings.Contains(body, "Tool not allowed by token tool access") {
+		t.Fatalf("expected read token to be denied spawn_agent, got %s", body)
+	}
+	if body := call("spawner", "delete_task"); !strings.Contains(body, "Tool not allowed by token tool access") {
+		t.Fatalf("expected spawn token to be denied delete_task, got %s", body)
+	}
+}
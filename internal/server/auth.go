@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+// authCookie carries the token for the browser UI, which cannot send headers
+// on page loads. It is set by the /ui/login form or when /ui is opened with
+// ?token=<token>.
+const authCookie = "mesnada_token"
+
+// authMiddleware requires a configured bearer token or a valid OAuth access
+// token on every endpoint except the /health probes, the protected-resource
+// metadata, the UI sign-in pages and assets, and CORS preflights. Without
+// configured tokens or OAuth it lets all requests through. Opening the UI
+// without a token redirects to its sign-in page.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.appConfig().Server
+		tokens := cfg.Tokens()
+		if (len(tokens) == 0 && !cfg.OAuth.Enabled()) || r.Method == http.MethodOptions ||
+			r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/health/") ||
+			strings.HasPrefix(r.URL.Path, protectedResourcePath) ||
+			r.URL.Path == uiLoginPath || r.URL.Path == uiLogoutPath || strings.HasPrefix(r.URL.Path, "/ui/assets/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := requestToken(r)
+		if validToken(tokens, token) {
+			if rule, ok := cfg.ToolAccessFor(token); ok {
+				// Limited to its tools on /mcp and to its scope elsewhere.
+				ctx := context.WithValue(r.Context(), apiTokenKey{}, rule.APIToken())
+				r = r.WithContext(context.WithValue(ctx, tokenToolsKey{}, rule.Tools))
+			} else if apiToken, ok := cfg.APITokenFor(token); ok {
+				r = r.WithContext(withAPIToken(r.Context(), apiToken))
+			}
+			if r.URL.Query().Get("token") != "" && strings.HasPrefix(r.URL.Path, "/ui") {
+				setAuthCookie(w, r, token)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		challenge := `Bearer realm="mesnada"`
+		if cfg.OAuth.Enabled() {
+			challenge = fmt.Sprintf(`Bearer resource_metadata="%s%s"`, baseURL(r), protectedResourcePath)
+			if token != "" {
+				claims, err := s.validateAccessToken(r.Context(), token)
+				if err == nil {
+					// Scoped by oauth.scopes on /mcp and by oauth.api_scopes elsewhere.
+					ctx := context.WithValue(r.Context(), tokenClaimsKey{}, claims)
+					ctx = context.WithValue(ctx, apiTokenKey{}, config.APIToken{
+						Name:   "oauth:" + claims.Subject,
+						Scopes: cfg.OAuth.APIScopesFor(claims.Scopes),
+					})
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+				log.Printf("mcp_event=invalid_token path=%s remote=%s error=%q", r.URL.Path, r.RemoteAddr, err.Error())
+				challenge += `, error="invalid_token"`
+			}
+		}
+
+		if r.Method == http.MethodGet && (r.URL.Path == "/ui" || r.URL.Path == "/ui/") {
+			http.Redirect(w, r, basePath(r.Context())+uiLoginPath, http.StatusFound)
+			return
+		}
+
+		log.Printf("mcp_event=unauthorized path=%s remote=%s", r.URL.Path, r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", challenge)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// requestToken reads the token from the Authorization header, the UI cookie
+// or, for /ui page loads, the token query parameter.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if scheme, token, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if c, err := r.Cookie(authCookie); err == nil {
+		return c.Value
+	}
+	if strings.HasPrefix(r.URL.Path, "/ui") {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// validToken compares against every token in constant time.
+func validToken(tokens []string, token string) bool {
+	if token == "" {
+		return false
+	}
+	valid := 0
+	for _, t := range tokens {
+		valid |= subtle.ConstantTimeCompare([]byte(t), []byte(token))
+	}
+	return valid == 1
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.AuthTokens = []string{"secret-1", "secret-2"}
+	srv.ReloadConfig(cfg)
+
+	do := func(method, path, auth string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/api/tasks", "", nil); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected 401 with challenge, got %d", w.Code)
+	}
+	if w := do("GET", "/api/tasks", "Bearer wrong", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong token, got %d", w.Code)
+	}
+	if w := do("GET", "/api/tasks", "Bearer secret-2", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with a valid token, got %d", w.Code)
+	}
+	if w := do("DELETE", "/mcp", "", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected /mcp to require auth, got %d", w.Code)
+	}
+	if w := do("GET", "/health", "", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected /health to stay open, got %d", w.Code)
+	}
+	if w := do("GET", "/health/live", "", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected /health/live to stay open, got %d", w.Code)
+	}
+	if w := do("OPTIONS", "/mcp", "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected preflight to pass, got %d", w.Code)
+	}
+
+	w := do("GET", "/ui?token=secret-1", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected /ui with token query to load, got %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != authCookie {
+		t.Fatalf("expected auth cookie, got %v", cookies)
+	}
+	if w := do("GET", "/api/tasks", "", cookies[0]); w.Code != http.StatusOK {
+		t.Fatalf("expected cookie to authenticate API calls, got %d", w.Code)
+	}
+}
+
+func TestUILogin(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	do := func(method, path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/ui/login", "", nil); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/ui" {
+		t.Fatalf("expected the login page to redirect to the UI without auth, got %d", w.Code)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Server.AuthTokens = []string{"secret-1"}
+	srv.ReloadConfig(cfg)
+
+	if w := do("GET", "/ui", "", nil); w.Code != http.StatusFound || w.Header().Get("Location") != "/ui/login" {
+		t.Fatalf("expected /ui to redirect to the login page, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := do("GET", "/ui/partials/tasks", "", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected partials to answer 401, got %d", w.Code)
+	}
+	if w := do("GET", "/ui/login", "", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="token"`) {
+		t.Fatalf("expected the login form, got %d", w.Code)
+	}
+	if w := do("POST", "/ui/login", "token=wrong", nil); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Invalid token") {
+		t.Fatalf("expected a wrong token to be rejected, got %d", w.Code)
+	}
+
+	w := do("POST", "/ui/login", "token=secret-1", nil)
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/ui" || len(cookies) != 1 || cookies[0].Value != "secret-1" {
+		t.Fatalf("expected a redirect to the UI with the auth cookie, got %d %v", w.Code, cookies)
+	}
+	if w := do("GET", "/ui", "", cookies[0]); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<meta name="mesnada-auth" content="on" />`) {
+		t.Fatalf("expected the UI with sign-out enabled, got %d", w.Code)
+	}
+
+	w = do("POST", "/ui/logout", "", cookies[0])
+	cookies = w.Result().Cookies()
+	if w.Code != http.StatusSeeOther || len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Fatalf("expected logout to clear the cookie, got %d %v", w.Code, cookies)
+	}
+}
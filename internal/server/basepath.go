@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type basePathKey struct{}
+
+// basePathMiddleware serves the routes under server.base_path: it strips the
+// prefix before routing, records it for handlers that build public URLs and
+// answers 404 for paths outside it. gRPC methods stay at the root, as gRPC
+// clients cannot add a path prefix.
+func (s *Server) basePathMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := s.appConfig().Server.Prefix()
+		if prefix == "" || strings.HasPrefix(r.URL.Path, grpcServicePath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			http.NotFound(w, r)
+			return
+		}
+		if rest == "" {
+			rest = "/"
+		}
+
+		r2 := r.WithContext(context.WithValue(r.Context(), basePathKey{}, prefix))
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = rest
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// basePath returns the server.base_path prefix the request came in under.
+func basePath(ctx context.Context) string {
+	prefix, _ := ctx.Value(basePathKey{}).(string)
+	return prefix
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+func TestBasePath_ServesRoutesUnderPrefix(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.BasePath = "/mesnada/"
+	srv.ReloadConfig(cfg)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/health"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 outside the base path, got %d", w.Code)
+	}
+	if w := get("/mesnadax/health"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a path that only shares the prefix, got %d", w.Code)
+	}
+	if w := get("/mesnada/health"); w.Code != http.StatusOK {
+		t.Fatalf("expected /mesnada/health to be served, got %d", w.Code)
+	}
+	if w := get("/mesnada"); w.Code != http.StatusFound || w.Header().Get("Location") != "/mesnada/ui" {
+		t.Fatalf("expected a redirect to /mesnada/ui, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := get("/mesnada/ui"); !strings.Contains(w.Body.String(), `<base href="/mesnada/" />`) {
+		t.Fatalf("expected the UI base to point at the prefix, got %d", w.Code)
+	}
+	if w := get("/mesnada/api/tasks"); w.Code != http.StatusOK {
+		t.Fatalf("expected /mesnada/api/tasks to be served, got %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/mesnada/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	req.Header.Set("Content-Type", "application/json")
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"result"`) {
+		t.Fatalf("expected /mesnada/mcp to answer, got %d %s", w.Code, w.Body.String())
+	}
+}
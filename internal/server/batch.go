@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// isBatch reports whether a client message is a JSON-RPC batch (an array).
+func isBatch(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// decodeBatch splits a JSON-RPC batch into its requests. Elements that are
+// not request objects get an Invalid Request response right away.
+func decodeBatch(data []byte) ([]*JSONRPCRequest, []*JSONRPCResponse, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		reqs    []*JSONRPCRequest
+		invalid []*JSONRPCResponse
+	)
+	for _, element := range elements {
+		var req JSONRPCRequest
+		if err := json.Unmarshal(element, &req); err != nil || req.JSONRPC != jsonRPCVersion {
+			invalid = append(invalid, &JSONRPCResponse{
+				JSONRPC: jsonRPCVersion,
+				Error:   &JSONRPCError{Code: -32600, Message: "Invalid Request"},
+			})
+			continue
+		}
+		reqs = append(reqs, &req)
+	}
+	return reqs, invalid, nil
+}
+
+// handleBatch handles the requests of a batch in order and returns the
+// responses; notifications in the batch get none.
+func (s *Server) handleBatch(ctx context.Context, session *Session, reqs []*JSONRPCRequest) []*JSONRPCResponse {
+	var responses []*JSONRPCResponse
+	for _, req := range reqs {
+		if response := s.handleRequest(ctx, session, req); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	return responses
+}
+
+// handleMCPBatch answers a batched POST with a JSON array of responses, or
+// 202 Accepted when the batch only held notifications and responses.
+func (s *Server) handleMCPBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	reqs, responses, err := decodeBatch(body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, nil, -32700, "Parse error", err.Error())
+		return
+	}
+	if len(reqs) == 0 && len(responses) == 0 {
+		s.writeError(w, http.StatusBadRequest, nil, -32600, "Invalid Request", "empty batch")
+		return
+	}
+
+	// An initialize in the batch starts the session the other requests use.
+	sessionReq := &JSONRPCRequest{}
+	for _, req := range reqs {
+		if req.Method == "initialize" {
+			sessionReq = req
+			break
+		}
+	}
+	session, ok := s.requestSession(w, r, sessionReq)
+	if !ok {
+		return
+	}
+
+	// Notifications raised while the batch is handled go to the session's
+	// event stream.
+	ctx := withRequestStream(r.Context(), session, nil)
+	responses = append(responses, s.handleBatch(ctx, session, reqs)...)
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
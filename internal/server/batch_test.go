@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestMCPBatch(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	body := `[
+		{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":2,"method":"tools/list"},
+		42
+	]`
+	w := postMCP(t, srv, "", body, nil)
+	if w.Code != http.StatusOK || w.Header().Get("Mcp-Session-Id") == "" {
+		t.Fatalf("expected a batch response with a new session, got %d %s", w.Code, w.Body.String())
+	}
+	var responses []JSONRPCResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("expected a JSON array: %v (%s)", err, w.Body.String())
+	}
+	if len(responses) != 3 {
+		t.Fatalf("expected 3 responses (invalid element, initialize, tools/list), got %d", len(responses))
+	}
+	ids := map[float64]bool{}
+	invalid := 0
+	for _, resp := range responses {
+		if resp.ID == nil {
+			if resp.Error == nil || resp.Error.Code != -32600 {
+				t.Fatalf("expected Invalid Request for the bad element, got %+v", resp)
+			}
+			invalid++
+			continue
+		}
+		ids[resp.ID.(float64)] = resp.Error == nil
+	}
+	if invalid != 1 || !ids[1] || !ids[2] {
+		t.Fatalf("unexpected responses %+v", responses)
+	}
+
+	session := w.Header().Get("Mcp-Session-Id")
+	w = postMCP(t, srv, session, `[{"jsonrpc":"2.0","method":"notifications/initialized"}]`, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for a batch of notifications, got %d", w.Code)
+	}
+	w = postMCP(t, srv, session, `[]`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty batch, got %d", w.Code)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	corsAllowMethods  = "GET, POST, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Authorization, Mcp-Session-Id, Mcp-Protocol-Version, Last-Event-ID, " + allowedToolsHeader
+	corsExposeHeaders = "Mcp-Session-Id"
+	// corsMaxAge lets browsers cache preflight results, in seconds.
+	corsMaxAge = "600"
+)
+
+// corsMiddleware adds CORS headers for the origins in server.cors and answers
+// preflight requests. Without configured origins it allows any origin while
+// auth is disabled and none once tokens or OAuth are configured, so a page on
+// another site cannot drive an authenticated server with a stored cookie.
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowOrigin, credentials := s.corsOrigin(origin)
+		if origin != "" {
+			w.Header().Add("Vary", "Origin")
+		}
+		if allowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+			if credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if r.Method == http.MethodOptions {
+			if origin != "" && allowOrigin == "" {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsOrigin returns the Access-Control-Allow-Origin value for a request
+// origin ("" to send none) and whether credentials may be included.
+func (s *Server) corsOrigin(origin string) (string, bool) {
+	cfg := s.appConfig().Server
+	allowed := cfg.CORS.AllowedOrigins
+	if len(allowed) == 0 {
+		if len(cfg.Tokens()) == 0 && !cfg.OAuth.Enabled() {
+			return "*", false
+		}
+		return "", false
+	}
+	for _, o := range allowed {
+		if o == "*" {
+			return "*", false
+		}
+		if origin != "" && strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// checkOrigin accepts requests without an Origin (non-browser clients),
+// from pages of the same host and from origins listed in server.cors. The
+// allow-all default of corsOrigin does not count, so a page on another site
+// cannot drive the server from a visitor's browser even without auth.
+func (s *Server) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q", origin)
+	}
+	if u.Host == r.Host {
+		return nil
+	}
+	if len(s.appConfig().Server.CORS.AllowedOrigins) > 0 {
+		if allowed, _ := s.corsOrigin(origin); allowed != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+// originGuard rejects requests that change state from origins checkOrigin
+// does not accept. With requireJSON, a request body must also be
+// application/json, which a page on another site cannot send without a
+// preflight.
+func (s *Server) originGuard(requireJSON bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := c.Request
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if err := s.checkOrigin(r); err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if requireJSON && r.ContentLength != 0 {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+				c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "request body must be application/json"})
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// taskEventHistory is how many task events are kept for /api/events clients
+// that reconnect with Last-Event-ID.
+const taskEventHistory = 1024
+
+// taskEvent is one /api/events message: a task change with the task's state
+// after it, or "resync" when the events a client asked for are gone.
+type taskEvent struct {
+	ID          int64                `json:"id"`
+	Change      string               `json:"change"`
+	Time        time.Time            `json:"time"`
+	TaskID      string               `json:"task_id,omitempty"`
+	Status      models.TaskStatus    `json:"status,omitempty"`
+	Engine      models.Engine        `json:"engine,omitempty"`
+	Model       string               `json:"model,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Progress    *models.TaskProgress `json:"progress,omitempty"`
+	ExitCode    *int                 `json:"exit_code,omitempty"`
+	Error       string               `json:"error,omitempty"`
+	StartedAt   *time.Time           `json:"started_at,omitempty"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty"`
+}
+
+// newTaskEvent copies what clients mirror of a task, as the orchestrator
+// keeps changing it after the event.
+func newTaskEvent(change orchestrator.TaskChange, task *models.Task) taskEvent {
+	ev := taskEvent{
+		Change:      string(change),
+		Time:        time.Now().UTC(),
+		TaskID:      task.ID,
+		Status:      task.Status,
+		Engine:      task.Engine,
+		Model:       task.Model,
+		Tags:        append([]string(nil), task.Tags...),
+		Error:       task.Error,
+		StartedAt:   task.StartedAt,
+		CompletedAt: task.CompletedAt,
+	}
+	if task.Progress != nil {
+		progress := *task.Progress
+		ev.Progress = &progress
+	}
+	if task.ExitCode != nil {
+		code := *task.ExitCode
+		ev.ExitCode = &code
+	}
+	return ev
+}
+
+// taskEventLog numbers task events and keeps the latest ones, so /api/events
+// streams can follow it at their own pace and resume after a reconnect.
+type taskEventLog struct {
+	mu     sync.Mutex
+	events []taskEvent // oldest first
+	last   int64       // id of the newest event
+	wake   chan struct{}
+}
+
+// append numbers ev, keeps it and wakes the streams waiting for it.
+func (l *taskEventLog) append(ev taskEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.last++
+	ev.ID = l.last
+	if len(l.events) == taskEventHistory {
+		l.events = append(l.events[:0], l.events[1:]...)
+	}
+	l.events = append(l.events, ev)
+	if l.wake != nil {
+		close(l.wake)
+		l.wake = nil
+	}
+}
+
+// since returns the events after id, the id of the newest event and a
+// channel closed when the next one arrives. It reports false when events
+// after id are no longer kept, or id is from before a restart.
+func (l *taskEventLog) since(id int64) ([]taskEvent, int64, <-chan struct{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.wake == nil {
+		l.wake = make(chan struct{})
+	}
+	oldest := l.last - int64(len(l.events)) + 1
+	if id > l.last || id < oldest-1 {
+		return nil, l.last, l.wake, false
+	}
+	events := append([]taskEvent(nil), l.events[id-oldest+1:]...)
+	return events, l.last, l.wake, true
+}
+
+// handleAPIEvents streams every task change as Server-Sent Events, named by
+// the change, so other systems can mirror task state without polling. Each
+// event's id numbers it; reconnecting with Last-Event-ID (or
+// ?last_event_id=) replays the events missed in between, or sends "resync"
+// when they are gone.
+func (s *Server) handleAPIEvents(c *gin.Context) {
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	_, next, _, _ := s.taskEvents.since(0)
+	if raw = strings.TrimSpace(raw); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid last event id"})
+			return
+		}
+		next = v
+	}
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		events, last, wake, ok := s.taskEvents.since(next)
+		if !ok {
+			events = []taskEvent{{ID: last, Change: "resync", Time: time.Now().UTC()}}
+		}
+		for _, ev := range events {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Change, data); err != nil {
+				return
+			}
+			next = ev.ID
+		}
+		w.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		case <-wake:
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			w.Flush()
+		}
+	}
+}
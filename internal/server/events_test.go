@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestAPIEvents(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	first := spawnPending(t, srv, models.SpawnRequest{Tags: []string{"a"}})
+
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/events", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	// The timeout bounds reading the stream too.
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() (string, taskEvent) {
+		t.Helper()
+		var name string
+		for lines.Scan() {
+			line := lines.Text()
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				name = v
+			}
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				var ev taskEvent
+				if err := json.Unmarshal([]byte(v), &ev); err != nil {
+					t.Fatal(err)
+				}
+				return name, ev
+			}
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return "", taskEvent{}
+	}
+
+	// The event before connecting is replayed from Last-Event-ID.
+	if name, ev := next(); name != "created" || ev.ID != 1 || ev.TaskID != first.ID || ev.Status != models.TaskStatusPending || ev.Tags[0] != "a" {
+		t.Fatalf("expected the replayed created event, got %s %+v", name, ev)
+	}
+
+	second := spawnPending(t, srv, models.SpawnRequest{})
+	if name, ev := next(); name != "created" || ev.ID != 2 || ev.TaskID != second.ID {
+		t.Fatalf("expected the live created event, got %s %+v", name, ev)
+	}
+
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/events?last_event_id=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid id, got %d", w.Code)
+	}
+}
+
+func TestTaskEventLogSince(t *testing.T) {
+	var log taskEventLog
+	for i := 0; i < taskEventHistory+5; i++ {
+		log.append(taskEvent{Change: "updated"})
+	}
+	last := int64(taskEventHistory + 5)
+
+	if events, _, _, ok := log.since(last - 2); !ok || len(events) != 2 || events[0].ID != last-1 {
+		t.Fatalf("expected the last two events, got %v %+v", ok, events)
+	}
+	if events, _, _, ok := log.since(5); !ok || len(events) != taskEventHistory {
+		t.Fatalf("expected every kept event, got %v %d", ok, len(events))
+	}
+	if _, got, _, ok := log.since(4); ok || got != last {
+		t.Fatalf("expected a resync at %d for dropped events, got %v %d", last, ok, got)
+	}
+	if _, _, _, ok := log.since(last + 1); ok {
+		t.Fatal("expected a resync for an id from before a restart")
+	}
+
+	_, _, wake, _ := log.since(last)
+	log.append(taskEvent{Change: "finished"})
+	select {
+	case <-wake:
+	default:
+		t.Fatal("expected the new event to wake waiting streams")
+	}
+}
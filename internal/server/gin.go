@@ -1,16 +1,27 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
 	"io"
 	"io/fs"
+	"mime"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sevir/mesnada/internal/agent"
+	"github.com/sevir/mesnada/internal/config"
 	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/internal/persona"
 	"github.com/sevir/mesnada/pkg/models"
 	uiassets "github.com/sevir/mesnada/ui"
 )
@@ -19,35 +30,25 @@ func (s *Server) newGinEngine() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 
 	r := gin.New()
-	r.Use(gin.Recovery())
+	r.Use(gin.Recovery(), apiScopeMiddleware)
 
 	// Optional convenience redirect.
 	r.GET("/", func(c *gin.Context) {
-		c.Redirect(http.StatusFound, "/ui")
+		c.Redirect(http.StatusFound, basePath(c.Request.Context())+"/ui")
 	})
 
 	// UI.
-	r.GET("/ui", func(c *gin.Context) {
-		b, err := fs.ReadFile(uiassets.FS, "index.html")
-		if err != nil {
-			c.String(http.StatusInternalServerError, "failed to load UI")
-			return
-		}
-		c.Data(http.StatusOK, "text/html; charset=utf-8", b)
-	})
-	r.GET("/ui/", func(c *gin.Context) {
-		b, err := fs.ReadFile(uiassets.FS, "index.html")
-		if err != nil {
-			c.String(http.StatusInternalServerError, "failed to load UI")
-			return
-		}
-		c.Data(http.StatusOK, "text/html; charset=utf-8", b)
-	})
+	r.GET("/ui", s.serveUIIndex)
+	r.GET("/ui/", s.serveUIIndex)
 
 	r.GET("/ui/partials/tasks", gin.WrapF(s.handleUITasks))
 	r.GET("/ui/partials/panel", gin.WrapF(s.handleUIPanel))
 	r.GET("/ui/partials/log", gin.WrapF(s.handleUILog))
-	r.POST("/ui/purge", gin.WrapF(s.handleUIPurge))
+	r.POST("/ui/purge", s.originGuard(false), gin.WrapF(s.handleUIPurge))
+	r.GET("/ui/events", s.handleUIEvents)
+	r.GET("/ui/login", s.handleUILogin)
+	r.POST("/ui/login", s.originGuard(false), s.handleUILogin)
+	r.POST("/ui/logout", s.originGuard(false), s.handleUILogout)
 
 	// Serve static assets.
 	r.GET("/ui/assets/*filepath", func(c *gin.Context) {
@@ -69,20 +70,87 @@ func (s *Server) newGinEngine() *gin.Engine {
 		c.Data(http.StatusOK, contentType, b)
 	})
 
-	api := r.Group("/api")
-	{
-		api.GET("/version", s.handleAPIVersion)
-		api.GET("/tasks", s.handleAPITasksList)
-		api.GET("/tasks/:id/log", s.handleAPITaskLog)
-		api.POST("/tasks/:id/pause", s.handleAPITaskPause)
-		api.POST("/tasks/:id/resume", s.handleAPITaskResume)
-		api.DELETE("/tasks/:id", s.handleAPITaskDelete)
-		api.DELETE("/tasks/:id/purge", s.handleAPITaskPurge)
-	}
+	// The REST API is versioned under /api/v1. /api serves the same routes so
+	// clients written before versioning keep working; a breaking change would
+	// ship as /api/v2 and leave both in place.
+	s.registerAPIRoutes(r.Group(apiV1Prefix))
+	s.registerAPIRoutes(r.Group("/api"))
+
+	// GraphQL takes queries only, so it is read-only like the GET routes.
+	r.GET("/graphql", s.handleGraphQL)
+	r.POST("/graphql", s.handleGraphQL)
 
 	return r
 }
 
+// apiV1Prefix is the current REST API version.
+const apiV1Prefix = "/api/v1"
+
+// registerAPIRoutes adds the REST API routes to api.
+func (s *Server) registerAPIRoutes(api *gin.RouterGroup) {
+	api.Use(s.originGuard(true))
+	api.GET("/openapi.json", s.handleAPIOpenAPI)
+	api.GET("/docs", handleAPIDocs)
+	api.GET("/version", s.handleAPIVersion)
+	api.GET("/engines", s.handleAPIEngines)
+	api.GET("/config", s.handleAPIConfig)
+	api.GET("/config/schema", handleAPIConfigSchema)
+	api.GET("/events", s.handleAPIEvents)
+	api.GET("/stats", s.handleAPIStats)
+	api.GET("/stats/history", s.handleAPIStatsHistory)
+	api.GET("/stats/timeline", s.handleAPIStatsTimeline)
+	api.GET("/personas", s.handleAPIPersonas)
+	api.GET("/personas/:name", s.handleAPIPersonaGet)
+	api.PUT("/personas/:name", s.handleAPIPersonaPut)
+	api.DELETE("/personas/:name", s.handleAPIPersonaDelete)
+	api.GET("/tasks", s.handleAPITasksList)
+	api.POST("/tasks", s.handleAPITaskSpawn)
+	api.DELETE("/tasks", s.handleAPITasksDelete)
+	api.POST("/tasks/bulk/cancel", s.handleAPITasksBulk(bulkCancel, false))
+	api.POST("/tasks/bulk/delete", s.handleAPITasksBulk(bulkDelete, false))
+	api.POST("/tasks/bulk/purge", s.handleAPITasksBulk(bulkPurge, false))
+	api.POST("/tasks/bulk/tag", s.handleAPITasksBulk(bulkTag, true))
+	api.GET("/tasks/search", s.handleAPITasksSearch)
+	api.GET("/tasks/facets", s.handleAPITaskFacets)
+	api.GET("/graph", s.handleAPIGraph)
+	api.GET("/tasks/:id/log", s.handleAPITaskLog)
+	api.GET("/tasks/:id/log/ws", s.handleAPITaskLogWS)
+	api.GET("/tasks/:id/log/sse", s.handleAPITaskLogSSE)
+	api.GET("/tasks/:id/log/download", s.handleAPITaskLogDownload)
+	api.POST("/tasks/:id/pause", s.handleAPITaskPause)
+	api.POST("/tasks/:id/resume", s.handleAPITaskResume)
+	api.GET("/tasks/:id/diff", s.handleAPITaskDiff)
+	api.GET("/tasks/:id/artifacts", s.handleAPITaskArtifacts)
+	api.GET("/tasks/:id/artifacts/*path", s.handleAPITaskArtifact)
+	api.POST("/tasks/:id/cancel", s.handleAPITaskCancel)
+	api.POST("/tasks/:id/retry", s.handleAPITaskRetry)
+	api.DELETE("/tasks/:id", s.handleAPITaskDelete)
+	api.DELETE("/tasks/:id/purge", s.handleAPITaskPurge)
+}
+
+// serveUIIndex serves the UI page with its <base> pointing at
+// server.base_path, against which all of its URLs resolve, the default theme
+// from server.ui.theme, and whether sign-in is enabled.
+func (s *Server) serveUIIndex(c *gin.Context) {
+	b, err := fs.ReadFile(uiassets.FS, "index.html")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to load UI")
+		return
+	}
+	base := `<base href="` + html.EscapeString(basePath(c.Request.Context())) + `/" />`
+	b = bytes.Replace(b, []byte(`<base href="/" />`), []byte(base), 1)
+	theme := `<meta name="mesnada-theme" content="` + s.appConfig().Server.UI.DefaultTheme() + `" />`
+	b = bytes.Replace(b, []byte(`<meta name="mesnada-theme" content="dark" />`), []byte(theme), 1)
+	if s.uiAuthEnabled() {
+		b = bytes.Replace(b, []byte(`<meta name="mesnada-auth" content="off" />`), []byte(`<meta name="mesnada-auth" content="on" />`), 1)
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", b)
+}
+
+// handleAPITasksList lists tasks newest first. ?limit= pages the result:
+// the response carries the total match count and, when more tasks remain,
+// a next_cursor to pass back as ?cursor=. ?offset= is accepted instead of a
+// cursor for random access.
 func (s *Server) handleAPITasksList(c *gin.Context) {
 	statuses, err := parseStatusQuery(c)
 	if err != nil {
@@ -90,12 +158,45 @@ func (s *Server) handleAPITasksList(c *gin.Context) {
 		return
 	}
 
+	limit, err := queryCount(c, "limit")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	offset, err := queryCount(c, "offset")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cursor := c.Query("cursor")
+	if cursor != "" && offset > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor and offset are mutually exclusive"})
+		return
+	}
+
 	tasks, err := s.orchestrator.ListTasks(models.ListRequest{Status: statuses})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	total := len(tasks)
+	if cursor != "" {
+		if tasks, err = tasksAfterCursor(tasks, cursor); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if offset < len(tasks) {
+		tasks = tasks[offset:]
+	} else {
+		tasks = nil
+	}
+	nextCursor := ""
+	if limit > 0 && len(tasks) > limit {
+		tasks = tasks[:limit]
+		nextCursor = encodeTaskCursor(tasks[limit-1])
+	}
+
 	type taskItem struct {
 		ID            string            `json:"id"`
 		Status        models.TaskStatus `json:"status"`
@@ -115,7 +216,304 @@ func (s *Server) handleAPITasksList(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"tasks": items})
+	resp := gin.H{"tasks": items, "total": total}
+	if nextCursor != "" {
+		resp["next_cursor"] = nextCursor
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleAPITaskSpawn spawns a task from a JSON body with the spawn_agent
+// arguments. The task always runs in the background.
+func (s *Server) handleAPITaskSpawn(c *gin.Context) {
+	var req spawnArgs
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prompt is required"})
+		return
+	}
+
+	spawn := s.spawnRequest(req)
+	spawn.Background = true
+	task, err := s.orchestrator.Spawn(c.Request.Context(), spawn)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"task": task})
+}
+
+func (s *Server) handleAPIPersonas(c *gin.Context) {
+	result, _ := s.toolListPersonas(c.Request.Context(), nil)
+	c.JSON(http.StatusOK, result)
+}
+
+// personaJSON is a persona as /api/personas/:name returns it.
+func personaJSON(name, content string) gin.H {
+	return gin.H{
+		"name":         name,
+		"summary":      persona.Summary(content),
+		"content":      content,
+		"placeholders": persona.Placeholders(content),
+	}
+}
+
+func (s *Server) handleAPIPersonaGet(c *gin.Context) {
+	name := c.Param("name")
+	content := s.orchestrator.GetPersona(name)
+	if content == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("persona %q not found", name)})
+		return
+	}
+	c.JSON(http.StatusOK, personaJSON(name, content))
+}
+
+// handleAPIPersonaPut creates or replaces a persona file in persona_path.
+// Invalid names and content are rejected with 400.
+func (s *Server) handleAPIPersonaPut(c *gin.Context) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := c.Param("name")
+	created := !s.orchestrator.HasPersona(name)
+	if err := s.orchestrator.SavePersona(name, req.Content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, personaJSON(name, req.Content))
+}
+
+func (s *Server) handleAPIPersonaDelete(c *gin.Context) {
+	if err := s.orchestrator.DeletePersona(c.Param("name")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handleAPITasksDelete removes the terminal tasks matching ?status= and
+// ?before= (creation time, RFC3339 or YYYY-MM-DD) and reports how many were
+// deleted. ?purge=true removes their logs too.
+func (s *Server) handleAPITasksDelete(c *gin.Context) {
+	statuses, err := parseStatusQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, st := range statuses {
+		if st == models.TaskStatusPending || st == models.TaskStatusRunning {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only terminal tasks can be bulk deleted"})
+			return
+		}
+	}
+	before, err := parseTimeBound(c.Query("before"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before"})
+		return
+	}
+	if len(statuses) == 0 && before.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status or before is required"})
+		return
+	}
+
+	purge := c.Query("purge") == "true" || c.Query("purge") == "1"
+	deleted, err := s.orchestrator.DeleteTasks(models.ListRequest{Status: statuses, CreatedBefore: before}, purge)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "deleted": deleted})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// maxBulkTasks caps the tasks one bulk request may name.
+const maxBulkTasks = 500
+
+// bulkRequest is the body of the /api/tasks/bulk endpoints. Add and Remove
+// are only used by bulk/tag.
+type bulkRequest struct {
+	TaskIDs []string `json:"task_ids"`
+	Add     []string `json:"add"`
+	Remove  []string `json:"remove"`
+}
+
+// bulkResult reports the outcome for one task; Error is empty on success.
+type bulkResult struct {
+	TaskID string `json:"task_id"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bulkAction applies a bulk operation to one task.
+type bulkAction func(s *Server, taskID string, req *bulkRequest) error
+
+func bulkCancel(s *Server, taskID string, _ *bulkRequest) error {
+	return s.orchestrator.Cancel(taskID)
+}
+
+func bulkDelete(s *Server, taskID string, _ *bulkRequest) error {
+	return s.orchestrator.Delete(taskID)
+}
+
+func bulkPurge(s *Server, taskID string, _ *bulkRequest) error {
+	return s.orchestrator.Purge(taskID)
+}
+
+// bulkTag adds and removes tags, keeping the order of the existing ones.
+// Like update_task it fails for finished tasks.
+func bulkTag(s *Server, taskID string, req *bulkRequest) error {
+	task, err := s.orchestrator.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+	drop := make(map[string]bool, len(req.Remove))
+	for _, t := range req.Remove {
+		drop[t] = true
+	}
+	tags := []string{}
+	for _, t := range append(append([]string{}, task.Tags...), req.Add...) {
+		if t = strings.TrimSpace(t); t != "" && !drop[t] && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	_, err = s.orchestrator.Update(taskID, orchestrator.TaskUpdate{Tags: &tags})
+	return err
+}
+
+// handleAPITasksBulk applies action to each of the tasks in the body's
+// task_ids and reports the outcome per task. A task failing does not stop
+// the others, so the response is 200 unless the request itself is invalid.
+// tags requires the body to add or remove tags.
+func (s *Server) handleAPITasksBulk(action bulkAction, tags bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req bulkRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body: " + err.Error()})
+			return
+		}
+		if len(req.TaskIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "task_ids is required"})
+			return
+		}
+		if len(req.TaskIDs) > maxBulkTasks {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d task_ids are allowed", maxBulkTasks)})
+			return
+		}
+		if tags && len(req.Add) == 0 && len(req.Remove) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "add or remove is required"})
+			return
+		}
+
+		results := make([]bulkResult, 0, len(req.TaskIDs))
+		failed := 0
+		for _, id := range req.TaskIDs {
+			r := bulkResult{TaskID: id}
+			if err := action(s, id, &req); err != nil {
+				r.Error = err.Error()
+				failed++
+			}
+			results = append(results, r)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"results":   results,
+			"succeeded": len(results) - failed,
+			"failed":    failed,
+		})
+	}
+}
+
+// handleAPITasksSearch runs search_tasks from query parameters: q, status,
+// tag, engine, model, created_after, created_before, sort, limit and offset.
+func (s *Server) handleAPITasksSearch(c *gin.Context) {
+	statuses, err := parseStatusQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := taskSearch{
+		Query:         c.Query("q"),
+		Tags:          c.QueryArray("tag"),
+		Engine:        c.Query("engine"),
+		Model:         c.Query("model"),
+		CreatedAfter:  c.Query("created_after"),
+		CreatedBefore: c.Query("created_before"),
+		Sort:          c.Query("sort"),
+	}
+	for _, st := range statuses {
+		req.Status = append(req.Status, string(st))
+	}
+	if req.Limit, err = queryCount(c, "limit"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Offset, err = queryCount(c, "offset"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := s.searchTasks(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// taskFacet is a filter value with the number of tasks that have it.
+type taskFacet struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// handleAPITaskFacets lists the engines, models and tags in use, with task
+// counts, for the UI's task filters.
+func (s *Server) handleAPITaskFacets(c *gin.Context) {
+	tasks, err := s.orchestrator.ListTasks(models.ListRequest{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	engines := make(map[string]int)
+	modelNames := make(map[string]int)
+	tags := make(map[string]int)
+	for _, t := range tasks {
+		engines[string(t.Engine)]++
+		if t.Model != "" {
+			modelNames[t.Model]++
+		}
+		for _, tag := range t.Tags {
+			tags[tag]++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"engines": facetList(engines),
+		"models":  facetList(modelNames),
+		"tags":    facetList(tags),
+	})
+}
+
+func facetList(counts map[string]int) []taskFacet {
+	facets := make([]taskFacet, 0, len(counts))
+	for name, count := range counts {
+		if name != "" {
+			facets = append(facets, taskFacet{Name: name, Count: count})
+		}
+	}
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Name < facets[j].Name })
+	return facets
 }
 
 func (s *Server) handleAPITaskLog(c *gin.Context) {
@@ -157,6 +555,45 @@ func (s *Server) handleAPITaskLog(c *gin.Context) {
 	})
 }
 
+// handleAPITaskLogDownload sends the whole log file of a task, the rotated
+// segment included, as an attachment. A compressed log is sent as it is
+// stored, gzipped.
+func (s *Server) handleAPITaskLogDownload(c *gin.Context) {
+	task, err := s.findTaskByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if task == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+	if task.LogFile == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "log not available"})
+		return
+	}
+
+	r, err := agent.OpenRawLog(task.LogFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "log not available"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer r.Close()
+
+	name, contentType := task.ID+".log", "text/plain; charset=utf-8"
+	if r.Gzipped {
+		name, contentType = name+".gz", "application/gzip"
+	}
+	c.DataFromReader(http.StatusOK, r.Size, contentType, r, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": name}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
 func (s *Server) handleAPITaskPause(c *gin.Context) {
 	id := c.Param("id")
 	task, err := s.orchestrator.Pause(id)
@@ -208,6 +645,126 @@ func (s *Server) handleAPITaskResume(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"task": task})
 }
 
+// handleAPITaskDiff returns the changes of a task split by file, or 409 when
+// its work dir is not a git repository.
+func (s *Server) handleAPITaskDiff(c *gin.Context) {
+	diff, err := s.orchestrator.TaskDiff(c.Param("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// handleAPITaskArtifacts lists the files a task added or changed in its git
+// work dir.
+func (s *Server) handleAPITaskArtifacts(c *gin.Context) {
+	artifacts, truncated, err := s.orchestrator.TaskArtifacts(c.Param("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"task_id": c.Param("id"), "artifacts": artifacts, "truncated": truncated})
+}
+
+// maxArtifactDownload caps the size of an artifact served for download.
+const maxArtifactDownload = 64 << 20
+
+// handleAPITaskArtifact downloads one artifact as the task left it. The
+// content type comes from the extension or, failing that, the first bytes;
+// it is always sent as an attachment so HTML artifacts never run in the UI's
+// origin.
+func (s *Server) handleAPITaskArtifact(c *gin.Context) {
+	path := strings.TrimPrefix(c.Param("path"), "/")
+	r, artifact, err := s.orchestrator.OpenArtifact(c.Param("id"), path)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	defer r.Close()
+	if artifact.Size > maxArtifactDownload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("artifact is larger than %d bytes", maxArtifactDownload)})
+		return
+	}
+
+	body := bufio.NewReader(r)
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		head, _ := body.Peek(512)
+		contentType = http.DetectContentType(head)
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(path)}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, artifact.Size, contentType, body, nil)
+}
+
+func (s *Server) handleAPITaskCancel(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.orchestrator.Cancel(id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := s.orchestrator.GetTask(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"task": task})
+}
+
+// handleAPITaskRetry retries a failed or cancelled task in the background,
+// like retry_task, and answers 201 with the new task.
+func (s *Server) handleAPITaskRetry(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		PromptAddendum string `json:"prompt_addendum"`
+		IncludeError   *bool  `json:"include_error"`
+	}
+	// The body is optional.
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	includeError := true
+	if req.IncludeError != nil {
+		includeError = *req.IncludeError
+	}
+
+	task, err := s.orchestrator.Retry(c.Request.Context(), id, orchestrator.RetryOptions{
+		PromptAddendum: req.PromptAddendum,
+		IncludeError:   includeError,
+		Background:     true,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"task": task})
+}
+
 func (s *Server) handleAPITaskDelete(c *gin.Context) {
 	id := c.Param("id")
 	if err := s.orchestrator.Delete(id); err != nil {
@@ -237,6 +794,80 @@ func (s *Server) handleAPIVersion(c *gin.Context) {
 	})
 }
 
+func (s *Server) handleAPIEngines(c *gin.Context) {
+	refresh := c.Query("refresh") == "true" || c.Query("refresh") == "1"
+	c.JSON(http.StatusOK, gin.H{"engines": s.engineListings(c.Request.Context(), refresh)})
+}
+
+// handleAPIConfig serves the configuration the server is running with,
+// command-line overrides and SIGHUP reloads included, with tokens and keys
+// redacted.
+func (s *Server) handleAPIConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"config": s.appConfig().Redacted()})
+}
+
+// handleAPIConfigSchema serves the JSON Schema of the config file, for
+// editors to validate and complete it.
+func handleAPIConfigSchema(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json", config.JSONSchema())
+}
+
+// handleAPIStats serves the orchestrator stats with queue, per-engine and
+// cost breakdowns for dashboards.
+func (s *Server) handleAPIStats(c *gin.Context) {
+	c.JSON(http.StatusOK, s.orchestrator.GetDetailedStats())
+}
+
+// handleAPIStatsHistory serves per-day task counts and per-model outcomes
+// for the last ?days= days (default 14).
+func (s *Server) handleAPIStatsHistory(c *gin.Context) {
+	days, err := queryCount(c, "days")
+	if err != nil || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid days"})
+		return
+	}
+	if days == 0 {
+		days = 14
+	}
+	history, err := s.orchestrator.TaskHistory(days, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// handleAPIStatsTimeline serves the runs of the tasks active in the last
+// ?hours= (1-720, default 24).
+func (s *Server) handleAPIStatsTimeline(c *gin.Context) {
+	hours, err := queryCount(c, "hours")
+	if err != nil || hours > 720 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hours"})
+		return
+	}
+	if hours == 0 {
+		hours = 24
+	}
+	to := time.Now()
+	from := to.Add(-time.Duration(hours) * time.Hour)
+	tasks, err := s.orchestrator.Timeline(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "tasks": tasks})
+}
+
+// handleAPIGraph serves the dependency graph of ?task_id=, ?tag= or all tasks.
+func (s *Server) handleAPIGraph(c *gin.Context) {
+	graph, err := s.orchestrator.TaskGraph(c.Query("task_id"), c.Query("tag"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, graph)
+}
+
 func (s *Server) findTaskByID(id string) (*models.Task, error) {
 	tasks, err := s.orchestrator.ListTasks(models.ListRequest{})
 	if err != nil {
@@ -276,6 +907,19 @@ func parseStatusQuery(c *gin.Context) ([]models.TaskStatus, error) {
 	return statuses, nil
 }
 
+// queryCount parses a non-negative integer query parameter, 0 when absent.
+func queryCount(c *gin.Context, name string) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, &apiError{msg: "invalid " + name}
+	}
+	return n, nil
+}
+
 type apiError struct{ msg string }
 
 func (e *apiError) Error() string { return e.msg }
@@ -292,18 +936,13 @@ func promptExcerpt(s string, max int) string {
 }
 
 func readLogChunk(path string, offset, max int64) ([]byte, int64, bool, error) {
-	f, err := os.Open(path)
+	f, err := agent.OpenLog(path)
 	if err != nil {
 		return nil, offset, false, err
 	}
 	defer f.Close()
 
-	st, err := f.Stat()
-	if err != nil {
-		return nil, offset, false, err
-	}
-
-	size := st.Size()
+	size := f.Size()
 	start := offset
 	truncated := false
 
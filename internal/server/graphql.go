@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sevir/mesnada/internal/graphql"
+	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// handleGraphQL answers GraphQL queries over tasks, stats and engines, sent
+// as a JSON body or, for GET, as the query, operationName and variables
+// query parameters. Query errors are reported in the response body.
+func (s *Server) handleGraphQL(c *gin.Context) {
+	var req graphql.Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if vars := c.Query("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, graphql.Response{Errors: []graphql.Error{{Message: "invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, graphql.Response{Errors: []graphql.Error{{Message: "invalid request: " + err.Error()}}})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.graphQLSchema().Execute(c.Request.Context(), req))
+}
+
+// graphQLSchema builds the schema for one request. Task fields are the JSON
+// fields of the REST API, except that dependencies and dependents resolve
+// to the tasks themselves so queries can follow them.
+func (s *Server) graphQLSchema() *graphql.Schema {
+	var all []*models.Task // loaded on first use by dependents
+	return &graphql.Schema{
+		Query: map[string]graphql.Resolver{
+			"task":    s.graphQLTask,
+			"tasks":   s.graphQLTasks,
+			"stats":   s.graphQLStats,
+			"engines": s.graphQLEngines,
+		},
+		Resolve: func(ctx context.Context, parent interface{}, field *graphql.Field) (interface{}, bool, error) {
+			task, ok := parent.(*models.Task)
+			if !ok {
+				return nil, false, nil
+			}
+			switch field.Name {
+			case "dependencies":
+				deps := []*models.Task{}
+				for _, id := range task.Dependencies {
+					// Deleted dependencies are left out.
+					if dep, err := s.orchestrator.GetTask(id); err == nil {
+						deps = append(deps, dep)
+					}
+				}
+				return deps, true, nil
+			case "dependents":
+				if all == nil {
+					var err error
+					if all, err = s.orchestrator.ListTasks(models.ListRequest{}); err != nil {
+						return nil, true, err
+					}
+				}
+				dependents := []*models.Task{}
+				for _, t := range all {
+					for _, id := range t.Dependencies {
+						if id == task.ID {
+							dependents = append(dependents, t)
+							break
+						}
+					}
+				}
+				return dependents, true, nil
+			}
+			return nil, false, nil
+		},
+		TypeName: func(v interface{}) string {
+			switch v.(type) {
+			case *models.Task:
+				return "Task"
+			case *orchestrator.DetailedStats:
+				return "Stats"
+			case *engineListing:
+				return "Engine"
+			}
+			return ""
+		},
+	}
+}
+
+// graphQLTask resolves task(id:), which is null for an unknown task.
+func (s *Server) graphQLTask(ctx context.Context, f *graphql.Field) (interface{}, error) {
+	if err := f.CheckArgs("id"); err != nil {
+		return nil, err
+	}
+	id, err := f.StringArg("id")
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, fmt.Errorf("argument \"id\" is required")
+	}
+	task, err := s.orchestrator.GetTask(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+// graphQLTasks resolves tasks(...), filtering and sorting like search_tasks.
+// Unlike search_tasks it returns every match unless limit is given.
+func (s *Server) graphQLTasks(ctx context.Context, f *graphql.Field) (interface{}, error) {
+	if err := f.CheckArgs("status", "tags", "query", "engine", "model", "created_after", "created_before", "sort", "limit", "offset"); err != nil {
+		return nil, err
+	}
+	var req models.ListRequest
+	statuses, err := f.StringsArg("status")
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range statuses {
+		switch st := models.TaskStatus(st); st {
+		case models.TaskStatusPending, models.TaskStatusRunning, models.TaskStatusPaused, models.TaskStatusCompleted, models.TaskStatusFailed, models.TaskStatusCancelled:
+			req.Status = append(req.Status, st)
+		default:
+			return nil, fmt.Errorf("invalid status: %s", st)
+		}
+	}
+	if req.Tags, err = f.StringsArg("tags"); err != nil {
+		return nil, err
+	}
+	if req.Query, err = f.StringArg("query"); err != nil {
+		return nil, err
+	}
+	engine, err := f.StringArg("engine")
+	if err != nil {
+		return nil, err
+	}
+	req.Engine = engineFromToolName(engine)
+	if req.Model, err = f.StringArg("model"); err != nil {
+		return nil, err
+	}
+	if req.CreatedAfter, err = timeBoundArg(f, "created_after"); err != nil {
+		return nil, err
+	}
+	if req.CreatedBefore, err = timeBoundArg(f, "created_before"); err != nil {
+		return nil, err
+	}
+	if req.Sort, err = f.StringArg("sort"); err != nil {
+		return nil, err
+	}
+	if !models.ValidTaskSort(req.Sort) {
+		return nil, fmt.Errorf("invalid sort: %s (use %s, optionally prefixed with -)", req.Sort, strings.Join(models.TaskSortFields(), ", "))
+	}
+	if req.Limit, err = f.IntArg("limit"); err != nil {
+		return nil, err
+	}
+	if req.Offset, err = f.IntArg("offset"); err != nil {
+		return nil, err
+	}
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, fmt.Errorf("limit and offset must not be negative")
+	}
+	return s.orchestrator.ListTasks(req)
+}
+
+// timeBoundArg reads a created_after or created_before argument.
+func timeBoundArg(f *graphql.Field, name string) (time.Time, error) {
+	value, err := f.StringArg(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := parseTimeBound(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// graphQLStats resolves stats, the detailed stats of GET /api/v1/stats.
+func (s *Server) graphQLStats(ctx context.Context, f *graphql.Field) (interface{}, error) {
+	if err := f.CheckArgs(); err != nil {
+		return nil, err
+	}
+	stats := s.orchestrator.GetDetailedStats()
+	return &stats, nil
+}
+
+// graphQLEngines resolves engines(refresh:), the listings of
+// GET /api/v1/engines.
+func (s *Server) graphQLEngines(ctx context.Context, f *graphql.Field) (interface{}, error) {
+	if err := f.CheckArgs("refresh"); err != nil {
+		return nil, err
+	}
+	refresh, err := f.BoolArg("refresh")
+	if err != nil {
+		return nil, err
+	}
+	return s.engineListings(ctx, refresh), nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestGraphQL(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	parent := spawnPending(t, srv, models.SpawnRequest{Tags: []string{"build"}})
+	child := spawnPending(t, srv, models.SpawnRequest{Dependencies: []string{parent.ID}, Tags: []string{"deploy"}})
+
+	post := func(body string) (int, string) {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(body)))
+		return w.Code, w.Body.String()
+	}
+
+	query, _ := json.Marshal(map[string]interface{}{
+		"query": `query Child($id: String!) {
+			task(id: $id) { id status dependencies { id status dependents { id } } }
+			missing: task(id: "nope") { id }
+		}`,
+		"variables": map[string]string{"id": child.ID},
+	})
+	code, body := post(string(query))
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", code, body)
+	}
+	want := `{"data":{"task":{"id":"` + child.ID + `","status":"pending","dependencies":[{"id":"` + parent.ID +
+		`","status":"pending","dependents":[{"id":"` + child.ID + `"}]}]},"missing":null}}`
+	if body != want {
+		t.Fatalf("unexpected response\n got %s\nwant %s", body, want)
+	}
+
+	code, body = post(`{"query":"{ tasks(tags: [\"build\"]) { id __typename } stats { total waiting_on_dependencies } engines { engine } }"}`)
+	var resp struct {
+		Data struct {
+			Tasks []struct {
+				ID       string `json:"id"`
+				TypeName string `json:"__typename"`
+			} `json:"tasks"`
+			Stats struct {
+				Total   int `json:"total"`
+				Waiting int `json:"waiting_on_dependencies"`
+			} `json:"stats"`
+			Engines []map[string]string `json:"engines"`
+		} `json:"data"`
+		Errors []interface{} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	if len(resp.Errors) > 0 || len(resp.Data.Tasks) != 1 || resp.Data.Tasks[0].ID != parent.ID || resp.Data.Tasks[0].TypeName != "Task" ||
+		resp.Data.Stats.Total != 2 || resp.Data.Stats.Waiting != 2 || len(resp.Data.Engines) == 0 {
+		t.Fatalf("unexpected response %s", body)
+	}
+
+	// GET takes the query as parameters.
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ task(id: \""+parent.ID+"\") { prompt } }"), nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"data":{"task":{"prompt":"p"}}}` {
+		t.Fatalf("unexpected GET response %d %s", w.Code, w.Body.String())
+	}
+
+	for query, msg := range map[string]string{
+		`{ task(id: \"` + parent.ID + `\") { nope } }`: `cannot query field \"nope\" on type Task`,
+		`{ tasks { id } tasks2 { id } }`:               `cannot query field \"tasks2\" on type Query`,
+		`mutation { spawn { id } }`:                    `mutation operations are not supported`,
+		`{ tasks(status: [\"done\"]) { id } }`:         `invalid status: done`,
+		`{ task { id }`:                                `unexpected end of query`,
+	} {
+		code, body := post(`{"query":"` + query + `"}`)
+		if code != http.StatusOK || !strings.Contains(body, msg) {
+			t.Errorf("%s: expected %q, got %d %s", query, msg, code, body)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// grpcServicePath prefixes the methods of the mesnada.v1.Orchestrator
+// service defined in api/proto/mesnada/v1/orchestrator.proto.
+const grpcServicePath = "/mesnada.v1.Orchestrator/"
+
+// grpcMaxMessage is the largest request message accepted, the default of
+// the grpc-go server.
+const grpcMaxMessage = 4 << 20
+
+// gRPC status codes returned by the Orchestrator service.
+const (
+	grpcOK                 = 0
+	grpcCanceled           = 1
+	grpcInvalidArgument    = 3
+	grpcDeadlineExceeded   = 4
+	grpcNotFound           = 5
+	grpcPermissionDenied   = 7
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+)
+
+// grpcError is an RPC failure with its gRPC status code.
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+func grpcErrorf(code int, format string, args ...interface{}) error {
+	return &grpcError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// grpcMethod handles one RPC: it decodes the request message and calls send
+// with each response message, once for unary methods.
+type grpcMethod struct {
+	scope  string
+	handle func(s *Server, ctx context.Context, req []byte, send func([]byte) error) error
+}
+
+var grpcMethods = map[string]grpcMethod{
+	"Spawn":      {config.ScopeSpawn, (*Server).grpcSpawn},
+	"GetTask":    {config.ScopeRead, (*Server).grpcGetTask},
+	"ListTasks":  {config.ScopeRead, (*Server).grpcListTasks},
+	"WaitTask":   {config.ScopeRead, (*Server).grpcWaitTask},
+	"CancelTask": {config.ScopeSpawn, (*Server).grpcCancelTask},
+}
+
+// handleGRPC serves the Orchestrator gRPC service over HTTP/2, on the same
+// port and behind the same authentication as the REST API. Scoped API
+// tokens need the spawn scope for Spawn and CancelTask and read for the
+// rest, as on the matching REST routes.
+func (s *Server) handleGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/grpc" && !strings.HasPrefix(ct, "application/grpc+proto") {
+		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	start := time.Now()
+	name := strings.TrimPrefix(r.URL.Path, grpcServicePath)
+	w.Header().Set("Content-Type", "application/grpc")
+	err := s.serveGRPC(w, r, name)
+
+	code, msg := grpcOK, ""
+	if err != nil {
+		var gerr *grpcError
+		if !errors.As(err, &gerr) {
+			gerr = &grpcError{code: grpcInternal, msg: err.Error()}
+		}
+		code, msg = gerr.code, gerr.msg
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcEncodeMessage(msg))
+	}
+
+	tokenName := "-"
+	if token, ok := r.Context().Value(apiTokenKey{}).(config.APIToken); ok {
+		tokenName = token.Name
+	}
+	log.Printf("audit_event=grpc_request method=%s code=%d token=%q remote=%s duration_ms=%d",
+		name, code, tokenName, r.RemoteAddr, time.Since(start).Milliseconds())
+}
+
+func (s *Server) serveGRPC(w http.ResponseWriter, r *http.Request, name string) error {
+	method, ok := grpcMethods[name]
+	if !ok {
+		return grpcErrorf(grpcUnimplemented, "unknown method %s", name)
+	}
+	if token, scoped := r.Context().Value(apiTokenKey{}).(config.APIToken); scoped && !token.HasScope(method.scope) {
+		return grpcErrorf(grpcPermissionDenied, "token lacks the %s scope", method.scope)
+	}
+	if s.orchestrator == nil {
+		return grpcErrorf(grpcUnavailable, "orchestrator not available")
+	}
+
+	req, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	send := func(msg []byte) error {
+		var prefix [5]byte
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+		if _, err := w.Write(prefix[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(msg); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	return method.handle(s, r.Context(), req, send)
+}
+
+// readGRPCMessage reads the single length-prefixed request message of a
+// unary or server-streaming call.
+func readGRPCMessage(body io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "missing request message")
+	}
+	if prefix[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessage {
+		return nil, grpcErrorf(grpcInvalidArgument, "request message of %d bytes exceeds %d", size, grpcMaxMessage)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "truncated request message")
+	}
+	return msg, nil
+}
+
+// grpcEncodeMessage percent-encodes a status message for the grpc-message
+// trailer.
+func grpcEncodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// grpcTaskError maps an orchestrator error about a task to a status.
+func grpcTaskError(err error, code int) error {
+	if strings.Contains(err.Error(), "not found") {
+		return grpcErrorf(grpcNotFound, "%s", err.Error())
+	}
+	return grpcErrorf(code, "%s", err.Error())
+}
+
+func (s *Server) grpcSpawn(ctx context.Context, data []byte, send func([]byte) error) error {
+	req, err := decodeSpawnRequest(data)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "invalid SpawnRequest: %v", err)
+	}
+	if strings.TrimSpace(req.args.Prompt) == "" {
+		return grpcErrorf(grpcInvalidArgument, "prompt is required")
+	}
+
+	spawn := s.spawnRequest(req.args)
+	spawn.Priority = req.priority
+	spawn.IncludeDependencyLogs = req.includeDependencyLogs
+	spawn.DependencyLogLines = req.dependencyLogLines
+	spawn.ResumeSessionID = req.resumeSessionID
+	task, err := s.orchestrator.Spawn(ctx, spawn)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "%s", err.Error())
+	}
+	return send(encodeTask(task))
+}
+
+func (s *Server) grpcGetTask(_ context.Context, data []byte, send func([]byte) error) error {
+	id, _, err := decodeTaskIDRequest(data)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "invalid GetTaskRequest: %v", err)
+	}
+	task, err := s.orchestrator.GetTask(id)
+	if err != nil {
+		return grpcTaskError(err, grpcInternal)
+	}
+	return send(encodeTask(task))
+}
+
+func (s *Server) grpcListTasks(_ context.Context, data []byte, send func([]byte) error) error {
+	req, err := decodeListTasksRequest(data)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "invalid ListTasksRequest: %v", err)
+	}
+	if !models.ValidTaskSort(req.Sort) {
+		return grpcErrorf(grpcInvalidArgument, "invalid sort %q", req.Sort)
+	}
+	tasks, err := s.orchestrator.ListTasks(req)
+	if err != nil {
+		return grpcErrorf(grpcInternal, "%s", err.Error())
+	}
+	return send(encodeListTasksResponse(tasks))
+}
+
+func (s *Server) grpcCancelTask(_ context.Context, data []byte, send func([]byte) error) error {
+	id, _, err := decodeTaskIDRequest(data)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "invalid CancelTaskRequest: %v", err)
+	}
+	if err := s.orchestrator.Cancel(id); err != nil {
+		return grpcTaskError(err, grpcFailedPrecondition)
+	}
+	task, err := s.orchestrator.GetTask(id)
+	if err != nil {
+		return grpcTaskError(err, grpcInternal)
+	}
+	return send(encodeTask(task))
+}
+
+// grpcWaitTask sends the task, then again after each change to it, until it
+// is sent in a terminal status.
+func (s *Server) grpcWaitTask(ctx context.Context, data []byte, send func([]byte) error) error {
+	id, rawTimeout, err := decodeTaskIDRequest(data)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "invalid WaitTaskRequest: %v", err)
+	}
+	if rawTimeout != "" {
+		timeout, err := time.ParseDuration(rawTimeout)
+		if err != nil {
+			return grpcErrorf(grpcInvalidArgument, "invalid timeout: %v", err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Note the newest event before reading the task, so no change is missed.
+	_, last, _, _ := s.taskEvents.since(0)
+	task, err := s.orchestrator.GetTask(id)
+	if err != nil {
+		return grpcTaskError(err, grpcInternal)
+	}
+	// The store shares the task, so check whether it was terminal before
+	// encoding it: a task that finishes in between is sent again.
+	terminal := task.IsTerminal()
+	if err := send(encodeTask(task)); err != nil {
+		return err
+	}
+
+	for !terminal {
+		events, next, wake, ok := s.taskEvents.since(last)
+		last = next
+		changed := !ok
+		for _, ev := range events {
+			changed = changed || ev.TaskID == id
+		}
+		if !changed {
+			select {
+			case <-wake:
+				continue
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return grpcErrorf(grpcDeadlineExceeded, "timed out waiting for task %s", id)
+				}
+				return grpcErrorf(grpcCanceled, "%s", ctx.Err().Error())
+			case <-s.done:
+				return grpcErrorf(grpcUnavailable, "server shutting down")
+			}
+		}
+		if task, err = s.orchestrator.GetTask(id); err != nil {
+			return grpcTaskError(err, grpcInternal)
+		}
+		terminal = task.IsTerminal()
+		if err := send(encodeTask(task)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
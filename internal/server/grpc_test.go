@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// grpcResult is the messages and status of a gRPC call.
+type grpcResult struct {
+	messages [][]byte
+	status   string
+	message  string
+}
+
+// grpcOpen starts a gRPC call over cleartext HTTP/2, as grpc-go clients
+// make them.
+func grpcOpen(t *testing.T, url, method, token string, req []byte) *http.Response {
+	t.Helper()
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+
+	body := make([]byte, 5, 5+len(req))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(req)))
+	body = append(body, req...)
+	httpReq, _ := http.NewRequest(http.MethodPost, url+grpcServicePath+method, bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/grpc")
+	httpReq.Header.Set("TE", "trailers")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: expected an HTTP/2 200, got %s %d", method, resp.Proto, resp.StatusCode)
+	}
+	return resp
+}
+
+// grpcRead reads the next response message, or returns nil at the end of
+// the stream.
+func grpcRead(t *testing.T, body io.Reader) []byte {
+	t.Helper()
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(body, msg); err != nil {
+		t.Fatalf("truncated message: %v", err)
+	}
+	return msg
+}
+
+// grpcCall makes a gRPC call and reads all of its response.
+func grpcCall(t *testing.T, url, method, token string, req []byte) grpcResult {
+	t.Helper()
+	resp := grpcOpen(t, url, method, token, req)
+	defer resp.Body.Close()
+	var result grpcResult
+	for msg := grpcRead(t, resp.Body); msg != nil; msg = grpcRead(t, resp.Body) {
+		result.messages = append(result.messages, msg)
+	}
+	result.status = resp.Trailer.Get("Grpc-Status")
+	result.message = resp.Trailer.Get("Grpc-Message")
+	return result
+}
+
+// taskFields decodes the string and varint fields of a Task message.
+func taskFields(t *testing.T, msg []byte) (map[protowire.Number]string, map[protowire.Number]uint64) {
+	t.Helper()
+	strs := map[protowire.Number]string{}
+	nums := map[protowire.Number]uint64{}
+	err := wireFields(msg, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		switch typ {
+		case protowire.BytesType:
+			strs[num] = string(b)
+		case protowire.VarintType:
+			nums[num] = n
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("invalid Task message: %v", err)
+	}
+	return strs, nums
+}
+
+func TestGRPC_Orchestrator(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+
+	var spawn []byte
+	spawn = appendString(spawn, 1, "do something")
+	spawn = appendString(spawn, 2, t.TempDir())
+	spawn = appendStrings(spawn, 5, []string{"missing"})
+	spawn = appendStrings(spawn, 6, []string{"grpc"})
+	spawn = appendVarint(spawn, 14, 1)
+	spawn = appendStringMap(spawn, 19, map[string]string{"ticket": "T-1"})
+	res := grpcCall(t, ts.URL, "Spawn", "", spawn)
+	if res.status != "0" || len(res.messages) != 1 {
+		t.Fatalf("Spawn: expected one task and status 0, got %d messages, status %s %q", len(res.messages), res.status, res.message)
+	}
+	strs, nums := taskFields(t, res.messages[0])
+	id := strs[1]
+	if id == "" || nums[4] != grpcStatusNumber("pending") || strs[17] != "grpc" {
+		t.Fatalf("Spawn: expected a pending task tagged grpc, got %v %v", strs, nums)
+	}
+
+	var byID []byte
+	byID = appendString(byID, 1, id)
+	res = grpcCall(t, ts.URL, "GetTask", "", byID)
+	if strs, _ := taskFields(t, res.messages[0]); res.status != "0" || strs[1] != id {
+		t.Fatalf("GetTask: expected task %s, got status %s %v", id, res.status, strs)
+	}
+	if res = grpcCall(t, ts.URL, "GetTask", "", appendString(nil, 1, "nope")); res.status != "5" || len(res.messages) != 0 {
+		t.Fatalf("GetTask: expected NOT_FOUND for an unknown task, got %s %q", res.status, res.message)
+	}
+
+	res = grpcCall(t, ts.URL, "ListTasks", "", appendStrings(nil, 2, []string{"grpc"}))
+	var listed int
+	var total uint64
+	wireFields(res.messages[0], func(num protowire.Number, _ protowire.Type, n uint64, _ []byte) error {
+		if num == 1 {
+			listed++
+		} else if num == 2 {
+			total = n
+		}
+		return nil
+	})
+	if res.status != "0" || listed != 1 || total != 1 {
+		t.Fatalf("ListTasks: expected one task, got %d (total %d), status %s", listed, total, res.status)
+	}
+
+	res = grpcCall(t, ts.URL, "WaitTask", "", appendString(byID, 2, "50ms"))
+	if res.status != "4" || len(res.messages) != 1 {
+		t.Fatalf("WaitTask: expected the task then DEADLINE_EXCEEDED, got %d messages, status %s", len(res.messages), res.status)
+	}
+
+	res = grpcCall(t, ts.URL, "CancelTask", "", byID)
+	if _, nums := taskFields(t, res.messages[0]); res.status != "0" || nums[4] != grpcStatusNumber("cancelled") {
+		t.Fatalf("CancelTask: expected a cancelled task, got status %s %v", res.status, nums)
+	}
+	res = grpcCall(t, ts.URL, "WaitTask", "", byID)
+	if _, nums := taskFields(t, res.messages[0]); res.status != "0" || len(res.messages) != 1 || nums[4] != grpcStatusNumber("cancelled") {
+		t.Fatalf("WaitTask: expected the finished task once, got %d messages, status %s", len(res.messages), res.status)
+	}
+
+	if res = grpcCall(t, ts.URL, "PauseTask", "", byID); res.status != "12" {
+		t.Fatalf("expected UNIMPLEMENTED for an unknown method, got %s", res.status)
+	}
+}
+
+func TestGRPC_WaitTaskStreamsChanges(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	resp := grpcOpen(t, ts.URL, "WaitTask", "", appendString(nil, 1, task.ID))
+	defer resp.Body.Close()
+	if _, nums := taskFields(t, grpcRead(t, resp.Body)); nums[4] != grpcStatusNumber("pending") {
+		t.Fatalf("expected the stream to start with the pending task, got %v", nums)
+	}
+
+	if err := srv.orchestrator.Cancel(task.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	var last []byte
+	for msg := grpcRead(t, resp.Body); msg != nil; msg = grpcRead(t, resp.Body) {
+		last = msg
+	}
+	if status := resp.Trailer.Get("Grpc-Status"); status != "0" || last == nil {
+		t.Fatalf("expected the cancelled task and status 0, got status %s", status)
+	}
+	if _, nums := taskFields(t, last); nums[4] != grpcStatusNumber("cancelled") {
+		t.Fatalf("expected the stream to end with the cancelled task, got %v", nums)
+	}
+}
+
+func TestGRPC_Scopes(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	cfg := config.DefaultConfig()
+	cfg.Server.APITokens = []config.APIToken{{Name: "dashboard", Token: "ro", Scopes: []string{config.ScopeRead}}}
+	// gRPC methods stay at the root under a base path.
+	cfg.Server.BasePath = "/mesnada"
+	srv.ReloadConfig(cfg)
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+
+	if res := grpcCall(t, ts.URL, "Spawn", "ro", appendString(nil, 1, "p")); res.status != "7" {
+		t.Fatalf("expected PERMISSION_DENIED for a read token, got %s %q", res.status, res.message)
+	}
+	if res := grpcCall(t, ts.URL, "ListTasks", "ro", nil); res.status != "0" {
+		t.Fatalf("expected a read token to list tasks, got %s %q", res.status, res.message)
+	}
+}
@@ -0,0 +1,417 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protocol Buffers encoding of the mesnada.v1 messages defined in
+// api/proto/mesnada/v1/orchestrator.proto. Field numbers must match the
+// .proto file; the messages are small enough that generated code is not
+// worth a protoc step in the build.
+
+// grpcStatuses maps the TaskStatus enum numbers to task statuses.
+var grpcStatuses = []models.TaskStatus{
+	"",
+	models.TaskStatusPending,
+	models.TaskStatusRunning,
+	models.TaskStatusPaused,
+	models.TaskStatusCompleted,
+	models.TaskStatusFailed,
+	models.TaskStatusCancelled,
+}
+
+func grpcStatusNumber(status models.TaskStatus) uint64 {
+	for i, s := range grpcStatuses {
+		if s == status {
+			return uint64(i)
+		}
+	}
+	return 0
+}
+
+// wireFields calls fn with each field of a message, its wire type and its
+// raw value: the varint or fixed value in n, or the bytes in b.
+func wireFields(data []byte, fn func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error) error {
+	for len(data) > 0 {
+		num, typ, l := protowire.ConsumeTag(data)
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		data = data[l:]
+		var n uint64
+		var b []byte
+		switch typ {
+		case protowire.VarintType:
+			n, l = protowire.ConsumeVarint(data)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, l = protowire.ConsumeFixed32(data)
+			n = uint64(v)
+		case protowire.Fixed64Type:
+			n, l = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			b, l = protowire.ConsumeBytes(data)
+		default:
+			l = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		data = data[l:]
+		if err := fn(num, typ, n, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wireString returns a string field, rejecting other wire types.
+func wireString(num protowire.Number, typ protowire.Type, b []byte) (string, error) {
+	if typ != protowire.BytesType {
+		return "", fmt.Errorf("field %d: expected a string", num)
+	}
+	return string(b), nil
+}
+
+// wireVarint returns a varint field, rejecting other wire types.
+func wireVarint(num protowire.Number, typ protowire.Type, n uint64) (uint64, error) {
+	if typ != protowire.VarintType {
+		return 0, fmt.Errorf("field %d: expected a varint", num)
+	}
+	return n, nil
+}
+
+// decodeMapEntry decodes a map<string, string> entry.
+func decodeMapEntry(b []byte) (string, string, error) {
+	var key, value string
+	err := wireFields(b, func(num protowire.Number, typ protowire.Type, _ uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			key, err = wireString(num, typ, b)
+		case 2:
+			value, err = wireString(num, typ, b)
+		}
+		return err
+	})
+	return key, value, err
+}
+
+// decodeTimestamp decodes a google.protobuf.Timestamp.
+func decodeTimestamp(b []byte) (time.Time, error) {
+	var seconds, nanos uint64
+	err := wireFields(b, func(num protowire.Number, typ protowire.Type, n uint64, _ []byte) error {
+		var err error
+		switch num {
+		case 1:
+			seconds, err = wireVarint(num, typ, n)
+		case 2:
+			nanos, err = wireVarint(num, typ, n)
+		}
+		return err
+	})
+	return time.Unix(int64(seconds), int64(int32(nanos))).UTC(), err
+}
+
+// grpcSpawnRequest is a decoded mesnada.v1.SpawnRequest.
+type grpcSpawnRequest struct {
+	args                  spawnArgs
+	priority              int
+	includeDependencyLogs bool
+	dependencyLogLines    int
+	resumeSessionID       string
+}
+
+func decodeSpawnRequest(data []byte) (grpcSpawnRequest, error) {
+	var req grpcSpawnRequest
+	background := false
+	req.args.Background = &background
+	err := wireFields(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		a := &req.args
+		var s string
+		var err error
+		switch num {
+		case 1, 2, 3, 4, 5, 6, 8, 9, 10, 11, 12, 13, 17:
+			if s, err = wireString(num, typ, b); err != nil {
+				return err
+			}
+		case 7, 14, 15, 16, 18:
+			if n, err = wireVarint(num, typ, n); err != nil {
+				return err
+			}
+		}
+		switch num {
+		case 1:
+			a.Prompt = s
+		case 2:
+			a.WorkDir = s
+		case 3:
+			a.Model = s
+		case 4:
+			a.Engine = s
+		case 5:
+			a.Dependencies = append(a.Dependencies, s)
+		case 6:
+			a.Tags = append(a.Tags, s)
+		case 7:
+			req.priority = int(int32(n))
+		case 8:
+			a.Timeout = s
+		case 9:
+			a.MCPConfig = s
+		case 10:
+			a.ExtraArgs = append(a.ExtraArgs, s)
+		case 11:
+			a.Persona = s
+		case 12:
+			a.Sandbox = s
+		case 13:
+			a.Secrets = append(a.Secrets, s)
+		case 14:
+			background = n != 0
+		case 15:
+			req.includeDependencyLogs = n != 0
+		case 16:
+			req.dependencyLogLines = int(int32(n))
+		case 17:
+			req.resumeSessionID = s
+		case 18:
+			autoCommit := n != 0
+			a.AutoCommit = &autoCommit
+		case 19:
+			if typ != protowire.BytesType {
+				return fmt.Errorf("field %d: expected a map entry", num)
+			}
+			k, v, err := decodeMapEntry(b)
+			if err != nil {
+				return err
+			}
+			if a.Metadata == nil {
+				a.Metadata = map[string]string{}
+			}
+			a.Metadata[k] = v
+		}
+		return nil
+	})
+	return req, err
+}
+
+// decodeTaskIDRequest decodes GetTaskRequest, CancelTaskRequest and
+// WaitTaskRequest, whose task_id is field 1; WaitTaskRequest's timeout is
+// field 2.
+func decodeTaskIDRequest(data []byte) (taskID, timeout string, err error) {
+	err = wireFields(data, func(num protowire.Number, typ protowire.Type, _ uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			taskID, err = wireString(num, typ, b)
+		case 2:
+			timeout, err = wireString(num, typ, b)
+		}
+		return err
+	})
+	return taskID, timeout, err
+}
+
+func decodeListTasksRequest(data []byte) (models.ListRequest, error) {
+	var req models.ListRequest
+	addStatus := func(n uint64) error {
+		if n == 0 || n >= uint64(len(grpcStatuses)) {
+			return fmt.Errorf("unknown task status %d", n)
+		}
+		req.Status = append(req.Status, grpcStatuses[n])
+		return nil
+	}
+	err := wireFields(data, func(num protowire.Number, typ protowire.Type, n uint64, b []byte) error {
+		var s string
+		var err error
+		switch num {
+		case 1:
+			if typ == protowire.BytesType {
+				// Packed repeated enum.
+				for len(b) > 0 {
+					v, l := protowire.ConsumeVarint(b)
+					if l < 0 {
+						return protowire.ParseError(l)
+					}
+					if err := addStatus(v); err != nil {
+						return err
+					}
+					b = b[l:]
+				}
+				return nil
+			}
+			if n, err = wireVarint(num, typ, n); err != nil {
+				return err
+			}
+			return addStatus(n)
+		case 2, 5, 6, 7, 10:
+			if s, err = wireString(num, typ, b); err != nil {
+				return err
+			}
+		case 3, 4:
+			if n, err = wireVarint(num, typ, n); err != nil {
+				return err
+			}
+		case 8, 9:
+			if typ != protowire.BytesType {
+				return fmt.Errorf("field %d: expected a timestamp", num)
+			}
+			t, err := decodeTimestamp(b)
+			if err != nil {
+				return err
+			}
+			if num == 8 {
+				req.CreatedAfter = t
+			} else {
+				req.CreatedBefore = t
+			}
+			return nil
+		}
+		switch num {
+		case 2:
+			req.Tags = append(req.Tags, s)
+		case 3:
+			req.Limit = int(int32(n))
+		case 4:
+			req.Offset = int(int32(n))
+		case 5:
+			req.Query = s
+		case 6:
+			req.Engine = models.Engine(s)
+		case 7:
+			req.Model = s
+		case 10:
+			req.Sort = s
+		}
+		return nil
+	})
+	return req, err
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendStrings(b []byte, num protowire.Number, ss []string) []byte {
+	for _, s := range ss {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendInt encodes an int32 or int64 field; negative values take ten
+// bytes, as protobuf sign-extends them.
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	return appendVarint(b, num, uint64(v))
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func appendTimestamp(b []byte, num protowire.Number, t *time.Time) []byte {
+	if t == nil || t.IsZero() {
+		return b
+	}
+	var ts []byte
+	ts = appendInt(ts, 1, t.Unix())
+	ts = appendInt(ts, 2, int64(t.Nanosecond()))
+	return appendMessage(b, num, ts)
+}
+
+func appendStringMap(b []byte, num protowire.Number, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, m[k])
+		b = appendMessage(b, num, entry)
+	}
+	return b
+}
+
+// encodeTask encodes a mesnada.v1.Task.
+func encodeTask(task *models.Task) []byte {
+	var b []byte
+	b = appendString(b, 1, task.ID)
+	b = appendString(b, 2, task.Prompt)
+	b = appendString(b, 3, task.WorkDir)
+	b = appendVarint(b, 4, grpcStatusNumber(task.Status))
+	b = appendString(b, 5, string(task.Engine))
+	b = appendInt(b, 6, int64(task.PID))
+	b = appendString(b, 7, task.OutputTail)
+	b = appendString(b, 8, task.Error)
+	if task.ExitCode != nil {
+		// Optional fields are sent even when zero.
+		b = protowire.AppendTag(b, 9, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(*task.ExitCode)))
+	}
+	b = appendString(b, 10, task.Model)
+	b = appendString(b, 11, task.LogFile)
+	if p := task.Progress; p != nil {
+		var m []byte
+		m = appendInt(m, 1, int64(p.Percentage))
+		m = appendString(m, 2, p.Description)
+		m = appendTimestamp(m, 3, &p.UpdatedAt)
+		b = appendMessage(b, 12, m)
+	}
+	b = appendTimestamp(b, 13, &task.CreatedAt)
+	b = appendTimestamp(b, 14, task.StartedAt)
+	b = appendTimestamp(b, 15, task.CompletedAt)
+	b = appendStrings(b, 16, task.Dependencies)
+	b = appendStrings(b, 17, task.Tags)
+	b = appendInt(b, 18, int64(task.Priority))
+	b = appendString(b, 19, task.Persona)
+	b = appendString(b, 20, task.Sandbox)
+	b = appendString(b, 21, task.SessionID)
+	b = appendInt(b, 22, task.DurationMS)
+	b = appendInt(b, 23, int64(task.NumTurns))
+	b = appendString(b, 24, task.ResultStatus)
+	b = appendInt(b, 25, task.TokensIn)
+	b = appendInt(b, 26, task.TokensOut)
+	if task.CostUSD != 0 {
+		b = protowire.AppendTag(b, 27, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(task.CostUSD))
+	}
+	b = appendString(b, 28, task.Summary)
+	b = appendString(b, 29, task.CommitBranch)
+	b = appendString(b, 30, task.CommitSHA)
+	b = appendString(b, 31, task.RetryOf)
+	b = appendInt(b, 32, int64(task.Attempt))
+	b = appendStringMap(b, 33, task.Metadata)
+	return b
+}
+
+// encodeListTasksResponse encodes a mesnada.v1.ListTasksResponse.
+func encodeListTasksResponse(tasks []*models.Task) []byte {
+	var b []byte
+	for _, task := range tasks {
+		b = appendMessage(b, 1, encodeTask(task))
+	}
+	return appendInt(b, 2, int64(len(tasks)))
+}
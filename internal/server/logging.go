@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// logSeverities ranks the MCP (RFC 5424) log levels from least to most severe.
+var logSeverities = map[string]int{
+	"debug":     0,
+	"info":      1,
+	"notice":    2,
+	"warning":   3,
+	"error":     4,
+	"critical":  5,
+	"alert":     6,
+	"emergency": 7,
+}
+
+// handleLoggingSetLevel sets the minimum level of the log messages the
+// session receives as notifications/message.
+func (s *Server) handleLoggingSetLevel(session *Session, req *JSONRPCRequest) *JSONRPCResponse {
+	var params struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return rpcError(req.ID, -32602, "Invalid params", err.Error())
+	}
+	if _, ok := logSeverities[params.Level]; !ok {
+		return rpcError(req.ID, -32602, "Invalid params", "unknown log level: "+params.Level)
+	}
+
+	session.mu.Lock()
+	session.logLevel = params.Level
+	session.mu.Unlock()
+	log.Printf("mcp_event=log_level_set session_id=%s level=%s", session.ID, params.Level)
+
+	return &JSONRPCResponse{JSONRPC: jsonRPCVersion, ID: req.ID, Result: map[string]interface{}{}}
+}
+
+// wantsLog reports whether the session asked for messages of this level.
+func (s *Session) wantsLog(level string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logLevel != "" && logSeverities[level] >= logSeverities[s.logLevel]
+}
+
+// sendLog forwards a log message to every session whose level admits it.
+func (s *Server) sendLog(level, logger string, data map[string]interface{}) {
+	for _, session := range s.sessionList() {
+		if session.wantsLog(level) {
+			session.notify("notifications/message", map[string]interface{}{
+				"level":  level,
+				"logger": logger,
+				"data":   data,
+			})
+		}
+	}
+}
+
+// logTaskChange forwards task lifecycle events to clients: creation and
+// starts as info, completion as notice and failures as errors.
+func (s *Server) logTaskChange(change orchestrator.TaskChange, task *models.Task) {
+	data := map[string]interface{}{
+		"event":   string(change),
+		"task_id": task.ID,
+		"status":  task.Status,
+		"engine":  task.Engine,
+	}
+
+	level := "info"
+	switch change {
+	case orchestrator.TaskCreated, orchestrator.TaskStarted:
+	case orchestrator.TaskFinished:
+		switch task.Status {
+		case models.TaskStatusFailed:
+			level = "error"
+			data["error"] = task.Error
+			data["exit_code"] = task.ExitCode
+		case models.TaskStatusCancelled, models.TaskStatusPaused:
+			level = "warning"
+		default:
+			level = "notice"
+		}
+	default:
+		return
+	}
+	s.sendLog(level, "orchestrator", data)
+}
+
+// logEngineChanges warns clients, when a probe sees engines change, about
+// the engines it could not find.
+func (s *Server) logEngineChanges(infos []models.EngineInfo) {
+	for _, info := range infos {
+		if info.Available {
+			continue
+		}
+		s.sendLog("warning", "engines", map[string]interface{}{
+			"event":  "engine_unavailable",
+			"engine": info.Engine,
+			"binary": info.Binary,
+			"error":  info.Error,
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestLogging_SetLevelFiltersMessages(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	session := newSession("logs")
+	srv.sessionMu.Lock()
+	srv.sessions[session.ID] = session
+	srv.sessionMu.Unlock()
+
+	task := &models.Task{ID: "task-1", Status: models.TaskStatusFailed, Error: "boom"}
+	srv.logTaskChange(orchestrator.TaskFinished, task)
+	select {
+	case msg := <-session.events:
+		t.Fatalf("expected no log messages before logging/setLevel, got %s", msg)
+	default:
+	}
+
+	if resp := callMethod(t, srv, session, "logging/setLevel", map[string]string{"level": "verbose"}); resp.Error == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+	if resp := callMethod(t, srv, session, "logging/setLevel", map[string]string{"level": "warning"}); resp.Error != nil {
+		t.Fatalf("logging/setLevel: %+v", resp.Error)
+	}
+
+	srv.logTaskChange(orchestrator.TaskStarted, &models.Task{ID: "task-2", Status: models.TaskStatusRunning})
+	srv.logTaskChange(orchestrator.TaskFinished, task)
+	msg := string(<-session.events)
+	if !strings.Contains(msg, `"method":"notifications/message"`) || !strings.Contains(msg, `"level":"error"`) ||
+		!strings.Contains(msg, `"task_id":"task-1"`) || !strings.Contains(msg, "boom") {
+		t.Fatalf("expected an error message for the failed task, got %s", msg)
+	}
+	select {
+	case msg := <-session.events:
+		t.Fatalf("expected info messages to be filtered, got %s", msg)
+	default:
+	}
+}
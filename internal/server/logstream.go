@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sevir/mesnada/pkg/models"
+	"golang.org/x/net/websocket"
+)
+
+// logStreamPollInterval is how often a log stream checks on a task that has
+// no live output to follow yet (pending, or between process exit and its
+// final status).
+const logStreamPollInterval = time.Second
+
+// logStreamMessage is one WebSocket message of a log stream. "log" messages
+// carry log bytes starting at Offset; the first one holds the existing log
+// (its tail when Truncated), later ones new output. "end" carries the final
+// status of the task, after which the server closes the connection.
+type logStreamMessage struct {
+	Type      string            `json:"type"`
+	Content   string            `json:"content,omitempty"`
+	Offset    int64             `json:"offset"`
+	Truncated bool              `json:"truncated,omitempty"`
+	Status    models.TaskStatus `json:"status,omitempty"`
+}
+
+// handleAPITaskLogWS streams a task log over a WebSocket: what is already
+// written (from ?offset=, or the last 1MB), then new output as the agent
+// produces it, until the task finishes.
+func (s *Server) handleAPITaskLogWS(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := s.orchestrator.GetTask(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	offset, ok := logStreamOffset(c, c.Query("offset"))
+	if !ok {
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: s.checkWebSocketOrigin,
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+			// Clients only send close frames; reading notices disconnects.
+			go func() {
+				io.Copy(io.Discard, ws)
+				cancel()
+			}()
+			s.streamTaskLog(ctx, id, offset, func(msg logStreamMessage) error {
+				return websocket.JSON.Send(ws, msg)
+			})
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// handleAPITaskLogSSE is the Server-Sent Events variant of
+// handleAPITaskLogWS for networks that block WebSockets. Each "log" event has
+// the log offset after its content as id, so a reconnecting client resumes
+// with Last-Event-ID where it left off.
+func (s *Server) handleAPITaskLogSSE(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := s.orchestrator.GetTask(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("offset")
+	}
+	offset, ok := logStreamOffset(c, raw)
+	if !ok {
+		return
+	}
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	// Keep-alives and events share the response writer.
+	var mu sync.Mutex
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		keepAlive := time.NewTicker(sseKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-keepAlive.C:
+				mu.Lock()
+				fmt.Fprint(w, ": keep-alive\n\n")
+				w.Flush()
+				mu.Unlock()
+			}
+		}
+	}()
+
+	s.streamTaskLog(ctx, id, offset, func(msg logStreamMessage) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		next := msg.Offset + int64(len(msg.Content))
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", next, msg.Type, data)
+		w.Flush()
+		return err
+	})
+	cancel()
+	<-stopped
+}
+
+// logStreamOffset parses the starting offset of a log stream, answering 400
+// when it is invalid.
+func logStreamOffset(c *gin.Context, raw string) (int64, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, true
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return 0, false
+	}
+	return v, true
+}
+
+// checkWebSocketOrigin rejects WebSocket handshakes from other sites, so
+// they cannot read logs with the UI cookie; see checkOrigin.
+func (s *Server) checkWebSocketOrigin(cfg *websocket.Config, r *http.Request) error {
+	return s.checkOrigin(r)
+}
+
+// streamTaskLog sends the log of a task from offset, then follows it: the
+// agent's live output channel signals new lines, which are read back from
+// the log file so nothing is lost when the channel drops lines for a slow
+// client. It returns when the task finishes, ctx ends or send fails.
+func (s *Server) streamTaskLog(ctx context.Context, taskID string, offset int64, send func(logStreamMessage) error) error {
+	flush := func() error {
+		task, err := s.orchestrator.GetTask(taskID)
+		if err != nil || task.LogFile == "" {
+			return err
+		}
+		for {
+			data, next, truncated, err := readLogChunk(task.LogFile, offset, maxOutputChunkBytes)
+			if err != nil || len(data) == 0 {
+				// The log file does not exist until the task starts.
+				return nil
+			}
+			msg := logStreamMessage{Type: "log", Content: string(data), Offset: next - int64(len(data)), Truncated: truncated && offset == 0}
+			offset = next
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	ticker := time.NewTicker(logStreamPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := flush(); err != nil {
+			return err
+		}
+		task, err := s.orchestrator.GetTask(taskID)
+		if err != nil {
+			return err
+		}
+		if task.IsTerminal() {
+			return send(logStreamMessage{Type: "end", Offset: offset, Status: task.Status})
+		}
+
+		lines, stop, err := s.orchestrator.StreamOutput(taskID)
+		if err != nil {
+			// Not running yet, or already exited and about to be finalized.
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+			continue
+		}
+		if err := followOutput(ctx, lines, flush); err != nil {
+			stop()
+			return err
+		}
+		stop()
+	}
+}
+
+// followOutput flushes the log whenever the output channel has lines, until
+// the channel closes with the process.
+func followOutput(ctx context.Context, lines <-chan string, flush func() error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			// Drain lines that arrived together so one flush covers them.
+			for drained := false; !drained; {
+				select {
+				case _, ok := <-lines:
+					if !ok {
+						return flush()
+					}
+				default:
+					drained = true
+				}
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+	"golang.org/x/net/websocket"
+)
+
+func TestAPITaskLogWS_StreamsUntilTaskEnds(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	task.LogFile = filepath.Join(t.TempDir(), "task.log")
+	if err := os.WriteFile(task.LogFile, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/tasks/" + task.ID + "/log/ws"
+	ws, err := websocket.Dial(wsURL, "", ts.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(10 * time.Second))
+
+	receive := func() logStreamMessage {
+		t.Helper()
+		var msg logStreamMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+		return msg
+	}
+
+	if msg := receive(); msg.Type != "log" || msg.Content != "first\n" || msg.Offset != 0 {
+		t.Fatalf("expected the existing log, got %+v", msg)
+	}
+
+	f, err := os.OpenFile(task.LogFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("second\n")
+	f.Close()
+	if msg := receive(); msg.Type != "log" || msg.Content != "second\n" || msg.Offset != 6 {
+		t.Fatalf("expected the new output, got %+v", msg)
+	}
+
+	task.Status = models.TaskStatusCompleted
+	if msg := receive(); msg.Type != "end" || msg.Status != models.TaskStatusCompleted || msg.Offset != 13 {
+		t.Fatalf("expected the end of the stream, got %+v", msg)
+	}
+}
+
+func TestAPITaskLogWS_ChecksOrigin(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.AuthTokens = []string{"secret"}
+	srv.ReloadConfig(cfg)
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+
+	dial := func(origin string) error {
+		wsCfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/tasks/"+task.ID+"/log/ws", origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wsCfg.Header.Set("Authorization", "Bearer secret")
+		ws, err := websocket.DialConfig(wsCfg)
+		if err == nil {
+			ws.Close()
+		}
+		return err
+	}
+
+	if err := dial("https://evil.example"); err == nil {
+		t.Fatal("expected a foreign origin to be rejected")
+	}
+	if err := dial(ts.URL); err != nil {
+		t.Fatalf("expected the server's own origin to be accepted, got %v", err)
+	}
+}
+
+func TestAPITaskLogSSE_ResumesFromLastEventID(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	task.LogFile = filepath.Join(t.TempDir(), "task.log")
+	if err := os.WriteFile(task.LogFile, []byte("first\nsecond\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	task.Status = models.TaskStatusFailed
+
+	req := httptest.NewRequest("GET", "/api/tasks/"+task.ID+"/log/sse", nil)
+	req.Header.Set("Last-Event-ID", "6")
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", w.Header().Get("Content-Type"))
+	}
+	if strings.Contains(body, "first") || !strings.Contains(body, "id: 13\nevent: log\n") || !strings.Contains(body, `"content":"second\n"`) {
+		t.Fatalf("expected only the output after offset 6, got %q", body)
+	}
+	if !strings.Contains(body, "event: end\n") || !strings.Contains(body, `"status":"failed"`) {
+		t.Fatalf("expected the end event, got %q", body)
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sevir/mesnada/internal/agent"
+	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// Histogram buckets, in seconds.
+var (
+	taskDurationBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200}
+	httpDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, le := range h.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metrics holds the counters and histograms served on /metrics. Task gauges
+// are read from the orchestrator at scrape time instead.
+type metrics struct {
+	mu           sync.Mutex
+	spawned      map[string]uint64
+	spawnLatency map[string]*histogram
+	runDuration  map[[2]string]*histogram
+	httpRequests map[[3]string]uint64
+	httpDuration map[string]*histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		spawned:      make(map[string]uint64),
+		spawnLatency: make(map[string]*histogram),
+		runDuration:  make(map[[2]string]*histogram),
+		httpRequests: make(map[[3]string]uint64),
+		httpDuration: make(map[string]*histogram),
+	}
+}
+
+// observeTask records spawns, the time tasks wait before starting and how
+// long they run.
+func (m *metrics) observeTask(change orchestrator.TaskChange, task *models.Task) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	engine := string(task.Engine)
+	switch change {
+	case orchestrator.TaskCreated:
+		m.spawned[engine]++
+	case orchestrator.TaskStarted:
+		if task.StartedAt != nil {
+			h := m.spawnLatency[engine]
+			if h == nil {
+				h = newHistogram(taskDurationBuckets)
+				m.spawnLatency[engine] = h
+			}
+			h.observe(task.StartedAt.Sub(task.CreatedAt).Seconds())
+		}
+	case orchestrator.TaskFinished:
+		if task.StartedAt != nil && task.CompletedAt != nil {
+			key := [2]string{engine, string(task.Status)}
+			h := m.runDuration[key]
+			if h == nil {
+				h = newHistogram(taskDurationBuckets)
+				m.runDuration[key] = h
+			}
+			h.observe(task.CompletedAt.Sub(*task.StartedAt).Seconds())
+		}
+	}
+}
+
+func (m *metrics) observeRequest(handler, method string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.httpRequests[[3]string{handler, method, strconv.Itoa(code)}]++
+	h := m.httpDuration[handler]
+	if h == nil {
+		h = newHistogram(httpDurationBuckets)
+		m.httpDuration[handler] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// metricsHandler labels a request path by the part of the server that
+// handles it, keeping label cardinality bounded.
+func metricsHandler(path string) string {
+	for _, handler := range []string{"/mcp", "/api", "/graphql", "/ui", "/health", "/metrics"} {
+		if path == handler || strings.HasPrefix(path, handler+"/") {
+			return strings.TrimPrefix(handler, "/")
+		}
+	}
+	return "other"
+}
+
+// statusRecorder captures the response status for the HTTP metrics. It
+// forwards Flush and Hijack so SSE and WebSocket handlers keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// metricsMiddleware counts requests and their durations.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		s.metrics.observeRequest(metricsHandler(r.URL.Path), r.Method, rec.status, time.Since(start))
+	})
+}
+
+// handleMetrics serves the metrics in the Prometheus text format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	stats := s.orchestrator.GetDetailedStats()
+	tasks, _ := s.orchestrator.ListTasks(models.ListRequest{})
+	byStatus := make(map[[2]string]int)
+	for _, task := range tasks {
+		byStatus[[2]string{string(task.Status), string(task.Engine)}]++
+	}
+
+	writeMetricHeader(w, "mesnada_build_info", "gauge", "Version and commit of the running server.")
+	fmt.Fprintf(w, "mesnada_build_info{version=%q,commit=%q} 1\n", s.version, s.commit)
+
+	writeMetricHeader(w, "mesnada_tasks", "gauge", "Tasks in the store by status and engine.")
+	for _, key := range sortedKeys(byStatus) {
+		fmt.Fprintf(w, "mesnada_tasks{status=%q,engine=%q} %d\n", key[0], key[1], byStatus[key])
+	}
+	writeMetricHeader(w, "mesnada_queue_depth", "gauge", "Pending tasks ready to start, waiting for a free slot.")
+	fmt.Fprintf(w, "mesnada_queue_depth %d\n", stats.QueueDepth)
+	writeMetricHeader(w, "mesnada_tasks_waiting_on_dependencies", "gauge", "Pending tasks whose dependencies have not completed.")
+	fmt.Fprintf(w, "mesnada_tasks_waiting_on_dependencies %d\n", stats.WaitingOnDependencies)
+	writeMetricHeader(w, "mesnada_max_parallel", "gauge", "Maximum number of tasks running at once.")
+	fmt.Fprintf(w, "mesnada_max_parallel %d\n", stats.MaxParallel)
+	writeMetricHeader(w, "mesnada_log_bytes_written_total", "counter", "Bytes of agent output written to task logs.")
+	fmt.Fprintf(w, "mesnada_log_bytes_written_total %d\n", agent.LogBytesWritten())
+
+	m := s.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeMetricHeader(w, "mesnada_tasks_spawned_total", "counter", "Tasks spawned by engine.")
+	for _, engine := range sortedKeys(m.spawned) {
+		fmt.Fprintf(w, "mesnada_tasks_spawned_total{engine=%q} %d\n", engine, m.spawned[engine])
+	}
+	writeMetricHeader(w, "mesnada_task_spawn_latency_seconds", "histogram", "Time from spawn until the agent process starts.")
+	for _, engine := range sortedKeys(m.spawnLatency) {
+		writeHistogram(w, "mesnada_task_spawn_latency_seconds", fmt.Sprintf("engine=%q", engine), m.spawnLatency[engine])
+	}
+	writeMetricHeader(w, "mesnada_task_run_duration_seconds", "histogram", "Time tasks ran, by engine and final status.")
+	for _, key := range sortedKeys(m.runDuration) {
+		writeHistogram(w, "mesnada_task_run_duration_seconds", fmt.Sprintf("engine=%q,status=%q", key[0], key[1]), m.runDuration[key])
+	}
+	writeMetricHeader(w, "mesnada_http_requests_total", "counter", "HTTP requests by handler, method and status code.")
+	for _, key := range sortedKeys(m.httpRequests) {
+		fmt.Fprintf(w, "mesnada_http_requests_total{handler=%q,method=%q,code=%q} %d\n", key[0], key[1], key[2], m.httpRequests[key])
+	}
+	writeMetricHeader(w, "mesnada_http_request_duration_seconds", "histogram", "HTTP request durations by handler.")
+	for _, handler := range sortedKeys(m.httpDuration) {
+		writeHistogram(w, "mesnada_http_request_duration_seconds", fmt.Sprintf("handler=%q", handler), m.httpDuration[handler])
+	}
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	for i, le := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// sortedKeys returns a map's keys in a stable order for the exposition.
+func sortedKeys[K string | [2]string | [3]string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineClaude})
+
+	created := time.Now().Add(-time.Minute)
+	started := created.Add(2 * time.Second)
+	completed := started.Add(40 * time.Second)
+	task := &models.Task{Engine: models.EngineGemini, Status: models.TaskStatusCompleted, CreatedAt: created, StartedAt: &started, CompletedAt: &completed}
+	srv.metrics.observeTask(orchestrator.TaskStarted, task)
+	srv.metrics.observeTask(orchestrator.TaskFinished, task)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	get("/api/tasks")
+	w := get("/metrics")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE mesnada_tasks gauge",
+		`mesnada_tasks{status="pending",engine="claude"} 1`,
+		`mesnada_tasks_spawned_total{engine="claude"} 1`,
+		"mesnada_tasks_waiting_on_dependencies 1",
+		`mesnada_task_spawn_latency_seconds_bucket{engine="gemini",le="1"} 0`,
+		`mesnada_task_spawn_latency_seconds_bucket{engine="gemini",le="5"} 1`,
+		`mesnada_task_run_duration_seconds_bucket{engine="gemini",status="completed",le="60"} 1`,
+		`mesnada_task_run_duration_seconds_sum{engine="gemini",status="completed"} 40`,
+		`mesnada_http_requests_total{handler="api",method="GET",code="200"} 1`,
+		`mesnada_http_request_duration_seconds_count{handler="api"} 1`,
+		"mesnada_log_bytes_written_total ",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in metrics:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+const (
+	// protectedResourcePath serves the RFC 9728 protected-resource metadata.
+	protectedResourcePath = "/.well-known/oauth-protected-resource"
+	// oauthKeyRefreshInterval limits JWKS refetches triggered by unknown key IDs.
+	oauthKeyRefreshInterval = time.Minute
+	// oauthClockSkew tolerates small clock differences with the issuer.
+	oauthClockSkew = 30 * time.Second
+)
+
+// tokenClaims are the parts of a validated access token mesnada uses.
+type tokenClaims struct {
+	Subject string
+	Scopes  []string
+}
+
+type tokenClaimsKey struct{}
+
+// oauthValidator validates JWT access tokens issued by the configured
+// authorization server, caching its signing keys.
+type oauthValidator struct {
+	cfg       config.OAuthConfig
+	client    *http.Client
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newOAuthValidator(cfg config.OAuthConfig) *oauthValidator {
+	return &oauthValidator{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// validateAccessToken validates an OAuth access token against the current
+// OAuth config. Validation is serialized since it may refresh the keys.
+func (s *Server) validateAccessToken(ctx context.Context, token string) (*tokenClaims, error) {
+	cfg := s.appConfig().Server.OAuth
+	s.oauthMu.Lock()
+	defer s.oauthMu.Unlock()
+	if s.oauthValidator == nil || !reflect.DeepEqual(s.oauthValidator.cfg, cfg) {
+		s.oauthValidator = newOAuthValidator(cfg)
+	}
+	return s.oauthValidator.validate(ctx, token)
+}
+
+// validate checks the token signature, issuer, audience and lifetime.
+func (v *oauthValidator) validate(ctx context.Context, token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	var claims struct {
+		Issuer    string          `json:"iss"`
+		Subject   string          `json:"sub"`
+		Audience  json.RawMessage `json:"aud"`
+		Expiry    float64         `json:"exp"`
+		NotBefore float64         `json:"nbf"`
+		Scope     string          `json:"scope"`
+		Scp       []string        `json:"scp"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding")
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if claims.Issuer != v.cfg.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if claims.Expiry == 0 || now.After(time.Unix(int64(claims.Expiry), 0).Add(oauthClockSkew)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(oauthClockSkew).Before(time.Unix(int64(claims.NotBefore), 0)) {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+	aud := v.cfg.RequiredAudience()
+	if aud == "" {
+		// Without one, tokens issued for any service of the issuer would pass.
+		return nil, fmt.Errorf("no oauth audience or resource configured")
+	}
+	if !audienceContains(claims.Audience, aud) {
+		return nil, fmt.Errorf("token audience does not include %q", aud)
+	}
+
+	scopes := claims.Scp
+	if claims.Scope != "" {
+		scopes = strings.Fields(claims.Scope)
+	}
+	return &tokenClaims{Subject: claims.Subject, Scopes: scopes}, nil
+}
+
+// key returns the signing key for kid, refetching the JWKS when the key is
+// unknown (at most once per oauthKeyRefreshInterval).
+func (v *oauthValidator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if k := v.lookupKey(kid); k != nil {
+		return k, nil
+	}
+	if v.keys != nil && time.Since(v.fetchedAt) < oauthKeyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := v.fetchKeys(ctx); err != nil {
+		return nil, err
+	}
+	if k := v.lookupKey(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (v *oauthValidator) lookupKey(kid string) crypto.PublicKey {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k
+		}
+	}
+	return v.keys[kid]
+}
+
+func (v *oauthValidator) fetchKeys(ctx context.Context) error {
+	v.fetchedAt = time.Now()
+
+	jwksURL := v.cfg.JWKSURL
+	if jwksURL == "" {
+		var err error
+		if jwksURL, err = v.discoverJWKSURL(ctx); err != nil {
+			return err
+		}
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &jwks); err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range jwks.Keys {
+		switch {
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	v.keys = keys
+	return nil
+}
+
+// discoverJWKSURL reads jwks_uri from the issuer's authorization server
+// metadata, falling back to OpenID Connect discovery.
+func (v *oauthValidator) discoverJWKSURL(ctx context.Context) (string, error) {
+	issuer := strings.TrimSuffix(v.cfg.Issuer, "/")
+	for _, path := range []string{"/.well-known/oauth-authorization-server", "/.well-known/openid-configuration"} {
+		var meta struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, issuer+path, &meta); err == nil && meta.JWKSURI != "" {
+			return meta.JWKSURI, nil
+		}
+	}
+	return "", fmt.Errorf("no jwks_uri found in metadata of issuer %s", v.cfg.Issuer)
+}
+
+func (v *oauthValidator) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// verifySignature supports RS256 and ES256, the algorithms MCP authorization
+// servers commonly issue.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return fmt.Errorf("invalid token signature")
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 ||
+			!ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return fmt.Errorf("invalid token signature")
+		}
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	return nil
+}
+
+func decodeSegment(seg string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// audienceContains accepts "aud" as a string or an array of strings.
+func audienceContains(raw json.RawMessage, want string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == want
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, aud := range many {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scopesAllow reports whether the OAuth token of the request grants a tool.
+// Requests without OAuth claims (no auth or a static token) may call any tool.
+func (s *Server) scopesAllow(ctx context.Context, name string) bool {
+	claims, ok := ctx.Value(tokenClaimsKey{}).(*tokenClaims)
+	if !ok {
+		return true
+	}
+	scopes := s.appConfig().Server.OAuth.Scopes
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range claims.Scopes {
+		if matchTool(scopes[scope], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleProtectedResource serves the RFC 9728 metadata that points MCP
+// clients at the authorization server.
+func (s *Server) handleProtectedResource(w http.ResponseWriter, r *http.Request) {
+	cfg := s.appConfig().Server.OAuth
+	if !cfg.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	scopes := make([]string, 0, len(cfg.Scopes))
+	for scope := range cfg.Scopes {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"resource":                 resourceURL(r, cfg),
+		"authorization_servers":    []string{cfg.Issuer},
+		"scopes_supported":         scopes,
+		"bearer_methods_supported": []string{"header"},
+		"resource_name":            "mesnada",
+	})
+}
+
+// resourceURL is the configured resource, or the /mcp URL the request came in on.
+func resourceURL(r *http.Request, cfg config.OAuthConfig) string {
+	if cfg.Resource != "" {
+		return cfg.Resource
+	}
+	return baseURL(r) + "/mcp"
+}
+
+// baseURL is the public URL of the server root, including server.base_path.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	} else if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + basePath(r.Context())
+}
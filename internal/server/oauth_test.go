@@ -0,0 +1,181 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+// testIssuer is an authorization server serving its metadata and JWKS.
+func testIssuer(t *testing.T) (*httptest.Server, func(claims map[string]interface{}) string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issuer *httptest.Server
+	issuer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/oauth-authorization-server":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.URL, "jwks_uri": issuer.URL + "/jwks"})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(issuer.Close)
+
+	sign := func(claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+		payload, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	return issuer, sign
+}
+
+func TestOAuth_ValidatesTokensAndScopes(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	issuer, sign := testIssuer(t)
+
+	cfg := config.DefaultConfig()
+	cfg.Server.OAuth = config.OAuthConfig{
+		Issuer:   issuer.URL,
+		Resource: "https://mesnada.example/mcp",
+		Scopes: map[string][]string{
+			"tasks:read":  {"get_task", "list_tasks"},
+			"tasks:admin": {"*"},
+		},
+	}
+	srv.ReloadConfig(cfg)
+
+	valid := map[string]interface{}{
+		"iss":   issuer.URL,
+		"aud":   []string{"https://mesnada.example/mcp"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "tasks:read",
+	}
+	post := func(token, body string) *httptest.ResponseRecorder {
+		headers := map[string]string{}
+		if token != "" {
+			headers["Authorization"] = "Bearer " + token
+		}
+		return postMCP(t, srv, "", body, headers)
+	}
+
+	w := post("", `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Header().Get("WWW-Authenticate"), `resource_metadata="http://example.com/.well-known/oauth-protected-resource"`) {
+		t.Fatalf("expected 401 pointing at the resource metadata, got %d %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	expired := map[string]interface{}{"iss": issuer.URL, "aud": "https://mesnada.example/mcp", "exp": time.Now().Add(-time.Hour).Unix()}
+	if w := post(sign(expired), `{"jsonrpc":"2.0","id":1,"method":"ping"}`); w.Code != http.StatusUnauthorized ||
+		!strings.Contains(w.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Fatalf("expected invalid_token for an expired token, got %d", w.Code)
+	}
+	wrongAud := map[string]interface{}{"iss": issuer.URL, "aud": "https://other.example", "exp": time.Now().Add(time.Hour).Unix()}
+	if w := post(sign(wrongAud), `{"jsonrpc":"2.0","id":1,"method":"ping"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a token for another audience, got %d", w.Code)
+	}
+
+	token := sign(valid)
+	w = post(token, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected tools/list to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	var list struct {
+		Result struct {
+			Tools []Tool `json:"tools"`
+		} `json:"result"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Result.Tools) != 2 {
+		t.Fatalf("expected only the tools of tasks:read, got %d", len(list.Result.Tools))
+	}
+
+	w = post(token, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"get_stats","arguments":{}}}`)
+	if !strings.Contains(w.Body.String(), "Tool not allowed by token scopes") {
+		t.Fatalf("expected scope error for get_stats, got %s", w.Body.String())
+	}
+	w = post(token, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"list_tasks","arguments":{}}}`)
+	if strings.Contains(w.Body.String(), `"error"`) {
+		t.Fatalf("expected list_tasks to be allowed, got %s", w.Body.String())
+	}
+
+	// On the REST API, token scopes map to read, spawn or admin.
+	rest := func(method, path string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	if got := rest("GET", "/api/tasks"); got != http.StatusForbidden {
+		t.Fatalf("expected a token without API scopes to be denied, got %d", got)
+	}
+	cfg.Server.OAuth.APIScopes = map[string]string{"tasks:read": config.ScopeRead}
+	srv.ReloadConfig(cfg)
+	if got := rest("GET", "/api/tasks"); got != http.StatusOK {
+		t.Fatalf("expected tasks:read to read tasks, got %d", got)
+	}
+	if got := rest("POST", "/api/tasks"); got != http.StatusForbidden {
+		t.Fatalf("expected tasks:read to be denied spawning, got %d", got)
+	}
+
+	// Without an audience or resource no token is accepted.
+	cfg.Server.OAuth.Resource = ""
+	srv.ReloadConfig(cfg)
+	if w := post(token, `{"jsonrpc":"2.0","id":5,"method":"ping"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a configured audience, got %d", w.Code)
+	}
+}
+
+func TestOAuth_ProtectedResourceMetadata(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	req := httptest.NewRequest("GET", protectedResourcePath, nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when OAuth is disabled, got %d", w.Code)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Server.OAuth = config.OAuthConfig{Issuer: "https://auth.example", Scopes: map[string][]string{"tasks:read": {"get_task"}}}
+	srv.ReloadConfig(cfg)
+
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	var meta map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &meta); err != nil {
+		t.Fatalf("parse metadata: %v (%s)", err, w.Body.String())
+	}
+	if meta["resource"] != "http://example.com/mcp" || meta["authorization_servers"].([]interface{})[0] != "https://auth.example" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+}
@@ -0,0 +1,778 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUIPage renders the OpenAPI document with Swagger UI from a CDN, like
+// the main UI loads htmx. The spec URL is relative so it works under
+// server.base_path.
+const swaggerUIPage = `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8" />
+<title>mesnada REST API</title>
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14/swagger-ui.css" />
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+</script>
+</body>
+</html>
+`
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func apiResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	response := map[string]interface{}{"description": description}
+	if schema != nil {
+		response["content"] = jsonContent(schema)
+	}
+	return response
+}
+
+func refSchema(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+var (
+	errorResponse = map[string]interface{}{"$ref": "#/components/responses/Error"}
+
+	taskIDParam = map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   stringSchema,
+	}
+
+	// statsSchema extends the get_stats properties with the breakdowns
+	// only /api/stats returns.
+	statsSchema = func() map[string]interface{} {
+		properties := map[string]interface{}{
+			"queue_depth":             integerSchema,
+			"waiting_on_dependencies": integerSchema,
+			"max_parallel":            integerSchema,
+			"tokens_in":               integerSchema,
+			"tokens_out":              integerSchema,
+			"cost_usd":                typeSchema("number"),
+			"engines": map[string]interface{}{
+				"type": "object",
+				"additionalProperties": objectSchema(map[string]interface{}{
+					"pending":    integerSchema,
+					"running":    integerSchema,
+					"completed":  integerSchema,
+					"failed":     integerSchema,
+					"tokens_in":  integerSchema,
+					"tokens_out": integerSchema,
+					"cost_usd":   typeSchema("number"),
+				}, "pending", "running", "completed", "failed"),
+			},
+		}
+		for name, schema := range statsProperties {
+			properties[name] = schema
+		}
+		return objectSchema(properties, "total", "pending", "running", "queue_depth", "engines", "cost_usd")
+	}()
+
+	statsHistorySchema = objectSchema(map[string]interface{}{
+		"days": arraySchema(objectSchema(map[string]interface{}{
+			"date":      stringSchema,
+			"created":   integerSchema,
+			"completed": integerSchema,
+			"failed":    integerSchema,
+			"cancelled": integerSchema,
+		}, "date", "created", "completed", "failed", "cancelled")),
+		"models": arraySchema(objectSchema(map[string]interface{}{
+			"engine":               stringSchema,
+			"model":                stringSchema,
+			"completed":            integerSchema,
+			"failed":               integerSchema,
+			"cancelled":            integerSchema,
+			"avg_duration_seconds": typeSchema("number"),
+			"tokens_in":            integerSchema,
+			"tokens_out":           integerSchema,
+			"cost_usd":             typeSchema("number"),
+		}, "engine", "model", "completed", "failed", "cancelled")),
+	}, "days", "models")
+
+	taskDiffSchema = objectSchema(map[string]interface{}{
+		"task_id": stringSchema,
+		"source":  map[string]interface{}{"type": "string", "enum": []string{"commit", "work_dir"}},
+		"base":    stringSchema,
+		"commit":  stringSchema,
+		"files": arraySchema(objectSchema(map[string]interface{}{
+			"path":      stringSchema,
+			"old_path":  stringSchema,
+			"status":    map[string]interface{}{"type": "string", "enum": []string{"added", "deleted", "renamed", "modified"}},
+			"additions": integerSchema,
+			"deletions": integerSchema,
+			"binary":    booleanSchema,
+			"patch":     stringSchema,
+			"truncated": booleanSchema,
+		}, "path", "status", "additions", "deletions", "patch")),
+		"additions": integerSchema,
+		"deletions": integerSchema,
+		"truncated": booleanSchema,
+	}, "task_id", "source", "files", "additions", "deletions")
+
+	timelineSchema = objectSchema(map[string]interface{}{
+		"from": stringSchema,
+		"to":   stringSchema,
+		"tasks": arraySchema(objectSchema(map[string]interface{}{
+			"id":             stringSchema,
+			"status":         refSchema("TaskStatus"),
+			"engine":         stringSchema,
+			"model":          stringSchema,
+			"tags":           arraySchema(stringSchema),
+			"prompt_excerpt": stringSchema,
+			"started_at":     stringSchema,
+			"completed_at":   stringSchema,
+		}, "id", "status", "engine", "tags", "prompt_excerpt", "started_at")),
+	}, "from", "to", "tasks")
+
+	artifactsSchema = objectSchema(map[string]interface{}{
+		"task_id": stringSchema,
+		"artifacts": arraySchema(objectSchema(map[string]interface{}{
+			"path":   stringSchema,
+			"status": stringSchema,
+			"size":   integerSchema,
+		}, "path", "status", "size")),
+		"truncated": booleanSchema,
+	}, "task_id", "artifacts", "truncated")
+
+	configSchema = objectSchema(map[string]interface{}{
+		"default_model": stringSchema,
+		"models":        arraySchema(objectSchema(map[string]interface{}{"id": stringSchema, "description": stringSchema}, "id")),
+		"engines":       typeSchema("object"),
+		"server":        typeSchema("object"),
+		"orchestrator":  typeSchema("object"),
+		"secrets":       typeSchema("object"),
+	}, "server", "orchestrator")
+
+	personaSchema = objectSchema(map[string]interface{}{
+		"name":         stringSchema,
+		"summary":      stringSchema,
+		"content":      stringSchema,
+		"placeholders": arraySchema(stringSchema),
+	}, "name", "summary", "content", "placeholders")
+
+	personaNameParam = map[string]interface{}{
+		"name":     "name",
+		"in":       "path",
+		"required": true,
+		"schema":   stringSchema,
+	}
+
+	bulkResultSchema = objectSchema(map[string]interface{}{
+		"results": arraySchema(objectSchema(map[string]interface{}{
+			"task_id": stringSchema,
+			"error":   stringSchema,
+		}, "task_id")),
+		"succeeded": integerSchema,
+		"failed":    integerSchema,
+	}, "results", "succeeded", "failed")
+)
+
+// bulkPath documents one of the /api/tasks/bulk endpoints. extra adds body
+// properties to task_ids.
+func bulkPath(summary, operationID string, extra map[string]interface{}) map[string]interface{} {
+	props := map[string]interface{}{"task_ids": arraySchema(stringSchema)}
+	for k, v := range extra {
+		props[k] = v
+	}
+	return map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary":     summary,
+			"description": "Applies the action to each task in task_ids (at most 500) and reports the outcome per task; one task failing does not stop the others.",
+			"operationId": operationID,
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(objectSchema(props, "task_ids")),
+			},
+			"responses": map[string]interface{}{
+				"200": apiResponse("Outcome per task", bulkResultSchema),
+				"400": errorResponse,
+			},
+		},
+	}
+}
+
+// openAPIPaths documents every /api route registered in newGinEngine.
+func openAPIPaths() map[string]interface{} {
+	return map[string]interface{}{
+		"/api/v1/version": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Server version and commit",
+				"operationId": "getVersion",
+				"responses": map[string]interface{}{
+					"200": apiResponse("Version", objectSchema(map[string]interface{}{
+						"version": stringSchema,
+						"commit":  stringSchema,
+					}, "version", "commit")),
+				},
+			},
+		},
+		"/api/v1/engines": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "List engines with availability and models",
+				"operationId": "listEngines",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "refresh", "in": "query", "description": "Probe the engine binaries again", "schema": booleanSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Engines", toolOutputSchemas["list_engines"]),
+				},
+			},
+		},
+		"/api/v1/config": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Configuration the server is running with",
+				"description": "The loaded config file with command-line overrides and the last SIGHUP reload applied. Bearer tokens, engine API keys and engine environment values are redacted.",
+				"operationId": "getConfig",
+				"responses": map[string]interface{}{
+					"200": apiResponse("Configuration", objectSchema(map[string]interface{}{"config": configSchema}, "config")),
+				},
+			},
+		},
+		"/api/v1/config/schema": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "JSON Schema of the config file",
+				"description": "For editors to validate and autocomplete YAML or JSON config files, e.g. with a `# yaml-language-server: $schema=` comment.",
+				"operationId": "getConfigSchema",
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "JSON Schema (draft 2020-12)",
+						"content":     map[string]interface{}{"application/schema+json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}},
+					},
+				},
+			},
+		},
+		"/api/v1/events": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Stream every task change as Server-Sent Events",
+				"description": "Events are named created, started, updated (progress and other changes), finished or " +
+					"deleted, with the task's state after the change as data. Event ids number the changes; " +
+					"reconnecting with Last-Event-ID replays the ones missed, or sends a resync event when they are " +
+					"no longer kept.",
+				"operationId": "streamTaskEvents",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "last_event_id", "in": "query", "description": "Id of the last event received", "schema": integerSchema},
+					map[string]interface{}{"name": "Last-Event-ID", "in": "header", "description": "Id of the last event received; overrides last_event_id", "schema": stringSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "Event stream",
+						"content":     map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": stringSchema}},
+					},
+					"400": errorResponse,
+				},
+			},
+		},
+		"/api/v1/personas": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "List personas with a one-line summary",
+				"operationId": "listPersonas",
+				"responses": map[string]interface{}{
+					"200": apiResponse("Personas", toolOutputSchemas["list_personas"]),
+				},
+			},
+		},
+		"/api/v1/personas/{name}": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Get a persona with its content and placeholders",
+				"operationId": "getPersona",
+				"parameters":  []interface{}{personaNameParam},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Persona", personaSchema),
+					"404": errorResponse,
+				},
+			},
+			"put": map[string]interface{}{
+				"summary":     "Create or replace a persona",
+				"description": "Writes the persona to persona_path. New names may use letters, digits, '.', '_' and '-'; the content must be non-empty, at most 256 KiB, and its {{placeholders}} well formed. Needs the admin scope.",
+				"operationId": "putPersona",
+				"parameters":  []interface{}{personaNameParam},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content":  jsonContent(objectSchema(map[string]interface{}{"content": stringSchema}, "content")),
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Updated persona", personaSchema),
+					"201": apiResponse("Created persona", personaSchema),
+					"400": errorResponse,
+				},
+			},
+			"delete": map[string]interface{}{
+				"summary":     "Delete a persona and its file",
+				"operationId": "deletePersona",
+				"parameters":  []interface{}{personaNameParam},
+				"responses": map[string]interface{}{
+					"204": apiResponse("Deleted", nil),
+					"404": errorResponse,
+				},
+			},
+		},
+		"/api/v1/stats": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Task stats with queue depth, per-engine counts and cost totals",
+				"operationId": "getStats",
+				"responses": map[string]interface{}{
+					"200": apiResponse("Stats", statsSchema),
+				},
+			},
+		},
+		"/api/v1/stats/history": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Tasks per day and outcomes per engine and model",
+				"description": "Counts tasks created and finished on each of the last days (server time zone), and the outcomes, average run time and usage of the tasks that finished in that range by engine and model.",
+				"operationId": "getStatsHistory",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "days", "in": "query", "description": "Days to cover, including today (1-365, default 14)", "schema": integerSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("History", statsHistorySchema),
+					"400": errorResponse,
+					"500": errorResponse,
+				},
+			},
+		},
+		"/api/v1/stats/timeline": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Task runs in the last hours",
+				"description": "Lists the tasks that ran at some point in the range, ordered by start time, with their start and, once they stopped, completion time.",
+				"operationId": "getStatsTimeline",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "hours", "in": "query", "description": "Hours to cover up to now (1-720, default 24)", "schema": integerSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Timeline", timelineSchema),
+					"400": errorResponse,
+					"500": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "List tasks, newest first",
+				"operationId": "listTasks",
+				"parameters": []interface{}{
+					map[string]interface{}{
+						"name":        "status",
+						"in":          "query",
+						"description": "Statuses to include, repeated or comma-separated",
+						"schema":      arraySchema(refSchema("TaskStatus")),
+						"explode":     true,
+					},
+					map[string]interface{}{"name": "limit", "in": "query", "description": "Page size; all tasks when omitted", "schema": integerSchema},
+					map[string]interface{}{"name": "cursor", "in": "query", "description": "next_cursor from the previous page", "schema": stringSchema},
+					map[string]interface{}{"name": "offset", "in": "query", "description": "Tasks to skip, instead of a cursor", "schema": integerSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Tasks", objectSchema(map[string]interface{}{
+						"tasks":       arraySchema(refSchema("TaskItem")),
+						"total":       integerSchema,
+						"next_cursor": stringSchema,
+					}, "tasks", "total")),
+					"400": errorResponse,
+				},
+			},
+			"post": map[string]interface{}{
+				"summary":     "Spawn a task",
+				"description": "Takes the spawn_agent arguments; the task always runs in the background.",
+				"operationId": "spawnTask",
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content":  jsonContent(refSchema("SpawnRequest")),
+				},
+				"responses": map[string]interface{}{
+					"201": apiResponse("Spawned task", objectSchema(map[string]interface{}{"task": refSchema("Task")}, "task")),
+					"400": errorResponse,
+				},
+			},
+			"delete": map[string]interface{}{
+				"summary":     "Delete terminal tasks in bulk",
+				"description": "Deletes completed, failed, cancelled or paused tasks matching the filters. At least one of status and before is required; pending and running tasks are never deleted.",
+				"operationId": "deleteTasks",
+				"parameters": []interface{}{
+					map[string]interface{}{
+						"name":        "status",
+						"in":          "query",
+						"description": "Statuses to delete, repeated or comma-separated. Defaults to completed, failed and cancelled",
+						"schema":      arraySchema(refSchema("TaskStatus")),
+						"explode":     true,
+					},
+					map[string]interface{}{"name": "before", "in": "query", "description": "Only tasks created before this time (RFC3339 or YYYY-MM-DD)", "schema": stringSchema},
+					map[string]interface{}{"name": "purge", "in": "query", "description": "Also remove the tasks' log files", "schema": booleanSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Number of tasks deleted", objectSchema(map[string]interface{}{
+						"deleted": integerSchema,
+					}, "deleted")),
+					"400": errorResponse,
+					"500": errorResponse,
+				},
+			},
+		},
+		"/api/v1/graph": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Dependency graph of a task, a tag or all tasks",
+				"operationId": "getTaskGraph",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "task_id", "in": "query", "schema": stringSchema},
+					map[string]interface{}{"name": "tag", "in": "query", "schema": stringSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Graph", toolOutputSchemas["get_task_graph"]),
+					"404": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/search": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Search tasks",
+				"description": "Runs the search_tasks MCP tool: q matches tasks containing every word in their ID, prompt, summary, error, result, work dir, tags or metadata.",
+				"operationId": "searchTasks",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "q", "in": "query", "description": "Words the task must contain", "schema": stringSchema},
+					map[string]interface{}{
+						"name":        "status",
+						"in":          "query",
+						"description": "Statuses to include, repeated or comma-separated",
+						"schema":      arraySchema(refSchema("TaskStatus")),
+						"explode":     true,
+					},
+					map[string]interface{}{"name": "tag", "in": "query", "description": "Tags the task must have, repeated", "schema": arraySchema(stringSchema), "explode": true},
+					map[string]interface{}{"name": "engine", "in": "query", "description": "Engine, as spawn_agent names it", "schema": stringSchema},
+					map[string]interface{}{"name": "model", "in": "query", "schema": stringSchema},
+					map[string]interface{}{"name": "created_after", "in": "query", "description": "RFC3339 time or YYYY-MM-DD", "schema": stringSchema},
+					map[string]interface{}{"name": "created_before", "in": "query", "description": "RFC3339 time or YYYY-MM-DD", "schema": stringSchema},
+					map[string]interface{}{"name": "sort", "in": "query", "description": "created_at, started_at or completed_at, prefixed with - for descending. Defaults to -created_at", "schema": stringSchema},
+					map[string]interface{}{"name": "limit", "in": "query", "schema": map[string]interface{}{"type": "integer", "default": 20}},
+					map[string]interface{}{"name": "offset", "in": "query", "schema": integerSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Matching task summaries", toolOutputSchemas["search_tasks"]),
+					"400": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/facets": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Engines, models and tags in use, with task counts",
+				"operationId": "getTaskFacets",
+				"responses": map[string]interface{}{
+					"200": apiResponse("Facets", func() map[string]interface{} {
+						facets := arraySchema(objectSchema(map[string]interface{}{
+							"name":  stringSchema,
+							"count": integerSchema,
+						}, "name", "count"))
+						return objectSchema(map[string]interface{}{
+							"engines": facets,
+							"models":  facets,
+							"tags":    facets,
+						}, "engines", "models", "tags")
+					}()),
+					"500": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/log": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Read a chunk of a task log",
+				"operationId": "getTaskLog",
+				"parameters": []interface{}{
+					taskIDParam,
+					map[string]interface{}{"name": "offset", "in": "query", "description": "Byte offset to read from", "schema": integerSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Log chunk", objectSchema(map[string]interface{}{
+						"content":     stringSchema,
+						"next_offset": integerSchema,
+						"truncated":   booleanSchema,
+					}, "content", "next_offset", "truncated")),
+					"400": errorResponse,
+					"404": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/log/ws": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Stream a task log over a WebSocket",
+				"description": "Upgrades to a WebSocket that sends JSON messages: {type: \"log\", content, offset, truncated} " +
+					"with the existing log (from offset, or its last 1MB) and then new output, and a final {type: \"end\", status} " +
+					"when the task finishes.",
+				"operationId": "streamTaskLog",
+				"parameters": []interface{}{
+					taskIDParam,
+					map[string]interface{}{"name": "offset", "in": "query", "description": "Byte offset to start from", "schema": integerSchema},
+				},
+				"responses": map[string]interface{}{
+					"101": apiResponse("Switching to the WebSocket protocol", nil),
+					"400": errorResponse,
+					"404": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/log/download": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Download the full log file of a task",
+				"description": "The whole log, the rotated segment first when max_log_size rotated it, as an attachment named <task_id>.log. Logs compressed by compress_logs are sent gzipped as <task_id>.log.gz.",
+				"operationId": "downloadTaskLog",
+				"parameters":  []interface{}{taskIDParam},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "Log file",
+						"content": map[string]interface{}{
+							"text/plain":       map[string]interface{}{"schema": stringSchema},
+							"application/gzip": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+						},
+					},
+					"404": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/log/sse": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary": "Stream a task log as Server-Sent Events",
+				"description": "Same messages as /log/ws, as \"log\" and \"end\" events whose id is the log offset after " +
+					"the event. Reconnecting with Last-Event-ID resumes from there.",
+				"operationId": "streamTaskLogSSE",
+				"parameters": []interface{}{
+					taskIDParam,
+					map[string]interface{}{"name": "offset", "in": "query", "description": "Byte offset to start from", "schema": integerSchema},
+					map[string]interface{}{"name": "Last-Event-ID", "in": "header", "description": "Offset to resume from; overrides offset", "schema": stringSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "Event stream",
+						"content":     map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": stringSchema}},
+					},
+					"400": errorResponse,
+					"404": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/pause": map[string]interface{}{
+			"post": map[string]interface{}{
+				"summary":     "Pause a running task",
+				"operationId": "pauseTask",
+				"parameters":  []interface{}{taskIDParam},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Paused task", objectSchema(map[string]interface{}{"task": refSchema("Task")}, "task")),
+					"404": errorResponse,
+					"409": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/resume": map[string]interface{}{
+			"post": map[string]interface{}{
+				"summary":     "Resume a paused or finished task as a new task",
+				"operationId": "resumeTask",
+				"parameters":  []interface{}{taskIDParam},
+				"requestBody": map[string]interface{}{
+					"content": jsonContent(objectSchema(map[string]interface{}{
+						"prompt":     stringSchema,
+						"model":      stringSchema,
+						"background": booleanSchema,
+						"timeout":    stringSchema,
+						"tags":       arraySchema(stringSchema),
+					})),
+				},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Resumed task", objectSchema(map[string]interface{}{"task": refSchema("Task")}, "task")),
+					"400": errorResponse,
+					"404": errorResponse,
+					"409": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/bulk/cancel": bulkPath("Cancel several pending or running tasks", "bulkCancelTasks", nil),
+		"/api/v1/tasks/bulk/delete": bulkPath("Delete several tasks", "bulkDeleteTasks", nil),
+		"/api/v1/tasks/bulk/purge":  bulkPath("Delete several tasks and their logs", "bulkPurgeTasks", nil),
+		"/api/v1/tasks/bulk/tag": bulkPath("Add and remove tags on several pending or running tasks", "bulkTagTasks", map[string]interface{}{
+			"add":    arraySchema(stringSchema),
+			"remove": arraySchema(stringSchema),
+		}),
+		"/api/v1/tasks/{id}/diff": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Changes a task made to its git work dir",
+				"description": "Shows the task's auto-commit when it has one. Otherwise compares the work dir, untracked files included, with the HEAD recorded when the task started, so later edits are included too.",
+				"operationId": "getTaskDiff",
+				"parameters":  []interface{}{taskIDParam},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Diff split by file", taskDiffSchema),
+					"404": errorResponse,
+					"409": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/artifacts": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Files a task added or changed in its git work dir",
+				"description": "Taken from the task's auto-commit when it has one, otherwise from the work dir compared with the HEAD recorded when the task started. Deleted files, symlinks and submodules are left out; at most 1000 files are listed.",
+				"operationId": "listTaskArtifacts",
+				"parameters":  []interface{}{taskIDParam},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Artifacts", artifactsSchema),
+					"404": errorResponse,
+					"409": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/artifacts/{path}": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Download an artifact of a task",
+				"description": "Sends the file as the task left it, as an attachment, with a content type from its extension or content. Files over 64 MiB are refused.",
+				"operationId": "getTaskArtifact",
+				"parameters": []interface{}{
+					taskIDParam,
+					map[string]interface{}{"name": "path", "in": "path", "required": true, "description": "Path within the work dir, slashes included", "schema": stringSchema},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "File content",
+						"content": map[string]interface{}{
+							"application/octet-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+						},
+					},
+					"404": errorResponse,
+					"409": errorResponse,
+					"413": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/cancel": map[string]interface{}{
+			"post": map[string]interface{}{
+				"summary":     "Cancel a pending or running task",
+				"operationId": "cancelTask",
+				"parameters":  []interface{}{taskIDParam},
+				"responses": map[string]interface{}{
+					"200": apiResponse("Cancelled task", objectSchema(map[string]interface{}{"task": refSchema("Task")}, "task")),
+					"404": errorResponse,
+					"409": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/retry": map[string]interface{}{
+			"post": map[string]interface{}{
+				"summary":     "Retry a failed or cancelled task as a new task",
+				"description": "Runs retry_task in the background. The body is optional; include_error defaults to true.",
+				"operationId": "retryTask",
+				"parameters":  []interface{}{taskIDParam},
+				"requestBody": map[string]interface{}{
+					"content": jsonContent(objectSchema(map[string]interface{}{
+						"prompt_addendum": stringSchema,
+						"include_error":   booleanSchema,
+					})),
+				},
+				"responses": map[string]interface{}{
+					"201": apiResponse("New task", objectSchema(map[string]interface{}{"task": refSchema("Task")}, "task")),
+					"400": errorResponse,
+					"404": errorResponse,
+					"409": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}": map[string]interface{}{
+			"delete": map[string]interface{}{
+				"summary":     "Delete a finished task",
+				"operationId": "deleteTask",
+				"parameters":  []interface{}{taskIDParam},
+				"responses": map[string]interface{}{
+					"204": apiResponse("Deleted", nil),
+					"404": errorResponse,
+					"409": errorResponse,
+				},
+			},
+		},
+		"/api/v1/tasks/{id}/purge": map[string]interface{}{
+			"delete": map[string]interface{}{
+				"summary":     "Delete a finished task and its log file",
+				"operationId": "purgeTask",
+				"parameters":  []interface{}{taskIDParam},
+				"responses": map[string]interface{}{
+					"204": apiResponse("Purged", nil),
+					"500": errorResponse,
+				},
+			},
+		},
+	}
+}
+
+// openAPIDocument builds the OpenAPI 3 description of the REST API served
+// under prefix.
+func (s *Server) openAPIDocument(prefix string) map[string]interface{} {
+	server := prefix
+	if server == "" {
+		server = "/"
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "mesnada REST API",
+			"description": "Task management API used by the mesnada web UI. Every path is also served without the /v1 version segment, under /api, for clients written before the API was versioned.",
+			"version":     s.version,
+		},
+		"servers": []interface{}{map[string]interface{}{"url": server}},
+		"paths":   openAPIPaths(),
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Task": taskSchema,
+				"TaskStatus": map[string]interface{}{
+					"type": "string",
+					"enum": []string{"pending", "running", "paused", "completed", "failed", "cancelled"},
+				},
+				"TaskItem": objectSchema(map[string]interface{}{
+					"id":             stringSchema,
+					"status":         refSchema("TaskStatus"),
+					"prompt_excerpt": stringSchema,
+					"log_file":       stringSchema,
+					"created_at":     map[string]interface{}{"type": "string", "format": "date-time"},
+				}, "id", "status", "prompt_excerpt", "log_file", "created_at"),
+				"Error":        objectSchema(map[string]interface{}{"error": stringSchema}, "error"),
+				"SpawnRequest": s.toolInputSchema("spawn_agent"),
+			},
+			"responses": map[string]interface{}{
+				"Error": apiResponse("Error", refSchema("Error")),
+			},
+		},
+	}
+
+	cfg := s.appConfig().Server
+	if len(cfg.Tokens()) > 0 || cfg.OAuth.Enabled() {
+		components := doc["components"].(map[string]interface{})
+		components["securitySchemes"] = map[string]interface{}{
+			"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+		}
+		doc["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	return doc
+}
+
+// toolInputSchema returns the input schema of an MCP tool, so REST routes
+// taking the same arguments document them once.
+func (s *Server) toolInputSchema(name string) map[string]interface{} {
+	for _, tool := range s.getToolDefinitions() {
+		if tool.Name == name {
+			return tool.InputSchema
+		}
+	}
+	return typeSchema("object")
+}
+
+func (s *Server) handleAPIOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, s.openAPIDocument(basePath(c.Request.Context())))
+}
+
+func handleAPIDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
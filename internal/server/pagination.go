@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// encodeTaskCursor marks the last task of a page in the newest-first order
+// of /api/tasks. It encodes the task's creation time and ID rather than an
+// offset, so pages don't shift when tasks are created while paging.
+func encodeTaskCursor(task *models.Task) string {
+	raw := strconv.FormatInt(task.CreatedAt.UnixNano(), 10) + ":" + task.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTaskCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", &apiError{msg: "invalid cursor"}
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if !ok || err != nil || id == "" {
+		return time.Time{}, "", &apiError{msg: "invalid cursor"}
+	}
+	return time.Unix(0, n), id, nil
+}
+
+// tasksAfterCursor drops the tasks up to and including the cursor position
+// from a newest-first list.
+func tasksAfterCursor(tasks []*models.Task, cursor string) ([]*models.Task, error) {
+	createdAt, id, err := decodeTaskCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	for i, task := range tasks {
+		if task.CreatedAt.Before(createdAt) || (task.CreatedAt.Equal(createdAt) && task.ID > id) {
+			return tasks[i:], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	// progressPollInterval is how often a blocking tool call checks its task.
+	progressPollInterval = time.Second
+	// progressHeartbeatInterval is the minimum gap between output heartbeats
+	// when the task has not reported progress itself.
+	progressHeartbeatInterval = 10 * time.Second
+)
+
+type progressTokenKey struct{}
+
+// withProgressToken attaches the progressToken a client sent in a request's
+// _meta, if any.
+func withProgressToken(ctx context.Context, token interface{}) context.Context {
+	if token == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressTokenKey{}, token)
+}
+
+// waitTask waits for a task like Orchestrator.Wait, sending
+// notifications/progress to the client while it blocks when the tool call
+// asked for progress.
+func (s *Server) waitTask(ctx context.Context, taskID string, timeout time.Duration) (*models.Task, error) {
+	token := ctx.Value(progressTokenKey{})
+	if token == nil {
+		return s.orchestrator.Wait(ctx, taskID, timeout)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.reportProgress(ctx, token, taskID, done)
+
+	return s.orchestrator.Wait(ctx, taskID, timeout)
+}
+
+// reportProgress sends a notification whenever the task reports progress
+// (set_progress) and a heartbeat when its output grows, until done closes.
+// progress is the reported percentage, so it never decreases.
+func (s *Server) reportProgress(ctx context.Context, token interface{}, taskID string, done <-chan struct{}) {
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+
+	var (
+		lastUpdate    time.Time
+		lastLogSize   int64
+		lastHeartbeat time.Time
+		percentage    int
+	)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		task, err := s.orchestrator.GetTask(taskID)
+		if err != nil {
+			return
+		}
+
+		var message string
+		if p := task.Progress; p != nil && p.UpdatedAt.After(lastUpdate) {
+			lastUpdate = p.UpdatedAt
+			if p.Percentage > percentage {
+				percentage = p.Percentage
+			}
+			message = p.Description
+		} else if size := logSize(task.LogFile); size > lastLogSize && time.Since(lastHeartbeat) >= progressHeartbeatInterval {
+			lastLogSize = size
+			message = fmt.Sprintf("%s: %s, %d bytes of output", task.ID, task.Status, size)
+		} else {
+			continue
+		}
+		lastHeartbeat = time.Now()
+
+		sendNotification(ctx, "notifications/progress", map[string]interface{}{
+			"progressToken": token,
+			"progress":      percentage,
+			"total":         100,
+			"message":       message,
+		})
+	}
+}
+
+// logSize returns the current size of a task log, or 0 when it is unavailable.
+func logSize(path string) int64 {
+	if path == "" {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
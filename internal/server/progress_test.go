@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestWaitTask_SendsProgressNotifications(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task, err := srv.orchestrator.Spawn(context.Background(), models.SpawnRequest{Prompt: "p", WorkDir: "/tmp", Background: true, Dependencies: []string{"missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+	write := func(msg interface{}) error {
+		data, _ := json.Marshal(msg)
+		mu.Lock()
+		messages = append(messages, string(data))
+		mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = withRequestStream(ctx, newSession("progress"), write)
+
+	resp := make(chan *JSONRPCResponse, 1)
+	go func() {
+		resp <- srv.handleToolsCall(ctx, &JSONRPCRequest{
+			JSONRPC: "2.0",
+			ID:      1,
+			Method:  "tools/call",
+			Params:  json.RawMessage(`{"name":"wait_task","arguments":{"task_id":"` + task.ID + `"},"_meta":{"progressToken":"tok-1"}}`),
+		})
+	}()
+
+	if err := srv.orchestrator.SetProgress(task.ID, 40, "halfway there"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		mu.Lock()
+		got := strings.Join(messages, "\n")
+		mu.Unlock()
+		if strings.Contains(got, `"method":"notifications/progress"`) {
+			if !strings.Contains(got, `"progressToken":"tok-1"`) || !strings.Contains(got, `"progress":40`) || !strings.Contains(got, "halfway there") {
+				t.Fatalf("unexpected progress notification %s", got)
+			}
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for notifications/progress")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case r := <-resp:
+		if r.Error != nil {
+			t.Fatalf("wait_task: %+v", r.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait_task did not return when the request ended")
+	}
+}
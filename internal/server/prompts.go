@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/sevir/mesnada/internal/persona"
+)
+
+// promptTaskArgument is the optional prompt argument appended to the persona
+// the same way spawn_agent applies a persona to a task prompt.
+const promptTaskArgument = "task"
+
+// promptArguments describes the arguments of a persona prompt: one required
+// argument per {{placeholder}} plus the optional task.
+func promptArguments(content string) []map[string]interface{} {
+	args := []map[string]interface{}{}
+	hasTask := false
+	for _, name := range persona.Placeholders(content) {
+		if name == promptTaskArgument {
+			hasTask = true
+		}
+		args = append(args, map[string]interface{}{
+			"name":        name,
+			"description": fmt.Sprintf("Value for {{%s}}", name),
+			"required":    true,
+		})
+	}
+	if !hasTask {
+		args = append(args, map[string]interface{}{
+			"name":        promptTaskArgument,
+			"description": "Task to append after the persona instructions",
+			"required":    false,
+		})
+	}
+	return args
+}
+
+func (s *Server) handlePromptsList(req *JSONRPCRequest) *JSONRPCResponse {
+	names := s.orchestrator.ListPersonas()
+	sort.Strings(names)
+
+	prompts := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		content := s.orchestrator.GetPersona(name)
+		prompts = append(prompts, map[string]interface{}{
+			"name":        name,
+			"description": persona.Summary(content),
+			"arguments":   promptArguments(content),
+		})
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		ID:      req.ID,
+		Result:  map[string]interface{}{"prompts": prompts},
+	}
+}
+
+func (s *Server) handlePromptsGet(req *JSONRPCRequest) *JSONRPCResponse {
+	var params struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return rpcError(req.ID, -32602, "Invalid params", err.Error())
+	}
+
+	content := s.orchestrator.GetPersona(params.Name)
+	if content == "" {
+		return rpcError(req.ID, -32602, "Invalid params", fmt.Sprintf("unknown prompt: %s", params.Name))
+	}
+
+	placeholders := persona.Placeholders(content)
+	for _, name := range placeholders {
+		if _, ok := params.Arguments[name]; !ok {
+			return rpcError(req.ID, -32602, "Invalid params", fmt.Sprintf("missing required argument: %s", name))
+		}
+	}
+
+	text := persona.Render(content, params.Arguments)
+	if task := params.Arguments[promptTaskArgument]; task != "" && !contains(placeholders, promptTaskArgument) {
+		text += "\n\n" + task
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		ID:      req.ID,
+		Result: map[string]interface{}{
+			"description": persona.Summary(content),
+			"messages": []map[string]interface{}{
+				{
+					"role": "user",
+					"content": map[string]interface{}{
+						"type": "text",
+						"text": text,
+					},
+				},
+			},
+		},
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
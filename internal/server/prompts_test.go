@@ -0,0 +1,82 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/orchestrator"
+)
+
+func setupPromptServer(t *testing.T) *Server {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "mesnada-prompts-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	personas := filepath.Join(dir, "personas")
+	if err := os.MkdirAll(personas, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(personas, "reviewer.md"), []byte("# Code reviewer\n\nReview {{ language }} code."), 0644)
+	os.WriteFile(filepath.Join(personas, "helper.md"), []byte("You are helpful."), 0644)
+
+	orch, err := orchestrator.New(orchestrator.Config{
+		StorePath:   filepath.Join(dir, "tasks.json"),
+		LogDir:      filepath.Join(dir, "logs"),
+		MaxParallel: 1,
+		PersonaPath: personas,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		orch.Shutdown()
+		os.RemoveAll(dir)
+	})
+	return New(Config{Addr: ":0", Orchestrator: orch})
+}
+
+func TestPrompts_ListPersonas(t *testing.T) {
+	srv := setupPromptServer(t)
+
+	resp := callMethod(t, srv, newSession("p"), "prompts/list", map[string]string{})
+	prompts := resp.Result.(map[string]interface{})["prompts"].([]map[string]interface{})
+	if len(prompts) != 2 || prompts[0]["name"] != "helper" || prompts[1]["name"] != "reviewer" {
+		t.Fatalf("unexpected prompts %+v", prompts)
+	}
+	if prompts[1]["description"] != "Code reviewer" {
+		t.Fatalf("expected heading as description, got %v", prompts[1]["description"])
+	}
+	args := prompts[1]["arguments"].([]map[string]interface{})
+	if len(args) != 2 || args[0]["name"] != "language" || args[0]["required"] != true || args[1]["name"] != "task" {
+		t.Fatalf("unexpected arguments %+v", args)
+	}
+}
+
+func TestPrompts_GetSubstitutesArguments(t *testing.T) {
+	srv := setupPromptServer(t)
+	session := newSession("p")
+
+	resp := callMethod(t, srv, session, "prompts/get", map[string]interface{}{
+		"name":      "reviewer",
+		"arguments": map[string]string{"language": "Go", "task": "Review main.go"},
+	})
+	if resp.Error != nil {
+		t.Fatalf("prompts/get: %+v", resp.Error)
+	}
+	messages := resp.Result.(map[string]interface{})["messages"].([]map[string]interface{})
+	text := messages[0]["content"].(map[string]interface{})["text"]
+	if want := "# Code reviewer\n\nReview Go code.\n\nReview main.go"; text != want {
+		t.Fatalf("expected %q, got %q", want, text)
+	}
+
+	resp = callMethod(t, srv, session, "prompts/get", map[string]interface{}{"name": "reviewer"})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("expected missing argument error, got %+v", resp)
+	}
+	resp = callMethod(t, srv, session, "prompts/get", map[string]interface{}{"name": "nope"})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("expected unknown prompt error, got %+v", resp)
+	}
+}
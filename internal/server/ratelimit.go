@@ -0,0 +1,156 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+const (
+	// rateLimitIdle is how long an unused client bucket is kept.
+	rateLimitIdle = 10 * time.Minute
+	// rateLimitMaxBuckets bounds the buckets kept at once; the least recently
+	// used one makes room for a new client.
+	rateLimitMaxBuckets = 10000
+)
+
+// rateLimiter is a token bucket per client key.
+type rateLimiter struct {
+	cfg       config.RateLimitConfig
+	perSecond float64
+	burst     float64
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastPrune time.Time
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(cfg config.RateLimitConfig) *rateLimiter {
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = cfg.RequestsPerMinute
+	}
+	return &rateLimiter{
+		cfg:       cfg,
+		perSecond: float64(cfg.RequestsPerMinute) / 60,
+		burst:     float64(burst),
+		buckets:   make(map[string]*rateBucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// allow takes a token from the client's bucket. When it is empty, it returns
+// false and how long until the next token.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > rateLimitIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimitMaxBuckets {
+			l.evictOldest()
+		}
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second))
+}
+
+// evictOldest drops the least recently used bucket.
+func (l *rateLimiter) evictOldest() {
+	var oldest string
+	var oldestLast time.Time
+	for k, b := range l.buckets {
+		if oldest == "" || b.last.Before(oldestLast) {
+			oldest, oldestLast = k, b.last
+		}
+	}
+	delete(l.buckets, oldest)
+}
+
+// rateLimitMiddleware answers 429 with Retry-After once a client exceeds
+// server.rate_limit on the MCP or REST endpoints.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := s.rateLimiter()
+		if limiter == nil || r.Method == http.MethodOptions ||
+			!(strings.HasPrefix(r.URL.Path, "/mcp") || strings.HasPrefix(r.URL.Path, "/api")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := rateLimitKey(r, limiter.cfg.By, s.appConfig().Server.Tokens())
+		if ok, wait := limiter.allow(key, time.Now()); !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			log.Printf("mcp_event=rate_limited path=%s client=%s retry_after=%d", r.URL.Path, key, retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter returns the limiter for the current config, or nil when
+// rate limiting is disabled.
+func (s *Server) rateLimiter() *rateLimiter {
+	cfg := s.appConfig().Server.RateLimit
+	if cfg.RequestsPerMinute <= 0 {
+		return nil
+	}
+	s.limiterMu.Lock()
+	defer s.limiterMu.Unlock()
+	if s.limiter == nil || s.limiter.cfg != cfg {
+		s.limiter = newRateLimiter(cfg)
+	}
+	return s.limiter
+}
+
+// rateLimitKey identifies the client by a hash of its token or by its IP.
+// Only configured tokens count, since the limiter runs before auth and a
+// client could otherwise get a fresh bucket with every made-up token; other
+// requests, OAuth ones included, are keyed by IP. Tokens are hashed so they
+// never appear in logs.
+func rateLimitKey(r *http.Request, by string, tokens []string) string {
+	if by == "token" {
+		if token := requestToken(r); validToken(tokens, token) {
+			sum := sha256.Sum256([]byte(token))
+			return "token:" + hex.EncodeToString(sum[:8])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+func TestRateLimiter_Refills(t *testing.T) {
+	l := newRateLimiter(config.RateLimitConfig{RequestsPerMinute: 60, Burst: 2})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d within burst was limited", i)
+		}
+	}
+	ok, wait := l.allow("a", now)
+	if ok || wait <= 0 || wait > time.Second {
+		t.Fatalf("expected limit with ~1s wait, got ok=%t wait=%s", ok, wait)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Fatal("expected other clients to have their own bucket")
+	}
+	if ok, _ := l.allow("a", now.Add(time.Second)); !ok {
+		t.Fatal("expected a token after one second at 60/min")
+	}
+}
+
+func TestRateLimiter_CapsBuckets(t *testing.T) {
+	l := newRateLimiter(config.RateLimitConfig{RequestsPerMinute: 60, Burst: 1})
+	now := time.Now()
+
+	l.allow("first", now)
+	for i := 0; i < rateLimitMaxBuckets; i++ {
+		l.allow(strconv.Itoa(i), now.Add(time.Millisecond))
+	}
+	if len(l.buckets) != rateLimitMaxBuckets {
+		t.Fatalf("expected at most %d buckets, got %d", rateLimitMaxBuckets, len(l.buckets))
+	}
+	if _, ok := l.buckets["first"]; ok {
+		t.Fatal("expected the least recently used bucket to be evicted")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.AuthTokens = []string{"t1", "t2"}
+	cfg.Server.RateLimit = config.RateLimitConfig{RequestsPerMinute: 1, By: "token"}
+	srv.ReloadConfig(cfg)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/api/tasks", "t1"); w.Code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", w.Code)
+	}
+	w := get("/api/tasks", "t1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", w.Code)
+	}
+	if w := get("/api/tasks", "t2"); w.Code != http.StatusOK {
+		t.Fatalf("expected a different token to have its own limit, got %d", w.Code)
+	}
+	if w := get("/health", "t1"); w.Code != http.StatusOK {
+		t.Fatalf("expected /health not to be limited, got %d", w.Code)
+	}
+
+	// Unknown tokens share the client's IP bucket instead of getting their own.
+	if w := get("/api/tasks", "fake-1"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the first unknown token to reach auth, got %d", w.Code)
+	}
+	if w := get("/api/tasks", "fake-2"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a new unknown token to stay limited, got %d", w.Code)
+	}
+}
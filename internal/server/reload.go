@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// appConfig returns the current application config.
+func (s *Server) appConfig() *config.Config {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config
+}
+
+// ReloadConfig swaps in a reloaded config. The model lists and secret names
+// advertised by the tools and the accepted auth tokens follow the new config;
+// connected clients are told to refresh their tool list when it changed.
+func (s *Server) ReloadConfig(cfg *config.Config) {
+	before := s.toolDefinitionsJSON()
+	s.configMu.Lock()
+	s.config = cfg
+	s.configMu.Unlock()
+
+	if s.toolDefinitionsJSON() != before {
+		log.Printf("mcp_event=tools_changed reason=config_reload")
+		s.notifyToolsListChanged()
+	}
+}
+
+// onEnginesChange refreshes clients' tool lists when an engine probe finds
+// engines appearing, disappearing or changing version.
+func (s *Server) onEnginesChange(infos []models.EngineInfo) {
+	log.Printf("mcp_event=tools_changed reason=engine_probe")
+	s.notifyToolsListChanged()
+	s.logEngineChanges(infos)
+}
+
+// notifyToolsListChanged sends notifications/tools/list_changed to every session.
+func (s *Server) notifyToolsListChanged() {
+	for _, session := range s.sessionList() {
+		session.notify("notifications/tools/list_changed", nil)
+	}
+}
+
+func (s *Server) toolDefinitionsJSON() string {
+	data, _ := json.Marshal(s.getToolDefinitions())
+	return string(data)
+}
+
+// unavailableEngines lists engines whose binary the last probe did not find.
+func (s *Server) unavailableEngines() []models.Engine {
+	var engines []models.Engine
+	for _, info := range s.orchestrator.ListEngines(context.Background(), false) {
+		if !info.Available {
+			engines = append(engines, info.Engine)
+		}
+	}
+	return engines
+}
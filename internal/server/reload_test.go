@@ -0,0 +1,42 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+func TestReloadConfig_NotifiesToolsListChanged(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	session := newSession("reload")
+	srv.sessionMu.Lock()
+	srv.sessions[session.ID] = session
+	srv.sessionMu.Unlock()
+
+	// Reloading an identical config does not notify.
+	srv.ReloadConfig(config.DefaultConfig())
+	select {
+	case msg := <-session.events:
+		t.Fatalf("expected no notification for unchanged tools, got %s", msg)
+	default:
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Models = append(cfg.Models, config.ModelConfig{ID: "new-model"})
+	srv.ReloadConfig(cfg)
+
+	select {
+	case msg := <-session.events:
+		if !strings.Contains(string(msg), "notifications/tools/list_changed") {
+			t.Fatalf("expected tools/list_changed, got %s", msg)
+		}
+	default:
+		t.Fatal("expected tools/list_changed after the model list changed")
+	}
+	if !strings.Contains(srv.toolDefinitionsJSON(), "new-model") {
+		t.Fatal("expected reloaded model in spawn_agent model enum")
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+const (
+	taskResourcePrefix = "mesnada://tasks/"
+	// resourcePageSize is how many tasks one resources/list page covers.
+	resourcePageSize = 50
+	// maxResourceLogBytes caps the log text returned by resources/read (the tail is kept).
+	maxResourceLogBytes = 1024 * 1024
+)
+
+// taskResourceURIs returns the log and result resource URIs of a task.
+func taskResourceURIs(taskID string) (string, string) {
+	return taskResourcePrefix + taskID + "/log", taskResourcePrefix + taskID + "/result"
+}
+
+// parseTaskResourceURI splits mesnada://tasks/{id}/{log|result}.
+func parseTaskResourceURI(uri string) (taskID, kind string, err error) {
+	rest, ok := strings.CutPrefix(uri, taskResourcePrefix)
+	if ok {
+		if i := strings.LastIndex(rest, "/"); i > 0 {
+			taskID, kind = rest[:i], rest[i+1:]
+			if kind == "log" || kind == "result" {
+				return taskID, kind, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("unknown resource: %s", uri)
+}
+
+func (s *Server) handleResourcesList(req *JSONRPCRequest) *JSONRPCResponse {
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	json.Unmarshal(req.Params, &params)
+
+	offset := 0
+	if params.Cursor != "" {
+		n, err := strconv.Atoi(params.Cursor)
+		if err != nil || n < 0 {
+			return rpcError(req.ID, -32602, "Invalid params", "invalid cursor")
+		}
+		offset = n
+	}
+
+	tasks, err := s.orchestrator.ListTasks(models.ListRequest{Limit: resourcePageSize, Offset: offset})
+	if err != nil {
+		return rpcError(req.ID, -32603, "Internal error", err.Error())
+	}
+
+	resources := make([]map[string]interface{}, 0, 2*len(tasks))
+	for _, task := range tasks {
+		logURI, resultURI := taskResourceURIs(task.ID)
+		description := promptExcerpt(stripTaskIDPrefix(task.Prompt), 120)
+		resources = append(resources,
+			map[string]interface{}{
+				"uri":         logURI,
+				"name":        task.ID + " log",
+				"description": description,
+				"mimeType":    "text/plain",
+			},
+			map[string]interface{}{
+				"uri":         resultURI,
+				"name":        task.ID + " result",
+				"description": description,
+				"mimeType":    "application/json",
+			},
+		)
+	}
+
+	result := map[string]interface{}{"resources": resources}
+	if len(tasks) == resourcePageSize {
+		result["nextCursor"] = strconv.Itoa(offset + resourcePageSize)
+	}
+	return &JSONRPCResponse{JSONRPC: jsonRPCVersion, ID: req.ID, Result: result}
+}
+
+func (s *Server) handleResourceTemplatesList(req *JSONRPCRequest) *JSONRPCResponse {
+	return &JSONRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		ID:      req.ID,
+		Result: map[string]interface{}{
+			"resourceTemplates": []map[string]interface{}{
+				{
+					"uriTemplate": taskResourcePrefix + "{task_id}/log",
+					"name":        "Task log",
+					"description": "Agent output log of a task (last 1MB)",
+					"mimeType":    "text/plain",
+				},
+				{
+					"uriTemplate": taskResourcePrefix + "{task_id}/result",
+					"name":        "Task result",
+					"description": "Status, summary, output and exit code of a task",
+					"mimeType":    "application/json",
+				},
+			},
+		},
+	}
+}
+
+func (s *Server) handleResourcesRead(req *JSONRPCRequest) *JSONRPCResponse {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return rpcError(req.ID, -32602, "Invalid params", err.Error())
+	}
+
+	taskID, kind, err := parseTaskResourceURI(params.URI)
+	if err != nil {
+		return rpcError(req.ID, -32002, "Resource not found", err.Error())
+	}
+	task, err := s.orchestrator.GetTask(taskID)
+	if err != nil {
+		return rpcError(req.ID, -32002, "Resource not found", err.Error())
+	}
+
+	content := map[string]interface{}{"uri": params.URI}
+	if kind == "log" {
+		text := task.Output
+		if task.LogFile != "" {
+			if tail := readLastBytes(task.LogFile, maxResourceLogBytes); tail != "" {
+				text = tail
+			}
+		}
+		content["mimeType"] = "text/plain"
+		content["text"] = text
+	} else {
+		data, _ := json.MarshalIndent(taskResult(task), "", "  ")
+		content["mimeType"] = "application/json"
+		content["text"] = string(data)
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		ID:      req.ID,
+		Result:  map[string]interface{}{"contents": []map[string]interface{}{content}},
+	}
+}
+
+// taskResult is the body of the mesnada://tasks/{id}/result resource.
+func taskResult(task *models.Task) map[string]interface{} {
+	return map[string]interface{}{
+		"task_id":      task.ID,
+		"status":       task.Status,
+		"engine":       task.Engine,
+		"model":        task.Model,
+		"summary":      task.Summary,
+		"output":       task.Output,
+		"error":        task.Error,
+		"exit_code":    task.ExitCode,
+		"started_at":   task.StartedAt,
+		"completed_at": task.CompletedAt,
+		"commit_sha":   task.CommitSHA,
+	}
+}
+
+// handleResourcesSubscribe adds or removes a resources/updated subscription.
+func (s *Server) handleResourcesSubscribe(session *Session, req *JSONRPCRequest, subscribe bool) *JSONRPCResponse {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return rpcError(req.ID, -32602, "Invalid params", err.Error())
+	}
+	if _, _, err := parseTaskResourceURI(params.URI); err != nil {
+		return rpcError(req.ID, -32002, "Resource not found", err.Error())
+	}
+
+	session.mu.Lock()
+	if subscribe {
+		if session.subscriptions == nil {
+			session.subscriptions = make(map[string]bool)
+		}
+		session.subscriptions[params.URI] = true
+	} else {
+		delete(session.subscriptions, params.URI)
+	}
+	session.mu.Unlock()
+
+	return &JSONRPCResponse{JSONRPC: jsonRPCVersion, ID: req.ID, Result: map[string]interface{}{}}
+}
+
+// onTaskChange tells sessions about resource changes: list_changed when tasks
+// come and go, and resources/updated for subscribed task resources.
+func (s *Server) onTaskChange(change orchestrator.TaskChange, task *models.Task) {
+	if change == orchestrator.TaskFinished {
+		s.pushTaskFinished(task)
+	}
+	s.logTaskChange(change, task)
+	s.metrics.observeTask(change, task)
+	s.uiEvents.publish(uiEvent{Change: string(change), TaskID: task.ID, Status: task.Status})
+	s.taskEvents.append(newTaskEvent(change, task))
+
+	logURI, resultURI := taskResourceURIs(task.ID)
+	listChanged := change == orchestrator.TaskCreated || change == orchestrator.TaskDeleted
+
+	for _, session := range s.sessionList() {
+		if listChanged {
+			session.notify("notifications/resources/list_changed", nil)
+		}
+		for _, uri := range []string{logURI, resultURI} {
+			if session.subscribed(uri) {
+				session.notify("notifications/resources/updated", map[string]string{"uri": uri})
+			}
+		}
+	}
+}
+
+// sessionList returns a snapshot of the live sessions.
+func (s *Server) sessionList() []*Session {
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// rpcError builds a JSON-RPC error response.
+func rpcError(id interface{}, code int, message, data string) *JSONRPCResponse {
+	return &JSONRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		ID:      id,
+		Error:   &JSONRPCError{Code: code, Message: message, Data: data},
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func callMethod(t *testing.T, srv *Server, session *Session, method string, params interface{}) *JSONRPCResponse {
+	t.Helper()
+	raw, _ := json.Marshal(params)
+	return srv.handleRequest(context.Background(), session, &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: raw})
+}
+
+func TestResources_ListAndRead(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task, err := srv.orchestrator.Spawn(context.Background(), models.SpawnRequest{Prompt: "write the docs", WorkDir: "/tmp", Background: true, Dependencies: []string{"missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newSession("test")
+
+	resp := callMethod(t, srv, session, "resources/list", map[string]string{})
+	if resp.Error != nil {
+		t.Fatalf("resources/list: %+v", resp.Error)
+	}
+	resources := resp.Result.(map[string]interface{})["resources"].([]map[string]interface{})
+	logURI, resultURI := taskResourceURIs(task.ID)
+	if len(resources) != 2 || resources[0]["uri"] != logURI || resources[1]["uri"] != resultURI {
+		t.Fatalf("unexpected resources %+v", resources)
+	}
+	if resources[0]["description"] != "write the docs" {
+		t.Fatalf("expected prompt as description, got %v", resources[0]["description"])
+	}
+
+	resp = callMethod(t, srv, session, "resources/read", map[string]string{"uri": resultURI})
+	if resp.Error != nil {
+		t.Fatalf("resources/read: %+v", resp.Error)
+	}
+	content := resp.Result.(map[string]interface{})["contents"].([]map[string]interface{})[0]
+	if content["mimeType"] != "application/json" || !strings.Contains(content["text"].(string), `"status": "pending"`) {
+		t.Fatalf("unexpected result content %+v", content)
+	}
+
+	resp = callMethod(t, srv, session, "resources/read", map[string]string{"uri": "mesnada://tasks/nope/result"})
+	if resp.Error == nil || resp.Error.Code != -32002 {
+		t.Fatalf("expected resource not found, got %+v", resp)
+	}
+}
+
+func TestResources_SubscribeNotifiesUpdates(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	session := newSession("sub")
+	srv.sessionMu.Lock()
+	srv.sessions[session.ID] = session
+	srv.sessionMu.Unlock()
+
+	task, err := srv.orchestrator.Spawn(context.Background(), models.SpawnRequest{Prompt: "p", WorkDir: "/tmp", Background: true, Dependencies: []string{"missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(<-session.events); !strings.Contains(msg, "notifications/resources/list_changed") {
+		t.Fatalf("expected list_changed on spawn, got %s", msg)
+	}
+
+	_, resultURI := taskResourceURIs(task.ID)
+	if resp := callMethod(t, srv, session, "resources/subscribe", map[string]string{"uri": resultURI}); resp.Error != nil {
+		t.Fatalf("subscribe: %+v", resp.Error)
+	}
+	if err := srv.orchestrator.SetProgress(task.ID, 50, "halfway"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(<-session.events); !strings.Contains(msg, "notifications/resources/updated") || !strings.Contains(msg, resultURI) {
+		t.Fatalf("expected resources/updated for %s, got %s", resultURI, msg)
+	}
+
+	callMethod(t, srv, session, "resources/unsubscribe", map[string]string{"uri": resultURI})
+	srv.orchestrator.SetProgress(task.ID, 60, "more")
+	select {
+	case msg := <-session.events:
+		t.Fatalf("expected no notification after unsubscribe, got %s", msg)
+	default:
+	}
+}
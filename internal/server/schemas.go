@@ -0,0 +1,232 @@
+package server
+
+// Output schemas describe the structuredContent of each tool result. They
+// only list the fields a result always has as required, so clients that
+// validate results accept every variant a tool returns.
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func arraySchema(items map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": items}
+}
+
+func typeSchema(types ...string) map[string]interface{} {
+	if len(types) == 1 {
+		return map[string]interface{}{"type": types[0]}
+	}
+	return map[string]interface{}{"type": types}
+}
+
+var (
+	stringSchema  = typeSchema("string")
+	integerSchema = typeSchema("integer")
+	booleanSchema = typeSchema("boolean")
+
+	// taskSchema is a full task as stored by the orchestrator.
+	taskSchema = objectSchema(map[string]interface{}{
+		"id":           stringSchema,
+		"status":       stringSchema,
+		"prompt":       stringSchema,
+		"work_dir":     stringSchema,
+		"engine":       stringSchema,
+		"model":        stringSchema,
+		"created_at":   stringSchema,
+		"tags":         arraySchema(stringSchema),
+		"dependencies": arraySchema(stringSchema),
+		"output_tail":  stringSchema,
+		"error":        stringSchema,
+		"exit_code":    integerSchema,
+		"summary":      stringSchema,
+		"result":       typeSchema("object"),
+		"metadata":     typeSchema("object"),
+	}, "id", "status", "prompt", "created_at")
+
+	statsProperties = map[string]interface{}{
+		"total":            integerSchema,
+		"pending":          integerSchema,
+		"running":          integerSchema,
+		"paused":           integerSchema,
+		"completed":        integerSchema,
+		"failed":           integerSchema,
+		"cancelled":        integerSchema,
+		"running_progress": typeSchema("object"),
+		"tokens_by_model":  typeSchema("object"),
+	}
+
+	taskSummarySchema = objectSchema(map[string]interface{}{
+		"id":           stringSchema,
+		"prompt":       stringSchema,
+		"work_dir":     stringSchema,
+		"status":       stringSchema,
+		"created_at":   stringSchema,
+		"completed_at": stringSchema,
+		"duration":     stringSchema,
+		"summary":      stringSchema,
+	}, "id", "prompt", "status", "created_at")
+
+	taskListSchema = objectSchema(map[string]interface{}{
+		"tasks":   arraySchema(taskSummarySchema),
+		"total":   integerSchema,
+		"matches": integerSchema,
+	}, "tasks", "total")
+
+	taskWithIDSchema = objectSchema(map[string]interface{}{
+		"task_id": stringSchema,
+		"task":    taskSchema,
+	}, "task_id", "task")
+
+	dependencyEditSchema = objectSchema(map[string]interface{}{
+		"task_id":      stringSchema,
+		"status":       stringSchema,
+		"dependencies": arraySchema(stringSchema),
+	}, "task_id", "status")
+)
+
+// toolOutputSchemas maps each tool to the schema of its structured result.
+var toolOutputSchemas = map[string]map[string]interface{}{
+	"spawn_agent": objectSchema(map[string]interface{}{
+		"task_id":          stringSchema,
+		"status":           stringSchema,
+		"work_dir":         stringSchema,
+		"created_at":       stringSchema,
+		"output_tail":      stringSchema,
+		"exit_code":        typeSchema("integer", "null"),
+		"error":            stringSchema,
+		"available_models": arraySchema(stringSchema),
+		"engine":           stringSchema,
+		"suggestion":       stringSchema,
+	}, "task_id", "status"),
+	"spawn_batch": objectSchema(map[string]interface{}{
+		"task_ids": arraySchema(stringSchema),
+		"tasks": arraySchema(objectSchema(map[string]interface{}{
+			"index":        integerSchema,
+			"task_id":      stringSchema,
+			"status":       stringSchema,
+			"dependencies": typeSchema("array", "null"),
+		}, "index", "task_id", "status")),
+	}, "task_ids", "tasks"),
+	"get_task": objectSchema(map[string]interface{}{
+		"task":             taskSchema,
+		"available_models": arraySchema(stringSchema),
+		"suggestion":       stringSchema,
+	}, "task"),
+	"list_tasks":   taskListSchema,
+	"search_tasks": taskListSchema,
+	"get_task_graph": objectSchema(map[string]interface{}{
+		"nodes": arraySchema(objectSchema(map[string]interface{}{
+			"id":       stringSchema,
+			"label":    stringSchema,
+			"status":   stringSchema,
+			"engine":   stringSchema,
+			"tags":     arraySchema(stringSchema),
+			"progress": integerSchema,
+			"missing":  booleanSchema,
+		}, "id", "label")),
+		"edges": arraySchema(objectSchema(map[string]interface{}{
+			"from": stringSchema,
+			"to":   stringSchema,
+		}, "from", "to")),
+	}, "nodes", "edges"),
+	"wait_task": objectSchema(map[string]interface{}{
+		"task":        taskSchema,
+		"output_tail": stringSchema,
+		"result":      typeSchema("object", "null"),
+		"error":       stringSchema,
+		"timeout":     booleanSchema,
+	}, "task"),
+	"wait_multiple": objectSchema(map[string]interface{}{
+		"tasks": map[string]interface{}{
+			"type": "object",
+			"additionalProperties": objectSchema(map[string]interface{}{
+				"status":      stringSchema,
+				"output_tail": stringSchema,
+				"exit_code":   typeSchema("integer", "null"),
+				"error":       stringSchema,
+			}, "status"),
+		},
+		"completed": integerSchema,
+		"requested": integerSchema,
+		"error":     stringSchema,
+	}, "tasks", "completed", "requested"),
+	"cancel_task": objectSchema(map[string]interface{}{
+		"task_id":   stringSchema,
+		"cancelled": booleanSchema,
+	}, "task_id", "cancelled"),
+	"pause_task":  taskSchema,
+	"resume_task": taskWithIDSchema,
+	"retry_task": objectSchema(map[string]interface{}{
+		"task_id":  stringSchema,
+		"retry_of": stringSchema,
+		"attempt":  integerSchema,
+		"task":     taskSchema,
+	}, "task_id", "retry_of", "attempt", "task"),
+	"update_task":       taskWithIDSchema,
+	"add_dependency":    dependencyEditSchema,
+	"remove_dependency": dependencyEditSchema,
+	"delete_task": objectSchema(map[string]interface{}{
+		"task_id": stringSchema,
+		"deleted": booleanSchema,
+	}, "task_id", "deleted"),
+	"get_stats": objectSchema(statsProperties, "total", "pending", "running", "paused", "completed", "failed", "cancelled"),
+	"get_task_output": objectSchema(map[string]interface{}{
+		"task_id":  stringSchema,
+		"status":   stringSchema,
+		"output":   stringSchema,
+		"log_file": stringSchema,
+		"is_tail":  booleanSchema,
+	}, "task_id", "status", "output"),
+	"get_task_output_chunk": objectSchema(map[string]interface{}{
+		"task_id":     stringSchema,
+		"status":      stringSchema,
+		"content":     stringSchema,
+		"next_offset": integerSchema,
+		"truncated":   booleanSchema,
+		"eof":         booleanSchema,
+	}, "task_id", "status", "content", "next_offset", "truncated", "eof"),
+	"set_progress": objectSchema(map[string]interface{}{
+		"task_id":     stringSchema,
+		"percentage":  integerSchema,
+		"description": stringSchema,
+		"updated":     booleanSchema,
+	}, "task_id", "percentage", "updated"),
+	"set_task_result": objectSchema(map[string]interface{}{
+		"task_id": stringSchema,
+		"status":  stringSchema,
+		"updated": booleanSchema,
+	}, "task_id", "status", "updated"),
+	"list_engines": objectSchema(map[string]interface{}{
+		"engines": arraySchema(objectSchema(map[string]interface{}{
+			"engine":           stringSchema,
+			"name":             stringSchema,
+			"binary":           stringSchema,
+			"available":        booleanSchema,
+			"version":          stringSchema,
+			"error":            stringSchema,
+			"checked_at":       stringSchema,
+			"supported_models": arraySchema(stringSchema),
+			"default":          booleanSchema,
+			"default_model":    stringSchema,
+			"models": arraySchema(objectSchema(map[string]interface{}{
+				"id":          stringSchema,
+				"description": stringSchema,
+			}, "id")),
+			"running": integerSchema,
+			"pending": integerSchema,
+		}, "engine", "name", "available", "models", "running", "pending")),
+	}, "engines"),
+	"list_personas": objectSchema(map[string]interface{}{
+		"personas": arraySchema(objectSchema(map[string]interface{}{
+			"name":    stringSchema,
+			"summary": stringSchema,
+		}, "name", "summary")),
+	}, "personas"),
+}
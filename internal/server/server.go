@@ -3,27 +3,34 @@ package server
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/sevir/mesnada/internal/config"
 	"github.com/sevir/mesnada/internal/orchestrator"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const (
 	jsonRPCVersion = "2.0"
-	mcpVersion     = "2024-11-05"
+	// mcpVersion is the latest MCP protocol revision the server speaks.
+	mcpVersion = "2025-06-18"
 )
 
+// supportedProtocolVersions lists the MCP revisions accepted at initialize.
+var supportedProtocolVersions = []string{mcpVersion, "2025-03-26", "2024-11-05"}
+
 // Server is the MCP HTTP Streamable and stdio server.
 type Server struct {
 	orchestrator *orchestrator.Orchestrator
@@ -36,19 +43,95 @@ type Server struct {
 	tools        map[string]ToolHandler
 	useStdio     bool
 	config       *config.Config
+	configMu     sync.RWMutex
+
+	oauthMu        sync.Mutex
+	oauthValidator *oauthValidator
+
+	limiterMu sync.Mutex
+	limiter   *rateLimiter
+
+	metrics *metrics
+
+	done     chan struct{}
+	doneOnce sync.Once
 
 	uiOnce   sync.Once
 	uiTpl    *template.Template
 	uiTplErr error
+	uiEvents uiEventHub
+
+	taskEvents taskEventLog
 }
 
 // Session represents an MCP session.
 type Session struct {
-	ID        string
-	CreatedAt time.Time
-	events    chan []byte
-	closed    bool
-	mu        sync.Mutex
+	ID              string
+	CreatedAt       time.Time
+	ProtocolVersion string
+	events          chan []byte
+	closed          bool
+	subscriptions   map[string]bool
+	watchedTasks    map[string]bool
+	// allowedTools, when set, limits the tools the session may call.
+	allowedTools []string
+	// logLevel is the minimum level of notifications/message the client asked
+	// for with logging/setLevel; empty sends none.
+	logLevel string
+	lastSeen time.Time
+	mu       sync.Mutex
+}
+
+// newSession creates a session with an empty server-to-client event queue.
+func newSession(id string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:        id,
+		CreatedAt: now,
+		lastSeen:  now,
+		events:    make(chan []byte, 100),
+	}
+}
+
+// send queues a server-to-client message for the session's event stream.
+func (s *Session) send(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("session closed: %s", s.ID)
+	}
+	select {
+	case s.events <- data:
+		return nil
+	default:
+		return fmt.Errorf("event channel full")
+	}
+}
+
+// notify queues a JSON-RPC notification for the session's event stream.
+func (s *Session) notify(method string, params interface{}) error {
+	data, err := json.Marshal(&JSONRPCNotification{JSONRPC: jsonRPCVersion, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return s.send(data)
+}
+
+// subscribed reports whether the session subscribed to updates of a resource.
+func (s *Session) subscribed(uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscriptions[uri]
+}
+
+// close terminates the session and ends its event streams.
+func (s *Session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
 }
 
 // JSONRPCRequest represents a JSON-RPC 2.0 request.
@@ -67,6 +150,13 @@ type JSONRPCResponse struct {
 	Error   *JSONRPCError `json:"error,omitempty"`
 }
 
+// JSONRPCNotification represents a JSON-RPC 2.0 notification (no ID, no response).
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 // JSONRPCError represents a JSON-RPC 2.0 error.
 type JSONRPCError struct {
 	Code    int         `json:"code"`
@@ -89,6 +179,10 @@ type Config struct {
 
 // New creates a new MCP server.
 func New(cfg Config) *Server {
+	if cfg.AppConfig == nil {
+		cfg.AppConfig = config.DefaultConfig()
+	}
+
 	s := &Server{
 		orchestrator: cfg.Orchestrator,
 		addr:         cfg.Addr,
@@ -98,23 +192,39 @@ func New(cfg Config) *Server {
 		tools:        make(map[string]ToolHandler),
 		useStdio:     cfg.UseStdio,
 		config:       cfg.AppConfig,
+		done:         make(chan struct{}),
+		metrics:      newMetrics(),
 	}
 
 	s.registerTools()
 
+	if s.orchestrator != nil {
+		s.orchestrator.OnTaskChange(s.onTaskChange)
+		s.orchestrator.OnEnginesChange(s.onEnginesChange)
+	}
+
 	// Only set up HTTP server if not using stdio
 	if !cfg.UseStdio {
 		mux := http.NewServeMux()
 		mux.HandleFunc("/mcp", s.handleMCP)
 		mux.HandleFunc("/mcp/sse", s.handleSSE)
 		mux.HandleFunc("/health", s.handleHealth)
+		mux.HandleFunc("/health/live", s.handleHealthLive)
+		mux.HandleFunc("/health/ready", s.handleHealthReady)
+		mux.HandleFunc("/metrics", s.handleMetrics)
+		mux.HandleFunc(protectedResourcePath, s.handleProtectedResource)
+		mux.HandleFunc(protectedResourcePath+"/", s.handleProtectedResource)
+		mux.HandleFunc(grpcServicePath, s.handleGRPC)
 
 		// UI + REST API are handled by Gin, while MCP endpoints remain on the stdlib mux.
 		mux.Handle("/", s.newGinEngine())
 
+		go s.sessionJanitor()
+
+		// h2c accepts HTTP/2 without TLS, for gRPC clients.
 		s.httpServer = &http.Server{
 			Addr:         cfg.Addr,
-			Handler:      s.corsMiddleware(mux),
+			Handler:      h2c.NewHandler(s.basePathMiddleware(s.metricsMiddleware(s.corsMiddleware(s.rateLimitMiddleware(s.authMiddleware(mux))))), &http2.Server{}),
 			ReadTimeout:  30 * time.Second,
 			WriteTimeout: 0, // No timeout for SSE
 		}
@@ -123,33 +233,21 @@ func New(cfg Config) *Server {
 	return s
 }
 
-func (s *Server) corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Mcp-Session-Id")
-		w.Header().Set("Access-Control-Expose-Headers", "Mcp-Session-Id")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
 // Start starts the HTTP server or stdio loop.
 func (s *Server) Start() error {
 	if s.useStdio {
 		return s.runStdio()
 	}
+	if tlsCfg := s.appConfig().Server.TLS; tlsCfg.Enabled() {
+		return s.listenTLS(tlsCfg)
+	}
 	log.Printf("MCP server starting on %s", s.addr)
 	return s.httpServer.ListenAndServe()
 }
 
 // Shutdown gracefully shuts down the server.
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.doneOnce.Do(func() { close(s.done) })
 	if s.useStdio {
 		// For stdio, just return nil as there's no server to shutdown
 		return nil
@@ -159,35 +257,103 @@ func (s *Server) Shutdown(ctx context.Context) error {
 
 // runStdio runs the MCP server in stdio mode.
 func (s *Server) runStdio() error {
-	scanner := bufio.NewScanner(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+	return s.serveStdio(os.Stdin, os.Stdout)
+}
 
-	// Create a dummy session for stdio
-	session := &Session{
-		ID:        "stdio",
-		CreatedAt: time.Now(),
-		events:    make(chan []byte, 100),
+// serveStdio serves newline-delimited JSON-RPC messages. Each request is
+// handled on its own goroutine so a blocking call such as wait_task does not
+// hold up the others; whole messages are written to out one at a time.
+// Notifications are handled inline to keep their order.
+func (s *Server) serveStdio(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	encoder := json.NewEncoder(out)
+
+	// Responses and notifications share stdout.
+	var writeMu sync.Mutex
+	write := func(msg interface{}) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return encoder.Encode(msg)
 	}
 
+	// Create a dummy session for stdio; its queued server messages go to stdout.
+	session := newSession("stdio")
+	s.sessionMu.Lock()
+	s.sessions[session.ID] = session
+	s.sessionMu.Unlock()
+	defer session.close()
+	go func() {
+		for data := range session.events {
+			write(json.RawMessage(data))
+		}
+	}()
+
+	// In-flight requests are cancelled once the client closes stdin.
+	baseCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx := withRequestStream(baseCtx, session, write)
+
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+
 	for scanner.Scan() {
-		line := scanner.Bytes()
+		// The scanner reuses its buffer; goroutines need their own copy.
+		line := append([]byte(nil), scanner.Bytes()...)
 		if len(line) == 0 {
 			continue
 		}
 
+		if isBatch(line) {
+			reqs, responses, err := decodeBatch(line)
+			if err != nil {
+				write(parseErrorResponse(err))
+				continue
+			}
+			if len(reqs) == 0 && len(responses) == 0 {
+				write(&JSONRPCResponse{
+					JSONRPC: jsonRPCVersion,
+					Error:   &JSONRPCError{Code: -32600, Message: "Invalid Request", Data: "empty batch"},
+				})
+				continue
+			}
+			inflight.Add(1)
+			go func() {
+				defer inflight.Done()
+				responses = append(responses, s.handleBatch(ctx, session, reqs)...)
+				if len(responses) > 0 {
+					if err := write(responses); err != nil {
+						slog.Error("failed to encode response", "error", err)
+					}
+				}
+			}()
+			continue
+		}
+
 		var req JSONRPCRequest
 		if err := json.Unmarshal(line, &req); err != nil {
-			s.writeStdioError(encoder, nil, -32700, "Parse error", err.Error())
+			write(parseErrorResponse(err))
 			continue
 		}
 
-		response := s.handleRequest(context.Background(), session, &req)
-		if err := encoder.Encode(response); err != nil {
-			log.Printf("Error encoding response: %v", err)
-			return err
+		if req.ID == nil || req.Method == "" {
+			s.handleRequest(ctx, session, &req)
+			continue
 		}
+
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			response := s.handleRequest(ctx, session, &req)
+			if response == nil {
+				return
+			}
+			if err := write(response); err != nil {
+				slog.Error("failed to encode response", "error", err)
+			}
+		}()
 	}
 
+	cancel()
 	if err := scanner.Err(); err != nil && err != io.EOF {
 		return fmt.Errorf("error reading from stdin: %w", err)
 	}
@@ -195,19 +361,6 @@ func (s *Server) runStdio() error {
 	return nil
 }
 
-// writeStdioError writes an error response to stdout in stdio mode.
-func (s *Server) writeStdioError(encoder *json.Encoder, id interface{}, code int, message, data string) {
-	encoder.Encode(&JSONRPCResponse{
-		JSONRPC: jsonRPCVersion,
-		ID:      id,
-		Error: &JSONRPCError{
-			Code:    code,
-			Message: message,
-			Data:    data,
-		},
-	})
-}
-
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	stats := s.orchestrator.GetStats()
 	w.Header().Set("Content-Type", "application/json")
@@ -217,97 +370,78 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Get or create session
-	sessionID := r.Header.Get("Mcp-Session-Id")
-	if sessionID == "" {
-		sessionID = uuid.New().String()
-	}
-
-	s.sessionMu.Lock()
-	session, exists := s.sessions[sessionID]
-	if !exists {
-		session = &Session{
-			ID:        sessionID,
-			CreatedAt: time.Now(),
-			events:    make(chan []byte, 100),
-		}
-		s.sessions[sessionID] = session
-	}
-	s.sessionMu.Unlock()
-
-	// Parse request
-	var req JSONRPCRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.writeError(w, nil, -32700, "Parse error", err.Error())
-		return
-	}
-
-	// Set session header
-	w.Header().Set("Mcp-Session-Id", sessionID)
+// handleHealthLive answers liveness probes without touching the store.
+func (s *Server) handleHealthLive(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-
-	// Handle request
-	response := s.handleRequest(r.Context(), session, &req)
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
 }
 
-func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
-	sessionID := r.Header.Get("Mcp-Session-Id")
-	if sessionID == "" {
-		http.Error(w, "Missing Mcp-Session-Id header", http.StatusBadRequest)
-		return
+// handleHealthReady answers readiness probes: 200 when the store can persist
+// tasks, the engines have been probed and the server is not shutting down,
+// 503 with the failing checks otherwise.
+func (s *Server) handleHealthReady(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"store": "ok", "engines": "ok", "server": "ok"}
+	ready := true
+	if err := s.orchestrator.CheckStore(); err != nil {
+		checks["store"] = err.Error()
+		ready = false
 	}
-
-	s.sessionMu.RLock()
-	session, exists := s.sessions[sessionID]
-	s.sessionMu.RUnlock()
-
-	if !exists {
-		http.Error(w, "Session not found", http.StatusNotFound)
-		return
+	if !s.orchestrator.EnginesProbed() {
+		checks["engines"] = "not probed yet"
+		ready = false
 	}
-
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "SSE not supported", http.StatusInternalServerError)
-		return
+	select {
+	case <-s.done:
+		checks["server"] = "draining"
+		ready = false
+	default:
 	}
 
-	// Send initial connection event
-	fmt.Fprintf(w, "event: connected\ndata: {\"sessionId\":\"%s\"}\n\n", sessionID)
-	flusher.Flush()
-
-	for {
-		select {
-		case <-r.Context().Done():
-			return
-		case data := <-session.events:
-			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
-			flusher.Flush()
-		}
+	status := "ready"
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		status = "not_ready"
+		w.WriteHeader(http.StatusServiceUnavailable)
 	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
 }
 
+// handleRequest dispatches a client message. Notifications (no ID) and
+// responses to server requests return nil: they get no JSON-RPC response.
 func (s *Server) handleRequest(ctx context.Context, session *Session, req *JSONRPCRequest) *JSONRPCResponse {
+	if req.ID == nil || req.Method == "" {
+		s.handleNotification(session, req)
+		return nil
+	}
+
 	switch req.Method {
 	case "initialize":
-		return s.handleInitialize(req)
+		return s.handleInitialize(session, req)
 	case "initialized":
 		return s.handleInitialized(req)
 	case "tools/list":
-		return s.handleToolsList(req)
+		return s.handleToolsList(ctx, req)
 	case "tools/call":
 		return s.handleToolsCall(ctx, req)
+	case "resources/list":
+		return s.handleResourcesList(req)
+	case "resources/templates/list":
+		return s.handleResourceTemplatesList(req)
+	case "resources/read":
+		return s.handleResourcesRead(req)
+	case "resources/subscribe":
+		return s.handleResourcesSubscribe(session, req, true)
+	case "resources/unsubscribe":
+		return s.handleResourcesSubscribe(session, req, false)
+	case "prompts/list":
+		return s.handlePromptsList(req)
+	case "prompts/get":
+		return s.handlePromptsGet(req)
+	case "logging/setLevel":
+		return s.handleLoggingSetLevel(session, req)
 	case "ping":
 		return s.handlePing(req)
 	default:
@@ -322,18 +456,46 @@ func (s *Server) handleRequest(ctx context.Context, session *Session, req *JSONR
 	}
 }
 
-func (s *Server) handleInitialize(req *JSONRPCRequest) *JSONRPCResponse {
+// handleNotification handles client notifications such as notifications/initialized.
+func (s *Server) handleNotification(session *Session, req *JSONRPCRequest) {
+	switch req.Method {
+	case "notifications/initialized", "initialized":
+		log.Printf("mcp_event=initialized session_id=%s protocol_version=%s", session.ID, session.ProtocolVersion)
+	}
+}
+
+func (s *Server) handleInitialize(session *Session, req *JSONRPCRequest) *JSONRPCResponse {
+	var params struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(req.Params, &params)
+
+	// Echo the client's revision when supported, otherwise offer the latest.
+	version := mcpVersion
+	if supportedProtocolVersion(params.ProtocolVersion) {
+		version = params.ProtocolVersion
+	}
+	session.ProtocolVersion = version
+
 	return &JSONRPCResponse{
 		JSONRPC: jsonRPCVersion,
 		ID:      req.ID,
 		Result: map[string]interface{}{
-			"protocolVersion": mcpVersion,
+			"protocolVersion": version,
 			"serverInfo": map[string]string{
 				"name":    "mesnada",
 				"version": "1.0.0",
 			},
 			"capabilities": map[string]interface{}{
-				"tools": map[string]interface{}{},
+				"tools": map[string]interface{}{
+					"listChanged": true,
+				},
+				"resources": map[string]interface{}{
+					"subscribe":   true,
+					"listChanged": true,
+				},
+				"prompts": map[string]interface{}{},
+				"logging": map[string]interface{}{},
 			},
 		},
 	}
@@ -355,8 +517,14 @@ func (s *Server) handlePing(req *JSONRPCRequest) *JSONRPCResponse {
 	}
 }
 
-func (s *Server) handleToolsList(req *JSONRPCRequest) *JSONRPCResponse {
-	tools := s.getToolDefinitions()
+func (s *Server) handleToolsList(ctx context.Context, req *JSONRPCRequest) *JSONRPCResponse {
+	tools := []Tool{}
+	for _, tool := range s.getToolDefinitions() {
+		if s.toolAllowed(ctx, tool.Name) {
+			tool.OutputSchema = toolOutputSchemas[tool.Name]
+			tools = append(tools, tool)
+		}
+	}
 	return &JSONRPCResponse{
 		JSONRPC: jsonRPCVersion,
 		ID:      req.ID,
@@ -370,6 +538,9 @@ func (s *Server) handleToolsCall(ctx context.Context, req *JSONRPCRequest) *JSON
 	var params struct {
 		Name      string          `json:"name"`
 		Arguments json.RawMessage `json:"arguments"`
+		Meta      struct {
+			ProgressToken interface{} `json:"progressToken"`
+		} `json:"_meta"`
 	}
 
 	if err := json.Unmarshal(req.Params, &params); err != nil {
@@ -385,6 +556,19 @@ func (s *Server) handleToolsCall(ctx context.Context, req *JSONRPCRequest) *JSON
 	}
 
 	handler, exists := s.tools[params.Name]
+	if exists {
+		if denied := s.toolDenied(ctx, params.Name); denied != "" {
+			log.Printf("mcp_event=tool_denied tool=%s reason=%q", params.Name, denied)
+			return &JSONRPCResponse{
+				JSONRPC: jsonRPCVersion,
+				ID:      req.ID,
+				Error: &JSONRPCError{
+					Code:    -32003,
+					Message: fmt.Sprintf("Tool not allowed by %s: %s", denied, params.Name),
+				},
+			}
+		}
+	}
 	if !exists {
 		return &JSONRPCResponse{
 			JSONRPC: jsonRPCVersion,
@@ -396,7 +580,7 @@ func (s *Server) handleToolsCall(ctx context.Context, req *JSONRPCRequest) *JSON
 		}
 	}
 
-	result, err := handler(ctx, params.Arguments)
+	result, err := handler(withProgressToken(ctx, params.Meta.ProgressToken), params.Arguments)
 	if err != nil {
 		return &JSONRPCResponse{
 			JSONRPC: jsonRPCVersion,
@@ -414,23 +598,31 @@ func (s *Server) handleToolsCall(ctx context.Context, req *JSONRPCRequest) *JSON
 	}
 
 	// Format result as MCP tool result
+	// Format result as MCP tool result. The text block is kept for clients
+	// that do not read structuredContent.
 	text, _ := json.MarshalIndent(result, "", "  ")
+	toolResult := map[string]interface{}{
+		"content": []map[string]interface{}{
+			{
+				"type": "text",
+				"text": string(text),
+			},
+		},
+	}
+	if bytes.HasPrefix(text, []byte("{")) {
+		toolResult["structuredContent"] = json.RawMessage(text)
+	}
 	return &JSONRPCResponse{
 		JSONRPC: jsonRPCVersion,
 		ID:      req.ID,
-		Result: map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": string(text),
-				},
-			},
-		},
+		Result:  toolResult,
 	}
 }
 
-func (s *Server) writeError(w http.ResponseWriter, id interface{}, code int, message, data string) {
+// writeError writes a JSON-RPC error response with the given HTTP status.
+func (s *Server) writeError(w http.ResponseWriter, status int, id interface{}, code int, message, data string) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(&JSONRPCResponse{
 		JSONRPC: jsonRPCVersion,
 		ID:      id,
@@ -442,6 +634,18 @@ func (s *Server) writeError(w http.ResponseWriter, id interface{}, code int, mes
 	})
 }
 
+// parseErrorResponse is the JSON-RPC response to a message that is not valid JSON.
+func parseErrorResponse(err error) *JSONRPCResponse {
+	return &JSONRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		Error: &JSONRPCError{
+			Code:    -32700,
+			Message: "Parse error",
+			Data:    err.Error(),
+		},
+	}
+}
+
 // SendEvent sends an event to a session.
 func (s *Server) SendEvent(sessionID string, event interface{}) error {
 	s.sessionMu.RLock()
@@ -457,10 +661,15 @@ func (s *Server) SendEvent(sessionID string, event interface{}) error {
 		return err
 	}
 
-	select {
-	case session.events <- data:
-		return nil
-	default:
-		return fmt.Errorf("event channel full")
+	return session.send(data)
+}
+
+// supportedProtocolVersion reports whether the server speaks an MCP revision.
+func supportedProtocolVersion(version string) bool {
+	for _, v := range supportedProtocolVersions {
+		if v == version {
+			return true
+		}
 	}
+	return false
 }
@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/sevir/mesnada/internal/config"
 	"github.com/sevir/mesnada/internal/orchestrator"
 )
 
@@ -64,6 +66,41 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestHealthLiveAndReady(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse %s response: %v", path, err)
+		}
+		return w.Code, response
+	}
+
+	if code, response := get("/health/live"); code != http.StatusOK || response["status"] != "alive" {
+		t.Fatalf("expected live, got %d %v", code, response)
+	}
+
+	srv.orchestrator.ListEngines(context.Background(), false)
+	if code, response := get("/health/ready"); code != http.StatusOK || response["status"] != "ready" {
+		t.Fatalf("expected ready, got %d %v", code, response)
+	}
+
+	// Once shutdown starts, readiness fails so traffic drains away.
+	srv.doneOnce.Do(func() { close(srv.done) })
+	code, response := get("/health/ready")
+	checks, _ := response["checks"].(map[string]interface{})
+	if code != http.StatusServiceUnavailable || checks["server"] != "draining" {
+		t.Fatalf("expected draining, got %d %v", code, response)
+	}
+	if code, _ := get("/health/live"); code != http.StatusOK {
+		t.Fatalf("expected live while draining, got %d", code)
+	}
+}
+
 func TestMCPInitialize(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -247,6 +284,31 @@ func TestMCPToolsCall(t *testing.T) {
 	if len(content) == 0 {
 		t.Error("Expected content in response")
 	}
+	structured, ok := result["structuredContent"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected structuredContent in response, got %+v", result)
+	}
+	if _, ok := structured["total"]; !ok {
+		t.Errorf("Expected stats in structuredContent, got %+v", structured)
+	}
+}
+
+func TestMCPToolsHaveOutputSchemas(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	resp := srv.handleToolsList(context.Background(), &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/list"})
+	tools := resp.Result.(map[string]interface{})["tools"].([]Tool)
+	for _, tool := range tools {
+		if tool.OutputSchema == nil || tool.OutputSchema["type"] != "object" {
+			t.Errorf("tool %s has no object output schema", tool.Name)
+		}
+	}
+	for name := range toolOutputSchemas {
+		if _, ok := srv.tools[name]; !ok {
+			t.Errorf("output schema for unknown tool %s", name)
+		}
+	}
 }
 
 func TestMCPUnknownMethod(t *testing.T) {
@@ -329,6 +391,38 @@ func TestCORSHeaders(t *testing.T) {
 	}
 }
 
+func TestCORSAllowedOrigins(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/mcp", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Server.AuthTokens = []string{"secret"}
+	srv.ReloadConfig(cfg)
+	if w := preflight("https://evil.example"); w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected cross-origin requests to be refused with auth and no origins, got %d", w.Code)
+	}
+
+	cfg.Server.CORS.AllowedOrigins = []string{"https://app.example"}
+	srv.ReloadConfig(cfg)
+	w := preflight("https://app.example")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("expected the allowed origin to be echoed, got %d %v", w.Code, w.Header())
+	}
+	if w := preflight("https://other.example"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected other origins to be refused, got %d", w.Code)
+	}
+}
+
 func TestSpawnAgentTool(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
 	defer cleanup()
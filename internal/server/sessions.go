@@ -0,0 +1,84 @@
+package server
+
+import (
+	"log"
+	"time"
+)
+
+// sessionSweepInterval is how often idle sessions are expired.
+const sessionSweepInterval = time.Minute
+
+// touch records client activity on the session.
+func (s *Session) touch() {
+	s.mu.Lock()
+	s.lastSeen = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *Session) lastActive() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeen
+}
+
+// addSession registers a session, evicting the least recently used one when
+// server.max_sessions is reached.
+func (s *Server) addSession(session *Session) {
+	limit := s.appConfig().Server.SessionLimit()
+
+	s.sessionMu.Lock()
+	var evicted []*Session
+	for len(s.sessions) >= limit {
+		var oldest *Session
+		for _, candidate := range s.sessions {
+			if oldest == nil || candidate.lastActive().Before(oldest.lastActive()) {
+				oldest = candidate
+			}
+		}
+		delete(s.sessions, oldest.ID)
+		evicted = append(evicted, oldest)
+	}
+	s.sessions[session.ID] = session
+	s.sessionMu.Unlock()
+
+	for _, old := range evicted {
+		old.close()
+		log.Printf("mcp_event=session_evicted session_id=%s max_sessions=%d", old.ID, limit)
+	}
+}
+
+// removeSession ends a session; reason is logged as the event name.
+func (s *Server) removeSession(session *Session, reason string) {
+	s.sessionMu.Lock()
+	delete(s.sessions, session.ID)
+	s.sessionMu.Unlock()
+	session.close()
+	log.Printf("mcp_event=%s session_id=%s", reason, session.ID)
+}
+
+// expireSessions removes sessions idle for longer than server.session_ttl.
+func (s *Server) expireSessions(now time.Time) {
+	ttl, err := s.appConfig().Server.SessionTTLDuration()
+	if err != nil {
+		return
+	}
+	for _, session := range s.sessionList() {
+		if now.Sub(session.lastActive()) > ttl {
+			s.removeSession(session, "session_expired")
+		}
+	}
+}
+
+// sessionJanitor expires idle sessions until the server shuts down.
+func (s *Server) sessionJanitor() {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.expireSessions(now)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+func TestSessions_ExpireIdle(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.SessionTTL = "10m"
+	srv.ReloadConfig(cfg)
+
+	idle, _ := initializeSession(t, srv, mcpVersion)
+	active, _ := initializeSession(t, srv, mcpVersion)
+
+	session, _ := srv.lookupSession(idle)
+	session.mu.Lock()
+	session.lastSeen = time.Now().Add(-11 * time.Minute)
+	session.mu.Unlock()
+
+	srv.expireSessions(time.Now())
+	if w := postMCP(t, srv, idle, `{"jsonrpc":"2.0","id":2,"method":"ping"}`, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected idle session to expire, got %d", w.Code)
+	}
+	if w := postMCP(t, srv, active, `{"jsonrpc":"2.0","id":3,"method":"ping"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected active session to survive, got %d", w.Code)
+	}
+}
+
+func TestSessions_MaxSessionsEvictsLeastRecentlyUsed(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.MaxSessions = 2
+	srv.ReloadConfig(cfg)
+
+	first, _ := initializeSession(t, srv, mcpVersion)
+	time.Sleep(5 * time.Millisecond)
+	second, _ := initializeSession(t, srv, mcpVersion)
+	time.Sleep(5 * time.Millisecond)
+	postMCP(t, srv, first, `{"jsonrpc":"2.0","id":2,"method":"ping"}`, nil)
+	time.Sleep(5 * time.Millisecond)
+	initializeSession(t, srv, mcpVersion)
+
+	if len(srv.sessionList()) != 2 {
+		t.Fatalf("expected the session cap to hold, got %d sessions", len(srv.sessionList()))
+	}
+	if w := postMCP(t, srv, second, `{"jsonrpc":"2.0","id":3,"method":"ping"}`, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected least recently used session to be evicted, got %d", w.Code)
+	}
+	if w := postMCP(t, srv, first, `{"jsonrpc":"2.0","id":4,"method":"ping"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected recently used session to survive, got %d", w.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+func TestServeStdio_ConcurrentRequests(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	task := spawnPending(t, srv, models.SpawnRequest{})
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.serveStdio(inR, outW)
+		outW.Close()
+	}()
+
+	responses := make(chan JSONRPCResponse, 10)
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var resp JSONRPCResponse
+			if json.Unmarshal(scanner.Bytes(), &resp) == nil && resp.ID != nil {
+				responses <- resp
+			}
+		}
+	}()
+
+	// wait_task blocks on the pending task; ping must still be answered.
+	io.WriteString(inW, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"wait_task","arguments":{"task_id":"`+task.ID+`"}}}`+"\n")
+	io.WriteString(inW, `{"jsonrpc":"2.0","id":2,"method":"ping"}`+"\n")
+
+	select {
+	case resp := <-responses:
+		if resp.ID.(float64) != 2 {
+			t.Fatalf("expected the ping response first, got id %v", resp.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ping was blocked by wait_task")
+	}
+
+	// Closing stdin cancels the pending wait and stops the server.
+	inW.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveStdio did not return after stdin closed")
+	}
+	select {
+	case resp := <-responses:
+		if resp.ID.(float64) != 1 {
+			t.Fatalf("expected the wait_task response, got id %v", resp.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait_task response was not written")
+	}
+}
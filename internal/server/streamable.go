@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sseKeepAliveInterval is how often idle event streams get a comment line so
+// proxies do not close them.
+const sseKeepAliveInterval = 30 * time.Second
+
+// messageWriter delivers a server-to-client JSON-RPC message.
+type messageWriter func(msg interface{}) error
+
+type requestStreamKey struct{}
+
+// requestStream is the channel back to the client while a request is handled.
+type requestStream struct {
+	session *Session
+	// write sends on the request's own stream (the SSE response of a POST, or
+	// stdout in stdio mode); nil when the response is a single JSON body.
+	write messageWriter
+}
+
+// withRequestStream attaches the session and request stream to ctx.
+func withRequestStream(ctx context.Context, session *Session, write messageWriter) context.Context {
+	return context.WithValue(ctx, requestStreamKey{}, &requestStream{session: session, write: write})
+}
+
+// sendNotification sends a JSON-RPC notification to the client that made the
+// current request: on the request's own stream when it has one, otherwise on
+// the session's GET event stream.
+func sendNotification(ctx context.Context, method string, params interface{}) error {
+	rs, ok := ctx.Value(requestStreamKey{}).(*requestStream)
+	if !ok {
+		return fmt.Errorf("no client stream for notification %s", method)
+	}
+	msg := &JSONRPCNotification{JSONRPC: jsonRPCVersion, Method: method, Params: params}
+	if rs.write != nil {
+		return rs.write(msg)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return rs.session.send(data)
+}
+
+// handleMCP implements the Streamable HTTP transport on a single endpoint:
+// POST carries client messages, GET opens the server event stream and DELETE
+// ends the session. Requests from other origins get 403, as the transport
+// requires.
+func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkOrigin(r); err != nil {
+		s.writeError(w, http.StatusForbidden, nil, -32600, "Forbidden", err.Error())
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		s.handleMCPPost(w, r)
+	case http.MethodGet:
+		s.handleMCPGet(w, r)
+	case http.MethodDelete:
+		s.handleMCPDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleMCPPost(w http.ResponseWriter, r *http.Request) {
+	if v := r.Header.Get("Mcp-Protocol-Version"); v != "" && !supportedProtocolVersion(v) {
+		s.writeError(w, http.StatusBadRequest, nil, -32600, "Unsupported protocol version", v)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, nil, -32700, "Parse error", err.Error())
+		return
+	}
+	if isBatch(body) {
+		s.handleMCPBatch(w, r, body)
+		return
+	}
+
+	var req JSONRPCRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.writeError(w, http.StatusBadRequest, nil, -32700, "Parse error", err.Error())
+		return
+	}
+
+	session, ok := s.requestSession(w, r, &req)
+	if !ok {
+		return
+	}
+
+	// Notifications and responses are acknowledged without a body.
+	if req.ID == nil || req.Method == "" {
+		s.handleRequest(r.Context(), session, &req)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// Tool calls may emit notifications before their result, so clients that
+	// accept event streams get the response as SSE.
+	if req.Method == "tools/call" && acceptsEventStream(r) {
+		s.streamResponse(w, r, session, &req)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	ctx := withRequestStream(r.Context(), session, nil)
+	json.NewEncoder(w).Encode(s.handleRequest(ctx, session, &req))
+}
+
+// requestSession resolves the session of a POST. initialize always starts a
+// new session; other requests must name a live session in Mcp-Session-Id.
+// Requests without the header are served statelessly for clients that skip
+// session management. On failure the error response has been written.
+func (s *Server) requestSession(w http.ResponseWriter, r *http.Request, req *JSONRPCRequest) (*Session, bool) {
+	if req.Method == "initialize" {
+		session := newSession(uuid.New().String())
+		session.allowedTools = headerTools(r)
+		s.addSession(session)
+		w.Header().Set("Mcp-Session-Id", session.ID)
+		return session, true
+	}
+
+	sessionID := r.Header.Get("Mcp-Session-Id")
+	if sessionID == "" {
+		session := newSession(uuid.New().String())
+		session.allowedTools = headerTools(r)
+		return session, true
+	}
+
+	session, ok := s.lookupSession(sessionID)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, req.ID, -32001, "Session not found", sessionID)
+		return nil, false
+	}
+	w.Header().Set("Mcp-Session-Id", session.ID)
+	return session, true
+}
+
+// lookupSession returns a live session and marks it active.
+func (s *Server) lookupSession(id string) (*Session, bool) {
+	s.sessionMu.RLock()
+	session, ok := s.sessions[id]
+	s.sessionMu.RUnlock()
+	if ok {
+		session.touch()
+	}
+	return session, ok
+}
+
+// streamResponse answers a request with an SSE stream carrying any
+// notifications raised while it is handled, followed by the response.
+func (s *Server) streamResponse(w http.ResponseWriter, r *http.Request, session *Session, req *JSONRPCRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var (
+		mu   sync.Mutex
+		done bool
+	)
+	write := func(msg interface{}) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return fmt.Errorf("response stream closed")
+		}
+		writeSSE(w, "message", data)
+		flusher.Flush()
+		return nil
+	}
+
+	response := s.handleRequest(withRequestStream(r.Context(), session, write), session, req)
+	write(response)
+
+	mu.Lock()
+	done = true
+	mu.Unlock()
+}
+
+// handleMCPGet opens the event stream for server-initiated messages.
+func (s *Server) handleMCPGet(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.headerSession(w, r)
+	if !ok {
+		return
+	}
+	if !acceptsEventStream(r) {
+		http.Error(w, "GET /mcp requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	s.streamSession(w, r, session, false)
+}
+
+// handleMCPDelete ends a session at the client's request.
+func (s *Server) handleMCPDelete(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.headerSession(w, r)
+	if !ok {
+		return
+	}
+
+	s.removeSession(session, "session_closed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSSE serves the legacy (2024-11-05) event stream at /mcp/sse.
+func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkOrigin(r); err != nil {
+		s.writeError(w, http.StatusForbidden, nil, -32600, "Forbidden", err.Error())
+		return
+	}
+	session, ok := s.headerSession(w, r)
+	if !ok {
+		return
+	}
+	s.streamSession(w, r, session, true)
+}
+
+// headerSession returns the live session named by Mcp-Session-Id, writing
+// 400 when the header is missing and 404 when the session is unknown.
+func (s *Server) headerSession(w http.ResponseWriter, r *http.Request) (*Session, bool) {
+	sessionID := r.Header.Get("Mcp-Session-Id")
+	if sessionID == "" {
+		http.Error(w, "Missing Mcp-Session-Id header", http.StatusBadRequest)
+		return nil, false
+	}
+	session, ok := s.lookupSession(sessionID)
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil, false
+	}
+	return session, true
+}
+
+// streamSession relays the session's queued messages as SSE until the client
+// disconnects or the session ends. legacy sends the old "connected" event.
+func (s *Server) streamSession(w http.ResponseWriter, r *http.Request, session *Session, legacy bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Mcp-Session-Id", session.ID)
+	w.WriteHeader(http.StatusOK)
+
+	if legacy {
+		fmt.Fprintf(w, "event: connected\ndata: {\"sessionId\":\"%s\"}\n\n", session.ID)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			// An open stream keeps the session alive.
+			session.touch()
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case data, ok := <-session.events:
+			if !ok {
+				return
+			}
+			writeSSE(w, "message", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE writes one server-sent event.
+func writeSSE(w http.ResponseWriter, event string, data []byte) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// acceptsEventStream reports whether the client accepts text/event-stream.
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+func postMCP(t *testing.T, srv *Server, sessionID, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/mcp", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	return w
+}
+
+func initializeSession(t *testing.T, srv *Server, version string) (string, string) {
+	t.Helper()
+	w := postMCP(t, srv, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+version+`"}}`, nil)
+	var resp JSONRPCResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("parse initialize response: %v", err)
+	}
+	result := resp.Result.(map[string]interface{})
+	return w.Header().Get("Mcp-Session-Id"), result["protocolVersion"].(string)
+}
+
+func TestStreamableHTTP_InitializeNegotiatesVersion(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if id, version := initializeSession(t, srv, "2025-03-26"); id == "" || version != "2025-03-26" {
+		t.Fatalf("expected session and echoed version, got id=%q version=%q", id, version)
+	}
+	if _, version := initializeSession(t, srv, "1999-01-01"); version != mcpVersion {
+		t.Fatalf("expected latest version for unknown revision, got %q", version)
+	}
+
+	w := postMCP(t, srv, "", `{"jsonrpc":"2.0","id":2,"method":"ping"}`, map[string]string{"Mcp-Protocol-Version": "1999-01-01"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unsupported Mcp-Protocol-Version, got %d", w.Code)
+	}
+}
+
+func TestStreamableHTTP_NotificationAccepted(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	sessionID, _ := initializeSession(t, srv, mcpVersion)
+	w := postMCP(t, srv, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`, nil)
+	if w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+		t.Fatalf("expected 202 with empty body, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestStreamableHTTP_SessionLifecycle(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if w := postMCP(t, srv, "unknown-session", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown session, got %d", w.Code)
+	}
+
+	sessionID, _ := initializeSession(t, srv, mcpVersion)
+	if w := postMCP(t, srv, sessionID, `{"jsonrpc":"2.0","id":2,"method":"ping"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected ping on live session to succeed, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("DELETE", "/mcp", nil)
+	req.Header.Set("Mcp-Session-Id", sessionID)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 on DELETE, got %d", w.Code)
+	}
+
+	if w := postMCP(t, srv, sessionID, `{"jsonrpc":"2.0","id":3,"method":"ping"}`, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after DELETE, got %d", w.Code)
+	}
+}
+
+func TestStreamableHTTP_ToolCallAsEventStream(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	sessionID, _ := initializeSession(t, srv, mcpVersion)
+	w := postMCP(t, srv, sessionID,
+		`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"get_stats","arguments":{}}}`,
+		map[string]string{"Accept": "application/json, text/event-stream"})
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected SSE response, got %q", ct)
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "event: message\ndata: ") || !strings.Contains(body, `"id":7`) {
+		t.Fatalf("expected response as SSE message, got %q", body)
+	}
+}
+
+func TestStreamableHTTP_GetStreamDeliversServerMessages(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+
+	initResp, err := http.Post(ts.URL+"/mcp", "application/json",
+		bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	initResp.Body.Close()
+	sessionID := initResp.Header.Get("Mcp-Session-Id")
+
+	req, _ := http.NewRequest("GET", ts.URL+"/mcp", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Mcp-Session-Id", sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for GET stream, got %d", resp.StatusCode)
+	}
+
+	if err := srv.SendEvent(sessionID, JSONRPCNotification{JSONRPC: jsonRPCVersion, Method: "notifications/message"}); err != nil {
+		t.Fatalf("SendEvent: %v", err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed before the event arrived")
+			}
+			if strings.Contains(line, "notifications/message") {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for server message on GET stream")
+		}
+	}
+}
+
+func TestStreamableHTTP_ChecksOrigin(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`
+	post := func(origin string) int {
+		return postMCP(t, srv, "", initialize, map[string]string{"Origin": origin}).Code
+	}
+
+	// httptest requests are for example.com.
+	if got := post("http://example.com"); got != http.StatusOK {
+		t.Fatalf("expected the same host to be allowed, got %d", got)
+	}
+	if got := post("http://evil.example"); got != http.StatusForbidden {
+		t.Fatalf("expected another origin to be refused without auth, got %d", got)
+	}
+	req := httptest.NewRequest("GET", "/mcp/sse", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected /mcp/sse to refuse another origin, got %d", w.Code)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Server.CORS.AllowedOrigins = []string{"https://app.example"}
+	srv.ReloadConfig(cfg)
+	if got := post("https://app.example"); got != http.StatusOK {
+		t.Fatalf("expected a server.cors origin to be allowed, got %d", got)
+	}
+	if got := post("http://evil.example"); got != http.StatusForbidden {
+		t.Fatalf("expected an unlisted origin to be refused, got %d", got)
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// contextSession returns the MCP session a request came in on, if any.
+func contextSession(ctx context.Context) *Session {
+	if rs, ok := ctx.Value(requestStreamKey{}).(*requestStream); ok {
+		return rs.session
+	}
+	return nil
+}
+
+// watchTask subscribes the session of the current request to the completion
+// event of a task it spawned.
+func watchTask(ctx context.Context, taskID string) {
+	session := contextSession(ctx)
+	if session == nil {
+		return
+	}
+	session.mu.Lock()
+	if session.watchedTasks == nil {
+		session.watchedTasks = make(map[string]bool)
+	}
+	session.watchedTasks[taskID] = true
+	session.mu.Unlock()
+}
+
+// takeWatch reports whether the session waits for the task's completion
+// event, either because it spawned the task or subscribed to one of its
+// resources, and forgets the spawn watch.
+func (s *Session) takeWatch(taskID string) bool {
+	logURI, resultURI := taskResourceURIs(taskID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watched := s.watchedTasks[taskID] || s.subscriptions[logURI] || s.subscriptions[resultURI]
+	delete(s.watchedTasks, taskID)
+	return watched
+}
+
+// pushTaskFinished sends notifications/task_completed or
+// notifications/task_failed to the sessions watching a finished task, so
+// they do not have to poll wait_task. Paused tasks can be resumed and get
+// no event.
+func (s *Server) pushTaskFinished(task *models.Task) {
+	if !task.IsTerminal() || task.Status == models.TaskStatusPaused {
+		return
+	}
+	method := "notifications/task_failed"
+	if task.Status == models.TaskStatusCompleted {
+		method = "notifications/task_completed"
+	}
+	event := &JSONRPCNotification{
+		JSONRPC: jsonRPCVersion,
+		Method:  method,
+		Params: map[string]interface{}{
+			"task_id":     task.ID,
+			"status":      task.Status,
+			"exit_code":   task.ExitCode,
+			"error":       task.Error,
+			"output_tail": task.OutputTail,
+		},
+	}
+
+	for _, session := range s.sessionList() {
+		if session.takeWatch(task.ID) {
+			s.SendEvent(session.ID, event)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTaskFinishedEventPushedToSpawningSession(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	session := newSession("events")
+	srv.sessionMu.Lock()
+	srv.sessions[session.ID] = session
+	srv.sessionMu.Unlock()
+	other := newSession("other")
+	srv.sessionMu.Lock()
+	srv.sessions[other.ID] = other
+	srv.sessionMu.Unlock()
+
+	ctx := withRequestStream(context.Background(), session, nil)
+	resp := srv.handleToolsCall(ctx, &JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "tools/call",
+		Params:  json.RawMessage(`{"name":"spawn_agent","arguments":{"prompt":"p","work_dir":"/tmp","dependencies":["missing"]}}`),
+	})
+	text := resp.Result.(map[string]interface{})["content"].([]map[string]interface{})[0]["text"].(string)
+	var spawned struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := json.Unmarshal([]byte(text), &spawned); err != nil || spawned.TaskID == "" {
+		t.Fatalf("spawn_agent: %s", text)
+	}
+
+	if err := srv.orchestrator.Cancel(spawned.TaskID); err != nil {
+		t.Fatal(err)
+	}
+
+	drain := func(s *Session) string {
+		var msgs []string
+		for {
+			select {
+			case msg := <-s.events:
+				msgs = append(msgs, string(msg))
+			default:
+				return strings.Join(msgs, "\n")
+			}
+		}
+	}
+	got := drain(session)
+	if !strings.Contains(got, `"method":"notifications/task_failed"`) || !strings.Contains(got, `"status":"cancelled"`) ||
+		!strings.Contains(got, spawned.TaskID) {
+		t.Fatalf("expected task_failed event for %s, got %s", spawned.TaskID, got)
+	}
+	if strings.Contains(drain(other), "notifications/task_") {
+		t.Fatal("expected no task event for a session that did not spawn or subscribe")
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sevir/mesnada/internal/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// listenTLS serves HTTPS with the configured certificate files, or with
+// certificates obtained through ACME for the autocert hosts.
+func (s *Server) listenTLS(cfg config.TLSConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.CertFile != "" {
+		log.Printf("MCP server starting on %s (TLS)", s.addr)
+		return s.httpServer.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+	}
+
+	cacheDir := cfg.AutocertCacheDir
+	if cacheDir == "" {
+		home, _ := os.UserHomeDir()
+		cacheDir = filepath.Join(home, ".mesnada", "autocert")
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.AutocertHosts...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      cfg.AutocertEmail,
+	}
+	s.httpServer.TLSConfig = m.TLSConfig()
+
+	log.Printf("MCP server starting on %s (TLS, autocert hosts=%v)", s.addr, cfg.AutocertHosts)
+	return s.httpServer.ListenAndServeTLS("", "")
+}
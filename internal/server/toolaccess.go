@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// allowedToolsHeader lets a client narrow the tools of the session it
+// initializes, e.g. "X-Mesnada-Tools: list_tasks, get_*". It can only take
+// tools away: token and scope restrictions still apply.
+const allowedToolsHeader = "X-Mesnada-Tools"
+
+type tokenToolsKey struct{}
+
+// headerTools parses the allowedToolsHeader of a request; nil when absent.
+func headerTools(r *http.Request) []string {
+	value := r.Header.Get(allowedToolsHeader)
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	var tools []string
+	for _, tool := range strings.Split(value, ",") {
+		if tool = strings.TrimSpace(tool); tool != "" {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// matchTool reports whether name matches one of the tool names or patterns.
+func matchTool(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == name {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// toolDenied returns what forbids the current request from calling a tool:
+// the OAuth token scopes, the server.tool_access rule of its bearer token or
+// the session's own allowlist. It returns "" when the tool is allowed.
+func (s *Server) toolDenied(ctx context.Context, name string) string {
+	if !s.scopesAllow(ctx, name) {
+		return "token scopes"
+	}
+	if tools, ok := ctx.Value(tokenToolsKey{}).([]string); ok && !matchTool(tools, name) {
+		return "token tool access"
+	}
+	if rs, ok := ctx.Value(requestStreamKey{}).(*requestStream); ok && rs.session != nil &&
+		rs.session.allowedTools != nil && !matchTool(rs.session.allowedTools, name) {
+		return "session tool allowlist"
+	}
+	return ""
+}
+
+// toolAllowed reports whether the current request may call a tool.
+func (s *Server) toolAllowed(ctx context.Context, name string) bool {
+	return s.toolDenied(ctx, name) == ""
+}
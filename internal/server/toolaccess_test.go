@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+)
+
+func TestToolAccess_TokenRule(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.AuthTokens = []string{"admin"}
+	cfg.Server.ToolAccess = []config.ToolAccessRule{{Token: "observer", Tools: []string{"list_*", "get_*"}}}
+	srv.ReloadConfig(cfg)
+
+	call := func(token, tool string) string {
+		w := postMCP(t, srv, "", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"`+tool+`","arguments":{}}}`,
+			map[string]string{"Authorization": "Bearer " + token})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 for %s with %s, got %d", tool, token, w.Code)
+		}
+		return w.Body.String()
+	}
+
+	if body := call("observer", "get_stats"); strings.Contains(body, `"error"`) {
+		t.Fatalf("expected get_stats to be allowed, got %s", body)
+	}
+	if body := call("observer", "cancel_task"); !strings.Contains(body, "Tool not allowed by token tool access") {
+		t.Fatalf("expected cancel_task to be denied, got %s", body)
+	}
+	if body := call("admin", "get_stats"); strings.Contains(body, `"error"`) {
+		t.Fatalf("expected unrestricted token to call get_stats, got %s", body)
+	}
+}
+
+func TestToolAccess_TokenRuleScopesREST(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.ToolAccess = []config.ToolAccessRule{
+		{Token: "observer", Tools: []string{"list_*", "get_*"}},
+		{Token: "runner", Tools: []string{"spawn_agent"}, Scope: config.ScopeSpawn},
+	}
+	srv.ReloadConfig(cfg)
+
+	do := func(token, method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	spawn := `{"prompt":"p","work_dir":"/tmp","dependencies":["missing"]}`
+	if got := do("observer", "GET", "/api/tasks", ""); got != http.StatusOK {
+		t.Fatalf("expected a tool-restricted token to read tasks, got %d", got)
+	}
+	if got := do("observer", "POST", "/api/tasks", spawn); got != http.StatusForbidden {
+		t.Fatalf("expected a tool-restricted token to be denied POST /api/tasks, got %d", got)
+	}
+	if got := do("observer", "POST", "/ui/purge", ""); got != http.StatusForbidden {
+		t.Fatalf("expected a tool-restricted token to be denied purging, got %d", got)
+	}
+	if got := do("runner", "POST", "/api/tasks", spawn); got == http.StatusForbidden || got == http.StatusUnauthorized {
+		t.Fatalf("expected the spawn scope to allow POST /api/tasks, got %d", got)
+	}
+}
+
+func TestToolAccess_SessionHeader(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	w := postMCP(t, srv, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`,
+		map[string]string{allowedToolsHeader: "list_tasks, get_stats"})
+	sessionID := w.Header().Get("Mcp-Session-Id")
+
+	w = postMCP(t, srv, sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`, nil)
+	if !strings.Contains(w.Body.String(), `"name":"list_tasks"`) || strings.Contains(w.Body.String(), `"name":"spawn_agent"`) {
+		t.Fatalf("expected only the allowed tools to be listed, got %s", w.Body.String())
+	}
+	w = postMCP(t, srv, sessionID, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"delete_task","arguments":{"task_id":"x"}}}`, nil)
+	if !strings.Contains(w.Body.String(), "Tool not allowed by session tool allowlist") {
+		t.Fatalf("expected delete_task to be denied, got %s", w.Body.String())
+	}
+	w = postMCP(t, srv, sessionID, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"get_stats","arguments":{}}}`, nil)
+	if strings.Contains(w.Body.String(), `"error"`) {
+		t.Fatalf("expected get_stats to be allowed, got %s", w.Body.String())
+	}
+}
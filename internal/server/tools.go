@@ -5,40 +5,62 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/sevir/mesnada/internal/agent"
+	"github.com/sevir/mesnada/internal/config"
 	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/internal/persona"
 	"github.com/sevir/mesnada/pkg/models"
 )
 
+// maxSpawnBatchSize bounds the number of tasks a single spawn_batch creates.
+const maxSpawnBatchSize = 50
+
 // Tool represents an MCP tool definition.
 type Tool struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	InputSchema map[string]interface{} `json:"inputSchema"`
+	// OutputSchema describes the structuredContent of the tool's results.
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
 }
 
 func (s *Server) registerTools() {
 	s.tools["spawn_agent"] = s.toolSpawnAgent
+	s.tools["spawn_batch"] = s.toolSpawnBatch
 	s.tools["get_task"] = s.toolGetTask
 	s.tools["list_tasks"] = s.toolListTasks
+	s.tools["search_tasks"] = s.toolSearchTasks
+	s.tools["get_task_graph"] = s.toolGetTaskGraph
 	s.tools["wait_task"] = s.toolWaitTask
 	s.tools["wait_multiple"] = s.toolWaitMultiple
 	s.tools["cancel_task"] = s.toolCancelTask
 	s.tools["pause_task"] = s.toolPauseTask
 	s.tools["resume_task"] = s.toolResumeTask
+	s.tools["retry_task"] = s.toolRetryTask
+	s.tools["update_task"] = s.toolUpdateTask
+	s.tools["add_dependency"] = s.toolAddDependency
+	s.tools["remove_dependency"] = s.toolRemoveDependency
 	s.tools["delete_task"] = s.toolDeleteTask
 	s.tools["get_stats"] = s.toolGetStats
 	s.tools["get_task_output"] = s.toolGetTaskOutput
+	s.tools["get_task_output_chunk"] = s.toolGetTaskOutputChunk
 	s.tools["set_progress"] = s.toolSetProgress
+	s.tools["set_task_result"] = s.toolSetTaskResult
+	s.tools["list_engines"] = s.toolListEngines
+	s.tools["list_personas"] = s.toolListPersonas
 }
 
 // detectEngineForModel detects the appropriate engine for a given model
 // by checking if the model exists in each engine's configuration and if the
 // corresponding binary is installed.
 func (s *Server) detectEngineForModel(modelID string) models.Engine {
-	if s.config.Engines == nil {
-		return ""
+	if s.appConfig().Engines == nil {
+		return s.probedEngineForModel(modelID)
 	}
 
 	// Engine priority order for checking
@@ -51,21 +73,68 @@ func (s *Server) detectEngineForModel(modelID string) models.Engine {
 		{models.EngineGemini, "gemini"},
 		{models.EngineOpenCode, "opencode"},
 		{models.EngineCopilot, "copilot"},
+		{models.EngineOllamaClaude, "claude"},
+		{models.EngineOllamaOpenCode, "opencode"},
 	}
 
 	for _, e := range engineOrder {
 		// Check if model exists in this engine's configuration
-		if s.config.GetModelForEngine(string(e.engine), modelID) != nil {
+		if s.appConfig().GetModelForEngine(string(e.engine), modelID) != nil {
 			// Check if binary is installed
-			if _, err := exec.LookPath(e.binaryName); err == nil {
+			if _, err := exec.LookPath(s.appConfig().GetBinaryForEngine(string(e.engine), e.binaryName)); err == nil {
 				return e.engine
 			}
 		}
 	}
 
+	return s.probedEngineForModel(modelID)
+}
+
+// probedEngineForModel returns the first available engine whose last probe
+// reported the model, or "" when none did.
+func (s *Server) probedEngineForModel(modelID string) models.Engine {
+	for _, info := range s.orchestrator.ListEngines(context.Background(), false) {
+		if info.Available && slices.Contains(info.SupportedModels, modelID) {
+			return info.Engine
+		}
+	}
 	return ""
 }
 
+// engineModelIDs lists the models of each engine: the configured ones, then
+// those the last probe found an available engine supports that are not
+// configured. Engines without configured models and without probed models
+// are left out.
+func (s *Server) engineModelIDs() map[string][]string {
+	cfg := s.appConfig()
+	ids := make(map[string][]string)
+	for engineName := range cfg.Engines {
+		ids[engineName] = cfg.GetModelIDsForEngine(engineName)
+	}
+	for _, info := range s.orchestrator.ListEngines(context.Background(), false) {
+		if !info.Available || len(info.SupportedModels) == 0 {
+			continue
+		}
+		engineName := string(info.Engine)
+		for _, modelID := range info.SupportedModels {
+			if !slices.Contains(ids[engineName], modelID) {
+				ids[engineName] = append(ids[engineName], modelID)
+			}
+		}
+	}
+	return ids
+}
+
+// secretNames returns the configured secret names in sorted order.
+func (s *Server) secretNames() []string {
+	names := make([]string, 0, len(s.appConfig().Secrets))
+	for name := range s.appConfig().Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (s *Server) getToolDefinitions() []Tool {
 	// Get available personas for dynamic description
 	personas := s.orchestrator.ListPersonas()
@@ -74,94 +143,183 @@ func (s *Server) getToolDefinitions() []Tool {
 		personaDesc += fmt.Sprintf(". Available personas: %v", personas)
 	}
 
+	secretsDesc := "Names of configured secrets to inject as environment variables into the agent. Values are never stored or returned"
+	if names := s.secretNames(); len(names) > 0 {
+		secretsDesc += fmt.Sprintf(". Available secrets: %v", names)
+	}
+
 	// Build dynamic model description
 	modelDesc := "AI model to use. Available models depend on the selected engine. "
-	if s.config.Engines != nil && len(s.config.Engines) > 0 {
+	engineModels := s.engineModelIDs()
+	if len(engineModels) > 0 {
 		modelDesc += "Models by engine: "
-		for engineName := range s.config.Engines {
-			modelDesc += fmt.Sprintf("%s: %v; ", engineName, s.config.GetModelIDsForEngine(engineName))
+		engineNames := make([]string, 0, len(engineModels))
+		for engineName := range engineModels {
+			engineNames = append(engineNames, engineName)
 		}
-	} else if len(s.config.Models) > 0 {
-		modelDesc += fmt.Sprintf("Available: %v", s.config.GetModelIDsForEngine(""))
+		sort.Strings(engineNames)
+		for _, engineName := range engineNames {
+			modelDesc += fmt.Sprintf("%s: %v; ", engineName, engineModels[engineName])
+		}
+	} else if len(s.appConfig().Models) > 0 {
+		modelDesc += fmt.Sprintf("Available: %v", s.appConfig().GetModelIDsForEngine(""))
 	}
 
 	// Get all model IDs for enum (for backward compatibility with clients that expect it)
 	allModels := make(map[string]bool)
-	if s.config.Engines != nil {
-		for engineName := range s.config.Engines {
-			for _, modelID := range s.config.GetModelIDsForEngine(engineName) {
-				allModels[modelID] = true
-			}
+	for _, modelIDs := range engineModels {
+		for _, modelID := range modelIDs {
+			allModels[modelID] = true
 		}
 	}
 	// Add global models
-	for _, modelID := range s.config.GetModelIDsForEngine("") {
+	for _, modelID := range s.appConfig().GetModelIDsForEngine("") {
 		allModels[modelID] = true
 	}
+	// Aliases are accepted wherever a model is.
+	aliasNames := make([]string, 0, len(s.appConfig().Aliases))
+	for alias, target := range s.appConfig().Aliases {
+		if target != "" {
+			allModels[alias] = true
+			aliasNames = append(aliasNames, alias)
+		}
+	}
+	if len(aliasNames) > 0 {
+		sort.Strings(aliasNames)
+		modelDesc += " Aliases: "
+		for i, alias := range aliasNames {
+			if i > 0 {
+				modelDesc += ", "
+			}
+			modelDesc += fmt.Sprintf("%s -> %s", alias, s.appConfig().Aliases[alias])
+		}
+	}
 	modelEnum := make([]string, 0, len(allModels))
 	for modelID := range allModels {
 		modelEnum = append(modelEnum, modelID)
 	}
+	// Stable order so tools/list only changes when the models do.
+	sort.Strings(modelEnum)
+
+	engineDesc := "CLI engine to use: 'copilot' (GitHub Copilot CLI, default), 'claude-code' (Anthropic Claude CLI), 'gemini-cli' (Google Gemini CLI), 'opencode' (OpenCode.ai CLI), 'ollama-claude' (Ollama Claude interface), or 'ollama-opencode' (Ollama OpenCode interface). If not specified but model is provided, engine will be auto-detected based on the model configuration."
+	if unavailable := s.unavailableEngines(); len(unavailable) > 0 {
+		engineDesc += fmt.Sprintf(" Not available on this host: %v", unavailable)
+	}
+
+	spawnProperties := map[string]interface{}{
+		"prompt": map[string]interface{}{
+			"type":        "string",
+			"description": "The prompt/instruction for the agent to execute",
+		},
+		"work_dir": map[string]interface{}{
+			"type":        "string",
+			"description": "Working directory for the agent (absolute path)",
+		},
+		"engine": map[string]interface{}{
+			"type":        "string",
+			"description": engineDesc,
+			"enum":        []string{"copilot", "claude-code", "gemini-cli", "opencode", "ollama-claude", "ollama-opencode"},
+		},
+		"model": map[string]interface{}{
+			"type":        "string",
+			"description": modelDesc,
+			"enum":        modelEnum,
+		},
+		"background": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Run in background (true) or wait for completion (false). Default: true",
+			"default":     true,
+		},
+		"timeout": map[string]interface{}{
+			"type":        "string",
+			"description": "Timeout duration (e.g., '30m', '1h'). Empty for no timeout",
+		},
+		"dependencies": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]string{"type": "string"},
+			"description": "List of task IDs that must complete before this task starts",
+		},
+		"tags": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]string{"type": "string"},
+			"description": "Tags for organizing and filtering tasks",
+		},
+		"mcp_config": map[string]interface{}{
+			"type":        "string",
+			"description": "Additional MCP configuration JSON or file path (prefix with @)",
+		},
+		"extra_args": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]string{"type": "string"},
+			"description": "Additional command-line arguments for the CLI",
+		},
+		"persona": map[string]interface{}{
+			"type":        "string",
+			"description": personaDesc,
+		},
+		"sandbox": map[string]interface{}{
+			"type":        "string",
+			"description": "Where to run the agent: 'none' (directly on the host), 'docker' (inside a container with work_dir bind-mounted) or 'kubernetes' (as a Kubernetes Job). Defaults to the engine or orchestrator sandbox setting; an engine the config sandboxes cannot be moved to another mode.",
+			"enum":        []string{models.SandboxNone, models.SandboxDocker, models.SandboxKubernetes},
+		},
+		"secrets": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": secretsDesc,
+		},
+		"auto_commit": map[string]interface{}{
+			"type":        "boolean",
+			"description": "When the task completes in a git work_dir, commit its changes to a mesnada/<task_id> branch (the working tree and current branch are left untouched). Defaults to the orchestrator auto_commit setting.",
+		},
+		"metadata": map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": map[string]string{"type": "string"},
+			"description":          "Free-form key/value annotations stored with the task (e.g. ticket IDs). Can be changed later with update_task",
+		},
+	}
+
+	// spawn_batch items take the spawn_agent arguments, except background,
+	// plus dependencies on other items of the batch.
+	batchItemProperties := map[string]interface{}{
+		"depends_on": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]string{"type": "integer"},
+			"description": "Indexes (0-based) of other items in this batch that must complete before this task starts",
+		},
+	}
+	for name, prop := range spawnProperties {
+		if name != "background" {
+			batchItemProperties[name] = prop
+		}
+	}
 
 	return []Tool{
 		{
 			Name:        "spawn_agent",
 			Description: "Spawn a new CLI agent to execute a task. Supports multiple engines: 'copilot' (GitHub Copilot CLI, default), 'claude-code' (Anthropic Claude CLI), 'gemini-cli' (Google Gemini CLI), 'opencode' (OpenCode.ai CLI), 'ollama-claude' (Ollama Claude interface), or 'ollama-opencode' (Ollama OpenCode interface). The agent runs in the specified working directory with full tool access. Use background=true for long-running tasks.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": spawnProperties,
+				"required":   []string{"prompt"},
+			},
+		},
+		{
+			Name:        "spawn_batch",
+			Description: "Spawn several agents in one call. Each item takes the spawn_agent arguments plus depends_on, the indexes of other items in the batch it must wait for. Either all tasks are created or none is, and no task starts before the whole batch is created. Batch tasks always run in the background; use wait_multiple to wait for them.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"prompt": map[string]interface{}{
-						"type":        "string",
-						"description": "The prompt/instruction for the agent to execute",
-					},
-					"work_dir": map[string]interface{}{
-						"type":        "string",
-						"description": "Working directory for the agent (absolute path)",
-					},
-					"engine": map[string]interface{}{
-						"type":        "string",
-						"description": "CLI engine to use: 'copilot' (GitHub Copilot CLI, default), 'claude-code' (Anthropic Claude CLI), 'gemini-cli' (Google Gemini CLI), 'opencode' (OpenCode.ai CLI), 'ollama-claude' (Ollama Claude interface), or 'ollama-opencode' (Ollama OpenCode interface). If not specified but model is provided, engine will be auto-detected based on the model configuration.",
-						"enum":        []string{"copilot", "claude-code", "gemini-cli", "opencode", "ollama-claude", "ollama-opencode"},
-					},
-					"model": map[string]interface{}{
-						"type":        "string",
-						"description": modelDesc,
-						"enum":        modelEnum,
-					},
-					"background": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Run in background (true) or wait for completion (false). Default: true",
-						"default":     true,
-					},
-					"timeout": map[string]interface{}{
-						"type":        "string",
-						"description": "Timeout duration (e.g., '30m', '1h'). Empty for no timeout",
-					},
-					"dependencies": map[string]interface{}{
-						"type":        "array",
-						"items":       map[string]string{"type": "string"},
-						"description": "List of task IDs that must complete before this task starts",
-					},
-					"tags": map[string]interface{}{
-						"type":        "array",
-						"items":       map[string]string{"type": "string"},
-						"description": "Tags for organizing and filtering tasks",
-					},
-					"mcp_config": map[string]interface{}{
-						"type":        "string",
-						"description": "Additional MCP configuration JSON or file path (prefix with @)",
-					},
-					"extra_args": map[string]interface{}{
+					"tasks": map[string]interface{}{
 						"type":        "array",
-						"items":       map[string]string{"type": "string"},
-						"description": "Additional command-line arguments for the CLI",
-					},
-					"persona": map[string]interface{}{
-						"type":        "string",
-						"description": personaDesc,
+						"description": fmt.Sprintf("The tasks to spawn (at most %d)", maxSpawnBatchSize),
+						"items": map[string]interface{}{
+							"type":       "object",
+							"properties": batchItemProperties,
+							"required":   []string{"prompt"},
+						},
 					},
 				},
-				"required": []string{"prompt"},
+				"required": []string{"tasks"},
 			},
 		},
 		{
@@ -210,6 +368,80 @@ func (s *Server) getToolDefinitions() []Tool {
 				},
 			},
 		},
+		{
+			Name:        "search_tasks",
+			Description: "Search tasks to find earlier related work. The query matches tasks containing every word (case-insensitive) in their ID, prompt, summary, error, reported result, work dir, tags or metadata; combine it with status, tags, engine, model and creation date filters. Returns task summaries, newest first unless sort is given",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Words the task must contain",
+					},
+					"status": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "string",
+							"enum": []string{"pending", "running", "paused", "completed", "failed", "cancelled"},
+						},
+						"description": "Filter by task status",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]string{"type": "string"},
+						"description": "Filter by tags (tasks must have all specified tags)",
+					},
+					"engine": map[string]interface{}{
+						"type":        "string",
+						"description": "Filter by engine",
+						"enum":        []string{"copilot", "claude-code", "gemini-cli", "opencode", "ollama-claude", "ollama-opencode"},
+					},
+					"created_after": map[string]interface{}{
+						"type":        "string",
+						"description": "Only tasks created at or after this time (RFC 3339 or YYYY-MM-DD)",
+					},
+					"created_before": map[string]interface{}{
+						"type":        "string",
+						"description": "Only tasks created before this time (RFC 3339 or YYYY-MM-DD)",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"description": "Filter by model",
+					},
+					"sort": map[string]interface{}{
+						"type":        "string",
+						"description": "Sort by created_at, started_at or completed_at, ascending; prefix with - for descending. Defaults to newest first",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of tasks to return",
+						"default":     20,
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of tasks to skip",
+						"default":     0,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get_task_graph",
+			Description: "Get the dependency graph around a task (everything it depends on and everything waiting for it) or of all tasks with a tag. Returns nodes with their status and edges from each dependency to the task waiting for it. With neither task_id nor tag, returns the graph of all tasks.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The task whose dependency graph to return",
+					},
+					"tag": map[string]interface{}{
+						"type":        "string",
+						"description": "Return the graph of the tasks with this tag and their dependencies",
+					},
+				},
+			},
+		},
 		{
 			Name:        "wait_task",
 			Description: "Wait for a specific task to complete. Returns the task when it reaches a terminal state (completed, failed, or cancelled)",
@@ -282,7 +514,7 @@ func (s *Server) getToolDefinitions() []Tool {
 		},
 		{
 			Name:        "resume_task",
-			Description: "Resume a paused task by spawning a new agent task that continues work",
+			Description: "Resume a paused task by spawning a new agent task that continues work. Claude tasks continue their original session (claude --resume); other engines get a prompt referencing the previous log",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -313,10 +545,110 @@ func (s *Server) getToolDefinitions() []Tool {
 						"items":       map[string]string{"type": "string"},
 						"description": "Tags for organizing and filtering tasks (optional; defaults to previous task tags)",
 					},
+					"persona": map[string]interface{}{
+						"type":        "string",
+						"description": personaDesc + ". Defaults to the paused task's persona when the agent starts a fresh session",
+					},
 				},
 				"required": []string{"task_id", "prompt"},
 			},
 		},
+		{
+			Name:        "retry_task",
+			Description: "Retry a failed or cancelled task: spawns a new task with the same parameters (prompt, work_dir, engine, model, dependencies, tags, ...) and an incremented attempt counter. The previous error is appended to the prompt unless include_error is false.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The failed or cancelled task ID to retry",
+					},
+					"prompt_addendum": map[string]interface{}{
+						"type":        "string",
+						"description": "Extra instructions appended to the original prompt",
+					},
+					"include_error": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Append the previous attempt's error to the prompt. Default: true",
+						"default":     true,
+					},
+					"background": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Run in background (true) or wait for completion (false). Default: true",
+						"default":     true,
+					},
+				},
+				"required": []string{"task_id"},
+			},
+		},
+		{
+			Name:        "update_task",
+			Description: "Change the mutable fields of a task. Pending tasks accept tags, priority, timeout and metadata; running tasks only tags and metadata. Finished tasks cannot be updated. Omitted fields are left unchanged.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The task ID to update",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]string{"type": "string"},
+						"description": "Replaces the task tags",
+					},
+					"priority": map[string]interface{}{
+						"type":        "integer",
+						"description": "New priority (pending tasks only)",
+					},
+					"timeout": map[string]interface{}{
+						"type":        "string",
+						"description": "New timeout duration (e.g., '30m', '1h'); empty removes it (pending tasks only)",
+					},
+					"metadata": map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": map[string]string{"type": "string"},
+						"description":          "Keys to set in the task metadata; an empty value removes the key",
+					},
+				},
+				"required": []string{"task_id"},
+			},
+		},
+		{
+			Name:        "add_dependency",
+			Description: "Make a pending task also wait for another task. Rejected if the dependency does not exist or would create a dependency cycle",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The pending task ID to change",
+					},
+					"dependency_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The task ID the task must wait for",
+					},
+				},
+				"required": []string{"task_id", "dependency_id"},
+			},
+		},
+		{
+			Name:        "remove_dependency",
+			Description: "Remove a dependency from a pending task. The task starts right away if its remaining dependencies are satisfied",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The pending task ID to change",
+					},
+					"dependency_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The dependency task ID to remove",
+					},
+				},
+				"required": []string{"task_id", "dependency_id"},
+			},
+		},
 		{
 			Name:        "delete_task",
 			Description: "Delete a completed, failed, or cancelled task from the store",
@@ -339,6 +671,28 @@ func (s *Server) getToolDefinitions() []Tool {
 				"properties": map[string]interface{}{},
 			},
 		},
+		{
+			Name:        "list_personas",
+			Description: "List the configured personas (roles) with a one-line summary. Pass a persona name as spawn_agent's or resume_task's persona to prepend its instructions to the prompt, with any engine",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "list_engines",
+			Description: "List the CLI engines with their binary, availability, version, default model and the models configured for them. Use it to choose an installed engine and a valid model before spawning tasks. 'name' is the value to pass as spawn_agent's engine",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"refresh": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Probe the engine binaries again instead of returning the cached results",
+						"default":     false,
+					},
+				},
+			},
+		},
 		{
 			Name:        "get_task_output",
 			Description: "Get the output (stdout/stderr) of a task. For running tasks, returns current output. For completed tasks, returns full or tail output",
@@ -357,6 +711,28 @@ func (s *Server) getToolDefinitions() []Tool {
 				"required": []string{"task_id"},
 			},
 		},
+		{
+			Name:        "get_task_output_chunk",
+			Description: "Read a task's log incrementally from a byte offset. Pass the returned next_offset on the next call to get only new output. Offset 0 on a large log returns its tail",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The task ID",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Byte offset to read from (default: 0)",
+					},
+					"max_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum bytes to return (default: 65536, max: 1048576)",
+					},
+				},
+				"required": []string{"task_id"},
+			},
+		},
 		{
 			Name:        "set_progress",
 			Description: "Update the progress of a running task. This tool should be called by the agent task itself to report its progress. The percentage will be sanitized to be between 0 and 100.",
@@ -376,75 +752,117 @@ func (s *Server) getToolDefinitions() []Tool {
 						"description": "Brief description of current progress or what the task is currently doing",
 					},
 				},
-				"required": []string{"task_id", "percentage"},
+				"required": []string{"task_id", "percentage"},
+			},
+		},
+		{
+			Name:        "set_task_result",
+			Description: "Report the final result of a running task. This tool should be called by the agent task itself before it finishes. The result is stored as the task's result and returned directly by wait_task, so the caller does not need to parse the agent output.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The task ID to report the result for",
+					},
+					"status": map[string]interface{}{
+						"type":        "string",
+						"description": "Outcome of the work",
+						"enum":        []string{models.ResultStatusSuccess, models.ResultStatusPartial, models.ResultStatusFailure},
+					},
+					"summary": map[string]interface{}{
+						"type":        "string",
+						"description": "Short summary of what was done",
+					},
+					"data": map[string]interface{}{
+						"description": "Any JSON value with the structured result (e.g. changed files, findings)",
+					},
+				},
+				"required": []string{"task_id", "status"},
 			},
 		},
 	}
 }
 
-func (s *Server) toolSpawnAgent(ctx context.Context, params json.RawMessage) (interface{}, error) {
-	var req struct {
-		Prompt       string   `json:"prompt"`
-		WorkDir      string   `json:"work_dir"`
-		Engine       string   `json:"engine"`
-		Model        string   `json:"model"`
-		Background   *bool    `json:"background"`
-		Timeout      string   `json:"timeout"`
-		Dependencies []string `json:"dependencies"`
-		Tags         []string `json:"tags"`
-		MCPConfig    string   `json:"mcp_config"`
-		ExtraArgs    []string `json:"extra_args"`
-		Persona      string   `json:"persona"`
-	}
+// spawnArgs are the spawn_agent arguments, also used for spawn_batch items.
+
+type spawnArgs struct {
+	Prompt       string            `json:"prompt"`
+	WorkDir      string            `json:"work_dir"`
+	Engine       string            `json:"engine"`
+	Model        string            `json:"model"`
+	Background   *bool             `json:"background"`
+	Timeout      string            `json:"timeout"`
+	Dependencies []string          `json:"dependencies"`
+	Tags         []string          `json:"tags"`
+	MCPConfig    string            `json:"mcp_config"`
+	ExtraArgs    []string          `json:"extra_args"`
+	Persona      string            `json:"persona"`
+	Sandbox      string            `json:"sandbox"`
+	Secrets      []string          `json:"secrets"`
+	AutoCommit   *bool             `json:"auto_commit"`
+	Metadata     map[string]string `json:"metadata"`
+}
 
-	if err := json.Unmarshal(params, &req); err != nil {
-		return nil, fmt.Errorf("invalid parameters: %w", err)
+// spawnRequest maps tool arguments to an orchestrator spawn request.
+func (s *Server) spawnRequest(args spawnArgs) models.SpawnRequest {
+	// Default to background execution
+	background := true
+	if args.Background != nil {
+		background = *args.Background
 	}
 
-	if req.Prompt == "" {
-		return nil, fmt.Errorf("prompt is required")
+	// Auto-detect engine based on model if engine not specified
+	engine := engineFromToolName(args.Engine)
+	if engine == "" && args.Model != "" {
+		engine = s.detectEngineForModel(s.appConfig().ResolveModel(args.Model))
 	}
 
-	// Default to background execution
-	background := true
-	if req.Background != nil {
-		background = *req.Background
+	return models.SpawnRequest{
+		Prompt:       args.Prompt,
+		WorkDir:      args.WorkDir,
+		Engine:       engine,
+		Model:        args.Model,
+		Background:   background,
+		Timeout:      args.Timeout,
+		Dependencies: args.Dependencies,
+		Tags:         args.Tags,
+		MCPConfig:    args.MCPConfig,
+		ExtraArgs:    args.ExtraArgs,
+		Persona:      args.Persona,
+		Sandbox:      args.Sandbox,
+		Secrets:      args.Secrets,
+		AutoCommit:   args.AutoCommit,
+		Metadata:     args.Metadata,
 	}
+}
 
-	// Map tool engine names to internal engine names
-	// Tool uses "claude-code" and "gemini-cli" for disambiguation
-	// but internally we use "claude" and "gemini"
-	engineName := req.Engine
-	switch engineName {
-	case "claude-code":
-		engineName = "claude"
-	case "gemini-cli":
-		engineName = "gemini"
+func (s *Server) toolSpawnAgent(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req spawnArgs
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
 	}
 
-	// Auto-detect engine based on model if engine not specified
-	engine := models.Engine(engineName)
-	if engine == "" && req.Model != "" {
-		engine = s.detectEngineForModel(req.Model)
+	if req.Prompt == "" {
+		return nil, fmt.Errorf("prompt is required")
 	}
 
-	task, err := s.orchestrator.Spawn(ctx, models.SpawnRequest{
-		Prompt:       req.Prompt,
-		WorkDir:      req.WorkDir,
-		Engine:       engine,
-		Model:        req.Model,
-		Background:   background,
-		Timeout:      req.Timeout,
-		Dependencies: req.Dependencies,
-		Tags:         req.Tags,
-		MCPConfig:    req.MCPConfig,
-		ExtraArgs:    req.ExtraArgs,
-		Persona:      req.Persona,
-	})
+	spawn := s.spawnRequest(req)
+	background, engine := spawn.Background, spawn.Engine
+
+	task, err := s.orchestrator.Spawn(ctx, spawn)
 
 	if err != nil {
 		return nil, err
 	}
+	watchTask(ctx, task.ID)
+
+	// Spawn only waits for the agent to start; block until it finishes.
+	if !background && !task.IsTerminal() {
+		if finished, err := s.waitTask(ctx, task.ID, 0); err == nil {
+			task = finished
+		}
+	}
 
 	result := map[string]interface{}{
 		"task_id":    task.ID,
@@ -461,7 +879,7 @@ func (s *Server) toolSpawnAgent(ctx context.Context, params json.RawMessage) (in
 			
 			// If there was an error, include available models for the engine to help retry
 			if engine != "" {
-				availableModels := s.config.GetModelIDsForEngine(string(engine))
+				availableModels := s.appConfig().GetModelIDsForEngine(string(engine))
 				if len(availableModels) > 0 {
 					result["available_models"] = availableModels
 					result["engine"] = string(engine)
@@ -474,6 +892,59 @@ func (s *Server) toolSpawnAgent(ctx context.Context, params json.RawMessage) (in
 	return result, nil
 }
 
+func (s *Server) toolSpawnBatch(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Tasks []struct {
+			spawnArgs
+			DependsOn []int `json:"depends_on"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if len(req.Tasks) == 0 {
+		return nil, fmt.Errorf("tasks is required")
+	}
+	if len(req.Tasks) > maxSpawnBatchSize {
+		return nil, fmt.Errorf("too many tasks: %d (max %d)", len(req.Tasks), maxSpawnBatchSize)
+	}
+
+	spawns := make([]models.SpawnRequest, len(req.Tasks))
+	dependsOn := make([][]int, len(req.Tasks))
+	for i, item := range req.Tasks {
+		if item.Prompt == "" {
+			return nil, fmt.Errorf("item %d: prompt is required", i)
+		}
+		spawns[i] = s.spawnRequest(item.spawnArgs)
+		spawns[i].Background = true
+		dependsOn[i] = item.DependsOn
+	}
+
+	tasks, err := s.orchestrator.SpawnBatch(ctx, spawns, dependsOn)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(tasks))
+	results := make([]map[string]interface{}, len(tasks))
+	for i, task := range tasks {
+		watchTask(ctx, task.ID)
+		ids[i] = task.ID
+		results[i] = map[string]interface{}{
+			"index":        i,
+			"task_id":      task.ID,
+			"status":       task.Status,
+			"dependencies": task.Dependencies,
+		}
+	}
+
+	return map[string]interface{}{
+		"task_ids": ids,
+		"tasks":    results,
+	}, nil
+}
+
 func (s *Server) toolGetTask(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var req struct {
 		TaskID string `json:"task_id"`
@@ -494,7 +965,7 @@ func (s *Server) toolGetTask(ctx context.Context, params json.RawMessage) (inter
 	}
 	
 	if task.Status == models.TaskStatusFailed && task.Engine != "" {
-		availableModels := s.config.GetModelIDsForEngine(string(task.Engine))
+		availableModels := s.appConfig().GetModelIDsForEngine(string(task.Engine))
 		if len(availableModels) > 0 {
 			result["available_models"] = availableModels
 			result["suggestion"] = fmt.Sprintf("Task failed. Try one of these models for engine '%s': %v", task.Engine, availableModels)
@@ -549,6 +1020,115 @@ func (s *Server) toolListTasks(ctx context.Context, params json.RawMessage) (int
 	}, nil
 }
 
+// taskSearch holds the search_tasks parameters, shared by the MCP tool and
+// GET /api/tasks/search.
+type taskSearch struct {
+	Query         string   `json:"query"`
+	Status        []string `json:"status"`
+	Tags          []string `json:"tags"`
+	Engine        string   `json:"engine"`
+	Model         string   `json:"model"`
+	CreatedAfter  string   `json:"created_after"`
+	CreatedBefore string   `json:"created_before"`
+	Sort          string   `json:"sort"`
+	Limit         int      `json:"limit"`
+	Offset        int      `json:"offset"`
+}
+
+func (s *Server) toolSearchTasks(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req taskSearch
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	return s.searchTasks(req)
+}
+
+// searchTasks runs a task search and returns the page of summaries together
+// with the total number of matches.
+func (s *Server) searchTasks(req taskSearch) (map[string]interface{}, error) {
+	var statuses []models.TaskStatus
+	for _, s := range req.Status {
+		statuses = append(statuses, models.TaskStatus(s))
+	}
+
+	after, err := parseTimeBound(req.CreatedAfter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid created_after: %w", err)
+	}
+	before, err := parseTimeBound(req.CreatedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("invalid created_before: %w", err)
+	}
+	if !models.ValidTaskSort(req.Sort) {
+		return nil, fmt.Errorf("invalid sort: %s (use %s, optionally prefixed with -)", req.Sort, strings.Join(models.TaskSortFields(), ", "))
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	tasks, err := s.orchestrator.ListTasks(models.ListRequest{
+		Status:        statuses,
+		Tags:          req.Tags,
+		Query:         req.Query,
+		Engine:        engineFromToolName(req.Engine),
+		Model:         req.Model,
+		CreatedAfter:  after,
+		CreatedBefore: before,
+		Sort:          req.Sort,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := len(tasks)
+	if req.Offset > len(tasks) {
+		req.Offset = len(tasks)
+	}
+	tasks = tasks[req.Offset:]
+	if len(tasks) > req.Limit {
+		tasks = tasks[:req.Limit]
+	}
+
+	summaries := make([]models.TaskSummary, len(tasks))
+	for i, t := range tasks {
+		summaries[i] = t.ToSummary()
+	}
+
+	return map[string]interface{}{
+		"tasks":   summaries,
+		"total":   len(summaries),
+		"matches": matches,
+	}, nil
+}
+
+// parseTimeBound parses an RFC 3339 time or a YYYY-MM-DD date (UTC).
+func parseTimeBound(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func (s *Server) toolGetTaskGraph(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		TaskID string `json:"task_id"`
+		Tag    string `json:"tag"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if req.TaskID != "" && req.Tag != "" {
+		return nil, fmt.Errorf("task_id and tag are mutually exclusive")
+	}
+
+	return s.orchestrator.TaskGraph(req.TaskID, req.Tag)
+}
+
 func (s *Server) toolWaitTask(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var req struct {
 		TaskID  string `json:"task_id"`
@@ -568,7 +1148,7 @@ func (s *Server) toolWaitTask(ctx context.Context, params json.RawMessage) (inte
 		}
 	}
 
-	task, err := s.orchestrator.Wait(ctx, req.TaskID, timeout)
+	task, err := s.waitTask(ctx, req.TaskID, timeout)
 	if err != nil {
 		// Still return task state even on timeout
 		if task != nil {
@@ -584,6 +1164,7 @@ func (s *Server) toolWaitTask(ctx context.Context, params json.RawMessage) (inte
 	return map[string]interface{}{
 		"task":        task,
 		"output_tail": task.OutputTail,
+		"result":      task.Result,
 	}, nil
 }
 
@@ -677,6 +1258,7 @@ func (s *Server) toolResumeTask(ctx context.Context, params json.RawMessage) (in
 		Background *bool     `json:"background"`
 		Timeout    string    `json:"timeout"`
 		Tags       *[]string `json:"tags"`
+		Persona    string    `json:"persona"`
 	}
 
 	if err := json.Unmarshal(params, &req); err != nil {
@@ -694,6 +1276,82 @@ func (s *Server) toolResumeTask(ctx context.Context, params json.RawMessage) (in
 		Background: background,
 		Timeout:    req.Timeout,
 		Tags:       req.Tags,
+		Persona:    req.Persona,
+	})
+	if err != nil {
+		return nil, err
+	}
+	watchTask(ctx, task.ID)
+
+	return map[string]interface{}{
+		"task_id": task.ID,
+		"task":    task,
+	}, nil
+}
+
+func (s *Server) toolRetryTask(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		TaskID         string `json:"task_id"`
+		PromptAddendum string `json:"prompt_addendum"`
+		IncludeError   *bool  `json:"include_error"`
+		Background     *bool  `json:"background"`
+	}
+
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	includeError := true
+	if req.IncludeError != nil {
+		includeError = *req.IncludeError
+	}
+	background := true
+	if req.Background != nil {
+		background = *req.Background
+	}
+
+	task, err := s.orchestrator.Retry(ctx, req.TaskID, orchestrator.RetryOptions{
+		PromptAddendum: req.PromptAddendum,
+		IncludeError:   includeError,
+		Background:     background,
+	})
+	if err != nil {
+		return nil, err
+	}
+	watchTask(ctx, task.ID)
+
+	if !background && !task.IsTerminal() {
+		if finished, err := s.waitTask(ctx, task.ID, 0); err == nil {
+			task = finished
+		}
+	}
+
+	return map[string]interface{}{
+		"task_id":  task.ID,
+		"retry_of": task.RetryOf,
+		"attempt":  task.Attempt,
+		"task":     task,
+	}, nil
+}
+
+func (s *Server) toolUpdateTask(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		TaskID   string            `json:"task_id"`
+		Tags     *[]string         `json:"tags"`
+		Priority *int              `json:"priority"`
+		Timeout  *string           `json:"timeout"`
+		Metadata map[string]string `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	task, err := s.orchestrator.Update(req.TaskID, orchestrator.TaskUpdate{
+		Tags:     req.Tags,
+		Priority: req.Priority,
+		Timeout:  req.Timeout,
+		Metadata: req.Metadata,
 	})
 	if err != nil {
 		return nil, err
@@ -705,6 +1363,41 @@ func (s *Server) toolResumeTask(ctx context.Context, params json.RawMessage) (in
 	}, nil
 }
 
+func (s *Server) toolAddDependency(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return s.editDependency(params, s.orchestrator.AddDependency)
+}
+
+func (s *Server) toolRemoveDependency(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return s.editDependency(params, s.orchestrator.RemoveDependency)
+}
+
+// editDependency runs a dependency edit and returns the updated dependencies.
+func (s *Server) editDependency(params json.RawMessage, edit func(taskID, dependencyID string) (*models.Task, error)) (interface{}, error) {
+	var req struct {
+		TaskID       string `json:"task_id"`
+		DependencyID string `json:"dependency_id"`
+	}
+
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if req.TaskID == "" || req.DependencyID == "" {
+		return nil, fmt.Errorf("task_id and dependency_id are required")
+	}
+
+	task, err := edit(req.TaskID, req.DependencyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"task_id":      task.ID,
+		"status":       task.Status,
+		"dependencies": task.Dependencies,
+	}, nil
+}
+
 func (s *Server) toolDeleteTask(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var req struct {
 		TaskID string `json:"task_id"`
@@ -729,6 +1422,107 @@ func (s *Server) toolGetStats(ctx context.Context, params json.RawMessage) (inte
 	return stats, nil
 }
 
+func (s *Server) toolListEngines(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Refresh bool `json:"refresh"`
+	}
+
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+	}
+
+	return map[string]interface{}{
+		"engines": s.engineListings(ctx, req.Refresh),
+	}, nil
+}
+
+func (s *Server) toolListPersonas(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	names := s.orchestrator.ListPersonas()
+	sort.Strings(names)
+
+	personas := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		content := s.orchestrator.GetPersona(name)
+		personas = append(personas, map[string]interface{}{
+			"name":    name,
+			"summary": persona.Summary(content),
+		})
+	}
+
+	return map[string]interface{}{
+		"personas": personas,
+	}, nil
+}
+
+// engineListing is an engine's availability together with its configured models.
+type engineListing struct {
+	models.EngineInfo
+	// Name is the engine value spawn_agent accepts.
+	Name         string               `json:"name"`
+	Default      bool                 `json:"default,omitempty"`
+	DefaultModel string               `json:"default_model,omitempty"`
+	Models       []config.ModelConfig `json:"models"`
+	// Running and Pending count the engine's tasks, for spawn forms and monitoring.
+	Running int `json:"running"`
+	Pending int `json:"pending"`
+}
+
+func (s *Server) engineListings(ctx context.Context, refresh bool) []engineListing {
+	cfg := s.appConfig()
+	defaultEngine := models.Engine(cfg.Orchestrator.DefaultEngine)
+	if defaultEngine == "" {
+		defaultEngine = models.DefaultEngine()
+	}
+
+	infos := s.orchestrator.ListEngines(ctx, refresh)
+	usage := s.orchestrator.GetDetailedStats().Engines
+	listings := make([]engineListing, len(infos))
+	for i, info := range infos {
+		engineModels := append([]config.ModelConfig{}, cfg.GetModelsForEngine(string(info.Engine))...)
+		for _, modelID := range info.SupportedModels {
+			if cfg.GetModelForEngine(string(info.Engine), modelID) == nil {
+				engineModels = append(engineModels, config.ModelConfig{ID: modelID})
+			}
+		}
+		listings[i] = engineListing{
+			EngineInfo:   info,
+			Name:         toolEngineName(info.Engine),
+			Default:      info.Engine == defaultEngine,
+			DefaultModel: cfg.GetDefaultModelForEngine(string(info.Engine)),
+			Models:       engineModels,
+			Running:      usage[string(info.Engine)].Running,
+			Pending:      usage[string(info.Engine)].Pending,
+		}
+	}
+	return listings
+}
+
+// engineFromToolName maps a tool engine name to the internal engine.
+// Tools use "claude-code" and "gemini-cli" for disambiguation
+// but internally we use "claude" and "gemini".
+func engineFromToolName(name string) models.Engine {
+	switch name {
+	case "claude-code":
+		return models.EngineClaude
+	case "gemini-cli":
+		return models.EngineGemini
+	}
+	return models.Engine(name)
+}
+
+// toolEngineName maps an internal engine to the name the tools use for it.
+func toolEngineName(engine models.Engine) string {
+	switch engine {
+	case models.EngineClaude:
+		return "claude-code"
+	case models.EngineGemini:
+		return "gemini-cli"
+	}
+	return string(engine)
+}
+
 func (s *Server) toolGetTaskOutput(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var req struct {
 		TaskID string `json:"task_id"`
@@ -754,6 +1548,15 @@ func (s *Server) toolGetTaskOutput(ctx context.Context, params json.RawMessage)
 	if useTail {
 		output = task.OutputTail
 	}
+	// Running tasks have no captured output yet; read the (possibly
+	// compressed) log instead.
+	if output == "" && task.LogFile != "" {
+		if useTail {
+			output = readLastBytes(task.LogFile, defaultLogTailBytes)
+		} else if data, err := agent.ReadLog(task.LogFile); err == nil {
+			output = string(data)
+		}
+	}
 
 	return map[string]interface{}{
 		"task_id":  task.ID,
@@ -764,6 +1567,50 @@ func (s *Server) toolGetTaskOutput(ctx context.Context, params json.RawMessage)
 	}, nil
 }
 
+func (s *Server) toolGetTaskOutputChunk(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		TaskID   string `json:"task_id"`
+		Offset   int64  `json:"offset"`
+		MaxBytes int64  `json:"max_bytes"`
+	}
+
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("invalid offset: %d", req.Offset)
+	}
+	if req.MaxBytes <= 0 {
+		req.MaxBytes = defaultOutputChunkBytes
+	}
+	if req.MaxBytes > maxOutputChunkBytes {
+		req.MaxBytes = maxOutputChunkBytes
+	}
+
+	task, err := s.orchestrator.GetTask(req.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.LogFile == "" {
+		return nil, fmt.Errorf("log not available for task %s", task.ID)
+	}
+
+	data, nextOffset, truncated, err := readLogChunk(task.LogFile, req.Offset, req.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"task_id":     task.ID,
+		"status":      task.Status,
+		"content":     string(data),
+		"next_offset": nextOffset,
+		"truncated":   truncated,
+		// Once a finished task's log has been read to the end there is no more output.
+		"eof": task.IsTerminal() && !truncated && int64(len(data)) < req.MaxBytes,
+	}, nil
+}
+
 func (s *Server) toolSetProgress(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var req struct {
 		TaskID      string      `json:"task_id"`
@@ -808,3 +1655,35 @@ func (s *Server) toolSetProgress(ctx context.Context, params json.RawMessage) (i
 		"updated":     true,
 	}, nil
 }
+
+func (s *Server) toolSetTaskResult(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		TaskID  string          `json:"task_id"`
+		Status  string          `json:"status"`
+		Summary string          `json:"summary"`
+		Data    json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	if req.TaskID == "" {
+		return nil, fmt.Errorf("task_id is required")
+	}
+
+	err := s.orchestrator.SetResult(req.TaskID, models.TaskResult{
+		Status:  req.Status,
+		Summary: req.Summary,
+		Data:    req.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"task_id": req.TaskID,
+		"status":  req.Status,
+		"updated": true,
+	}, nil
+}
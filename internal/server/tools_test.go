@@ -0,0 +1,480 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/internal/orchestrator"
+	"github.com/sevir/mesnada/pkg/models"
+)
+
+// spawnPending creates a task that stays pending on a missing dependency.
+func spawnPending(t *testing.T, srv *Server, req models.SpawnRequest) *models.Task {
+	t.Helper()
+	if req.Prompt == "" {
+		req.Prompt = "p"
+	}
+	if req.WorkDir == "" {
+		req.WorkDir = "/tmp"
+	}
+	req.Background = true
+	req.Dependencies = append(req.Dependencies, "missing")
+	task, err := srv.orchestrator.Spawn(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+// callTool runs a tool handler and decodes its result into out.
+func callTool(t *testing.T, handler ToolHandler, args string, out interface{}) error {
+	t.Helper()
+	result, err := handler(context.Background(), json.RawMessage(args))
+	if err != nil {
+		return err
+	}
+	data, _ := json.Marshal(result)
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatalf("decode tool result: %v", err)
+	}
+	return nil
+}
+
+func TestGetTaskOutputChunkTool(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	task.LogFile = filepath.Join(t.TempDir(), "task.log")
+	if err := os.WriteFile(task.LogFile, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var chunk struct {
+		Content    string `json:"content"`
+		NextOffset int64  `json:"next_offset"`
+		EOF        bool   `json:"eof"`
+	}
+	args := `{"task_id":"` + task.ID + `","offset":0,"max_bytes":5}`
+	if err := callTool(t, srv.toolGetTaskOutputChunk, args, &chunk); err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Content != "world" || chunk.NextOffset != 11 {
+		t.Fatalf("expected tail window of a large log from offset 0, got %+v", chunk)
+	}
+
+	args = `{"task_id":"` + task.ID + `","offset":6}`
+	if err := callTool(t, srv.toolGetTaskOutputChunk, args, &chunk); err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Content != "world" || chunk.NextOffset != 11 || chunk.EOF {
+		t.Fatalf("unexpected chunk from offset 6: %+v", chunk)
+	}
+
+	f, _ := os.OpenFile(task.LogFile, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("!\n")
+	f.Close()
+	srv.orchestrator.Cancel(task.ID)
+
+	args = `{"task_id":"` + task.ID + `","offset":11}`
+	if err := callTool(t, srv.toolGetTaskOutputChunk, args, &chunk); err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Content != "!\n" || chunk.NextOffset != 13 || !chunk.EOF {
+		t.Fatalf("expected only the new output and eof, got %+v", chunk)
+	}
+
+	if err := callTool(t, srv.toolGetTaskOutputChunk, `{"task_id":"`+task.ID+`","offset":-1}`, &chunk); err == nil {
+		t.Fatal("expected error for a negative offset")
+	}
+}
+
+func TestSpawnBatchTool(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	var batch struct {
+		TaskIDs []string `json:"task_ids"`
+		Tasks   []struct {
+			Status       string   `json:"status"`
+			Dependencies []string `json:"dependencies"`
+		} `json:"tasks"`
+	}
+	args := `{"tasks":[
+		{"prompt":"first","work_dir":"/tmp","dependencies":["missing"]},
+		{"prompt":"second","work_dir":"/tmp","depends_on":[0],"tags":["batch"]}
+	]}`
+	if err := callTool(t, srv.tools["spawn_batch"], args, &batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.TaskIDs) != 2 {
+		t.Fatalf("expected 2 task IDs, got %v", batch.TaskIDs)
+	}
+	second := batch.Tasks[1]
+	if second.Status != string(models.TaskStatusPending) || len(second.Dependencies) != 1 || second.Dependencies[0] != batch.TaskIDs[0] {
+		t.Fatalf("expected the second task to wait for the first, got %+v", second)
+	}
+
+	for name, args := range map[string]string{
+		"cycle":          `{"tasks":[{"prompt":"a","work_dir":"/tmp","depends_on":[1]},{"prompt":"b","work_dir":"/tmp","depends_on":[0]}]}`,
+		"bad index":      `{"tasks":[{"prompt":"a","work_dir":"/tmp","depends_on":[3]}]}`,
+		"invalid item":   `{"tasks":[{"prompt":"a","work_dir":"/tmp"},{"prompt":"b","work_dir":"/tmp","sandbox":"vm"}]}`,
+		"missing prompt": `{"tasks":[{"prompt":"a","work_dir":"/tmp"},{"work_dir":"/tmp"}]}`,
+	} {
+		var out map[string]interface{}
+		if err := callTool(t, srv.tools["spawn_batch"], args, &out); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	tasks, err := srv.orchestrator.ListTasks(models.ListRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected rejected batches to create no tasks, got %d tasks", len(tasks))
+	}
+}
+
+func TestGetTaskGraphTool(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	first := spawnPending(t, srv, models.SpawnRequest{})
+	second := spawnPending(t, srv, models.SpawnRequest{Dependencies: []string{first.ID}})
+
+	var graph models.TaskGraph
+	if err := callTool(t, srv.tools["get_task_graph"], `{"task_id":"`+first.ID+`"}`, &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Nodes) != 3 {
+		t.Fatalf("expected the task, its dependent and the missing dependency, got %+v", graph.Nodes)
+	}
+	found := false
+	for _, e := range graph.Edges {
+		found = found || (e.From == first.ID && e.To == second.ID)
+	}
+	if !found {
+		t.Fatalf("expected an edge from %s to %s, got %+v", first.ID, second.ID, graph.Edges)
+	}
+
+	var out map[string]interface{}
+	if err := callTool(t, srv.tools["get_task_graph"], `{"task_id":"x","tag":"y"}`, &out); err == nil {
+		t.Fatal("expected task_id and tag to be mutually exclusive")
+	}
+}
+
+func TestRetryTaskTool(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{Prompt: "original"})
+	var out struct {
+		TaskID  string `json:"task_id"`
+		RetryOf string `json:"retry_of"`
+		Attempt int    `json:"attempt"`
+	}
+	if err := callTool(t, srv.tools["retry_task"], `{"task_id":"`+task.ID+`"}`, &out); err == nil {
+		t.Fatal("expected a pending task not to be retryable")
+	}
+
+	task.Status = models.TaskStatusFailed
+	task.Error = "boom"
+	if err := callTool(t, srv.tools["retry_task"], `{"task_id":"`+task.ID+`","include_error":false}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.RetryOf != task.ID || out.Attempt != 2 {
+		t.Fatalf("unexpected retry result %+v", out)
+	}
+	retry, err := srv.orchestrator.GetTask(out.TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.Prompt != "original" {
+		t.Fatalf("expected the original prompt without the error, got %q", retry.Prompt)
+	}
+}
+
+func TestSetTaskResultTool(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	var out map[string]interface{}
+	if err := callTool(t, srv.tools["set_task_result"], `{"task_id":"`+task.ID+`","status":"done"}`, &out); err == nil {
+		t.Fatal("expected an invalid status to be rejected")
+	}
+	args := `{"task_id":"` + task.ID + `","status":"success","summary":"fixed it","data":{"files":["main.go"]}}`
+	if err := callTool(t, srv.tools["set_task_result"], args, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	task.Status = models.TaskStatusCompleted
+	var waited struct {
+		Result *models.TaskResult `json:"result"`
+	}
+	if err := callTool(t, srv.tools["wait_task"], `{"task_id":"`+task.ID+`"}`, &waited); err != nil {
+		t.Fatal(err)
+	}
+	if waited.Result == nil || waited.Result.Status != "success" || waited.Result.Summary != "fixed it" ||
+		string(waited.Result.Data) != `{"files":["main.go"]}` || waited.Result.ReportedAt.IsZero() {
+		t.Fatalf("expected wait_task to return the reported result, got %+v", waited.Result)
+	}
+
+	if err := callTool(t, srv.tools["set_task_result"], args, &out); err == nil {
+		t.Fatal("expected a finished task to reject a result")
+	}
+}
+
+func TestListEnginesTool_IncludesModels(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Orchestrator.DefaultEngine = "claude"
+	cfg.Engines = map[string]config.EngineConfig{
+		"claude": {DefaultModel: "sonnet", Models: []config.ModelConfig{{ID: "sonnet"}, {ID: "opus"}}},
+	}
+	srv.ReloadConfig(cfg)
+
+	var out struct {
+		Engines []engineListing `json:"engines"`
+	}
+	if err := callTool(t, srv.tools["list_engines"], `{}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Engines) != len(models.AllEngines()) {
+		t.Fatalf("expected %d engines, got %d", len(models.AllEngines()), len(out.Engines))
+	}
+	for _, e := range out.Engines {
+		if e.Engine != models.EngineClaude {
+			if e.Default {
+				t.Fatalf("expected only claude to be the default engine, got %+v", e)
+			}
+			continue
+		}
+		if e.Name != "claude-code" || !e.Default || e.DefaultModel != "sonnet" || len(e.Models) != 2 {
+			t.Fatalf("unexpected claude listing %+v", e)
+		}
+		return
+	}
+	t.Fatal("claude engine not listed")
+}
+
+func TestListPersonasToolAndPersonaParam(t *testing.T) {
+	srv := setupPromptServer(t)
+
+	var out struct {
+		Personas []struct {
+			Name    string `json:"name"`
+			Summary string `json:"summary"`
+		} `json:"personas"`
+	}
+	if err := callTool(t, srv.tools["list_personas"], `{}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Personas) != 2 || out.Personas[1].Name != "reviewer" || out.Personas[1].Summary != "Code reviewer" {
+		t.Fatalf("unexpected personas %+v", out.Personas)
+	}
+
+	task := spawnPending(t, srv, models.SpawnRequest{Prompt: "check main.go", Persona: "helper"})
+	if task.Persona != "helper" || task.Prompt != "You are helpful.\n\ncheck main.go" {
+		t.Fatalf("expected the persona to be applied, got %q", task.Prompt)
+	}
+
+	var spawned map[string]interface{}
+	if err := callTool(t, srv.tools["spawn_agent"], `{"prompt":"x","work_dir":"/tmp","persona":"nobody"}`, &spawned); err == nil {
+		t.Fatal("expected an unknown persona to be rejected")
+	}
+
+	// A paused task resumed without a native session gets its persona again.
+	task.Status = models.TaskStatusPaused
+	var resumed struct {
+		Task models.Task `json:"task"`
+	}
+	if err := callTool(t, srv.tools["resume_task"], `{"task_id":"`+task.ID+`","prompt":"continue"}`, &resumed); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Task.Persona != "helper" || !strings.HasPrefix(resumed.Task.Prompt, "You are helpful.") {
+		t.Fatalf("expected the resumed task to keep the persona, got %q", resumed.Task.Prompt)
+	}
+	resumed.Task = models.Task{}
+	task.Status = models.TaskStatusPaused
+	if err := callTool(t, srv.tools["resume_task"], `{"task_id":"`+task.ID+`","prompt":"continue","persona":"reviewer"}`, &resumed); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Task.Persona != "reviewer" {
+		t.Fatalf("expected the persona override, got %q", resumed.Task.Persona)
+	}
+}
+
+func TestUpdateTaskTool(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	var out struct {
+		Task models.Task `json:"task"`
+	}
+	args := `{"task_id":"` + task.ID + `","priority":5,"tags":["a","b"],"metadata":{"ticket":"X-9"}}`
+	if err := callTool(t, srv.tools["update_task"], args, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Task.Priority != 5 || len(out.Task.Tags) != 2 || out.Task.Metadata["ticket"] != "X-9" {
+		t.Fatalf("unexpected updated task %+v", out.Task)
+	}
+}
+
+func TestDependencyTools(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	first := spawnPending(t, srv, models.SpawnRequest{})
+	second := spawnPending(t, srv, models.SpawnRequest{})
+
+	var out struct {
+		Dependencies []string `json:"dependencies"`
+	}
+	if err := callTool(t, srv.tools["add_dependency"], `{"task_id":"`+second.ID+`","dependency_id":"`+first.ID+`"}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Dependencies) != 2 {
+		t.Fatalf("expected 2 dependencies, got %v", out.Dependencies)
+	}
+	if err := callTool(t, srv.tools["add_dependency"], `{"task_id":"`+first.ID+`","dependency_id":"`+second.ID+`"}`, &out); err == nil {
+		t.Fatal("expected a cycle to be rejected")
+	}
+	if err := callTool(t, srv.tools["remove_dependency"], `{"task_id":"`+second.ID+`","dependency_id":"`+first.ID+`"}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Dependencies) != 1 || out.Dependencies[0] != "missing" {
+		t.Fatalf("unexpected dependencies after removal %v", out.Dependencies)
+	}
+}
+
+func TestSearchTasksTool(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	spawnPending(t, srv, models.SpawnRequest{Prompt: "Migrate the billing database", Engine: models.EngineClaude})
+	spawnPending(t, srv, models.SpawnRequest{Prompt: "Review billing UI", Tags: []string{"frontend"}})
+	spawnPending(t, srv, models.SpawnRequest{Prompt: "Unrelated cleanup"})
+
+	var out struct {
+		Tasks   []models.TaskSummary `json:"tasks"`
+		Matches int                  `json:"matches"`
+	}
+	search := func(args string) {
+		t.Helper()
+		if err := callTool(t, srv.tools["search_tasks"], args, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search(`{"query":"BILLING"}`)
+	if out.Matches != 2 {
+		t.Fatalf("expected 2 billing tasks, got %d", out.Matches)
+	}
+	search(`{"query":"billing","engine":"claude-code"}`)
+	if out.Matches != 1 || !strings.Contains(out.Tasks[0].Prompt, "database") {
+		t.Fatalf("expected the claude billing task, got %+v", out.Tasks)
+	}
+	search(`{"query":"billing","tags":["frontend"],"limit":1}`)
+	if out.Matches != 1 || len(out.Tasks) != 1 {
+		t.Fatalf("expected the tagged billing task, got %+v", out.Tasks)
+	}
+	search(`{"created_before":"2000-01-01"}`)
+	if out.Matches != 0 {
+		t.Fatalf("expected no tasks created before 2000, got %d", out.Matches)
+	}
+	if err := callTool(t, srv.tools["search_tasks"], `{"created_after":"yesterday"}`, &out); err == nil {
+		t.Fatal("expected an invalid date to be rejected")
+	}
+}
+
+func TestSpawnAgentToolModelAliases(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cfg := config.DefaultConfig()
+	cfg.Models = []config.ModelConfig{{ID: "claude-haiku-4.5"}, {ID: "claude-opus-4.5"}}
+	cfg.Aliases = map[string]string{"fast": "claude-haiku-4.5", "best": "claude-opus-4.5"}
+	srv.ReloadConfig(cfg)
+
+	for _, tool := range srv.getToolDefinitions() {
+		if tool.Name != "spawn_agent" {
+			continue
+		}
+		model := tool.InputSchema["properties"].(map[string]interface{})["model"].(map[string]interface{})
+		enum := strings.Join(model["enum"].([]string), ",")
+		if enum != "best,claude-haiku-4.5,claude-opus-4.5,fast" {
+			t.Fatalf("expected aliases in the model enum, got %s", enum)
+		}
+		if desc := model["description"].(string); !strings.Contains(desc, "Aliases: best -> claude-opus-4.5, fast -> claude-haiku-4.5") {
+			t.Fatalf("expected aliases in the model description, got %q", desc)
+		}
+		return
+	}
+	t.Fatal("spawn_agent tool not found")
+}
+
+func TestSpawnAgentToolProbedModels(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mesnada-server-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// A fake opencode that prints its version and lists its models.
+	script := `case "$1" in --version) echo opencode 1.0 ;; models) printf 'anthropic/claude-sonnet-4\nopenai/gpt-5\n' ;; esac`
+	engines := map[string]config.EngineConfig{
+		"opencode": {Binary: "sh", DefaultArgs: []string{"-c", script, "sh"}, Models: []config.ModelConfig{{ID: "openai/gpt-5", Description: "GPT-5"}}},
+	}
+	orch, err := orchestrator.New(orchestrator.Config{
+		StorePath:   filepath.Join(tmpDir, "tasks.json"),
+		LogDir:      filepath.Join(tmpDir, "logs"),
+		MaxParallel: 2,
+		Engines:     engines,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orch.Shutdown()
+	srv := New(Config{Addr: ":0", Orchestrator: orch})
+	cfg := config.DefaultConfig()
+	cfg.Models = nil
+	cfg.Engines = engines
+	srv.ReloadConfig(cfg)
+	orch.ListEngines(context.Background(), true)
+
+	for _, tool := range srv.getToolDefinitions() {
+		if tool.Name != "spawn_agent" {
+			continue
+		}
+		model := tool.InputSchema["properties"].(map[string]interface{})["model"].(map[string]interface{})
+		if enum := strings.Join(model["enum"].([]string), ","); !strings.Contains(enum, "anthropic/claude-sonnet-4,openai/gpt-5") {
+			t.Fatalf("expected the probed models in the enum, got %s", enum)
+		}
+		if desc := model["description"].(string); !strings.Contains(desc, "opencode: [openai/gpt-5 anthropic/claude-sonnet-4]") {
+			t.Fatalf("expected configured then probed models in the description, got %q", desc)
+		}
+	}
+	if engine := srv.detectEngineForModel("anthropic/claude-sonnet-4"); engine != models.EngineOpenCode {
+		t.Fatalf("expected a probed model to select its engine, got %q", engine)
+	}
+
+	for _, listing := range srv.engineListings(context.Background(), false) {
+		if listing.Engine != models.EngineOpenCode {
+			continue
+		}
+		if len(listing.Models) != 2 || listing.Models[0].Description != "GPT-5" || listing.Models[1].ID != "anthropic/claude-sonnet-4" {
+			t.Fatalf("unexpected opencode models %+v", listing.Models)
+		}
+		return
+	}
+	t.Fatal("opencode engine not listed")
+}
@@ -6,10 +6,11 @@ import (
 	"io"
 	"io/fs"
 	"net/http"
-	"os"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/sevir/mesnada/internal/agent"
 	"github.com/sevir/mesnada/pkg/models"
 	uiassets "github.com/sevir/mesnada/ui"
 )
@@ -26,6 +27,15 @@ type uiTaskRow struct {
 	EngineClass   string
 	Model         string
 	PromptExcerpt string
+	Progress      *uiProgress
+}
+
+// uiProgress renders a task's reported progress as a bar.
+type uiProgress struct {
+	Percent     int
+	Description string
+	// ETAText is a naive estimate of the time left, for running tasks.
+	ETAText string
 }
 
 type uiTasksVM struct {
@@ -45,6 +55,11 @@ type uiPanelVM struct {
 	DurationText  string
 	TagsText      string
 	Prompt        string
+	Progress      *uiProgress
+	// ToolEngine, TagList and TimeoutText pre-fill the follow-up form.
+	ToolEngine  string
+	TagList     string
+	TimeoutText string
 }
 
 type uiLogVM struct {
@@ -66,8 +81,21 @@ func (s *Server) handleUITasks(w http.ResponseWriter, r *http.Request) {
 	if status != "" && status != "all" {
 		statuses = []models.TaskStatus{models.TaskStatus(status)}
 	}
+	var tags []string
+	for _, tag := range r.Form["tag"] {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
 
-	tasks, err := s.orchestrator.ListTasks(models.ListRequest{Status: statuses})
+	// The same filters as search_tasks.
+	tasks, err := s.orchestrator.ListTasks(models.ListRequest{
+		Status: statuses,
+		Tags:   tags,
+		Query:  strings.TrimSpace(r.FormValue("q")),
+		Engine: engineFromToolName(strings.TrimSpace(r.FormValue("engine"))),
+		Model:  strings.TrimSpace(r.FormValue("model")),
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -102,6 +130,7 @@ func (s *Server) handleUITasks(w http.ResponseWriter, r *http.Request) {
 			EngineClass:   engineClass(t.Engine),
 			Model:         t.Model,
 			PromptExcerpt: truncate(stripTaskIDPrefix(t.Prompt), 100),
+			Progress:      progressOf(t, time.Now()),
 		})
 	}
 
@@ -180,6 +209,12 @@ func (s *Server) handleUIPanel(w http.ResponseWriter, r *http.Request) {
 		DurationText:  durationText,
 		TagsText:      tagsText,
 		Prompt:        stripTaskIDPrefix(task.Prompt),
+		Progress:      progressOf(task, time.Now()),
+		ToolEngine:    toolEngineName(task.Engine),
+		TagList:       strings.Join(task.Tags, ", "),
+	}
+	if task.Timeout > 0 {
+		vm.TimeoutText = time.Duration(task.Timeout).String()
 	}
 
 	tpl, err := s.getUITemplates()
@@ -212,6 +247,7 @@ func (s *Server) handleUILog(w http.ResponseWriter, r *http.Request) {
 	if logText == "" {
 		logText = task.Output
 	}
+	logText = stripANSI(logText)
 
 	tpl, err := s.getUITemplates()
 	if err != nil {
@@ -258,6 +294,39 @@ func statusClass(st models.TaskStatus) string {
 	}
 }
 
+// progressOf returns nil for tasks that never reported progress. The ETA
+// assumes the rest of a running task goes as fast as what it has done so far.
+func progressOf(task *models.Task, now time.Time) *uiProgress {
+	if task.Progress == nil {
+		return nil
+	}
+	p := &uiProgress{
+		Percent:     min(max(task.Progress.Percentage, 0), 100),
+		Description: task.Progress.Description,
+	}
+	if task.Status == models.TaskStatusRunning && task.StartedAt != nil && p.Percent > 0 && p.Percent < 100 {
+		if elapsed := now.Sub(*task.StartedAt); elapsed > 0 {
+			left := elapsed * time.Duration(100-p.Percent) / time.Duration(p.Percent)
+			p.ETAText = "~" + shortDuration(left) + " left"
+		}
+	}
+	return p
+}
+
+// shortDuration formats d to its two largest units, e.g. 45s, 4m10s or 2h5m.
+func shortDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return d.Round(time.Second).String()
+	case d < time.Hour:
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		d = d.Round(time.Minute)
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
 func engineClass(engine models.Engine) string {
 	if engine == "" {
 		engine = models.DefaultEngine()
@@ -301,19 +370,26 @@ func truncate(s string, max int) string {
 	return string(r[:max-1]) + "…"
 }
 
-func readLastBytes(path string, max int64) string {
-	f, err := os.Open(path)
-	if err != nil {
-		return ""
+// ansiEscape matches the CSI, OSC and two-byte escape sequences agents use
+// for colors, cursor movement and terminal titles.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[=>@-Z\\-_]`)
+
+// stripANSI removes terminal escape sequences so logs render as plain text.
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
 	}
-	defer f.Close()
+	return ansiEscape.ReplaceAllString(s, "")
+}
 
-	st, err := f.Stat()
+func readLastBytes(path string, max int64) string {
+	f, err := agent.OpenLog(path)
 	if err != nil {
 		return ""
 	}
+	defer f.Close()
 
-	size := st.Size()
+	size := f.Size()
 	start := int64(0)
 	if size > max {
 		start = size - max
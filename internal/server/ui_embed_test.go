@@ -6,6 +6,10 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/internal/config"
+	"github.com/sevir/mesnada/pkg/models"
 )
 
 func TestUIEndpointWorksOutsideRepoCWD(t *testing.T) {
@@ -55,6 +59,149 @@ func TestUIEndpointWorksOutsideRepoCWD(t *testing.T) {
 	}
 }
 
+func TestUILogStripsANSI(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	task.Output = "\x1b[1;32mok\x1b[0m done\x1b]0;title\x07\n"
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/partials/log?task_id="+task.ID, nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d (body=%q)", w.Code, w.Body.String())
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != "ok done" {
+		t.Fatalf("expected escape sequences stripped, got %q", got)
+	}
+}
+
+func TestUITasksFilters(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	match := spawnPending(t, srv, models.SpawnRequest{Prompt: "fix the login form", Engine: models.EngineGemini, Model: "pro", Tags: []string{"web", "auth"}})
+	otherEngine := spawnPending(t, srv, models.SpawnRequest{Prompt: "fix the login form", Tags: []string{"web", "auth"}})
+	otherTag := spawnPending(t, srv, models.SpawnRequest{Prompt: "fix the login form", Engine: models.EngineGemini, Model: "pro", Tags: []string{"web"}})
+	otherPrompt := spawnPending(t, srv, models.SpawnRequest{Prompt: "write docs", Engine: models.EngineGemini, Model: "pro", Tags: []string{"web", "auth"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/partials/tasks?status=all&engine=gemini&model=pro&tag=web&tag=auth&q=login", nil)
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d (body=%q)", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, match.ID) {
+		t.Fatalf("expected %s in the filtered list", match.ID)
+	}
+	for _, other := range []*models.Task{otherEngine, otherTag, otherPrompt} {
+		if strings.Contains(body, other.ID) {
+			t.Fatalf("expected %s to be filtered out", other.ID)
+		}
+	}
+}
+
+func TestUIPanelActions(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	panel := func(task *models.Task) string {
+		req := httptest.NewRequest(http.MethodGet, "/ui/partials/panel?task_id="+task.ID, nil)
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d (body=%q)", w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	pending := spawnPending(t, srv, models.SpawnRequest{})
+	if body := panel(pending); !strings.Contains(body, `title="Cancel"`) || strings.Contains(body, `title="Retry"`) {
+		t.Fatalf("expected only the cancel action for a pending task")
+	}
+
+	failed := spawnPending(t, srv, models.SpawnRequest{Engine: models.EngineGemini, Tags: []string{"a", "b"}, Timeout: "5m"})
+	failed.Status = models.TaskStatusFailed
+	body := panel(failed)
+	for _, want := range []string{`title="Retry"`, `title="Follow-up task"`, `data-engine="gemini-cli"`, `data-tags="a, b"`, `data-timeout="5m0s"`, `data-work-dir="/tmp"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %s in the panel of a failed task", want)
+		}
+	}
+	if strings.Contains(body, `title="Cancel"`) || strings.Contains(body, `title="Changes"`) {
+		t.Fatalf("expected no cancel or changes action for a failed task outside git")
+	}
+
+	failed.BaseCommit = "0123456789abcdef"
+	if body := panel(failed); !strings.Contains(body, `title="Changes"`) {
+		t.Fatalf("expected the changes action for a task started in a git work dir")
+	}
+}
+
+func TestUITaskProgress(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	started := time.Now().Add(-time.Minute)
+	task.Status = models.TaskStatusRunning
+	task.StartedAt = &started
+	task.Progress = &models.TaskProgress{Percentage: 25, Description: "Parsing files"}
+
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ui/partials/tasks?status=all", nil))
+	body := w.Body.String()
+	for _, want := range []string{`style="width: 25%"`, "Parsing files", "~3m0s left"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in the task list, got:\n%s", want, body)
+		}
+	}
+
+	task.Status = models.TaskStatusCompleted
+	task.Progress.Percentage = 100
+	w = httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ui/partials/panel?task_id="+task.ID, nil))
+	if body := w.Body.String(); !strings.Contains(body, `style="width: 100%"`) || strings.Contains(body, "left") {
+		t.Fatalf("expected a full bar without ETA for a completed task, got:\n%s", body)
+	}
+}
+
+func TestUIDefaultTheme(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	theme := func() string {
+		w := httptest.NewRecorder()
+		srv.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ui", nil))
+		body := w.Body.String()
+		for _, name := range []string{"dark", "light", "system"} {
+			if strings.Contains(body, `<meta name="mesnada-theme" content="`+name+`" />`) {
+				return name
+			}
+		}
+		return ""
+	}
+
+	if got := theme(); got != "dark" {
+		t.Fatalf("expected the dark theme by default, got %q", got)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Server.UI.Theme = "Light"
+	srv.ReloadConfig(cfg)
+	if got := theme(); got != "light" {
+		t.Fatalf("expected the configured light theme, got %q", got)
+	}
+	cfg.Server.UI.Theme = "neon"
+	srv.ReloadConfig(cfg)
+	if got := theme(); got != "dark" {
+		t.Fatalf("expected unknown themes to fall back to dark, got %q", got)
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
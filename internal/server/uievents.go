@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sevir/mesnada/pkg/models"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// uiEventsBuffer is how many events a slow UI socket may fall behind
+	// before further events are dropped for it.
+	uiEventsBuffer = 64
+	// uiEventsPing is how often an idle UI socket gets a ping, so proxies
+	// keep it open and dead clients are noticed.
+	uiEventsPing = 30 * time.Second
+)
+
+// uiEvent is one /ui/events message: a task change, "resync" after events
+// were dropped, or "ping".
+type uiEvent struct {
+	Change string            `json:"change"`
+	TaskID string            `json:"task_id,omitempty"`
+	Status models.TaskStatus `json:"status,omitempty"`
+}
+
+// uiEventHub fans task changes out to the connected UI sockets.
+type uiEventHub struct {
+	mu   sync.Mutex
+	subs map[chan uiEvent]bool
+}
+
+// subscribe returns a channel of events and a function to stop receiving them.
+func (h *uiEventHub) subscribe() (<-chan uiEvent, func()) {
+	ch := make(chan uiEvent, uiEventsBuffer)
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[chan uiEvent]bool)
+	}
+	h.subs[ch] = false
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// publish queues ev for every subscriber without blocking. A subscriber whose
+// queue is full misses events and gets a "resync" once there is room again.
+func (h *uiEventHub) publish(ev uiEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch, dropped := range h.subs {
+		if dropped {
+			select {
+			case ch <- uiEvent{Change: "resync"}:
+				dropped = false
+			default:
+			}
+		}
+		if !dropped {
+			select {
+			case ch <- ev:
+			default:
+				dropped = true
+			}
+		}
+		h.subs[ch] = dropped
+	}
+}
+
+// handleUIEvents pushes task changes to the UI over a WebSocket, so the task
+// list and panel refresh when something changes instead of polling.
+func (s *Server) handleUIEvents(c *gin.Context) {
+	server := websocket.Server{
+		Handshake: s.checkWebSocketOrigin,
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			events, unsubscribe := s.uiEvents.subscribe()
+			defer unsubscribe()
+
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+			// Clients only send close frames; reading notices disconnects.
+			go func() {
+				io.Copy(io.Discard, ws)
+				cancel()
+			}()
+
+			ping := time.NewTicker(uiEventsPing)
+			defer ping.Stop()
+			for {
+				var ev uiEvent
+				select {
+				case <-ctx.Done():
+					return
+				case <-s.done:
+					return
+				case ev = <-events:
+				case <-ping.C:
+					ev = uiEvent{Change: "ping"}
+				}
+				if err := websocket.JSON.Send(ws, ev); err != nil {
+					return
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sevir/mesnada/pkg/models"
+	"golang.org/x/net/websocket"
+)
+
+func TestUIEvents(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ui/events", "", ts.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// The socket subscribes after the handshake completes.
+	for i := 0; ; i++ {
+		srv.uiEvents.mu.Lock()
+		n := len(srv.uiEvents.subs)
+		srv.uiEvents.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("expected the socket to subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	task := spawnPending(t, srv, models.SpawnRequest{})
+	var ev uiEvent
+	if err := websocket.JSON.Receive(ws, &ev); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if ev.Change != "created" || ev.TaskID != task.ID || ev.Status != models.TaskStatusPending {
+		t.Fatalf("expected the created event of %s, got %+v", task.ID, ev)
+	}
+}
+
+func TestUIEventHubResync(t *testing.T) {
+	var hub uiEventHub
+	events, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < uiEventsBuffer+5; i++ {
+		hub.publish(uiEvent{Change: "updated", TaskID: "task-a"})
+	}
+	for i := 0; i < uiEventsBuffer; i++ {
+		<-events
+	}
+	hub.publish(uiEvent{Change: "finished", TaskID: "task-a"})
+	if ev := <-events; ev.Change != "resync" {
+		t.Fatalf("expected a resync after dropped events, got %+v", ev)
+	}
+	if ev := <-events; ev.Change != "finished" {
+		t.Fatalf("expected the next event after the resync, got %+v", ev)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	uiassets "github.com/sevir/mesnada/ui"
+)
+
+const (
+	// uiLoginPath and uiLogoutPath are open without a token, so a browser can
+	// sign in and out.
+	uiLoginPath  = "/ui/login"
+	uiLogoutPath = "/ui/logout"
+)
+
+var uiLoginTemplate = template.Must(template.ParseFS(fs.FS(uiassets.FS), "login.html"))
+
+type uiLoginVM struct {
+	Base  string
+	Theme string
+	Error string
+}
+
+// setAuthCookie keeps token for the browser UI's later requests.
+func setAuthCookie(w http.ResponseWriter, r *http.Request, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     authCookie,
+		Value:    token,
+		Path:     basePath(r.Context()) + "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// uiAuthEnabled reports whether the UI needs a token.
+func (s *Server) uiAuthEnabled() bool {
+	cfg := s.appConfig().Server
+	return len(cfg.Tokens()) > 0 || cfg.OAuth.Enabled()
+}
+
+// uiTokenValid accepts the tokens authMiddleware does: a configured token or,
+// with OAuth, a valid access token.
+func (s *Server) uiTokenValid(ctx context.Context, token string) bool {
+	cfg := s.appConfig().Server
+	if validToken(cfg.Tokens(), token) {
+		return true
+	}
+	if cfg.OAuth.Enabled() && token != "" {
+		_, err := s.validateAccessToken(ctx, token)
+		return err == nil
+	}
+	return false
+}
+
+// handleUILogin shows the sign-in form and, on POST, stores a valid token in
+// the UI cookie and opens the UI. Without authentication it goes straight to
+// the UI.
+func (s *Server) handleUILogin(c *gin.Context) {
+	base := basePath(c.Request.Context())
+	if !s.uiAuthEnabled() {
+		c.Redirect(http.StatusSeeOther, base+"/ui")
+		return
+	}
+
+	vm := uiLoginVM{Base: base, Theme: s.appConfig().Server.UI.DefaultTheme()}
+	status := http.StatusOK
+	if c.Request.Method == http.MethodPost {
+		token := strings.TrimSpace(c.PostForm("token"))
+		if s.uiTokenValid(c.Request.Context(), token) {
+			setAuthCookie(c.Writer, c.Request, token)
+			c.Redirect(http.StatusSeeOther, base+"/ui")
+			return
+		}
+		vm.Error = "Invalid token."
+		status = http.StatusUnauthorized
+	}
+
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = uiLoginTemplate.Execute(c.Writer, vm)
+}
+
+// handleUILogout clears the UI cookie and returns to the sign-in form.
+func (s *Server) handleUILogout(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     authCookie,
+		Value:    "",
+		Path:     basePath(c.Request.Context()) + "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	c.Redirect(http.StatusSeeOther, basePath(c.Request.Context())+uiLoginPath)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +21,8 @@ type Store interface {
 	List(filter ListFilter) ([]*models.Task, error)
 	Delete(id string) error
 	UpdateStatus(id string, status models.TaskStatus) error
+	// Check reports why the store cannot persist tasks, or nil when it can.
+	Check() error
 	Close() error
 }
 
@@ -29,6 +32,20 @@ type ListFilter struct {
 	Tags   []string
 	Limit  int
 	Offset int
+
+	// Query matches tasks containing every whitespace-separated term
+	// (case-insensitive) in their ID, prompt, summary, error, result,
+	// tags or metadata.
+	Query  string
+	Engine models.Engine
+	Model  string
+	// CreatedAfter and CreatedBefore bound the creation time, when set.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// Sort is a field from models.TaskSortFields, prefixed with "-" for
+	// descending order. Empty sorts newest first.
+	Sort string
 }
 
 // FileStore implements Store using a JSON file for persistence.
@@ -38,6 +55,7 @@ type FileStore struct {
 	mu       sync.RWMutex
 	saveOnce sync.Once
 	dirty    bool
+	saveErr  error
 	closeCh  chan struct{}
 }
 
@@ -122,11 +140,13 @@ func (fs *FileStore) backgroundSaver() {
 			fs.mu.RUnlock()
 
 			if dirty {
-				if err := fs.save(); err == nil {
-					fs.mu.Lock()
+				err := fs.save()
+				fs.mu.Lock()
+				fs.saveErr = err
+				if err == nil {
 					fs.dirty = false
-					fs.mu.Unlock()
 				}
+				fs.mu.Unlock()
 			}
 		case <-fs.closeCh:
 			fs.save()
@@ -135,6 +155,14 @@ func (fs *FileStore) backgroundSaver() {
 	}
 }
 
+// Check returns the error of the last failed background save, or nil once
+// a save succeeds again.
+func (fs *FileStore) Check() error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.saveErr
+}
+
 // Save stores or updates a task.
 func (fs *FileStore) Save(task *models.Task) error {
 	fs.mu.Lock()
@@ -172,10 +200,7 @@ func (fs *FileStore) List(filter ListFilter) ([]*models.Task, error) {
 		}
 	}
 
-	// Sort by creation time, newest first
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].CreatedAt.After(result[j].CreatedAt)
-	})
+	sortTasks(result, filter.Sort)
 
 	// Apply offset and limit
 	if filter.Offset > 0 {
@@ -192,6 +217,41 @@ func (fs *FileStore) List(filter ListFilter) ([]*models.Task, error) {
 	return result, nil
 }
 
+// sortTasks orders tasks by the given sort, newest first when it is empty.
+// Ties are broken by ID so pages stay stable, and tasks missing the sorted
+// timestamp go last.
+func sortTasks(tasks []*models.Task, order string) {
+	if order == "" {
+		order = "-created_at"
+	}
+	desc := strings.HasPrefix(order, "-")
+	field := strings.TrimPrefix(order, "-")
+
+	key := func(task *models.Task) *time.Time {
+		switch field {
+		case "started_at":
+			return task.StartedAt
+		case "completed_at":
+			return task.CompletedAt
+		}
+		return &task.CreatedAt
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := key(tasks[i]), key(tasks[j])
+		if a == nil || b == nil {
+			return a != nil
+		}
+		if a.Equal(*b) {
+			return tasks[i].ID < tasks[j].ID
+		}
+		if desc {
+			return a.After(*b)
+		}
+		return a.Before(*b)
+	})
+}
+
 func (fs *FileStore) matchesFilter(task *models.Task, filter ListFilter) bool {
 	// Filter by status
 	if len(filter.Status) > 0 {
@@ -223,9 +283,44 @@ func (fs *FileStore) matchesFilter(task *models.Task, filter ListFilter) bool {
 		}
 	}
 
+	if filter.Engine != "" && task.Engine != filter.Engine {
+		return false
+	}
+	if filter.Model != "" && task.Model != filter.Model {
+		return false
+	}
+	if !filter.CreatedAfter.IsZero() && task.CreatedAt.Before(filter.CreatedAfter) {
+		return false
+	}
+	if !filter.CreatedBefore.IsZero() && !task.CreatedAt.Before(filter.CreatedBefore) {
+		return false
+	}
+
+	if terms := strings.Fields(strings.ToLower(filter.Query)); len(terms) > 0 {
+		text := searchText(task)
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+	}
+
 	return true
 }
 
+// searchText is the lowercased text a ListFilter query is matched against.
+func searchText(task *models.Task) string {
+	parts := []string{task.ID, task.Prompt, task.Summary, task.Error, task.WorkDir}
+	parts = append(parts, task.Tags...)
+	if task.Result != nil {
+		parts = append(parts, task.Result.Summary)
+	}
+	for k, v := range task.Metadata {
+		parts = append(parts, k, v)
+	}
+	return strings.ToLower(strings.Join(parts, "\n"))
+}
+
 // Delete removes a task by ID.
 func (fs *FileStore) Delete(id string) error {
 	fs.mu.Lock()
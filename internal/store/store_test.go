@@ -3,6 +3,7 @@ package store
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -227,3 +228,65 @@ func TestFileStorePersistence(t *testing.T) {
 		t.Errorf("Expected Prompt %s, got %s", task.Prompt, retrieved.Prompt)
 	}
 }
+
+func TestFileStoreSearch(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mesnada-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := NewFileStore(filepath.Join(tmpDir, "tasks.json"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tasks := []*models.Task{
+		{ID: "s1", Prompt: "Fix the login bug", Engine: models.EngineClaude, Model: "claude-sonnet-4", CreatedAt: base},
+		{ID: "s2", Prompt: "Write docs", Summary: "Documented the LOGIN flow", Engine: models.EngineCopilot, CreatedAt: base.Add(24 * time.Hour)},
+		{ID: "s3", Prompt: "Refactor", Metadata: map[string]string{"ticket": "AUTH-7"}, Engine: models.EngineClaude, CreatedAt: base.Add(48 * time.Hour)},
+	}
+	completed := base.Add(72 * time.Hour)
+	tasks[0].CompletedAt = &completed
+	later := completed.Add(time.Hour)
+	tasks[1].CompletedAt = &later
+	for _, task := range tasks {
+		if err := store.Save(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(filter ListFilter) string {
+		result, err := store.List(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, task := range result {
+			got = append(got, task.ID)
+		}
+		return strings.Join(got, ",")
+	}
+
+	cases := []struct {
+		name   string
+		filter ListFilter
+		want   string
+	}{
+		{"query matches prompt and summary", ListFilter{Query: "login"}, "s2,s1"},
+		{"all terms must match", ListFilter{Query: "login bug"}, "s1"},
+		{"query matches metadata", ListFilter{Query: "auth-7"}, "s3"},
+		{"engine", ListFilter{Engine: models.EngineClaude}, "s3,s1"},
+		{"created range", ListFilter{CreatedAfter: base.Add(time.Hour), CreatedBefore: base.Add(48 * time.Hour)}, "s2"},
+		{"model", ListFilter{Model: "claude-sonnet-4"}, "s1"},
+		{"oldest first", ListFilter{Sort: "created_at"}, "s1,s2,s3"},
+		{"completed first, unfinished last", ListFilter{Sort: "-completed_at"}, "s2,s1,s3"},
+	}
+	for _, tc := range cases {
+		if got := ids(tc.filter); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -45,6 +47,39 @@ func DefaultEngine() Engine {
 	return EngineCopilot
 }
 
+// AllEngines returns every supported engine in display order.
+func AllEngines() []Engine {
+	return []Engine{EngineCopilot, EngineClaude, EngineGemini, EngineOpenCode, EngineOllamaClaude, EngineOllamaOpenCode}
+}
+
+// EngineInfo describes the availability of an engine binary on this host.
+type EngineInfo struct {
+	Engine    Engine `json:"engine"`
+	Binary    string `json:"binary"`
+	Available bool   `json:"available"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// SupportedModels are the models the engine reported it can run, for
+	// engines that can list them (copilot, opencode and the Ollama engines).
+	SupportedModels []string  `json:"supported_models,omitempty"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+// Sandbox modes control where the agent process runs.
+const (
+	// SandboxNone runs the agent CLI directly on the host (default).
+	SandboxNone = "none"
+	// SandboxDocker runs the agent CLI inside a Docker container.
+	SandboxDocker = "docker"
+	// SandboxKubernetes runs the agent CLI as a Kubernetes Job.
+	SandboxKubernetes = "kubernetes"
+)
+
+// ValidSandbox checks if a sandbox mode is valid.
+func ValidSandbox(mode string) bool {
+	return mode == "" || mode == SandboxNone || mode == SandboxDocker || mode == SandboxKubernetes
+}
+
 // TaskProgress represents the progress of a task.
 type TaskProgress struct {
 	Percentage  int       `json:"percentage"`
@@ -52,6 +87,26 @@ type TaskProgress struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// Result statuses an agent may report with its final result.
+const (
+	ResultStatusSuccess = "success"
+	ResultStatusPartial = "partial"
+	ResultStatusFailure = "failure"
+)
+
+// ValidResultStatus checks if a reported result status is valid.
+func ValidResultStatus(status string) bool {
+	return status == ResultStatusSuccess || status == ResultStatusPartial || status == ResultStatusFailure
+}
+
+// TaskResult is the structured result an agent reports for its own task.
+type TaskResult struct {
+	Status     string          `json:"status"`
+	Summary    string          `json:"summary,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	ReportedAt time.Time       `json:"reported_at"`
+}
+
 // Task represents a CLI agent task.
 type Task struct {
 	ID           string        `json:"id"`
@@ -77,6 +132,63 @@ type Task struct {
 	MCPConfig    string        `json:"mcp_config,omitempty"`
 	ExtraArgs    []string      `json:"extra_args,omitempty"`
 	Persona      string        `json:"persona,omitempty"`
+	Sandbox      string        `json:"sandbox,omitempty"`
+	Secrets      []string      `json:"secrets,omitempty"` // names only; values are never stored
+	SessionID    string        `json:"session_id,omitempty"`
+	ToolUses     []ToolUse     `json:"tool_uses,omitempty"`
+	DurationMS   int64         `json:"duration_ms,omitempty"`
+	NumTurns     int           `json:"num_turns,omitempty"`
+	ResultStatus string        `json:"result_status,omitempty"`
+	EventsFile   string        `json:"events_file,omitempty"`
+	TokensIn     int64         `json:"tokens_in,omitempty"`
+	TokensOut    int64         `json:"tokens_out,omitempty"`
+	CostUSD      float64       `json:"cost_usd,omitempty"`
+	Summary      string        `json:"summary,omitempty"` // final assistant message
+	Result       *TaskResult   `json:"result,omitempty"`  // reported by the agent with set_task_result
+
+	// ResumeSessionID is the engine session this task continues (native resume).
+	ResumeSessionID string `json:"resume_session_id,omitempty"`
+
+	// AutoCommit commits the work dir changes to a task branch when the task completes.
+	AutoCommit   bool   `json:"auto_commit,omitempty"`
+	CommitBranch string `json:"commit_branch,omitempty"`
+	CommitSHA    string `json:"commit_sha,omitempty"`
+	// BaseCommit is the work dir HEAD when the task started, when it is a git repository.
+	BaseCommit string `json:"base_commit,omitempty"`
+
+	// RetryOf is the task this task retries; Attempt is its attempt number
+	// (unset for an original task, which is attempt 1).
+	RetryOf string `json:"retry_of,omitempty"`
+	Attempt int    `json:"attempt,omitempty"`
+
+	// Metadata holds free-form key/value annotations set by the caller.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// TaskEventType classifies a normalized agent output event.
+type TaskEventType string
+
+const (
+	// TaskEventToolUse is emitted when the agent invokes a tool.
+	TaskEventToolUse TaskEventType = "tool_use"
+	// TaskEventError is emitted when the agent reports an error.
+	TaskEventError TaskEventType = "error"
+	// TaskEventSummary carries the agent's final answer.
+	TaskEventSummary TaskEventType = "summary"
+)
+
+// TaskEvent is an engine-independent event parsed from agent output.
+type TaskEvent struct {
+	Time    time.Time     `json:"time"`
+	Type    TaskEventType `json:"type"`
+	Tool    string        `json:"tool,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+// ToolUse records a tool invocation reported by the agent CLI.
+type ToolUse struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
 }
 
 // Duration is a wrapper around time.Duration for JSON marshaling.
@@ -124,6 +236,14 @@ func (t *Task) IsPending() bool {
 	return t.Status == TaskStatusPending
 }
 
+// AttemptNumber returns the 1-based attempt number of the task.
+func (t *Task) AttemptNumber() int {
+	if t.Attempt < 1 {
+		return 1
+	}
+	return t.Attempt
+}
+
 // TaskSummary provides a condensed view of a task for listing.
 type TaskSummary struct {
 	ID          string     `json:"id"`
@@ -133,6 +253,7 @@ type TaskSummary struct {
 	CreatedAt   time.Time  `json:"created_at"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 	Duration    string     `json:"duration,omitempty"`
+	Summary     string     `json:"summary,omitempty"`
 }
 
 // ToSummary converts a Task to a TaskSummary.
@@ -144,6 +265,7 @@ func (t *Task) ToSummary() TaskSummary {
 		Status:      t.Status,
 		CreatedAt:   t.CreatedAt,
 		CompletedAt: t.CompletedAt,
+		Summary:     truncateString(t.Summary, 300),
 	}
 	if t.CompletedAt != nil && t.StartedAt != nil {
 		summary.Duration = t.CompletedAt.Sub(*t.StartedAt).String()
@@ -171,9 +293,18 @@ type SpawnRequest struct {
 	MCPConfig             string   `json:"mcp_config,omitempty"`
 	ExtraArgs             []string `json:"extra_args,omitempty"`
 	Persona               string   `json:"persona,omitempty"`
+	Sandbox               string   `json:"sandbox,omitempty"`
+	Secrets               []string `json:"secrets,omitempty"`
 	Background            bool     `json:"background"`
 	IncludeDependencyLogs bool     `json:"include_dependency_logs,omitempty"`
 	DependencyLogLines    int      `json:"dependency_log_lines,omitempty"`
+	// ResumeSessionID continues an engine-native session instead of starting a new one.
+	ResumeSessionID string `json:"resume_session_id,omitempty"`
+	// AutoCommit commits the changes to a mesnada/<task_id> branch on completion
+	// (defaults to orchestrator.auto_commit).
+	AutoCommit *bool `json:"auto_commit,omitempty"`
+	// Metadata holds free-form key/value annotations for the task.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // WaitRequest represents a request to wait for task completion.
@@ -195,4 +326,62 @@ type ListRequest struct {
 	Tags   []string     `json:"tags,omitempty"`
 	Limit  int          `json:"limit,omitempty"`
 	Offset int          `json:"offset,omitempty"`
+
+	// Query, Engine, Model and the creation time bounds narrow a search.
+	Query         string    `json:"query,omitempty"`
+	Engine        Engine    `json:"engine,omitempty"`
+	Model         string    `json:"model,omitempty"`
+	CreatedAfter  time.Time `json:"created_after,omitempty"`
+	CreatedBefore time.Time `json:"created_before,omitempty"`
+
+	// Sort orders the results; see ValidTaskSort. Newest first by default.
+	Sort string `json:"sort,omitempty"`
+}
+
+// Task sort fields. Results are ascending unless the field is prefixed
+// with "-"; tasks without the timestamp sort last either way.
+var taskSortFields = []string{"created_at", "started_at", "completed_at"}
+
+// TaskSortFields returns the fields ListRequest.Sort accepts.
+func TaskSortFields() []string {
+	return append([]string(nil), taskSortFields...)
+}
+
+// ValidTaskSort checks if sort is empty or a known field, optionally
+// prefixed with "-" for descending order.
+func ValidTaskSort(sort string) bool {
+	if sort == "" {
+		return true
+	}
+	field := strings.TrimPrefix(sort, "-")
+	for _, f := range taskSortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskGraph is the dependency graph of a set of tasks.
+type TaskGraph struct {
+	Nodes []TaskGraphNode `json:"nodes"`
+	Edges []TaskGraphEdge `json:"edges"`
+}
+
+// TaskGraphNode is a task in a TaskGraph.
+type TaskGraphNode struct {
+	ID       string     `json:"id"`
+	Label    string     `json:"label"`
+	Status   TaskStatus `json:"status,omitempty"`
+	Engine   Engine     `json:"engine,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+	Progress int        `json:"progress,omitempty"`
+	// Missing marks a dependency that is not in the store.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// TaskGraphEdge links a dependency (From) to the task waiting for it (To).
+type TaskGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
 }
@@ -66,6 +66,7 @@ func TestTaskToSummary(t *testing.T) {
 		CreatedAt:   now,
 		StartedAt:   &now,
 		CompletedAt: &later,
+		Summary:     "All tests pass.",
 	}
 
 	summary := task.ToSummary()
@@ -79,6 +80,9 @@ func TestTaskToSummary(t *testing.T) {
 	if summary.Duration != "5m0s" {
 		t.Errorf("Expected Duration 5m0s, got %s", summary.Duration)
 	}
+	if summary.Summary != task.Summary {
+		t.Errorf("Expected Summary %q, got %q", task.Summary, summary.Summary)
+	}
 }
 
 func TestTaskToSummaryTruncatesLongPrompt(t *testing.T) {